@@ -0,0 +1,367 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"fullerite/metric"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The formats of the Apache status page, auto is the ?auto key/value
+// output and html the page with the ExtendedStatus workers table. json has
+// the keys of the ?auto output and the workers under "Workers", each worker
+// keyed by the columns of the html table (Srv, PID, Acc, M, CPU, ...).
+const (
+	apacheStatusAuto = "auto"
+	apacheStatusHTML = "html"
+	apacheStatusJSON = "json"
+)
+
+var (
+	// The default query path of each status format
+	apacheStatusQueryPaths = map[string]string{
+		apacheStatusAuto: "server-status?auto",
+		apacheStatusHTML: "server-status",
+		apacheStatusJSON: "server-status?auto&json",
+	}
+
+	// The keys of the extended status on top of knownApacheMetrics, the
+	// Cache ones are the mod_ssl session cache
+	extendedApacheMetrics = map[string]string{
+		"Total kBytes":           metric.CumulativeCounter,
+		"Total Duration":         metric.CumulativeCounter,
+		"DurationPerReq":         metric.Gauge,
+		"ServerUptimeSeconds":    metric.Gauge,
+		"CacheSharedMemory":      metric.Gauge,
+		"CacheCurrentEntries":    metric.Gauge,
+		"CacheIndexUsage":        metric.Gauge,
+		"CacheUsage":             metric.Gauge,
+		"CacheStoreCount":        metric.CumulativeCounter,
+		"CacheReplaceCount":      metric.CumulativeCounter,
+		"CacheExpireCount":       metric.CumulativeCounter,
+		"CacheDiscardCount":      metric.CumulativeCounter,
+		"CacheRetrieveHitCount":  metric.CumulativeCounter,
+		"CacheRetrieveMissCount": metric.CumulativeCounter,
+		"CacheRemoveHitCount":    metric.CumulativeCounter,
+		"CacheRemoveMissCount":   metric.CumulativeCounter,
+	}
+
+	// The worker states of the scoreboard, open slots without a process
+	// ('.') are not workers
+	apacheWorkerStates = map[string]string{
+		"_": "waiting",
+		"S": "starting",
+		"R": "reading",
+		"W": "writing",
+		"K": "keepalive",
+		"D": "dns",
+		"C": "closing",
+		"L": "logging",
+		"G": "finishing",
+		"I": "cleanup",
+	}
+
+	// The lines of the html status page and the ?auto keys they hold, the
+	// sizes are converted to bytes or to kB for Total kBytes
+	apacheHTMLLines = []struct {
+		pattern *regexp.Regexp
+		keys    []string
+	}{
+		{regexp.MustCompile(`Total accesses: (\d+)`), []string{"Total Accesses"}},
+		{regexp.MustCompile(`Total Traffic: ([\d.]+ [kMG]?B)`), []string{"Total kBytes"}},
+		{regexp.MustCompile(`Total Duration: (\d+)`), []string{"Total Duration"}},
+		{regexp.MustCompile(`([\d.]+)% CPU load`), []string{"CPULoad"}},
+		{regexp.MustCompile(`([\d.]+) requests/sec`), []string{"ReqPerSec"}},
+		{regexp.MustCompile(`([\d.]+ [kMG]?B)/second`), []string{"BytesPerSec"}},
+		{regexp.MustCompile(`([\d.]+ [kMG]?B)/request`), []string{"BytesPerReq"}},
+		{regexp.MustCompile(`([\d.]+) ms/request`), []string{"DurationPerReq"}},
+		{regexp.MustCompile(`(\d+) requests currently being processed, (\d+) idle workers`), []string{"BusyWorkers", "IdleWorkers"}},
+		{regexp.MustCompile(`shared memory: (\d+) bytes, current entries: (\d+)`), []string{"CacheSharedMemory", "CacheCurrentEntries"}},
+		{regexp.MustCompile(`index usage: (\d+)%, cache usage: (\d+)%`), []string{"CacheIndexUsage", "CacheUsage"}},
+		{regexp.MustCompile(`total entries stored since starting: (\d+)`), []string{"CacheStoreCount"}},
+		{regexp.MustCompile(`total entries replaced since starting: (\d+)`), []string{"CacheReplaceCount"}},
+		{regexp.MustCompile(`total entries expired since starting: (\d+)`), []string{"CacheExpireCount"}},
+		{regexp.MustCompile(`entries scrolled out of the cache: (\d+)`), []string{"CacheDiscardCount"}},
+		{regexp.MustCompile(`total retrieves since starting: (\d+) hit, (\d+) miss`), []string{"CacheRetrieveHitCount", "CacheRetrieveMissCount"}},
+		{regexp.MustCompile(`total removes since starting: (\d+) hit, (\d+) miss`), []string{"CacheRemoveHitCount", "CacheRemoveMissCount"}},
+	}
+
+	apacheUptimeRegexp    = regexp.MustCompile(`(\d+) (day|hour|minute|second)`)
+	apacheHTMLBreakRegexp = regexp.MustCompile(`(?i)<br\s*/?>|</(dt|dd|h[1-6]|p|tr)>`)
+	apacheHTMLTagRegexp   = regexp.MustCompile(`<[^>]*>`)
+	apacheHTMLPreRegexp   = regexp.MustCompile(`(?is)<pre>(.*?)</pre>`)
+	apacheHTMLTableRegexp = regexp.MustCompile(`(?is)<table[^>]*>(.*?)</table>`)
+	apacheHTMLRowRegexp   = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	apacheHTMLCellRegexp  = regexp.MustCompile(`(?is)<t([hd])[^>]*>(.*?)</t[hd]>`)
+	apacheByteUnits       = map[string]float64{"B": 1, "kB": 1024, "MB": 1024 * 1024, "GB": 1024 * 1024 * 1024}
+	apacheUptimeUnits     = map[string]float64{"day": 86400, "hour": 3600, "minute": 60, "second": 1}
+)
+
+// apacheStatus is the extended status of an Apache server: the values are
+// keyed like the ?auto output and the workers like the html table columns
+type apacheStatus struct {
+	values  map[string]string
+	workers []map[string]string
+}
+
+// extractExtendedApacheMetrics returns the metrics of an html or json
+// extended status page
+func extractExtendedApacheMetrics(data []byte, format string) ([]metric.Metric, error) {
+	var status apacheStatus
+	var err error
+	if format == apacheStatusJSON {
+		status, err = parseApacheJSONStatus(data)
+	} else {
+		status = parseApacheHTMLStatus(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status.metrics(), nil
+}
+
+// parseApacheJSONStatus reads the ?auto keys and the "Workers" list of the
+// json status, numbers are kept as their text
+func parseApacheJSONStatus(data []byte) (apacheStatus, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return apacheStatus{}, err
+	}
+
+	status := apacheStatus{values: map[string]string{}}
+	for key, value := range raw {
+		if key != "Workers" {
+			status.values[key] = fmt.Sprint(value)
+			continue
+		}
+		workers, _ := value.([]interface{})
+		for _, w := range workers {
+			columns, ok := w.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			worker := map[string]string{}
+			for column, v := range columns {
+				worker[column] = fmt.Sprint(v)
+			}
+			status.workers = append(status.workers, worker)
+		}
+	}
+	return status, nil
+}
+
+// parseApacheHTMLStatus reads the summary lines, the scoreboard, the
+// workers table and the SSL session cache of the html status page
+func parseApacheHTMLStatus(data []byte) apacheStatus {
+	page := string(data)
+	status := apacheStatus{values: map[string]string{}}
+
+	text := html.UnescapeString(apacheHTMLTagRegexp.ReplaceAllString(apacheHTMLBreakRegexp.ReplaceAllString(page, "\n"), ""))
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "Server uptime:") {
+			uptime := 0.0
+			for _, match := range apacheUptimeRegexp.FindAllStringSubmatch(line, -1) {
+				n, _ := strconv.ParseFloat(match[1], 64)
+				uptime += n * apacheUptimeUnits[match[2]]
+			}
+			status.values["ServerUptimeSeconds"] = strconv.FormatFloat(uptime, 'f', -1, 64)
+		}
+		for _, l := range apacheHTMLLines {
+			match := l.pattern.FindStringSubmatch(line)
+			for i := 1; i < len(match); i++ {
+				status.values[l.keys[i-1]] = apacheHTMLValue(l.keys[i-1], match[i])
+			}
+		}
+	}
+
+	if match := apacheHTMLPreRegexp.FindStringSubmatch(page); match != nil {
+		status.values["Scoreboard"] = strings.Join(strings.Fields(match[1]), "")
+	}
+
+	for _, table := range apacheHTMLTableRegexp.FindAllStringSubmatch(page, -1) {
+		var columns []string
+		for _, row := range apacheHTMLRowRegexp.FindAllStringSubmatch(table[1], -1) {
+			var cells []string
+			header := false
+			for _, cell := range apacheHTMLCellRegexp.FindAllStringSubmatch(row[1], -1) {
+				header = cell[1] == "h"
+				cells = append(cells, strings.TrimSpace(html.UnescapeString(apacheHTMLTagRegexp.ReplaceAllString(cell[2], ""))))
+			}
+			if header {
+				columns = cells
+				continue
+			}
+			if len(columns) == 0 || columns[0] != "Srv" || len(cells) != len(columns) {
+				continue
+			}
+			worker := map[string]string{}
+			for i, column := range columns {
+				worker[column] = cells[i]
+			}
+			status.workers = append(status.workers, worker)
+		}
+	}
+	return status
+}
+
+// apacheHTMLValue converts the sizes like "1.2 kB" of the html page to
+// bytes, or to kB for Total kBytes
+func apacheHTMLValue(key string, value string) string {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return value
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return value
+	}
+	n *= apacheByteUnits[fields[1]]
+	if key == "Total kBytes" {
+		n /= 1024
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// metrics returns the metrics of the ?auto output and of the extended
+// status. Each worker gets its CPU time, bytes per request and request
+// duration with the worker, state and vhost dimensions, and its accesses,
+// bytes and total duration with the worker dimension only. The busy workers
+// are counted by vhost and state and all of them by protocol, like h2.
+func (status apacheStatus) metrics() []metric.Metric {
+	results := []metric.Metric{}
+
+	keys := make([]string, 0, len(status.values))
+	for key := range status.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := strings.TrimSuffix(status.values[key], "%")
+		switch {
+		case key == "Scoreboard":
+			results = append(results, extractScoreBoardMetrics(key, value)...)
+		case key == "IdleWorkers":
+			continue
+		case extendedApacheMetrics[key] != "":
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				m := metric.WithValue(strings.Replace(key, " ", "", -1), v)
+				m.MetricType = extendedApacheMetrics[key]
+				results = append(results, m)
+			}
+		default:
+			if m, err := buildApacheMetric(key, value); err == nil {
+				results = append(results, m)
+			}
+		}
+	}
+
+	type vhostKey struct{ vhost, state string }
+	vhostWorkers := map[vhostKey]float64{}
+	vhostDurations := map[string][]float64{}
+	protocolWorkers := map[string]float64{}
+	// the counters of the slots are summed by worker, the state and the
+	// vhost changing with each request would break their series
+	workerCounters := map[[2]string]float64{}
+	for _, worker := range status.workers {
+		state, ok := apacheWorkerStates[worker["M"]]
+		if !ok {
+			continue
+		}
+		dimensions := map[string]string{"worker": worker["Srv"], "state": state}
+		if vhost := worker["VHost"]; vhost != "" {
+			dimensions["vhost"] = vhost
+		}
+		for _, m := range apacheWorkerMetrics(worker, dimensions) {
+			if m.MetricType == metric.CumulativeCounter {
+				workerCounters[[2]string{m.Name, worker["Srv"]}] += m.Value
+			} else {
+				results = append(results, m)
+			}
+		}
+
+		if protocol := worker["Protocol"]; protocol != "" {
+			protocolWorkers[protocol]++
+		}
+		if state == "waiting" || worker["VHost"] == "" {
+			continue
+		}
+		vhostWorkers[vhostKey{worker["VHost"], state}]++
+		if req, err := strconv.ParseFloat(worker["Req"], 64); err == nil {
+			vhostDurations[worker["VHost"]] = append(vhostDurations[worker["VHost"]], req)
+		}
+	}
+
+	for key, value := range workerCounters {
+		m := metric.WithValue(key[0], value)
+		m.MetricType = metric.CumulativeCounter
+		m.AddDimension("worker", key[1])
+		results = append(results, m)
+	}
+	for k, count := range vhostWorkers {
+		m := metric.WithValue("VHostWorkers", count)
+		m.AddDimensions(map[string]string{"vhost": k.vhost, "state": k.state})
+		results = append(results, m)
+	}
+	for vhost, durations := range vhostDurations {
+		total := 0.0
+		for _, d := range durations {
+			total += d
+		}
+		m := metric.WithValue("VHostRequestDuration", total/float64(len(durations)))
+		m.AddDimension("vhost", vhost)
+		results = append(results, m)
+	}
+	for protocol, count := range protocolWorkers {
+		m := metric.WithValue("ProtocolWorkers", count)
+		m.AddDimension("protocol", protocol)
+		results = append(results, m)
+	}
+	return results
+}
+
+// apacheWorkerMetrics returns the metrics of a row of the workers table:
+// the CPU seconds, the accesses and the MB served by the slot, the duration
+// in milliseconds of the last request (Req) and of all of them (Dur)
+func apacheWorkerMetrics(worker map[string]string, dimensions map[string]string) []metric.Metric {
+	results := []metric.Metric{}
+	add := func(name string, value float64, metricType string) {
+		m := metric.WithValue(name, value)
+		m.MetricType = metricType
+		m.AddDimensions(dimensions)
+		results = append(results, m)
+	}
+	parse := func(column string) (float64, bool) {
+		v, err := strconv.ParseFloat(worker[column], 64)
+		return v, err == nil
+	}
+
+	if cpu, ok := parse("CPU"); ok {
+		add("WorkerCPU", cpu, metric.Gauge)
+	}
+	if req, ok := parse("Req"); ok {
+		add("WorkerRequestDuration", req, metric.Gauge)
+	}
+	if dur, ok := parse("Dur"); ok {
+		add("WorkerTotalDuration", dur, metric.CumulativeCounter)
+	}
+
+	// Acc is the accesses of the connection, of the child and of the slot
+	accesses := strings.Split(worker["Acc"], "/")
+	slotAccesses, err := strconv.ParseFloat(accesses[len(accesses)-1], 64)
+	if err != nil {
+		return results
+	}
+	add("WorkerAccesses", slotAccesses, metric.CumulativeCounter)
+	if slot, ok := parse("Slot"); ok {
+		bytes := slot * apacheByteUnits["MB"]
+		add("WorkerBytes", bytes, metric.CumulativeCounter)
+		if slotAccesses > 0 {
+			add("WorkerBytesPerReq", bytes/slotAccesses, metric.Gauge)
+		}
+	}
+	return results
+}
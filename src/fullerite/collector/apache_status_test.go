@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"fullerite/metric"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const apacheHTMLStatus = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html><head>
+<title>Apache Status</title>
+</head><body>
+<h1>Apache Server Status for localhost (via 127.0.0.1)</h1>
+
+<dl><dt>Server Version: Apache/2.4.41 (Ubuntu) OpenSSL/1.1.1f</dt>
+<dt>Server MPM: event</dt>
+<dt>Server Built: 2020-08-12T19:46:17
+</dt></dl><hr /><dl>
+<dt>Current Time: Monday, 19-Oct-2026 10:00:00 UTC</dt>
+<dt>Restart Time: Monday, 19-Oct-2026 07:58:50 UTC</dt>
+<dt>Parent Server Config. Generation: 1</dt>
+<dt>Parent Server MPM Generation: 0</dt>
+<dt>Server uptime:  2 hours 1 minute 10 seconds</dt>
+<dt>Server load: 0.00 0.01 0.05</dt>
+<dt>Total accesses: 1200 - Total Traffic: 2.5 MB - Total Duration: 3456</dt>
+<dt>CPU Usage: u.52 s.36 cu0 cs0 - .0121% CPU load</dt>
+<dt>.165 requests/sec - 361 B/second - 2.1 kB/request - 2.88 ms/request</dt>
+<dt>2 requests currently being processed, 1 idle workers</dt>
+</dl><table rules="all" cellpadding="1%">
+<tr><th rowspan="2">Slot</th><th rowspan="2">PID</th><th rowspan="2">Stopping</th></tr>
+<tr><td>0</td><td>1234</td><td>no</td></tr>
+</table>
+<pre>_WK.
+....</pre>
+<p>Scoreboard Key:<br />
+"<b><code>_</code></b>" Waiting for Connection,
+"<b><code>.</code></b>" Open slot with no current process<br />
+</p>
+<table border="0"><tr><th>Srv</th><th>PID</th><th>Acc</th><th>M</th><th>CPU
+</th><th>SS</th><th>Req</th><th>Dur</th><th>Conn</th><th>Child</th><th>Slot</th><th>Client</th><th>Protocol</th><th>VHost</th><th>Request</th></tr>
+
+<tr><td><b>0-0</b></td><td>1234</td><td>0/10/100</td><td>_
+</td><td>0.20</td><td>3</td><td>4</td><td>400</td><td>0.0</td><td>0.10</td><td>1.00
+</td><td>10.0.0.1</td><td>http/1.1</td><td nowrap>www.example.com:443</td><td nowrap>GET / HTTP/1.1</td></tr>
+
+<tr><td><b>0-0</b></td><td>1234</td><td>1/20/200</td><td><b>W</b>
+</td><td>0.30</td><td>0</td><td>10</td><td>2000</td><td>0.5</td><td>0.20</td><td>0.50
+</td><td>10.0.0.2</td><td>h2</td><td nowrap>www.example.com:443</td><td nowrap>GET /status HTTP/2.0</td></tr>
+
+<tr><td><b>1-0</b></td><td>1235</td><td>0/5/50</td><td><b>K</b>
+</td><td>0.10</td><td>1</td><td>20</td><td>1000</td><td>0.0</td><td>0.05</td><td>0.25
+</td><td>10.0.0.3</td><td>h2</td><td nowrap>api.example.com:443</td><td nowrap>GET /api HTTP/2.0</td></tr>
+
+<tr><td><b>1-0</b></td><td>-</td><td>0/0/0</td><td>.
+</td><td>0.00</td><td>0</td><td>0</td><td>0</td><td>0.0</td><td>0.00</td><td>0.00
+</td><td>::1</td><td>http/1.1</td><td nowrap></td><td nowrap></td></tr>
+</table>
+<hr /> <table>
+<tr><th>Srv</th><td>Child Server number - generation</td></tr>
+<tr><th>PID</th><td>OS process ID</td></tr>
+</table>
+<hr />
+<h2>SSL/TLS Session Cache Status:</h2>
+cache type: <b>SHMCB</b>, shared memory: <b>512000</b> bytes, current entries: <b>7</b><br>subcaches: <b>32</b>, indexes per subcache: <b>88</b><br>time left on oldest entries' objects: avg: <b>100</b> seconds, (range: 50...150)<br>index usage: <b>1%</b>, cache usage: <b>2%</b><br>total entries stored since starting: <b>30</b><br>total entries replaced since starting: <b>0</b><br>total entries expired since starting: <b>23</b><br>total (pre-expiry) entries scrolled out of the cache: <b>0</b><br>total retrieves since starting: <b>12</b> hit, <b>4</b> miss<br>total removes since starting: <b>0</b> hit, <b>0</b> miss<br></body></html>
+`
+
+const apacheJSONStatus = `{
+	"Total Accesses": 1200,
+	"Total kBytes": 2560,
+	"CPULoad": 0.0121,
+	"ReqPerSec": 0.165,
+	"BusyWorkers": 2,
+	"IdleWorkers": 1,
+	"CacheUsage": "2%",
+	"CacheRetrieveHitCount": 12,
+	"Scoreboard": "_WK.",
+	"Workers": [
+		{"Srv": "0-1", "PID": 1234, "Acc": "1/20/200", "M": "W", "CPU": 0.3, "Req": 10, "Dur": 2000, "Slot": 0.5, "Protocol": "h2", "VHost": "www.example.com:443"},
+		{"Srv": "1-0", "PID": "-", "Acc": "0/0/0", "M": ".", "CPU": 0, "Req": 0, "Dur": 0, "Slot": 0, "Protocol": "", "VHost": ""}
+	]
+}`
+
+func apacheMetricsByName(metrics []metric.Metric) map[string][]metric.Metric {
+	byName := map[string][]metric.Metric{}
+	for _, m := range metrics {
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+	return byName
+}
+
+func TestParseApacheHTMLStatus(t *testing.T) {
+	status := parseApacheHTMLStatus([]byte(apacheHTMLStatus))
+
+	assert.Equal(t, map[string]string{
+		"ServerUptimeSeconds":    "7270",
+		"Total Accesses":         "1200",
+		"Total kBytes":           "2560",
+		"Total Duration":         "3456",
+		"CPULoad":                ".0121",
+		"ReqPerSec":              ".165",
+		"BytesPerSec":            "361",
+		"BytesPerReq":            "2150.4",
+		"DurationPerReq":         "2.88",
+		"BusyWorkers":            "2",
+		"IdleWorkers":            "1",
+		"Scoreboard":             "_WK.....",
+		"CacheSharedMemory":      "512000",
+		"CacheCurrentEntries":    "7",
+		"CacheIndexUsage":        "1",
+		"CacheUsage":             "2",
+		"CacheStoreCount":        "30",
+		"CacheReplaceCount":      "0",
+		"CacheExpireCount":       "23",
+		"CacheDiscardCount":      "0",
+		"CacheRetrieveHitCount":  "12",
+		"CacheRetrieveMissCount": "4",
+		"CacheRemoveHitCount":    "0",
+		"CacheRemoveMissCount":   "0",
+	}, status.values)
+
+	assert.Equal(t, 4, len(status.workers))
+	assert.Equal(t, "0-0", status.workers[1]["Srv"])
+	assert.Equal(t, "W", status.workers[1]["M"])
+	assert.Equal(t, "0.30", status.workers[1]["CPU"])
+	assert.Equal(t, "h2", status.workers[1]["Protocol"])
+	assert.Equal(t, "www.example.com:443", status.workers[1]["VHost"])
+}
+
+func TestExtractExtendedApacheMetricsHTML(t *testing.T) {
+	metrics, err := extractExtendedApacheMetrics([]byte(apacheHTMLStatus), apacheStatusHTML)
+	assert.Nil(t, err)
+	byName := apacheMetricsByName(metrics)
+
+	assert.Equal(t, 1200.0, byName["TotalAccesses"][0].Value)
+	assert.Equal(t, metric.CumulativeCounter, byName["TotalAccesses"][0].MetricType)
+	assert.Equal(t, 2560.0, byName["TotalkBytes"][0].Value)
+	assert.Equal(t, 2.88, byName["DurationPerReq"][0].Value)
+	assert.Equal(t, 2.0, byName["CacheUsage"][0].Value)
+	assert.Equal(t, metric.CumulativeCounter, byName["CacheRetrieveHitCount"][0].MetricType)
+	assert.Equal(t, 1.0, byName["WritingWorkers"][0].Value)
+	assert.Equal(t, 1.0, byName["IdleWorkers"][0].Value)
+
+	// the open slot is not a worker
+	assert.Equal(t, 3, len(byName["WorkerCPU"]))
+	// the counters of a slot keep their series when its state changes
+	for _, name := range []string{"WorkerAccesses", "WorkerBytes", "WorkerTotalDuration"} {
+		for _, m := range byName[name] {
+			assert.Equal(t, metric.CumulativeCounter, m.MetricType)
+			assert.Equal(t, 1, len(m.Dimensions), name)
+			assert.Contains(t, m.Dimensions, "worker", name)
+		}
+	}
+	accesses := map[string]float64{}
+	for _, m := range byName["WorkerAccesses"] {
+		accesses[m.Dimensions["worker"]] = m.Value
+	}
+	assert.Equal(t, map[string]float64{"0-0": 300, "1-0": 50}, accesses)
+	for _, m := range byName["WorkerBytesPerReq"] {
+		if m.Dimensions["state"] == "writing" {
+			assert.Equal(t, 0.5*1024*1024/200, m.Value)
+			assert.Equal(t, "0-0", m.Dimensions["worker"])
+			assert.Equal(t, "www.example.com:443", m.Dimensions["vhost"])
+		}
+	}
+
+	vhostWorkers := map[string]float64{}
+	for _, m := range byName["VHostWorkers"] {
+		vhostWorkers[m.Dimensions["vhost"]+" "+m.Dimensions["state"]] = m.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"www.example.com:443 writing":   1,
+		"api.example.com:443 keepalive": 1,
+	}, vhostWorkers)
+	assert.Equal(t, 2, len(byName["VHostRequestDuration"]))
+
+	protocols := map[string]float64{}
+	for _, m := range byName["ProtocolWorkers"] {
+		protocols[m.Dimensions["protocol"]] = m.Value
+	}
+	assert.Equal(t, map[string]float64{"h2": 2, "http/1.1": 1}, protocols)
+}
+
+func TestExtractExtendedApacheMetricsJSON(t *testing.T) {
+	metrics, err := extractExtendedApacheMetrics([]byte(apacheJSONStatus), apacheStatusJSON)
+	assert.Nil(t, err)
+	byName := apacheMetricsByName(metrics)
+
+	assert.Equal(t, 1200.0, byName["TotalAccesses"][0].Value)
+	assert.Equal(t, 2560.0, byName["TotalkBytes"][0].Value)
+	assert.Equal(t, 2.0, byName["CacheUsage"][0].Value)
+	assert.Equal(t, 1.0, byName["IdleWorkers"][0].Value)
+	assert.Equal(t, 1, len(byName["WorkerCPU"]))
+	assert.Equal(t, 0.3, byName["WorkerCPU"][0].Value)
+	assert.Equal(t, "writing", byName["WorkerCPU"][0].Dimensions["state"])
+	assert.Equal(t, 2000.0, byName["WorkerTotalDuration"][0].Value)
+	assert.Equal(t, 10.0, byName["VHostRequestDuration"][0].Value)
+
+	_, err = extractExtendedApacheMetrics([]byte("not json"), apacheStatusJSON)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/util"
+	"net"
+	"strconv"
+	"strings"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// cacheInstance is a Redis or memcached server, configured or discovered
+// in the nerve config
+type cacheInstance struct {
+	network    string
+	addr       string
+	dimensions map[string]string
+}
+
+// parseCacheInstances reads the instances option of the Redis and
+// Memcached collectors, a list of "host[:port]", "unix:///path" or socket
+// paths. A single instance can be given as a plain string.
+func parseCacheInstances(value interface{}, defaultPort int) []cacheInstance {
+	addresses := []string{}
+	if address, ok := value.(string); ok && !strings.HasPrefix(strings.TrimSpace(address), "[") {
+		addresses = append(addresses, address)
+	} else {
+		addresses = config.GetAsSlice(value)
+	}
+
+	instances := []cacheInstance{}
+	for _, address := range addresses {
+		if address = strings.TrimSpace(address); address != "" {
+			instances = append(instances, parseCacheInstance(address, defaultPort))
+		}
+	}
+	return instances
+}
+
+func parseCacheInstance(address string, defaultPort int) cacheInstance {
+	instance := cacheInstance{network: "tcp", addr: address}
+	if strings.HasPrefix(address, "unix://") {
+		instance.network, instance.addr = "unix", strings.TrimPrefix(address, "unix://")
+	} else if strings.HasPrefix(address, "/") {
+		instance.network = "unix"
+	} else {
+		// IPv6 addresses without a port may be bracketed
+		if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+			address = strings.Trim(address, "[]")
+		}
+		instance.addr = util.WithDefaultPort(address, defaultPort)
+	}
+	instance.dimensions = map[string]string{"instance": instance.addr}
+	return instance
+}
+
+// discoverCacheInstances returns the instances of the nerve services named,
+// with the dimensions of the nerve collectors
+func discoverCacheInstances(configPaths []string, services []string, log *l.Entry) []cacheInstance {
+	discovered, err := util.ReadNerveConfigs(configPaths, true)
+	if err != nil {
+		log.Warn("Failed to read the nerve configs: ", err)
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range services {
+		wanted[name] = true
+	}
+
+	instances := []cacheInstance{}
+	for _, service := range discovered {
+		if !wanted[service.Name] {
+			continue
+		}
+		addr := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+		instances = append(instances, cacheInstance{
+			network: "tcp",
+			addr:    addr,
+			dimensions: map[string]string{
+				"instance":          addr,
+				"service_name":      service.Name,
+				"service_namespace": service.Namespace,
+				"port":              strconv.Itoa(service.Port),
+			},
+		})
+	}
+	return instances
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"fullerite/util"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCacheInstances(t *testing.T) {
+	instances := parseCacheInstances([]interface{}{
+		"10.0.0.1", "10.0.0.2:6380", "[::1]", "unix:///run/redis.sock", "/run/memcached.sock", " ",
+	}, 6379)
+
+	assert.Equal(t, []cacheInstance{
+		{network: "tcp", addr: "10.0.0.1:6379", dimensions: map[string]string{"instance": "10.0.0.1:6379"}},
+		{network: "tcp", addr: "10.0.0.2:6380", dimensions: map[string]string{"instance": "10.0.0.2:6380"}},
+		{network: "tcp", addr: "[::1]:6379", dimensions: map[string]string{"instance": "[::1]:6379"}},
+		{network: "unix", addr: "/run/redis.sock", dimensions: map[string]string{"instance": "/run/redis.sock"}},
+		{network: "unix", addr: "/run/memcached.sock", dimensions: map[string]string{"instance": "/run/memcached.sock"}},
+	}, instances)
+}
+
+func TestDiscoverCacheInstances(t *testing.T) {
+	nerveConfig := util.CreateMinimalNerveConfig(map[string]util.EndPoint{
+		"redis_main.main":     {Host: "10.0.0.1", Port: "6379"},
+		"memcached_main.main": {Host: "10.0.0.1", Port: "11211"},
+		"web.main":            {Host: "10.0.0.1", Port: "8080"},
+	})
+	tmpFile, err := ioutil.TempFile("", "fullerite_testing")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	marshalled, err := json.Marshal(nerveConfig)
+	assert.Nil(t, err)
+	_, err = tmpFile.Write(marshalled)
+	assert.Nil(t, err)
+	tmpFile.Close()
+
+	log := l.WithField("testing", "cache_instance")
+	instances := discoverCacheInstances([]string{tmpFile.Name()}, []string{"redis_main"}, log)
+	assert.Equal(t, []cacheInstance{{
+		network: "tcp",
+		addr:    "10.0.0.1:6379",
+		dimensions: map[string]string{
+			"instance":          "10.0.0.1:6379",
+			"service_name":      "redis_main",
+			"service_namespace": "main",
+			"port":              "6379",
+		},
+	}}, instances)
+
+	assert.Equal(t, []cacheInstance{}, discoverCacheInstances([]string{"/does/not/exist"}, []string{"redis_main"}, log))
+}
@@ -0,0 +1,368 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values of the "cardinality_limit_action" collector config option
+const (
+	// CardinalityDrop drops new series once a limit is reached
+	CardinalityDrop = "drop"
+	// CardinalityStrip removes the dimension with the most unique values
+	// from new series once a limit is reached. The stripped series have
+	// their own room, as large as the limits.
+	CardinalityStrip = "strip"
+
+	// DefaultCardinalityWindow is the number of seconds a series is
+	// remembered for after it was last seen
+	DefaultCardinalityWindow = 600
+)
+
+// cardinalityLimiter tracks the unique series sent by a collector over a
+// sliding window, per canonical collector name and per metric name.
+type cardinalityLimiter struct {
+	maxSeries          int
+	maxSeriesPerMetric int
+	window             time.Duration
+	action             string
+
+	mu        sync.Mutex
+	lastPrune time.Time
+	// keyed by canonical collector name. Diamond sends metrics of many
+	// collectors through the same channel
+	collectors map[string]*collectorSeries
+}
+
+type collectorSeries struct {
+	series map[string]time.Time
+	// the stripped series admitted beyond the limits
+	aggregates map[string]time.Time
+	metrics    map[string]*metricSeries
+	limited    uint64
+	// count of limited series since the last call to limitedMetrics
+	unreported uint64
+}
+
+type metricSeries struct {
+	series     map[string]time.Time
+	aggregates map[string]time.Time
+	// dimension name -> dimension value -> last seen
+	dimensions map[string]map[string]time.Time
+}
+
+func newCardinalityLimiter(maxSeries, maxSeriesPerMetric int, window time.Duration, action string) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		maxSeries:          maxSeries,
+		maxSeriesPerMetric: maxSeriesPerMetric,
+		window:             window,
+		action:             action,
+		collectors:         make(map[string]*collectorSeries),
+	}
+}
+
+// configureCardinalityLimiter reads the cardinality limiter options out of
+// the collector config. The limiter is only enabled if one of the limits is set:
+//
+//	"max_series": 10000,
+//	"max_series_per_metric": 1000,
+//	"cardinality_window": 600,
+//	"cardinality_limit_action": "drop" | "strip"
+func (col *baseCollector) configureCardinalityLimiter(configMap map[string]interface{}) {
+	maxSeries := 0
+	if asInterface, exists := configMap["max_series"]; exists {
+		maxSeries = config.GetAsInt(asInterface, 0)
+	}
+
+	maxSeriesPerMetric := 0
+	if asInterface, exists := configMap["max_series_per_metric"]; exists {
+		maxSeriesPerMetric = config.GetAsInt(asInterface, 0)
+	}
+
+	if maxSeries <= 0 && maxSeriesPerMetric <= 0 {
+		return
+	}
+
+	window := DefaultCardinalityWindow
+	if asInterface, exists := configMap["cardinality_window"]; exists {
+		window = config.GetAsInt(asInterface, DefaultCardinalityWindow)
+	}
+
+	action := CardinalityDrop
+	if asInterface, exists := configMap["cardinality_limit_action"]; exists {
+		switch asInterface {
+		case CardinalityDrop, CardinalityStrip:
+			action = asInterface.(string)
+		default:
+			col.log.Warn("Unknown cardinality limit action ", asInterface, ", using ", CardinalityDrop)
+		}
+	}
+
+	col.cardinalityLimiter = newCardinalityLimiter(
+		maxSeries, maxSeriesPerMetric, time.Duration(window)*time.Second, action)
+}
+
+// LimitCardinality returns false if the metric would create a new series
+// beyond the configured limits and has to be dropped. In strip mode the
+// offending dimension is removed from the metric instead.
+func (col *baseCollector) LimitCardinality(canonicalName string, m *metric.Metric) bool {
+	if col.cardinalityLimiter == nil {
+		return true
+	}
+	return col.cardinalityLimiter.allow(canonicalName, m, time.Now())
+}
+
+// CardinalityLimitedMetrics returns a fullerite.cardinality_limited counter
+// for each canonical collector name which had series limited since the last call
+func (col *baseCollector) CardinalityLimitedMetrics() []metric.Metric {
+	if col.cardinalityLimiter == nil {
+		return nil
+	}
+	return col.cardinalityLimiter.limitedMetrics()
+}
+
+// CardinalityReport returns the tracked series count and top n offending
+// metric names and dimensions per canonical collector name
+func (col *baseCollector) CardinalityReport(n int) map[string]metric.CardinalityReport {
+	if col.cardinalityLimiter == nil {
+		return nil
+	}
+	return col.cardinalityLimiter.report(n)
+}
+
+func (c *cardinalityLimiter) allow(canonicalName string, m *metric.Metric, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) >= c.window/10 {
+		c.prune(now)
+		c.lastPrune = now
+	}
+
+	cs, exists := c.collectors[canonicalName]
+	if !exists {
+		cs = &collectorSeries{
+			series:     make(map[string]time.Time),
+			aggregates: make(map[string]time.Time),
+			metrics:    make(map[string]*metricSeries),
+		}
+		c.collectors[canonicalName] = cs
+	}
+	ms, exists := cs.metrics[m.Name]
+	if !exists {
+		ms = &metricSeries{
+			series:     make(map[string]time.Time),
+			aggregates: make(map[string]time.Time),
+			dimensions: make(map[string]map[string]time.Time),
+		}
+		cs.metrics[m.Name] = ms
+	}
+
+	key := seriesKey(m)
+	if _, exists := cs.series[key]; exists {
+		ms.record(key, m, now)
+		cs.series[key] = now
+		return true
+	}
+	if _, exists := cs.aggregates[key]; exists {
+		cs.aggregates[key] = now
+		ms.aggregates[key] = now
+		return true
+	}
+
+	collectorFull := c.maxSeries > 0 && len(cs.series) >= c.maxSeries
+	metricFull := c.maxSeriesPerMetric > 0 && len(ms.series) >= c.maxSeriesPerMetric
+	if !collectorFull && !metricFull {
+		ms.record(key, m, now)
+		cs.series[key] = now
+		return true
+	}
+
+	cs.limited++
+	cs.unreported++
+	if c.action != CardinalityStrip {
+		return false
+	}
+
+	dimension := ms.topDimension(m)
+	if dimension == "" {
+		return false
+	}
+	// the dimensions map may be shared with other metrics of the collector
+	dimensions := make(map[string]string, len(m.Dimensions))
+	for k, v := range m.Dimensions {
+		if k != dimension {
+			dimensions[k] = v
+		}
+	}
+	m.Dimensions = dimensions
+
+	key = seriesKey(m)
+	if _, exists := cs.series[key]; exists {
+		ms.record(key, m, now)
+		cs.series[key] = now
+		return true
+	}
+	// the stripped series are admitted beyond the limits, in a room of the
+	// same size, so that stripping more than one high cardinality
+	// dimension cannot grow without bound
+	if _, exists := cs.aggregates[key]; !exists {
+		if (c.maxSeries > 0 && len(cs.aggregates) >= c.maxSeries) ||
+			(c.maxSeriesPerMetric > 0 && len(ms.aggregates) >= c.maxSeriesPerMetric) {
+			return false
+		}
+	}
+	cs.aggregates[key] = now
+	ms.aggregates[key] = now
+	return true
+}
+
+func (c *cardinalityLimiter) prune(now time.Time) {
+	oldest := now.Add(-c.window)
+	for name, cs := range c.collectors {
+		pruneSeries(cs.series, oldest)
+		pruneSeries(cs.aggregates, oldest)
+		for metricName, ms := range cs.metrics {
+			ms.prune(oldest)
+			if len(ms.series) == 0 && len(ms.aggregates) == 0 {
+				delete(cs.metrics, metricName)
+			}
+		}
+		if len(cs.series) == 0 && len(cs.aggregates) == 0 && cs.unreported == 0 {
+			delete(c.collectors, name)
+		}
+	}
+}
+
+func (c *cardinalityLimiter) limitedMetrics() []metric.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := []metric.Metric{}
+	for name, cs := range c.collectors {
+		if cs.unreported == 0 {
+			continue
+		}
+		m := metric.WithValue("fullerite.cardinality_limited", float64(cs.unreported))
+		m.MetricType = metric.Counter
+		m.AddDimension("collector", name)
+		m.AddDimension("action", c.action)
+		metrics = append(metrics, m)
+		cs.unreported = 0
+	}
+	return metrics
+}
+
+func (c *cardinalityLimiter) report(n int) map[string]metric.CardinalityReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reports := make(map[string]metric.CardinalityReport)
+	for name, cs := range c.collectors {
+		topMetrics := []metric.SeriesCount{}
+		topDimensions := []metric.SeriesCount{}
+		for metricName, ms := range cs.metrics {
+			topMetrics = append(topMetrics, metric.SeriesCount{
+				Metric: metricName,
+				Count:  len(ms.series) + len(ms.aggregates),
+			})
+			for dimension, values := range ms.dimensions {
+				topDimensions = append(topDimensions, metric.SeriesCount{
+					Metric:    metricName,
+					Dimension: dimension,
+					Count:     len(values),
+				})
+			}
+		}
+		reports[name] = metric.CardinalityReport{
+			Series:        len(cs.series) + len(cs.aggregates),
+			Limited:       cs.limited,
+			TopMetrics:    topSeriesCounts(topMetrics, n),
+			TopDimensions: topSeriesCounts(topDimensions, n),
+		}
+	}
+	return reports
+}
+
+func (ms *metricSeries) record(key string, m *metric.Metric, now time.Time) {
+	ms.series[key] = now
+	for k, v := range m.Dimensions {
+		values, exists := ms.dimensions[k]
+		if !exists {
+			values = make(map[string]time.Time)
+			ms.dimensions[k] = values
+		}
+		values[v] = now
+	}
+}
+
+func (ms *metricSeries) prune(oldest time.Time) {
+	pruneSeries(ms.series, oldest)
+	pruneSeries(ms.aggregates, oldest)
+	for dimension, values := range ms.dimensions {
+		for value, lastSeen := range values {
+			if lastSeen.Before(oldest) {
+				delete(values, value)
+			}
+		}
+		if len(values) == 0 {
+			delete(ms.dimensions, dimension)
+		}
+	}
+}
+
+func pruneSeries(series map[string]time.Time, oldest time.Time) {
+	for key, lastSeen := range series {
+		if lastSeen.Before(oldest) {
+			delete(series, key)
+		}
+	}
+}
+
+// topDimension returns the dimension of m with the most unique values,
+// counting the values of m not seen yet
+func (ms *metricSeries) topDimension(m *metric.Metric) (dimension string) {
+	max := 0
+	for k, v := range m.Dimensions {
+		count := len(ms.dimensions[k])
+		if _, seen := ms.dimensions[k][v]; !seen {
+			count++
+		}
+		if count > max || (count == max && k < dimension) {
+			dimension = k
+			max = count
+		}
+	}
+	return dimension
+}
+
+func topSeriesCounts(counts []metric.SeriesCount, n int) []metric.SeriesCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		if counts[i].Metric != counts[j].Metric {
+			return counts[i].Metric < counts[j].Metric
+		}
+		return counts[i].Dimension < counts[j].Dimension
+	})
+	if n >= 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// seriesKey identifies a series by its name and sorted dimensions
+func seriesKey(m *metric.Metric) string {
+	dims := make([]string, 0, len(m.Dimensions))
+	for k, v := range m.Dimensions {
+		dims = append(dims, k+"="+v)
+	}
+	sort.Strings(dims)
+	return m.Name + "|" + strings.Join(dims, ",")
+}
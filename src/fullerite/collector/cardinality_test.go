@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"fullerite/metric"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cardinalityTestMetric(name string, dims map[string]string) metric.Metric {
+	m := metric.New(name)
+	m.AddDimensions(dims)
+	return m
+}
+
+func TestCardinalityLimiterDisabledByDefault(t *testing.T) {
+	col := New("Test")
+	col.Configure(map[string]interface{}{})
+
+	for i := 0; i < 100; i++ {
+		m := cardinalityTestMetric("foo", map[string]string{"id": fmt.Sprint(i)})
+		assert.True(t, col.LimitCardinality("Test", &m))
+	}
+	assert.Nil(t, col.CardinalityLimitedMetrics())
+	assert.Nil(t, col.CardinalityReport(10))
+}
+
+func TestCardinalityLimiterConfigure(t *testing.T) {
+	col := New("Test").(*Test)
+	col.Configure(map[string]interface{}{
+		"max_series":               "100",
+		"max_series_per_metric":    10,
+		"cardinality_window":       60,
+		"cardinality_limit_action": "strip",
+	})
+
+	assert.NotNil(t, col.cardinalityLimiter)
+	assert.Equal(t, 100, col.cardinalityLimiter.maxSeries)
+	assert.Equal(t, 10, col.cardinalityLimiter.maxSeriesPerMetric)
+	assert.Equal(t, time.Minute, col.cardinalityLimiter.window)
+	assert.Equal(t, CardinalityStrip, col.cardinalityLimiter.action)
+}
+
+func TestCardinalityLimiterConfigureUnknownAction(t *testing.T) {
+	col := New("Test").(*Test)
+	col.Configure(map[string]interface{}{
+		"max_series":               100,
+		"cardinality_limit_action": "explode",
+	})
+
+	assert.Equal(t, CardinalityDrop, col.cardinalityLimiter.action)
+	assert.Equal(t, DefaultCardinalityWindow*time.Second, col.cardinalityLimiter.window)
+}
+
+func TestCardinalityLimiterDropPerMetric(t *testing.T) {
+	c := newCardinalityLimiter(0, 2, time.Minute, CardinalityDrop)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		m := cardinalityTestMetric("foo", map[string]string{"id": fmt.Sprint(i)})
+		assert.Equal(t, i < 2, c.allow("Test", &m, now), "series %d", i)
+	}
+
+	// known series and other metric names are still accepted
+	m := cardinalityTestMetric("foo", map[string]string{"id": "0"})
+	assert.True(t, c.allow("Test", &m, now))
+	m = cardinalityTestMetric("bar", map[string]string{"id": "42"})
+	assert.True(t, c.allow("Test", &m, now))
+
+	limited := c.limitedMetrics()
+	assert.Equal(t, 1, len(limited))
+	assert.Equal(t, "fullerite.cardinality_limited", limited[0].Name)
+	assert.Equal(t, metric.Counter, limited[0].MetricType)
+	assert.Equal(t, 3.0, limited[0].Value)
+	assert.Equal(t, "Test", limited[0].Dimensions["collector"])
+	assert.Equal(t, CardinalityDrop, limited[0].Dimensions["action"])
+
+	// the counter is reset once reported
+	assert.Equal(t, 0, len(c.limitedMetrics()))
+}
+
+func TestCardinalityLimiterDropPerCollector(t *testing.T) {
+	c := newCardinalityLimiter(3, 0, time.Minute, CardinalityDrop)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		m := cardinalityTestMetric(fmt.Sprintf("foo%d", i), nil)
+		assert.Equal(t, i < 3, c.allow("Test", &m, now), "series %d", i)
+	}
+
+	// series are tracked per canonical collector name
+	m := cardinalityTestMetric("foo4", nil)
+	assert.True(t, c.allow("Other", &m, now))
+}
+
+func TestCardinalityLimiterStrip(t *testing.T) {
+	c := newCardinalityLimiter(0, 2, time.Minute, CardinalityStrip)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		dims := map[string]string{"request_id": fmt.Sprint(i), "status": "200"}
+		m := cardinalityTestMetric("requests", dims)
+		assert.True(t, c.allow("Test", &m, now), "series %d", i)
+		if i < 2 {
+			assert.Equal(t, dims, m.Dimensions)
+		} else {
+			assert.Equal(t, map[string]string{"status": "200"}, m.Dimensions)
+			// the original dimensions are left untouched
+			assert.Equal(t, fmt.Sprint(i), dims["request_id"])
+		}
+	}
+
+	// the stripped series is known afterwards, even when sent as is
+	m := cardinalityTestMetric("requests", map[string]string{"status": "200"})
+	assert.True(t, c.allow("Test", &m, now))
+	assert.Equal(t, map[string]string{"status": "200"}, m.Dimensions)
+
+	report := c.report(10)["Test"]
+	assert.Equal(t, 3, report.Series)
+	assert.Equal(t, uint64(3), report.Limited)
+}
+
+func TestCardinalityLimiterStripEnforcesLimits(t *testing.T) {
+	c := newCardinalityLimiter(0, 2, time.Minute, CardinalityStrip)
+	now := time.Now()
+
+	// stripping one of two high cardinality dimensions still creates new
+	// series, which are admitted until their own room is full
+	for i := 0; i < 5; i++ {
+		dims := map[string]string{"request_id": fmt.Sprint(i), "session": fmt.Sprint(i)}
+		m := cardinalityTestMetric("requests", dims)
+		assert.Equal(t, i < 4, c.allow("Test", &m, now), "series %d", i)
+	}
+	assert.Equal(t, 4, c.report(10)["Test"].Series)
+
+	// the stripped series expire like the others
+	m := cardinalityTestMetric("requests", map[string]string{"request_id": "5", "session": "5"})
+	assert.True(t, c.allow("Test", &m, now.Add(2*time.Minute)))
+	assert.Equal(t, 1, c.report(10)["Test"].Series)
+}
+
+func TestCardinalityLimiterStripOffendingDimension(t *testing.T) {
+	c := newCardinalityLimiter(0, 1, time.Minute, CardinalityStrip)
+	now := time.Now()
+
+	m := cardinalityTestMetric("requests", map[string]string{"host": "a", "id": "1"})
+	assert.True(t, c.allow("Test", &m, now))
+
+	// on the first overflow id is the dimension with a new value
+	m = cardinalityTestMetric("requests", map[string]string{"host": "a", "id": "2"})
+	ms := c.collectors["Test"].metrics["requests"]
+	assert.Equal(t, "id", ms.topDimension(&m))
+}
+
+func TestCardinalityLimiterWindow(t *testing.T) {
+	c := newCardinalityLimiter(0, 1, time.Minute, CardinalityDrop)
+	now := time.Now()
+
+	m := cardinalityTestMetric("foo", map[string]string{"id": "1"})
+	assert.True(t, c.allow("Test", &m, now))
+	m = cardinalityTestMetric("foo", map[string]string{"id": "2"})
+	assert.False(t, c.allow("Test", &m, now.Add(30*time.Second)))
+
+	// the first series expired
+	m = cardinalityTestMetric("foo", map[string]string{"id": "2"})
+	assert.True(t, c.allow("Test", &m, now.Add(2*time.Minute)))
+	m = cardinalityTestMetric("foo", map[string]string{"id": "1"})
+	assert.False(t, c.allow("Test", &m, now.Add(2*time.Minute)))
+}
+
+func TestCardinalityLimiterReport(t *testing.T) {
+	c := newCardinalityLimiter(100, 0, time.Minute, CardinalityDrop)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		m := cardinalityTestMetric("foo", map[string]string{"id": fmt.Sprint(i), "host": "a"})
+		c.allow("Test", &m, now)
+	}
+	m := cardinalityTestMetric("bar", map[string]string{"host": "a"})
+	c.allow("Test", &m, now)
+
+	report := c.report(2)["Test"]
+	assert.Equal(t, 4, report.Series)
+	assert.Equal(t, uint64(0), report.Limited)
+	assert.Equal(t, []metric.SeriesCount{
+		{Metric: "foo", Count: 3},
+		{Metric: "bar", Count: 1},
+	}, report.TopMetrics)
+	assert.Equal(t, []metric.SeriesCount{
+		{Metric: "foo", Dimension: "id", Count: 3},
+		{Metric: "bar", Dimension: "host", Count: 1},
+	}, report.TopDimensions)
+}
@@ -32,6 +32,8 @@ type ChronosStats struct {
 	client          http.Client
 	chronosHost     string
 	extraDimensions map[string]string
+	election        leaderElection
+	options         dropwizard.Options
 }
 
 func init() {
@@ -45,15 +47,18 @@ func newChronosStats(channel chan metric.Metric, initialInterval int, log *l.Ent
 	m.channel = channel
 	m.interval = initialInterval
 	m.name = "ChronosStats"
+	m.asyncScrape = true
 	m.client = http.Client{Timeout: chronosGetTimeout}
 	m.extraDimensions = make(map[string]string)
 
 	return m
 }
 
-// Configure just calls the default configure
+// Configure reads the chronos host and the leader election options
 func (m *ChronosStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
+	m.election.configure(configMap)
+	configureDropwizardOptions(configMap, &m.options)
 
 	c := config.GetAsMap(configMap)
 	if chronosHost, exists := c["chronosHost"]; exists && len(chronosHost) > 0 {
@@ -70,20 +75,36 @@ func (m *ChronosStats) Configure(configMap map[string]interface{}) {
 	}
 }
 
-// Collect compares the leader against this hosts's hostaname and sends metrics if this is the leader
-func (m *ChronosStats) Collect() {
-	// Non-chronos-leaders forward requests to the leader, so only the leader's metrics matter
-	if leader, err := util.IsLeader(m.chronosHost, "leader", m.client, m.log); leader && err == nil {
-		go sendChronosMetrics(m)
-	} else if err != nil {
-		m.log.Error("Error finding leader: ", err)
-	} else {
-		m.log.Debug("Not the leader, not sending metrics")
+// Targets returns the chronos metrics endpoint scraped by the collector
+func (m *ChronosStats) Targets() []string {
+	if leader := m.election.current(); m.election.remote && leader != "" {
+		return []string{getChronosMetricsURL(leader)}
 	}
+	return []string{getChronosMetricsURL(m.chronosHost)}
+}
+
+// Collect finds the leader and sends its metrics if this host is the
+// leader, or if it is the designated host scraping the leader remotely
+func (m *ChronosStats) Collect() {
+	m.scrapeInBackground(func() {
+		leader, scrape, err := m.election.resolve(func() (string, error) {
+			return util.LeaderOf(m.chronosHost, "leader", m.client)
+		}, m.log)
+		if err != nil {
+			m.log.Error("Error finding leader: ", err)
+		} else if !scrape {
+			m.log.Debug("Not the leader, not sending metrics")
+		} else if m.election.remote {
+			sendChronosMetrics(m, leader)
+		} else {
+			// Non-chronos-leaders forward requests to the leader, so only the leader's metrics matter
+			sendChronosMetrics(m, m.chronosHost)
+		}
+	})
 }
 
-func (m *ChronosStats) sendChronosMetrics() {
-	metrics := getChronosMetrics(m)
+func (m *ChronosStats) sendChronosMetrics(host string) {
+	metrics := getChronosMetrics(m, host)
 	for _, metric := range metrics {
 		if !m.ContainsBlacklistedDimension(metric.Dimensions) {
 			m.Channel() <- metric
@@ -91,8 +112,8 @@ func (m *ChronosStats) sendChronosMetrics() {
 	}
 }
 
-func (m *ChronosStats) getChronosMetrics() []metric.Metric {
-	url := getChronosMetricsURL(m.chronosHost)
+func (m *ChronosStats) getChronosMetrics(host string) []metric.Metric {
+	url := getChronosMetricsURL(host)
 
 	contents, err := util.GetWrapper(url, m.client)
 	if err != nil {
@@ -100,7 +121,7 @@ func (m *ChronosStats) getChronosMetrics() []metric.Metric {
 		return nil
 	}
 
-	metrics, err := dropwizard.Parse(contents, "java-1.1", true)
+	metrics, err := dropwizard.ParseWithOptions(contents, "java-1.1", true, m.options)
 
 	if err != nil {
 		m.log.Error("Unable to decode chronos metrics JSON: ", err)
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"fullerite/dropwizard"
 	"fullerite/metric"
 
 	l "github.com/Sirupsen/logrus"
@@ -84,7 +85,7 @@ func TestChronosStatsGetChronosMetrics(t *testing.T) {
 		getChronosMetricsURL = func(ip string) string { return ts.URL }
 
 		sut := newChronosStats(nil, 10, defaultLog).(*ChronosStats)
-		actual := getChronosMetrics(sut)
+		actual := getChronosMetrics(sut, "")
 
 		if test.err {
 			assert.True(t, actual == nil, test.msg)
@@ -102,7 +103,10 @@ func TestChronosStatsConfigure(t *testing.T) {
 	sut := newChronosStats(nil, 10, defaultLog).(*ChronosStats)
 	sut.Configure(map[string]interface{}{
 		"chronosHost":     "foobar",
-		"extraDimensions": "{\"cluster\": \"bar\"}"})
+		"extraDimensions": "{\"cluster\": \"bar\"}",
+		"rollupDimension": true,
+		"durationUnit":    "seconds"})
 
 	assert.Equal(t, sut.extraDimensions["cluster"], "bar")
+	assert.Equal(t, dropwizard.Options{RollupDimension: true, DurationUnit: "seconds"}, sut.options)
 }
@@ -6,6 +6,8 @@ import (
 
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	l "github.com/Sirupsen/logrus"
 )
@@ -38,6 +40,11 @@ type Collector interface {
 	DimensionsBlacklist() map[string]string
 	SetDimensionsBlacklist(map[string]string)
 	ContainsBlacklistedDimension(map[string]string) bool
+	InitialDelay(time.Time) time.Duration
+	LimitCardinality(string, *metric.Metric) bool
+	CardinalityLimitedMetrics() []metric.Metric
+	CardinalityReport(int) map[string]metric.CardinalityReport
+	LastScrape() (metric.RunStatus, bool)
 }
 
 var collectorConstructs map[string]func(chan metric.Metric, int, *l.Entry) Collector
@@ -85,6 +92,13 @@ type baseCollector struct {
 	prefix              string
 	blacklist           []string
 	dimensionsBlacklist map[string]string
+	splay               string
+	alignToInterval     bool
+	cardinalityLimiter  *cardinalityLimiter
+
+	// set by collectors scraping in the background, see scrapeInBackground
+	asyncScrape bool
+	lastScrape  atomic.Value
 
 	// intentionally exported
 	log *l.Entry
@@ -108,6 +122,9 @@ func (col *baseCollector) configureCommonParams(configMap map[string]interface{}
 	if asInterface, exists := configMap["dimensions_blacklist"]; exists {
 		col.dimensionsBlacklist = config.GetAsMap(asInterface)
 	}
+
+	col.configureSchedule(configMap)
+	col.configureCardinalityLimiter(configMap)
 }
 
 // SetInterval : set the interval to collect on
@@ -10,6 +10,15 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// metricsBySeriesKey indexes metrics by their seriesKey, name|dim=value,...
+func metricsBySeriesKey(metrics []metric.Metric) map[string]metric.Metric {
+	byKey := map[string]metric.Metric{}
+	for i := range metrics {
+		byKey[seriesKey(&metrics[i])] = metrics[i]
+	}
+	return byKey
+}
+
 func TestNew(t *testing.T) {
 	names := []string{"Test", "Diamond", "Fullerite", "ProcStatus", "ProcStatus Instance2"}
 	for _, name := range names {
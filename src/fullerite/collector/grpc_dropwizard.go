@@ -22,6 +22,7 @@ type grpcDropwizardCollector struct {
 
 	endpoints []GrpcEndpoint
 	timeout   int
+	options   dropwizard.Options
 }
 
 func newGrpcDropwizard(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
@@ -92,7 +93,7 @@ func (g *grpcDropwizardCollector) getMetrics(endpoint GrpcConnector) {
 		return
 	}
 
-	metrics, err := dropwizard.Parse([]byte(res.Data), schemaVer, true)
+	metrics, err := dropwizard.ParseWithOptions([]byte(res.Data), schemaVer, true, g.options)
 
 	if err != nil {
 		serviceLog.Warn("Failed to parse response into metrics: ", err)
@@ -140,6 +141,7 @@ func (g *grpcDropwizardCollector) Configure(configMap map[string]interface{}) {
 	if val, exists := configMap["timeout"]; exists {
 		g.timeout = config.GetAsInt(val, 2)
 	}
+	configureDropwizardOptions(configMap, &g.options)
 
 	g.configureCommonParams(configMap)
 }
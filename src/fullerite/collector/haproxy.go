@@ -0,0 +1,369 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const defaultHAProxySocketPath = "/var/run/haproxy.sock"
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	queryHAProxySocket = func(socketPath string, command string, timeout time.Duration) (string, error) {
+		conn, err := net.DialTimeout("unix", socketPath, timeout)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+
+		if _, err := io.WriteString(conn, command+"\n"); err != nil {
+			return "", err
+		}
+		// the connection is closed after a command in non-interactive mode
+		contents, err := ioutil.ReadAll(conn)
+		return string(contents), err
+	}
+
+	// The proxy types of the type column of show stat, listeners are not
+	// reported
+	haproxyProxyTypes = map[string]string{
+		"0": "frontend",
+		"1": "backend",
+		"2": "server",
+	}
+
+	// The columns of show stat reported, by name and type
+	haproxyStatColumns = map[string]struct {
+		name       string
+		metricType string
+	}{
+		"qcur":           {"queue_current", metric.Gauge},
+		"qmax":           {"queue_max", metric.Gauge},
+		"scur":           {"sessions_current", metric.Gauge},
+		"smax":           {"sessions_max", metric.Gauge},
+		"slim":           {"sessions_limit", metric.Gauge},
+		"stot":           {"sessions", metric.CumulativeCounter},
+		"bin":            {"bytes_in", metric.CumulativeCounter},
+		"bout":           {"bytes_out", metric.CumulativeCounter},
+		"dreq":           {"denied_requests", metric.CumulativeCounter},
+		"dresp":          {"denied_responses", metric.CumulativeCounter},
+		"ereq":           {"request_errors", metric.CumulativeCounter},
+		"econ":           {"connection_errors", metric.CumulativeCounter},
+		"eresp":          {"response_errors", metric.CumulativeCounter},
+		"wretr":          {"retries", metric.CumulativeCounter},
+		"wredis":         {"redispatches", metric.CumulativeCounter},
+		"cli_abrt":       {"client_aborts", metric.CumulativeCounter},
+		"srv_abrt":       {"server_aborts", metric.CumulativeCounter},
+		"chkfail":        {"check_failures", metric.CumulativeCounter},
+		"chkdown":        {"check_downs", metric.CumulativeCounter},
+		"downtime":       {"downtime_seconds", metric.CumulativeCounter},
+		"act":            {"active_servers", metric.Gauge},
+		"bck":            {"backup_servers", metric.Gauge},
+		"weight":         {"weight", metric.Gauge},
+		"rate":           {"session_rate", metric.Gauge},
+		"req_rate":       {"request_rate", metric.Gauge},
+		"req_tot":        {"requests", metric.CumulativeCounter},
+		"check_duration": {"check_duration_ms", metric.Gauge},
+		"qtime":          {"queue_time_ms", metric.Gauge},
+		"ctime":          {"connect_time_ms", metric.Gauge},
+		"rtime":          {"response_time_ms", metric.Gauge},
+		"ttime":          {"total_time_ms", metric.Gauge},
+	}
+
+	// The response code columns of show stat and their code_class
+	haproxyResponseColumns = map[string]string{
+		"hrsp_1xx":   "1xx",
+		"hrsp_2xx":   "2xx",
+		"hrsp_3xx":   "3xx",
+		"hrsp_4xx":   "4xx",
+		"hrsp_5xx":   "5xx",
+		"hrsp_other": "other",
+	}
+
+	// The keys of show info reported, by name and type
+	haproxyInfoKeys = map[string]struct {
+		name       string
+		metricType string
+	}{
+		"Uptime_sec":   {"uptime_seconds", metric.Gauge},
+		"CurrConns":    {"current_connections", metric.Gauge},
+		"Maxconn":      {"max_connections", metric.Gauge},
+		"CumConns":     {"connections", metric.CumulativeCounter},
+		"CumReq":       {"requests", metric.CumulativeCounter},
+		"ConnRate":     {"connection_rate", metric.Gauge},
+		"SessRate":     {"session_rate", metric.Gauge},
+		"CurrSslConns": {"current_ssl_connections", metric.Gauge},
+		"CumSslConns":  {"ssl_connections", metric.CumulativeCounter},
+		"Run_queue":    {"run_queue", metric.Gauge},
+		"Tasks":        {"tasks", metric.Gauge},
+		"Idle_pct":     {"idle_pct", metric.Gauge},
+	}
+)
+
+// HAProxy collector
+// reads the statistics of HAProxy from its admin UNIX socket, show stat and
+// show info, or from the CSV export of its HTTP stats page when statsURL is
+// set, e.g. "http://localhost:3212/;csv". It reports:
+//   - haproxy.<frontend|backend|server>.<stat> with a proxy dimension, and a
+//     server dimension for servers
+//   - haproxy.<type>.responses by code_class
+//   - haproxy.<type>.up, 1 when the status is UP, OPEN or no check, and
+//     haproxy.<type>.status with the status and the last check_status
+//   - the process wide show info values as haproxy.<info>, only available
+//     from the socket
+type HAProxy struct {
+	baseCollector
+
+	socketPath     string
+	statsURL       string
+	user           string
+	password       string
+	timeout        int
+	collectServers bool
+}
+
+func init() {
+	RegisterCollector("HAProxy", newHAProxy)
+}
+
+func newHAProxy(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	m := new(HAProxy)
+
+	m.log = log
+	m.channel = channel
+	m.interval = initialInterval
+
+	m.name = "HAProxy"
+	m.socketPath = defaultHAProxySocketPath
+	m.timeout = 5
+	m.collectServers = true
+	return m
+}
+
+// Configure the collector
+func (m *HAProxy) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["socketPath"]; exists {
+		m.socketPath = val.(string)
+	}
+	if val, exists := configMap["statsURL"]; exists {
+		m.statsURL = val.(string)
+	}
+	if val, exists := configMap["user"]; exists {
+		m.user = val.(string)
+	}
+	if val, exists := configMap["password"]; exists {
+		m.password = val.(string)
+	}
+	if val, exists := configMap["timeout"]; exists {
+		m.timeout = config.GetAsInt(val, 5)
+	}
+	if val, exists := configMap["collectServers"]; exists {
+		m.collectServers = config.GetAsBool(val, true)
+	}
+
+	m.configureCommonParams(configMap)
+}
+
+// Targets returns the stats page or the admin socket read by the collector
+func (m *HAProxy) Targets() []string {
+	if m.statsURL != "" {
+		return []string{m.statsURL}
+	}
+	return []string{m.socketPath}
+}
+
+// Collect reads the statistics and sends their metrics
+func (m *HAProxy) Collect() {
+	for _, metric := range m.metrics() {
+		m.Channel() <- metric
+	}
+}
+
+func (m *HAProxy) metrics() []metric.Metric {
+	timeout := time.Duration(m.timeout) * time.Second
+
+	var stats string
+	var err error
+	if m.statsURL != "" {
+		stats, err = m.queryStatsPage(timeout)
+	} else {
+		stats, err = queryHAProxySocket(m.socketPath, "show stat", timeout)
+	}
+	if err != nil {
+		m.log.Error("Failed to read the HAProxy stats from ", m.Targets()[0], ": ", err)
+		return nil
+	}
+
+	rows, err := parseHAProxyStats(stats)
+	if err != nil {
+		m.log.Error("Failed to parse the HAProxy stats: ", err)
+		return nil
+	}
+	metrics := haproxyStatMetrics(rows, m.collectServers)
+
+	if m.statsURL == "" {
+		if info, err := queryHAProxySocket(m.socketPath, "show info", timeout); err == nil {
+			metrics = append(metrics, haproxyInfoMetrics(info)...)
+		} else {
+			m.log.Warn("Failed to read the HAProxy info: ", err)
+		}
+	}
+	return metrics
+}
+
+func (m *HAProxy) queryStatsPage(timeout time.Duration) (string, error) {
+	req, err := http.NewRequest("GET", m.statsURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if m.user != "" {
+		req.SetBasicAuth(m.user, m.password)
+	}
+
+	client := http.Client{Timeout: timeout}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != 200 {
+		io.Copy(ioutil.Discard, rsp.Body)
+		return "", fmt.Errorf("%s returned %d error code", m.statsURL, rsp.StatusCode)
+	}
+	contents, err := ioutil.ReadAll(rsp.Body)
+	return string(contents), err
+}
+
+// parseHAProxyStats returns the rows of the show stat CSV keyed by the
+// columns of its "# pxname,svname,..." header
+func parseHAProxyStats(stats string) ([]map[string]string, error) {
+	if !strings.HasPrefix(stats, "# ") {
+		return nil, fmt.Errorf("no header in the stats CSV")
+	}
+	reader := csv.NewReader(strings.NewReader(stats[2:]))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) && header[i] != "" {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func haproxyStatMetrics(rows []map[string]string, collectServers bool) []metric.Metric {
+	metrics := []metric.Metric{}
+	for _, row := range rows {
+		proxyType, known := haproxyProxyTypes[row["type"]]
+		if !known || (proxyType == "server" && !collectServers) {
+			continue
+		}
+
+		dimensions := map[string]string{"proxy": row["pxname"]}
+		if proxyType == "server" {
+			dimensions["server"] = row["svname"]
+		}
+		add := func(name string, metricType string, value float64, extra map[string]string) {
+			m := metric.WithValue("haproxy."+proxyType+"."+name, value)
+			m.MetricType = metricType
+			m.AddDimensions(dimensions)
+			m.AddDimensions(extra)
+			metrics = append(metrics, m)
+		}
+
+		for column, stat := range haproxyStatColumns {
+			if value, err := strconv.ParseFloat(row[column], 64); err == nil {
+				add(stat.name, stat.metricType, value, nil)
+			}
+		}
+		for column, class := range haproxyResponseColumns {
+			if value, err := strconv.ParseFloat(row[column], 64); err == nil {
+				add("responses", metric.CumulativeCounter, value, map[string]string{"code_class": class})
+			}
+		}
+
+		if status := row["status"]; status != "" {
+			add("up", metric.Gauge, haproxyStatusUp(status), nil)
+			statusDimensions := map[string]string{"status": haproxyStatusName(status)}
+			if checkStatus := strings.TrimPrefix(row["check_status"], "* "); checkStatus != "" {
+				statusDimensions["check_status"] = checkStatus
+			}
+			add("status", metric.Gauge, 1, statusDimensions)
+		}
+	}
+	return metrics
+}
+
+// haproxyStatusUp is 1 for the servers UP or going down ("UP 1/3"), the
+// open frontends and the servers without health check
+func haproxyStatusUp(status string) float64 {
+	if strings.HasPrefix(status, "UP") || status == "OPEN" || status == "no check" {
+		return 1
+	}
+	return 0
+}
+
+// haproxyStatusName drops the check counts of the transitional states,
+// "DOWN 1/2" is down, and the origin of the maintenance "MAINT (via ...)"
+func haproxyStatusName(status string) string {
+	if status == "no check" {
+		return "no_check"
+	}
+	name := strings.Fields(status)[0]
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+// haproxyInfoMetrics reports the "Name: value" lines of show info
+func haproxyInfoMetrics(info string) []metric.Metric {
+	metrics := []metric.Metric{}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, known := haproxyInfoKeys[parts[0]]
+		if !known {
+			continue
+		}
+		if value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+			m := metric.WithValue("haproxy."+key.name, value)
+			m.MetricType = key.metricType
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
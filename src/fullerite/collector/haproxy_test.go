@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fullerite/metric"
+	"fullerite/test_utils"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestHAProxy() *HAProxy {
+	return newHAProxy(make(chan metric.Metric), 10, l.WithField("testing", "haproxy")).(*HAProxy)
+}
+
+func readHAProxyFixture(t *testing.T, name string) string {
+	contents, err := ioutil.ReadFile(path.Join(test_utils.DirectoryOfCurrentFile(), "/../../fixtures/haproxy", name))
+	assert.Nil(t, err)
+	return string(contents)
+}
+
+func haproxyMetricsByKey(metrics []metric.Metric) map[string]metric.Metric {
+	byKey := map[string]metric.Metric{}
+	for i := range metrics {
+		byKey[seriesKey(&metrics[i])] = metrics[i]
+	}
+	return byKey
+}
+
+func TestHAProxyConfigure(t *testing.T) {
+	h := getTestHAProxy()
+	h.Configure(map[string]interface{}{})
+	assert.Equal(t, "/var/run/haproxy.sock", h.socketPath)
+	assert.Equal(t, []string{"/var/run/haproxy.sock"}, h.Targets())
+	assert.Equal(t, 5, h.timeout)
+	assert.True(t, h.collectServers)
+
+	h.Configure(map[string]interface{}{
+		"interval":       30,
+		"statsURL":       "http://localhost:3212/;csv",
+		"user":           "admin",
+		"password":       "secret",
+		"timeout":        "2",
+		"collectServers": false,
+	})
+	assert.Equal(t, 30, h.Interval())
+	assert.Equal(t, []string{"http://localhost:3212/;csv"}, h.Targets())
+	assert.Equal(t, "admin", h.user)
+	assert.Equal(t, "secret", h.password)
+	assert.Equal(t, 2, h.timeout)
+	assert.False(t, h.collectServers)
+}
+
+func TestParseHAProxyStats(t *testing.T) {
+	rows, err := parseHAProxyStats(readHAProxyFixture(t, "stat.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, 9, len(rows))
+	assert.Equal(t, "service_a.main", rows[3]["pxname"])
+	assert.Equal(t, "10.0.0.1:31000_host1", rows[3]["svname"])
+	assert.Equal(t, "L7OK", rows[3]["check_status"])
+
+	_, err = parseHAProxyStats("Unknown command.\n")
+	assert.NotNil(t, err)
+}
+
+func TestHAProxyStatMetrics(t *testing.T) {
+	rows, err := parseHAProxyStats(readHAProxyFixture(t, "stat.csv"))
+	assert.Nil(t, err)
+	byKey := haproxyMetricsByKey(haproxyStatMetrics(rows, true))
+
+	frontend := "|proxy=service_a.main"
+	assert.Equal(t, 5.0, byKey["haproxy.frontend.sessions_current"+frontend].Value)
+	sessions := byKey["haproxy.frontend.sessions"+frontend]
+	assert.Equal(t, 1500.0, sessions.Value)
+	assert.Equal(t, metric.CumulativeCounter, sessions.MetricType)
+	assert.Equal(t, 4.0, byKey["haproxy.frontend.request_rate"+frontend].Value)
+	assert.Equal(t, 3.0, byKey["haproxy.frontend.request_errors"+frontend].Value)
+	assert.Equal(t, 1400.0, byKey["haproxy.frontend.responses|code_class=2xx,proxy=service_a.main"].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.frontend.up"+frontend].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.frontend.status|proxy=service_a.main,status=open"].Value)
+
+	assert.Equal(t, 1.0, byKey["haproxy.backend.queue_current"+frontend].Value)
+	assert.Equal(t, 3.0, byKey["haproxy.backend.active_servers"+frontend].Value)
+	assert.Equal(t, 4502000.0, byKey["haproxy.backend.bytes_out"+frontend].Value)
+	responseTime := byKey["haproxy.backend.response_time_ms"+frontend]
+	assert.Equal(t, 13.0, responseTime.Value)
+	assert.Equal(t, metric.Gauge, responseTime.MetricType)
+
+	host1 := "|proxy=service_a.main,server=10.0.0.1:31000_host1"
+	assert.Equal(t, 2.0, byKey["haproxy.server.sessions_current"+host1].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.connection_errors"+host1].Value)
+	assert.Equal(t, 4.0, byKey["haproxy.server.check_failures"+host1].Value)
+	assert.Equal(t, 3.0, byKey["haproxy.server.check_duration_ms"+host1].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.up"+host1].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.status|check_status=L7OK,proxy=service_a.main,server=10.0.0.1:31000_host1,status=up"].Value)
+
+	host2 := "|proxy=service_a.main,server=10.0.0.2:31000_host2"
+	assert.Equal(t, 0.0, byKey["haproxy.server.up"+host2].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.status|check_status=L4CON,proxy=service_a.main,server=10.0.0.2:31000_host2,status=down"].Value)
+	assert.Equal(t, 0.0, byKey["haproxy.server.up|proxy=service_a.main,server=10.0.0.3:31000_host3"].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.status|proxy=service_a.main,server=10.0.0.3:31000_host3,status=maint"].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.up|proxy=service_a.main,server=10.0.0.4:31000_host4"].Value)
+	assert.Equal(t, 1.0, byKey["haproxy.server.status|proxy=service_a.main,server=10.0.0.4:31000_host4,status=no_check"].Value)
+
+	for key := range byKey {
+		assert.NotContains(t, key, "stats_listener")
+	}
+
+	withoutServers := haproxyStatMetrics(rows, false)
+	for _, m := range withoutServers {
+		assert.NotContains(t, m.Name, "haproxy.server.")
+	}
+}
+
+func TestHAProxyInfoMetrics(t *testing.T) {
+	metrics := haproxyInfoMetrics(readHAProxyFixture(t, "info.txt"))
+	assert.Equal(t, 12, len(metrics))
+	byKey := haproxyMetricsByKey(metrics)
+
+	assert.Equal(t, 86400.0, byKey["haproxy.uptime_seconds|"].Value)
+	assert.Equal(t, 2000.0, byKey["haproxy.max_connections|"].Value)
+	requests := byKey["haproxy.requests|"]
+	assert.Equal(t, 1590.0, requests.Value)
+	assert.Equal(t, metric.CumulativeCounter, requests.MetricType)
+	assert.Equal(t, 97.0, byKey["haproxy.idle_pct|"].Value)
+}
+
+func TestHAProxyMetricsFromSocket(t *testing.T) {
+	oldQueryHAProxySocket := queryHAProxySocket
+	defer func() { queryHAProxySocket = oldQueryHAProxySocket }()
+
+	stat := readHAProxyFixture(t, "stat.csv")
+	info := readHAProxyFixture(t, "info.txt")
+	commands := []string{}
+	queryHAProxySocket = func(socketPath string, command string, timeout time.Duration) (string, error) {
+		assert.Equal(t, "/run/haproxy/admin.sock", socketPath)
+		commands = append(commands, command)
+		if command == "show info" {
+			return info, nil
+		}
+		return stat, nil
+	}
+
+	h := getTestHAProxy()
+	h.Configure(map[string]interface{}{"socketPath": "/run/haproxy/admin.sock"})
+	byKey := haproxyMetricsByKey(h.metrics())
+	assert.Equal(t, []string{"show stat", "show info"}, commands)
+	assert.Equal(t, 6.0, byKey["haproxy.current_connections|"].Value)
+	assert.Equal(t, 1500.0, byKey["haproxy.frontend.sessions|proxy=service_a.main"].Value)
+
+	queryHAProxySocket = func(socketPath string, command string, timeout time.Duration) (string, error) {
+		return "", fmt.Errorf("connection refused")
+	}
+	assert.Nil(t, h.metrics())
+}
+
+func TestHAProxyMetricsFromStatsPage(t *testing.T) {
+	stat := readHAProxyFixture(t, "stat.csv")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, stat)
+	}))
+	defer ts.Close()
+
+	h := getTestHAProxy()
+	h.Configure(map[string]interface{}{"statsURL": ts.URL + "/;csv", "user": "admin", "password": "secret"})
+	byKey := haproxyMetricsByKey(h.metrics())
+	assert.Equal(t, 1500.0, byKey["haproxy.frontend.sessions|proxy=service_a.main"].Value)
+	_, exists := byKey["haproxy.current_connections|"]
+	assert.False(t, exists)
+
+	h.password = "wrong"
+	assert.Nil(t, h.metrics())
+}
+
+func TestQueryHAProxySocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "admin.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		buffer := make([]byte, 64)
+		n, _ := conn.Read(buffer)
+		fmt.Fprintf(conn, "received %q", buffer[:n])
+		conn.Close()
+	}()
+
+	contents, err := queryHAProxySocket(socketPath, "show info", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, `received "show info\n"`, contents)
+
+	_, err = queryHAProxySocket(filepath.Join(dir, "missing.sock"), "show info", time.Second)
+	assert.NotNil(t, err)
+}
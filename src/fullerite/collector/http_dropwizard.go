@@ -5,7 +5,10 @@ import (
 	"fullerite/dropwizard"
 	"fullerite/metric"
 
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
 
 	l "github.com/Sirupsen/logrus"
 )
@@ -13,11 +16,20 @@ import (
 // The HTTP Dropwizard Collector allows to collect metrics emitted by java/python services
 // with one of the schemas defined at dropwizard/base_parser.go#L80.
 // User needs to specify port and path where the service'metrics endpoint is setup.
+// The schema is read from the Metrics-Schema header unless it is set for the
+// endpoint, like "actuator" for the Spring Boot /actuator/metrics endpoint
+// or "jolokia" for Jolokia reads. A body makes the request a POST, for
+// Jolokia bulk reads. With rollupDimension the rollups of a metric share
+// its name and are told apart by the rollup dimension, durationUnit and
+// rateUnit convert the timers and meters to units like "seconds". With
+// distributions each timer and histogram is sent as a distribution to the
+// handlers supporting them, the others still receive its rollups.
 type httpDropwizardCollector struct {
 	baseCollector
 
 	endpoints []ServiceEndpoint
 	timeout   int
+	options   dropwizard.Options
 }
 
 // ServiceEndpoint defines a struct for endpoints
@@ -28,6 +40,10 @@ type ServiceEndpoint struct {
 	Port string
 	// Path is the service metrics endpoint path (i.e. status/metrics)
 	Path string
+	// Schema overrides the Metrics-Schema header of the response
+	Schema string
+	// Body is posted to the endpoint if set
+	Body string
 }
 
 func init() {
@@ -54,9 +70,11 @@ func (h *httpDropwizardCollector) Configure(configMap map[string]interface{}) {
 		for _, e := range val {
 			endpoint := config.GetAsMap(e)
 			h.endpoints[index] = ServiceEndpoint{
-				Name: endpoint["service_name"],
-				Port: endpoint["port"],
-				Path: endpoint["path"],
+				Name:   endpoint["service_name"],
+				Port:   endpoint["port"],
+				Path:   endpoint["path"],
+				Schema: endpoint["schema"],
+				Body:   endpoint["body"],
 			}
 			index++
 		}
@@ -65,10 +83,27 @@ func (h *httpDropwizardCollector) Configure(configMap map[string]interface{}) {
 	if val, exists := configMap["http_timeout"]; exists {
 		h.timeout = config.GetAsInt(val, 2)
 	}
+	configureDropwizardOptions(configMap, &h.options)
 
 	h.configureCommonParams(configMap)
 }
 
+// configureDropwizardOptions reads the options of the dropwizard parsers
+func configureDropwizardOptions(configMap map[string]interface{}, options *dropwizard.Options) {
+	if val, exists := configMap["rollupDimension"]; exists {
+		options.RollupDimension = config.GetAsBool(val, false)
+	}
+	if val, exists := configMap["durationUnit"]; exists {
+		options.DurationUnit = val.(string)
+	}
+	if val, exists := configMap["rateUnit"]; exists {
+		options.RateUnit = val.(string)
+	}
+	if val, exists := configMap["distributions"]; exists {
+		options.Distributions = config.GetAsBool(val, false)
+	}
+}
+
 func (h *httpDropwizardCollector) Collect() {
 	for _, endpoint := range h.endpoints {
 		go h.queryService(endpoint)
@@ -81,12 +116,26 @@ func (h *httpDropwizardCollector) queryService(s ServiceEndpoint) {
 	endpoint := fmt.Sprintf("http://localhost:%s/%s", s.Port, s.Path)
 	serviceLog.Debug("making GET request to ", endpoint)
 
-	rawResponse, schemaVer, err := queryEndpoint(endpoint, h.timeout)
+	var rawResponse []byte
+	var schemaVer string
+	var err error
+	switch {
+	case s.Schema == "actuator":
+		rawResponse, err = queryActuator(endpoint, h.timeout)
+		schemaVer = s.Schema
+	case s.Body != "":
+		rawResponse, schemaVer, err = postEndpoint(endpoint, s.Body, h.timeout)
+	default:
+		rawResponse, schemaVer, err = queryEndpoint(endpoint, h.timeout)
+	}
 	if err != nil {
 		serviceLog.Warn("Failed to query endpoint ", endpoint, ": ", err)
 		return
 	}
-	metrics, err := dropwizard.Parse(rawResponse, schemaVer, true)
+	if s.Schema != "" {
+		schemaVer = s.Schema
+	}
+	metrics, err := dropwizard.ParseWithOptions(rawResponse, schemaVer, true, h.options)
 	if err != nil {
 		serviceLog.Warn("Failed to parse response into metrics: ", err)
 		return
@@ -103,3 +152,28 @@ func (h *httpDropwizardCollector) queryService(s ServiceEndpoint) {
 		}
 	}
 }
+
+// queryActuator reads every metric listed by a Spring Boot actuator
+// metrics endpoint and returns them as a list
+func queryActuator(endpoint string, timeout int) ([]byte, error) {
+	rawResponse, _, err := queryEndpoint(endpoint, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Names []string `json:"names"`
+	}
+	if err := json.Unmarshal(rawResponse, &list); err != nil {
+		return nil, err
+	}
+
+	meters := []json.RawMessage{}
+	for _, name := range list.Names {
+		meter, _, err := queryEndpoint(strings.TrimSuffix(endpoint, "/")+"/"+url.PathEscape(name), timeout)
+		if err != nil {
+			return nil, err
+		}
+		meters = append(meters, meter)
+	}
+	return json.Marshal(meters)
+}
@@ -1,7 +1,12 @@
 package collector
 
 import (
+	"fullerite/dropwizard"
 	"fullerite/metric"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	l "github.com/Sirupsen/logrus"
@@ -29,9 +34,13 @@ func TestConfigHttpDropwizard(t *testing.T) {
 	endpoints := make([]interface{}, 1)
 	endpoints[0] = service
 	cfg := map[string]interface{}{
-		"interval":     5,
-		"http_timeout": 10,
-		"endpoints":    endpoints,
+		"interval":        5,
+		"http_timeout":    10,
+		"endpoints":       endpoints,
+		"rollupDimension": "true",
+		"durationUnit":    "seconds",
+		"rateUnit":        "second",
+		"distributions":   true,
 	}
 
 	inst := getTestHTTPDropwizard()
@@ -42,4 +51,69 @@ func TestConfigHttpDropwizard(t *testing.T) {
 	assert.Equal(t, "test_name", inst.endpoints[0].Name)
 	assert.Equal(t, "3400", inst.endpoints[0].Port)
 	assert.Equal(t, "path0/path1", inst.endpoints[0].Path)
+	assert.Equal(t, dropwizard.Options{RollupDimension: true, DurationUnit: "seconds", RateUnit: "second", Distributions: true}, inst.options)
+}
+
+func TestHTTPDropwizardCollectActuator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/actuator/metrics":
+			w.Write([]byte(`{"names": ["jvm.threads.live", "process.uptime"]}`))
+		case "/actuator/metrics/jvm.threads.live":
+			w.Write([]byte(`{"name": "jvm.threads.live", "measurements": [{"statistic": "VALUE", "value": 42}]}`))
+		case "/actuator/metrics/process.uptime":
+			w.Write([]byte(`{"name": "process.uptime", "measurements": [{"statistic": "VALUE", "value": 100}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	inst := getTestHTTPDropwizard()
+	inst.Configure(map[string]interface{}{
+		"endpoints": []interface{}{map[string]interface{}{
+			"service_name": "test_name",
+			"port":         u.Port(),
+			"path":         "actuator/metrics",
+			"schema":       "actuator",
+		}},
+	})
+	go inst.Collect()
+
+	actual := map[string]float64{}
+	for len(actual) < 2 {
+		m := <-inst.Channel()
+		assert.Equal(t, "test_name", m.Dimensions["service"])
+		actual[m.Name] = m.Value
+	}
+	assert.Equal(t, map[string]float64{"jvm.threads.live": 42, "process.uptime": 100}, actual)
+}
+
+func TestHTTPDropwizardCollectJolokiaBulkRead(t *testing.T) {
+	body := `[{"type": "read", "mbean": "java.lang:type=Threading", "attribute": "ThreadCount"}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, body, string(posted))
+		w.Write([]byte(`[{"request": {"type": "read", "mbean": "java.lang:type=Threading", "attribute": "ThreadCount"}, "value": 12, "status": 200}]`))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	inst := getTestHTTPDropwizard()
+	inst.Configure(map[string]interface{}{
+		"endpoints": []interface{}{map[string]interface{}{
+			"service_name": "test_name",
+			"port":         u.Port(),
+			"path":         "jolokia",
+			"schema":       "jolokia",
+			"body":         body,
+		}},
+	})
+	go inst.Collect()
+
+	m := <-inst.Channel()
+	assert.Equal(t, "java.lang.Threading.ThreadCount", m.Name)
+	assert.Equal(t, 12.0, m.Value)
 }
@@ -19,6 +19,11 @@ type baseHTTPCollector struct {
 	endpoint string
 }
 
+// Targets returns the endpoint scraped by the collector
+func (base baseHTTPCollector) Targets() []string {
+	return []string{base.endpoint}
+}
+
 // Collect first queries the config'd endpoint and then passes the results to the handler functions
 func (base baseHTTPCollector) Collect() {
 	base.log.Info("Starting to collect metrics from ", base.endpoint)
@@ -0,0 +1,374 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const kafkaClientID = "fullerite"
+
+// kafkaClient is a connection to a broker of the Kafka collector
+type kafkaClient interface {
+	Metadata(topics []string) (util.KafkaMetadata, error)
+	ListOffsets(partitions map[string][]int32) (util.KafkaOffsets, error)
+	ListGroups() ([]string, error)
+	FindCoordinator(group string) (util.KafkaBroker, error)
+	OffsetFetch(group string) (util.KafkaOffsets, error)
+	Close() error
+}
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	dialKafka = func(addr string, timeout time.Duration) (kafkaClient, error) {
+		return util.DialKafka(addr, kafkaClientID, timeout)
+	}
+)
+
+// Kafka collector
+// reads the metadata, the high watermarks and the committed offsets of the
+// consumer groups of a Kafka cluster, through the Kafka protocol, and
+// reports:
+//   - kafka.brokers
+//   - kafka.topic.<partitions|under_replicated_partitions|offline_partitions>
+//     by topic
+//   - kafka.topic.isr_shrinks by topic, the cumulative count of partitions
+//     which lost in-sync replicas between two collections
+//   - kafka.consumer_group.lag by consumer_group, topic and partition, the
+//     high watermark minus the committed offset, and
+//     kafka.consumer_group.lag_total by consumer_group and topic
+//
+// The cluster is reached through the first of the bootstrap brokers
+// answering. groupsWhitelist and topicsWhitelist are regular expressions
+// matching the whole names, every group and every non internal topic are
+// reported without them.
+type Kafka struct {
+	baseCollector
+
+	brokers         []string
+	timeout         int
+	groupsWhitelist []*regexp.Regexp
+	topicsWhitelist []*regexp.Regexp
+
+	// The ISR sizes of the previous collection by topic and partition, and
+	// the ISR shrinks counted since the start by topic
+	isrSizes   map[string]map[int32]int
+	isrShrinks map[string]float64
+}
+
+// kafkaPool keeps the connections to the brokers during a collection
+type kafkaPool struct {
+	timeout time.Duration
+	conns   map[string]kafkaClient
+}
+
+func init() {
+	RegisterCollector("Kafka", newKafka)
+}
+
+func newKafka(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	m := new(Kafka)
+
+	m.log = log
+	m.channel = channel
+	m.interval = initialInterval
+
+	m.name = "Kafka"
+	m.brokers = []string{"localhost:9092"}
+	m.timeout = 5
+	m.isrSizes = map[string]map[int32]int{}
+	m.isrShrinks = map[string]float64{}
+	return m
+}
+
+// Configure the collector
+func (m *Kafka) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["brokers"]; exists {
+		m.brokers = []string{}
+		for _, broker := range config.GetAsSlice(val) {
+			m.brokers = append(m.brokers, util.WithDefaultPort(broker, 9092))
+		}
+	}
+	if val, exists := configMap["timeout"]; exists {
+		m.timeout = config.GetAsInt(val, 5)
+	}
+	if val, exists := configMap["groupsWhitelist"]; exists {
+		m.groupsWhitelist = m.compileWhitelist(val)
+	}
+	if val, exists := configMap["topicsWhitelist"]; exists {
+		m.topicsWhitelist = m.compileWhitelist(val)
+	}
+
+	m.configureCommonParams(configMap)
+}
+
+// compileWhitelist anchors the regular expressions to match whole names,
+// the invalid ones are left out
+func (m *Kafka) compileWhitelist(value interface{}) []*regexp.Regexp {
+	whitelist := []*regexp.Regexp{}
+	for _, pattern := range config.GetAsSlice(value) {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			m.log.Error("Invalid whitelist pattern ", pattern, ": ", err)
+			continue
+		}
+		whitelist = append(whitelist, re)
+	}
+	return whitelist
+}
+
+func kafkaWhitelisted(whitelist []*regexp.Regexp, name string) bool {
+	for _, re := range whitelist {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Kafka) topicWhitelisted(topic util.KafkaTopic) bool {
+	if len(m.topicsWhitelist) == 0 {
+		return !topic.Internal
+	}
+	return kafkaWhitelisted(m.topicsWhitelist, topic.Name)
+}
+
+func (m *Kafka) groupWhitelisted(group string) bool {
+	return len(m.groupsWhitelist) == 0 || kafkaWhitelisted(m.groupsWhitelist, group)
+}
+
+// Collect reads the cluster and sends its metrics
+func (m *Kafka) Collect() {
+	for _, metric := range m.clusterMetrics() {
+		m.Channel() <- metric
+	}
+}
+
+func (m *Kafka) clusterMetrics() []metric.Metric {
+	pool := &kafkaPool{timeout: time.Duration(m.timeout) * time.Second, conns: map[string]kafkaClient{}}
+	defer pool.close()
+
+	var bootstrap kafkaClient
+	var metadata util.KafkaMetadata
+	for _, broker := range m.brokers {
+		conn, err := pool.get(broker)
+		if err == nil {
+			metadata, err = conn.Metadata(nil)
+		}
+		if err != nil {
+			m.log.Warn("Failed to read the metadata from ", broker, ": ", err)
+			continue
+		}
+		bootstrap = conn
+		break
+	}
+	if bootstrap == nil {
+		m.log.Error("None of the brokers ", m.brokers, " answered")
+		return nil
+	}
+
+	topics := []util.KafkaTopic{}
+	for _, topic := range metadata.Topics {
+		if topic.Err == 0 && m.topicWhitelisted(topic) {
+			topics = append(topics, topic)
+		}
+	}
+
+	metrics := []metric.Metric{metric.WithValue("kafka.brokers", float64(len(metadata.Brokers)))}
+	metrics = append(metrics, m.topicMetrics(topics)...)
+	highWatermarks := m.highWatermarks(pool, metadata.Brokers, topics)
+	for _, group := range m.groups(pool, metadata.Brokers) {
+		metrics = append(metrics, m.groupMetrics(pool, bootstrap, group, highWatermarks)...)
+	}
+	return metrics
+}
+
+// topicMetrics reports the replication of the topics and counts the ISR
+// shrinks since the previous collection
+func (m *Kafka) topicMetrics(topics []util.KafkaTopic) []metric.Metric {
+	metrics := []metric.Metric{}
+	for _, topic := range topics {
+		underReplicated, offline := 0, 0
+		previous := m.isrSizes[topic.Name]
+		current := map[int32]int{}
+		for _, partition := range topic.Partitions {
+			if len(partition.ISR) < len(partition.Replicas) {
+				underReplicated++
+			}
+			if partition.Leader < 0 {
+				offline++
+			}
+			if size, exists := previous[partition.ID]; exists && len(partition.ISR) < size {
+				m.isrShrinks[topic.Name]++
+			}
+			current[partition.ID] = len(partition.ISR)
+		}
+		m.isrSizes[topic.Name] = current
+
+		dimensions := map[string]string{"topic": topic.Name}
+		for name, value := range map[string]float64{
+			"partitions":                  float64(len(topic.Partitions)),
+			"under_replicated_partitions": float64(underReplicated),
+			"offline_partitions":          float64(offline),
+		} {
+			topicMetric := metric.WithValue("kafka.topic."+name, value)
+			topicMetric.AddDimensions(dimensions)
+			metrics = append(metrics, topicMetric)
+		}
+		shrinks := metric.WithValue("kafka.topic.isr_shrinks", m.isrShrinks[topic.Name])
+		shrinks.MetricType = metric.CumulativeCounter
+		shrinks.AddDimensions(dimensions)
+		metrics = append(metrics, shrinks)
+	}
+	return metrics
+}
+
+// highWatermarks asks the leaders of the partitions for their high
+// watermarks
+func (m *Kafka) highWatermarks(pool *kafkaPool, brokers []util.KafkaBroker, topics []util.KafkaTopic) util.KafkaOffsets {
+	byLeader := map[int32]map[string][]int32{}
+	for _, topic := range topics {
+		for _, partition := range topic.Partitions {
+			if partition.Leader < 0 {
+				continue
+			}
+			if _, exists := byLeader[partition.Leader]; !exists {
+				byLeader[partition.Leader] = map[string][]int32{}
+			}
+			byLeader[partition.Leader][topic.Name] = append(byLeader[partition.Leader][topic.Name], partition.ID)
+		}
+	}
+
+	highWatermarks := util.KafkaOffsets{}
+	for _, broker := range brokers {
+		partitions, exists := byLeader[broker.ID]
+		if !exists {
+			continue
+		}
+		conn, err := pool.get(broker.Addr())
+		if err != nil {
+			m.log.Warn("Failed to connect to ", broker.Addr(), ": ", err)
+			continue
+		}
+		offsets, err := conn.ListOffsets(partitions)
+		if err != nil {
+			m.log.Warn("Failed to read the high watermarks from ", broker.Addr(), ": ", err)
+			continue
+		}
+		for topic, byPartition := range offsets {
+			if _, exists := highWatermarks[topic]; !exists {
+				highWatermarks[topic] = map[int32]int64{}
+			}
+			for partition, offset := range byPartition {
+				highWatermarks[topic][partition] = offset
+			}
+		}
+	}
+	return highWatermarks
+}
+
+// groups returns the whitelisted consumer groups of all the brokers
+func (m *Kafka) groups(pool *kafkaPool, brokers []util.KafkaBroker) []string {
+	seen := map[string]bool{}
+	for _, broker := range brokers {
+		conn, err := pool.get(broker.Addr())
+		if err != nil {
+			m.log.Warn("Failed to connect to ", broker.Addr(), ": ", err)
+			continue
+		}
+		groups, err := conn.ListGroups()
+		if err != nil {
+			m.log.Warn("Failed to list the groups of ", broker.Addr(), ": ", err)
+			continue
+		}
+		for _, group := range groups {
+			if m.groupWhitelisted(group) {
+				seen[group] = true
+			}
+		}
+	}
+
+	groups := []string{}
+	for group := range seen {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// groupMetrics reports the lag of the consumer group on the partitions it
+// committed offsets for
+func (m *Kafka) groupMetrics(pool *kafkaPool, bootstrap kafkaClient, group string, highWatermarks util.KafkaOffsets) []metric.Metric {
+	groupLog := m.log.WithField("consumer_group", group)
+	coordinator, err := bootstrap.FindCoordinator(group)
+	if err != nil {
+		groupLog.Warn("Failed to find the coordinator: ", err)
+		return nil
+	}
+	conn, err := pool.get(coordinator.Addr())
+	if err != nil {
+		groupLog.Warn("Failed to connect to the coordinator ", coordinator.Addr(), ": ", err)
+		return nil
+	}
+	committed, err := conn.OffsetFetch(group)
+	if err != nil {
+		groupLog.Warn("Failed to read the committed offsets: ", err)
+		return nil
+	}
+
+	metrics := []metric.Metric{}
+	for topic, byPartition := range committed {
+		total, found := 0.0, false
+		for partition, offset := range byPartition {
+			highWatermark, exists := highWatermarks[topic][partition]
+			if !exists {
+				continue
+			}
+			lag := float64(highWatermark - offset)
+			if lag < 0 {
+				lag = 0
+			}
+			total += lag
+			found = true
+
+			lagMetric := metric.WithValue("kafka.consumer_group.lag", lag)
+			lagMetric.AddDimensions(map[string]string{
+				"consumer_group": group,
+				"topic":          topic,
+				"partition":      strconv.Itoa(int(partition)),
+			})
+			metrics = append(metrics, lagMetric)
+		}
+		if found {
+			totalMetric := metric.WithValue("kafka.consumer_group.lag_total", total)
+			totalMetric.AddDimensions(map[string]string{"consumer_group": group, "topic": topic})
+			metrics = append(metrics, totalMetric)
+		}
+	}
+	return metrics
+}
+
+// get returns the connection to the broker, connecting on first use
+func (p *kafkaPool) get(addr string) (kafkaClient, error) {
+	if conn, exists := p.conns[addr]; exists {
+		return conn, nil
+	}
+	conn, err := dialKafka(addr, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[addr] = conn
+	return conn, nil
+}
+
+func (p *kafkaPool) close() {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
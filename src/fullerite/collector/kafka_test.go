@@ -0,0 +1,248 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"fullerite/metric"
+	"fullerite/util"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKafkaBroker answers for one broker of a fake cluster
+type fakeKafkaBroker struct {
+	metadata       util.KafkaMetadata
+	highWatermarks util.KafkaOffsets
+	groups         []string
+	coordinators   map[string]util.KafkaBroker
+	committed      map[string]util.KafkaOffsets
+	closed         bool
+}
+
+func (b *fakeKafkaBroker) Metadata(topics []string) (util.KafkaMetadata, error) {
+	return b.metadata, nil
+}
+
+func (b *fakeKafkaBroker) ListOffsets(partitions map[string][]int32) (util.KafkaOffsets, error) {
+	offsets := util.KafkaOffsets{}
+	for topic, ids := range partitions {
+		offsets[topic] = map[int32]int64{}
+		for _, id := range ids {
+			offsets[topic][id] = b.highWatermarks[topic][id]
+		}
+	}
+	return offsets, nil
+}
+
+func (b *fakeKafkaBroker) ListGroups() ([]string, error) {
+	return b.groups, nil
+}
+
+func (b *fakeKafkaBroker) FindCoordinator(group string) (util.KafkaBroker, error) {
+	coordinator, exists := b.coordinators[group]
+	if !exists {
+		return coordinator, util.KafkaError(15)
+	}
+	return coordinator, nil
+}
+
+func (b *fakeKafkaBroker) OffsetFetch(group string) (util.KafkaOffsets, error) {
+	offsets, exists := b.committed[group]
+	if !exists {
+		return nil, util.KafkaError(16)
+	}
+	return offsets, nil
+}
+
+func (b *fakeKafkaBroker) Close() error {
+	b.closed = true
+	return nil
+}
+
+// fakeKafkaCluster has two brokers, kafka-1 leading events/0 and
+// coordinating indexer, kafka-2 leading events/1 and coordinating archiver
+func fakeKafkaCluster() map[string]*fakeKafkaBroker {
+	metadata := util.KafkaMetadata{
+		Brokers: []util.KafkaBroker{{ID: 1, Host: "kafka-1", Port: 9092}, {ID: 2, Host: "kafka-2", Port: 9092}},
+		Topics: []util.KafkaTopic{
+			{Name: "events", Partitions: []util.KafkaPartition{
+				{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+				{ID: 1, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2, 1}},
+			}},
+			{Name: "logs", Partitions: []util.KafkaPartition{
+				{ID: 0, Leader: -1, Replicas: []int32{1}, ISR: []int32{}},
+			}},
+			{Name: "__consumer_offsets", Internal: true, Partitions: []util.KafkaPartition{
+				{ID: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+			}},
+			{Name: "missing", Err: util.KafkaError(3)},
+		},
+	}
+	coordinators := map[string]util.KafkaBroker{
+		"indexer":  metadata.Brokers[0],
+		"archiver": metadata.Brokers[1],
+	}
+	highWatermarks := util.KafkaOffsets{"events": {0: 100, 1: 50}, "__consumer_offsets": {0: 10}}
+	return map[string]*fakeKafkaBroker{
+		"kafka-1:9092": {
+			metadata:       metadata,
+			highWatermarks: highWatermarks,
+			groups:         []string{"indexer", "console-consumer-1"},
+			coordinators:   coordinators,
+			committed:      map[string]util.KafkaOffsets{"indexer": {"events": {0: 90, 1: 50}, "logs": {0: 5}}},
+		},
+		"kafka-2:9092": {
+			metadata:       metadata,
+			highWatermarks: highWatermarks,
+			groups:         []string{"archiver"},
+			coordinators:   coordinators,
+			committed:      map[string]util.KafkaOffsets{"archiver": {"events": {1: 60}}},
+		},
+	}
+}
+
+func getTestKafka() *Kafka {
+	return newKafka(make(chan metric.Metric), 10, l.WithField("testing", "kafka")).(*Kafka)
+}
+
+// withFakeKafkaCluster makes dialKafka connect to the cluster, the other
+// addresses being refused
+func withFakeKafkaCluster(cluster map[string]*fakeKafkaBroker) func() {
+	oldDialKafka := dialKafka
+	dialKafka = func(addr string, timeout time.Duration) (kafkaClient, error) {
+		if broker, exists := cluster[addr]; exists {
+			return broker, nil
+		}
+		return nil, fmt.Errorf("dial tcp %s: connection refused", addr)
+	}
+	return func() { dialKafka = oldDialKafka }
+}
+
+func TestKafkaConfigure(t *testing.T) {
+	k := getTestKafka()
+	k.Configure(map[string]interface{}{})
+	assert.Equal(t, []string{"localhost:9092"}, k.brokers)
+	assert.Equal(t, 5, k.timeout)
+	assert.Empty(t, k.groupsWhitelist)
+	assert.Empty(t, k.topicsWhitelist)
+
+	k.Configure(map[string]interface{}{
+		"interval":        30,
+		"brokers":         []interface{}{"kafka-1", "kafka-2:9093"},
+		"timeout":         "2",
+		"groupsWhitelist": []interface{}{"indexer", "archiver-.*", "("},
+		"topicsWhitelist": []interface{}{"events"},
+	})
+	assert.Equal(t, 30, k.Interval())
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9093"}, k.brokers)
+	assert.Equal(t, 2, k.timeout)
+	assert.Equal(t, 2, len(k.groupsWhitelist))
+	assert.True(t, k.groupWhitelisted("archiver-eu"))
+	assert.False(t, k.groupWhitelisted("indexer-2"))
+	assert.True(t, k.topicWhitelisted(util.KafkaTopic{Name: "events"}))
+	assert.False(t, k.topicWhitelisted(util.KafkaTopic{Name: "events-dlq"}))
+}
+
+func TestKafkaClusterMetrics(t *testing.T) {
+	cluster := fakeKafkaCluster()
+	defer withFakeKafkaCluster(cluster)()
+
+	k := getTestKafka()
+	k.Configure(map[string]interface{}{"brokers": []interface{}{"kafka-0", "kafka-1"}})
+	byKey := metricsBySeriesKey(k.clusterMetrics())
+
+	assert.Equal(t, 2.0, byKey["kafka.brokers|"].Value)
+	assert.Equal(t, 2.0, byKey["kafka.topic.partitions|topic=events"].Value)
+	assert.Equal(t, 0.0, byKey["kafka.topic.under_replicated_partitions|topic=events"].Value)
+	assert.Equal(t, 1.0, byKey["kafka.topic.under_replicated_partitions|topic=logs"].Value)
+	assert.Equal(t, 1.0, byKey["kafka.topic.offline_partitions|topic=logs"].Value)
+	assert.Equal(t, metric.CumulativeCounter, byKey["kafka.topic.isr_shrinks|topic=events"].MetricType)
+	for key := range byKey {
+		assert.NotContains(t, key, "topic=__consumer_offsets")
+		assert.NotContains(t, key, "topic=missing")
+	}
+
+	assert.Equal(t, 10.0, byKey["kafka.consumer_group.lag|consumer_group=indexer,partition=0,topic=events"].Value)
+	assert.Equal(t, 0.0, byKey["kafka.consumer_group.lag|consumer_group=indexer,partition=1,topic=events"].Value)
+	assert.Equal(t, 10.0, byKey["kafka.consumer_group.lag_total|consumer_group=indexer,topic=events"].Value)
+	// committed ahead of the high watermark read before it
+	assert.Equal(t, 0.0, byKey["kafka.consumer_group.lag|consumer_group=archiver,partition=1,topic=events"].Value)
+	_, exists := byKey["kafka.consumer_group.lag_total|consumer_group=indexer,topic=logs"]
+	assert.False(t, exists)
+	for key := range byKey {
+		assert.NotContains(t, key, "console-consumer-1")
+	}
+
+	for _, broker := range cluster {
+		assert.True(t, broker.closed)
+	}
+}
+
+func TestKafkaClusterMetricsWhitelists(t *testing.T) {
+	defer withFakeKafkaCluster(fakeKafkaCluster())()
+
+	k := getTestKafka()
+	k.Configure(map[string]interface{}{
+		"brokers":         []interface{}{"kafka-2"},
+		"groupsWhitelist": []interface{}{"archiver"},
+		"topicsWhitelist": []interface{}{"__consumer_offsets", "events"},
+	})
+	byKey := metricsBySeriesKey(k.clusterMetrics())
+
+	assert.Equal(t, 1.0, byKey["kafka.topic.partitions|topic=__consumer_offsets"].Value)
+	_, exists := byKey["kafka.topic.partitions|topic=logs"]
+	assert.False(t, exists)
+	assert.Equal(t, 0.0, byKey["kafka.consumer_group.lag_total|consumer_group=archiver,topic=events"].Value)
+	for key := range byKey {
+		assert.NotContains(t, key, "consumer_group=indexer")
+	}
+}
+
+func TestKafkaISRShrinks(t *testing.T) {
+	cluster := fakeKafkaCluster()
+	defer withFakeKafkaCluster(cluster)()
+
+	k := getTestKafka()
+	k.Configure(map[string]interface{}{"brokers": []interface{}{"kafka-1"}})
+	shrinks := func() float64 {
+		return metricsBySeriesKey(k.clusterMetrics())["kafka.topic.isr_shrinks|topic=events"].Value
+	}
+	setISR := func(partition int, isr []int32) {
+		for _, broker := range cluster {
+			broker.metadata.Topics[0].Partitions[partition].ISR = isr
+		}
+	}
+
+	assert.Equal(t, 0.0, shrinks())
+	setISR(0, []int32{1})
+	setISR(1, []int32{2})
+	assert.Equal(t, 2.0, shrinks())
+	assert.Equal(t, 2.0, shrinks())
+	setISR(0, []int32{1, 2})
+	assert.Equal(t, 2.0, shrinks())
+	setISR(0, []int32{2})
+	assert.Equal(t, 3.0, shrinks())
+}
+
+func TestKafkaClusterUnreachable(t *testing.T) {
+	defer withFakeKafkaCluster(map[string]*fakeKafkaBroker{})()
+
+	k := getTestKafka()
+	assert.Nil(t, k.clusterMetrics())
+}
+
+func TestKafkaCollect(t *testing.T) {
+	defer withFakeKafkaCluster(fakeKafkaCluster())()
+
+	c := make(chan metric.Metric)
+	k := newKafka(c, 10, l.WithField("testing", "kafka")).(*Kafka)
+	k.Configure(map[string]interface{}{"brokers": []interface{}{"kafka-1"}})
+	go k.Collect()
+
+	m := <-c
+	assert.Equal(t, "kafka.brokers", m.Name)
+	assert.Equal(t, 2.0, m.Value)
+}
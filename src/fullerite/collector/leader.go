@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/util"
+
+	"sync/atomic"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const zkDefaultTimeout = 5 * time.Second
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	isLocalHost     = util.IsLocalHost
+	zooKeeperLeader = util.ZooKeeperLeader
+)
+
+// leaderElection finds the leader of a Mesos, Marathon or Chronos cluster
+// on each collection and tells whether this host should scrape it. The
+// leader is read from ZooKeeper when zkServers and zkPath are configured,
+// from the cluster API otherwise.
+type leaderElection struct {
+	zkServers []string
+	zkPath    string
+	zkTimeout time.Duration
+	// remote makes this host scrape the leader wherever it runs. It is
+	// meant to be enabled on a single designated host.
+	remote bool
+
+	leader atomic.Value
+}
+
+// configure reads the zkServers, zkPath and scrapeLeaderRemotely options
+func (e *leaderElection) configure(configMap map[string]interface{}) {
+	e.zkTimeout = zkDefaultTimeout
+	if servers, exists := configMap["zkServers"]; exists {
+		e.zkServers = config.GetAsSlice(servers)
+	}
+	if path, exists := configMap["zkPath"]; exists {
+		e.zkPath = path.(string)
+	}
+	if remote, exists := configMap["scrapeLeaderRemotely"]; exists {
+		e.remote = config.GetAsBool(remote, false)
+	}
+}
+
+// resolve returns the current leader and whether this host should scrape
+// it, fromAPI asks the cluster API for the leader
+func (e *leaderElection) resolve(fromAPI func() (string, error), log *l.Entry) (string, bool, error) {
+	var leader string
+	var err error
+	if len(e.zkServers) > 0 && e.zkPath != "" {
+		leader, err = zooKeeperLeader(e.zkServers, e.zkPath, e.zkTimeout)
+	} else {
+		leader, err = fromAPI()
+	}
+	if err != nil {
+		return "", false, err
+	}
+	e.leader.Store(leader)
+
+	if e.remote {
+		return leader, true, nil
+	}
+	local, err := isLocalHost(leader, log)
+	return leader, local, err
+}
+
+// current returns the leader found by the last resolve
+func (e *leaderElection) current() string {
+	leader, _ := e.leader.Load().(string)
+	return leader
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderElectionConfigure(t *testing.T) {
+	var e leaderElection
+	e.configure(map[string]interface{}{
+		"zkServers":            []interface{}{"zk1:2181", "zk2:2181"},
+		"zkPath":               "/mesos",
+		"scrapeLeaderRemotely": "true",
+	})
+
+	assert.Equal(t, []string{"zk1:2181", "zk2:2181"}, e.zkServers)
+	assert.Equal(t, "/mesos", e.zkPath)
+	assert.Equal(t, zkDefaultTimeout, e.zkTimeout)
+	assert.True(t, e.remote)
+}
+
+func TestLeaderElectionResolve(t *testing.T) {
+	oldIsLocalHost := isLocalHost
+	oldZooKeeperLeader := zooKeeperLeader
+	defer func() {
+		isLocalHost = oldIsLocalHost
+		zooKeeperLeader = oldZooKeeperLeader
+	}()
+
+	isLocalHost = func(host string, log *l.Entry) (bool, error) { return host == "local:5050", nil }
+	zooKeeperLeader = func(servers []string, path string, timeout time.Duration) (string, error) {
+		return "zkleader:5050", nil
+	}
+	fromAPI := func(leader string, err error) func() (string, error) {
+		return func() (string, error) { return leader, err }
+	}
+
+	tests := []struct {
+		configMap      map[string]interface{}
+		fromAPI        func() (string, error)
+		expectedLeader string
+		expectedScrape bool
+		msg            string
+	}{
+		{map[string]interface{}{}, fromAPI("local:5050", nil), "local:5050", true, "Should scrape when this host is the leader"},
+		{map[string]interface{}{}, fromAPI("other:5050", nil), "other:5050", false, "Should not scrape another leader"},
+		{map[string]interface{}{"scrapeLeaderRemotely": true}, fromAPI("other:5050", nil), "other:5050", true, "Should scrape another leader remotely"},
+		{map[string]interface{}{"zkServers": []string{"zk1:2181"}, "zkPath": "/mesos"}, fromAPI("local:5050", nil), "zkleader:5050", false, "Should read the leader from ZooKeeper"},
+		{map[string]interface{}{}, fromAPI("", fmt.Errorf("down")), "", false, "Should not scrape when there is no leader"},
+	}
+
+	for _, test := range tests {
+		var e leaderElection
+		e.configure(test.configMap)
+
+		leader, scrape, err := e.resolve(test.fromAPI, defaultLog)
+
+		assert.Equal(t, test.expectedLeader, leader, test.msg)
+		assert.Equal(t, test.expectedScrape, scrape, test.msg)
+		if test.expectedLeader == "" {
+			assert.NotNil(t, err, test.msg)
+		} else {
+			assert.Equal(t, test.expectedLeader, e.current(), test.msg)
+		}
+	}
+}
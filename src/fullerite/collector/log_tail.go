@@ -0,0 +1,444 @@
+package collector
+
+import (
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// The types of the log patterns
+const (
+	logPatternCounter = "counter"
+	logPatternGauge   = "gauge"
+	logPatternTimer   = "timer"
+)
+
+var (
+	// The grok patterns which can be used in the patterns as %{SYNTAX} or
+	// %{SYNTAX:name}, the latter being a named capture
+	grokPatterns = map[string]string{
+		"INT":          `[+-]?\d+`,
+		"NUMBER":       `[+-]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?`,
+		"WORD":         `\b\w+\b`,
+		"NOTSPACE":     `\S+`,
+		"SPACE":        `\s*`,
+		"DATA":         `.*?`,
+		"GREEDYDATA":   `.*`,
+		"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"`,
+		"IPV4":         `(?:\d{1,3}\.){3}\d{1,3}`,
+		"IPV6":         `[0-9A-Fa-f:]*:[0-9A-Fa-f:.]+`,
+		"IP":           `(?:%{IPV6}|%{IPV4})`,
+		"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z.-]*\b`,
+		"IPORHOST":     `(?:%{IP}|%{HOSTNAME})`,
+		"PATH":         `(?:/[^\s]*)+`,
+		"URIPATH":      `/[^\s?#]*`,
+		"HTTPMETHOD":   `\b[A-Z]+\b`,
+		"LOGLEVEL":     `(?:[Tt]race|TRACE|[Dd]ebug|DEBUG|[Ii]nfo|INFO|[Nn]otice|NOTICE|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL)`,
+	}
+	grokReference = regexp.MustCompile(`%\{(\w+)(?::(\w+))?\}`)
+
+	defaultLogTailStateDir = "/var/lib/fullerite"
+)
+
+// defaultLogTailSeriesExpiry is how long a series is reported after the
+// last line matching it, in seconds
+const defaultLogTailSeriesExpiry = 3600
+
+// LogTail collector
+// follows log files across their rotation and derives metrics from their
+// lines matching patterns:
+//   - counter patterns count the lines, as cumulative counters
+//   - gauge patterns report the last value captured
+//   - timer patterns report the distribution of the values captured
+//
+// A pattern is a regex in which the grok patterns, like %{INT:status}, are
+// expanded. Its named captures are dimensions, but for the one holding the
+// value of gauges and timers. The files are globs which should not match
+// the rotated files. How far the files were read is saved in stateFile so
+// that lines are neither missed nor counted twice across restarts, its
+// directory is created if needed. A series is no longer reported once no
+// line matched it for seriesExpiry seconds.
+type LogTail struct {
+	baseCollector
+
+	files         []string
+	patterns      []*logPattern
+	stateFile     string
+	pollInterval  int
+	fromBeginning bool
+	seriesExpiry  time.Duration
+
+	started    bool
+	saveFailed bool
+	mutex      sync.Mutex
+	series     map[string]*logSeries
+	keys       []string
+}
+
+// logPattern derives a metric from the matching lines
+type logPattern struct {
+	name       string
+	metricType string
+	regex      *regexp.Regexp
+	value      string
+	buckets    []float64
+}
+
+// logSeries aggregates the lines matching a pattern with some dimensions
+type logSeries struct {
+	pattern    *logPattern
+	dimensions map[string]string
+	// the count of the counters and the last value of the gauges
+	value float64
+	// the observations of the timers in each bucket, the last one counts
+	// the values above the last bound
+	buckets []float64
+	count   float64
+	sum     float64
+	// when a line last matched
+	updated time.Time
+}
+
+func init() {
+	RegisterCollector("LogTail", newLogTail)
+}
+
+func newLogTail(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	t := new(LogTail)
+
+	t.log = log
+	t.channel = channel
+	t.interval = initialInterval
+
+	t.name = "LogTail"
+	t.pollInterval = 1
+	t.seriesExpiry = defaultLogTailSeriesExpiry * time.Second
+	t.series = map[string]*logSeries{}
+	t.SetCollectorType("listener")
+	return t
+}
+
+// Configure takes a dictionary of values with which the handler can configure itself
+func (t *LogTail) Configure(configMap map[string]interface{}) {
+	if files, exists := configMap["files"]; exists {
+		t.files = config.GetAsSlice(files)
+	} else {
+		t.log.Warn("Required config does not exist for LogTail: files")
+	}
+
+	if stateFile, exists := configMap["stateFile"]; exists {
+		t.stateFile = stateFile.(string)
+	} else {
+		name := t.CanonicalName()
+		if name == "" {
+			name = t.Name()
+		}
+		t.stateFile = filepath.Join(defaultLogTailStateDir, strings.Replace(name, " ", "_", -1)+".offsets")
+	}
+
+	if pollInterval, exists := configMap["pollInterval"]; exists {
+		if interval := config.GetAsInt(pollInterval, t.pollInterval); interval > 0 {
+			t.pollInterval = interval
+		} else {
+			t.log.Warn("Ignoring pollInterval ", pollInterval, ", it must be positive")
+		}
+	}
+
+	if seriesExpiry, exists := configMap["seriesExpiry"]; exists {
+		t.seriesExpiry = time.Duration(config.GetAsInt(seriesExpiry, defaultLogTailSeriesExpiry)) * time.Second
+	}
+
+	if fromBeginning, exists := configMap["fromBeginning"]; exists {
+		t.fromBeginning = config.GetAsBool(fromBeginning, false)
+	}
+
+	if patterns, exists := configMap["patterns"]; exists {
+		list, ok := patterns.([]interface{})
+		if !ok {
+			t.log.Warn("Invalid format of config entry `patterns'")
+		}
+		for _, item := range list {
+			pattern, err := parseLogPattern(item)
+			if err != nil {
+				t.log.Warn("Ignoring pattern: ", err)
+				continue
+			}
+			t.patterns = append(t.patterns, pattern)
+		}
+	}
+
+	t.configureCommonParams(configMap)
+}
+
+// parseLogPattern reads a pattern like
+// {"name": "requests.duration", "type": "timer", "match": "took %{NUMBER:ms}ms", "value": "ms"}
+func parseLogPattern(item interface{}) (*logPattern, error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid format %v", item)
+	}
+
+	pattern := &logPattern{metricType: logPatternCounter, buckets: metric.LatencyBuckets[:]}
+	pattern.name, _ = fields["name"].(string)
+	if pattern.name == "" {
+		return nil, fmt.Errorf("no name in %v", item)
+	}
+	if metricType, exists := fields["type"]; exists {
+		pattern.metricType, _ = metricType.(string)
+	}
+	pattern.value, _ = fields["value"].(string)
+
+	match, _ := fields["match"].(string)
+	expanded, err := expandGrok(match)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", pattern.name, err)
+	}
+	if pattern.regex, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("%s: %s", pattern.name, err)
+	}
+
+	switch pattern.metricType {
+	case logPatternCounter:
+	case logPatternGauge, logPatternTimer:
+		found := false
+		for _, name := range pattern.regex.SubexpNames() {
+			found = found || (name != "" && name == pattern.value)
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no capture named %q for the value", pattern.name, pattern.value)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown type %s", pattern.name, pattern.metricType)
+	}
+
+	if buckets, exists := fields["buckets"]; exists {
+		list, _ := buckets.([]interface{})
+		pattern.buckets = make([]float64, 0, len(list))
+		for _, bound := range list {
+			pattern.buckets = append(pattern.buckets, config.GetAsFloat(bound, 0))
+		}
+		sort.Float64s(pattern.buckets)
+	}
+	return pattern, nil
+}
+
+// expandGrok replaces the grok patterns of a regex by their definition
+func expandGrok(pattern string) (string, error) {
+	var err error
+	// the grok patterns can reference others
+	for depth := 0; depth < 5 && grokReference.MatchString(pattern); depth++ {
+		pattern = grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+			parts := grokReference.FindStringSubmatch(reference)
+			definition, exists := grokPatterns[parts[1]]
+			if !exists {
+				err = fmt.Errorf("unknown grok pattern %s", parts[1])
+				return reference
+			}
+			if parts[2] != "" {
+				return "(?P<" + parts[2] + ">" + definition + ")"
+			}
+			return "(?:" + definition + ")"
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return pattern, nil
+}
+
+// Collect starts following the files then emits the metrics of the lines
+// read so far
+func (t *LogTail) Collect() {
+	if !t.started {
+		t.started = true
+		go t.tail()
+	}
+
+	for _, m := range t.metrics() {
+		t.Channel() <- m
+	}
+}
+
+func (t *LogTail) tail() {
+	offsets, err := util.LoadTailOffsets(t.stateFile)
+	if err != nil {
+		t.log.Warn("Error while loading the offsets: ", err)
+	}
+	if t.stateFile != "" {
+		if err := os.MkdirAll(filepath.Dir(t.stateFile), 0755); err != nil {
+			t.log.Warn("Error while creating the directory of the state file: ", err)
+		}
+	}
+	tailer := util.NewTailer(offsets)
+	tailer.FromBeginning = t.fromBeginning
+
+	ticker := time.NewTicker(time.Duration(t.pollInterval) * time.Second)
+	defer ticker.Stop()
+	saved := map[string]util.TailOffset{}
+	for {
+		saved = t.poll(tailer, saved)
+		<-ticker.C
+	}
+}
+
+// poll reads the new lines of the files, forgets the files no longer
+// matching the globs and saves the offsets when they changed
+func (t *LogTail) poll(tailer *util.Tailer, saved map[string]util.TailOffset) map[string]util.TailOffset {
+	matched := map[string]bool{}
+	for _, file := range t.files {
+		paths, err := filepath.Glob(file)
+		if err != nil || len(paths) == 0 {
+			paths = []string{file}
+		}
+		for _, path := range paths {
+			matched[path] = true
+			t.pollPath(tailer, path)
+		}
+	}
+	for _, path := range tailer.Paths() {
+		if !matched[path] {
+			// read the lines written before the file went away
+			t.pollPath(tailer, path)
+			tailer.Forget(path)
+		}
+	}
+
+	offsets := tailer.Offsets()
+	if t.stateFile == "" || reflect.DeepEqual(offsets, saved) {
+		return saved
+	}
+	if err := util.SaveTailOffsets(t.stateFile, offsets); err != nil {
+		// warn once until the offsets can be saved again
+		if !t.saveFailed {
+			t.log.Warn("Error while saving the offsets: ", err)
+		}
+		t.saveFailed = true
+		return saved
+	}
+	t.saveFailed = false
+	return offsets
+}
+
+func (t *LogTail) pollPath(tailer *util.Tailer, path string) {
+	if err := tailer.Poll(path, t.parseLine); err != nil {
+		if os.IsNotExist(err) {
+			t.log.Debug("Error while reading ", path, ": ", err)
+		} else {
+			t.log.Error("Error while reading ", path, ": ", err)
+		}
+	}
+}
+
+// parseLine updates the series of the patterns matching a line
+func (t *LogTail) parseLine(line string) {
+	for _, pattern := range t.patterns {
+		match := pattern.regex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		dimensions := map[string]string{}
+		value, hasValue := 0.0, false
+		for i, name := range pattern.regex.SubexpNames() {
+			if i == 0 || name == "" || match[i] == "" {
+				continue
+			}
+			if name == pattern.value {
+				parsed, err := strconv.ParseFloat(match[i], 64)
+				if err != nil {
+					t.log.Debug("Invalid value ", match[i], " for ", pattern.name)
+					continue
+				}
+				value, hasValue = parsed, true
+			} else {
+				dimensions[name] = match[i]
+			}
+		}
+		if pattern.metricType == logPatternCounter || hasValue {
+			t.observe(pattern, dimensions, value)
+		}
+	}
+}
+
+func (t *LogTail) observe(pattern *logPattern, dimensions map[string]string, value float64) {
+	key := metric.New(pattern.name)
+	key.AddDimensions(dimensions)
+	seriesName := seriesKey(&key)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	series, exists := t.series[seriesName]
+	if !exists {
+		series = &logSeries{pattern: pattern, dimensions: dimensions}
+		if pattern.metricType == logPatternTimer {
+			series.buckets = make([]float64, len(pattern.buckets)+1)
+		}
+		t.series[seriesName] = series
+		t.keys = append(t.keys, seriesName)
+	}
+	series.updated = time.Now()
+
+	switch pattern.metricType {
+	case logPatternCounter:
+		series.value++
+	case logPatternGauge:
+		series.value = value
+	case logPatternTimer:
+		series.buckets[sort.SearchFloat64s(pattern.buckets, value)]++
+		series.count++
+		series.sum += value
+	}
+}
+
+// metrics returns the series of the lines read since the start, but for
+// the expired ones which are forgotten
+func (t *LogTail) metrics() []metric.Metric {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	metrics := make([]metric.Metric, 0, len(t.keys))
+	keys := t.keys[:0]
+	for _, key := range t.keys {
+		series := t.series[key]
+		if now.Sub(series.updated) > t.seriesExpiry {
+			delete(t.series, key)
+			continue
+		}
+		keys = append(keys, key)
+
+		var m metric.Metric
+		switch series.pattern.metricType {
+		case logPatternCounter:
+			m = metric.WithValue(series.pattern.name, series.value)
+			m.MetricType = metric.CumulativeCounter
+		case logPatternGauge:
+			m = metric.WithValue(series.pattern.name, series.value)
+		case logPatternTimer:
+			distribution := metric.Distribution{Count: series.count, Sum: series.sum}
+			cumulative := 0.0
+			for i, bound := range series.pattern.buckets {
+				cumulative += series.buckets[i]
+				distribution.Buckets = append(distribution.Buckets, metric.DistributionBucket{UpperBound: bound, Count: cumulative})
+			}
+			m = metric.WithDistribution(series.pattern.name, distribution)
+		}
+		m.AddDimensions(series.dimensions)
+		metrics = append(metrics, m)
+	}
+	t.keys = keys
+	return metrics
+}
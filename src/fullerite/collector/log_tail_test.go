@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"fullerite/metric"
+	"fullerite/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestLogTail(configMap map[string]interface{}) *LogTail {
+	t := newLogTail(make(chan metric.Metric), 10, l.WithField("testing", "log_tail")).(*LogTail)
+	t.Configure(configMap)
+	return t
+}
+
+func logTailPatterns() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"name":  "app.log_lines",
+			"match": `^%{LOGLEVEL:level} `,
+		},
+		map[string]interface{}{
+			"name":  "app.queue_size",
+			"type":  "gauge",
+			"match": `queue=(?P<queue>\w+) size=%{INT:size}`,
+			"value": "size",
+		},
+		map[string]interface{}{
+			"name":    "app.request_time",
+			"type":    "timer",
+			"match":   `%{HTTPMETHOD:method} %{URIPATH} took %{NUMBER:ms}ms`,
+			"value":   "ms",
+			"buckets": []interface{}{100.0, 10.0, "1000"},
+		},
+	}
+}
+
+func TestLogTailConfigure(t *testing.T) {
+	lt := getTestLogTail(map[string]interface{}{
+		"interval":      30,
+		"files":         []interface{}{"/var/log/app/*.log"},
+		"pollInterval":  "5",
+		"fromBeginning": true,
+		"patterns":      logTailPatterns(),
+	})
+
+	assert.Equal(t, "listener", lt.CollectorType())
+	assert.Equal(t, 30, lt.Interval())
+	assert.Equal(t, []string{"/var/log/app/*.log"}, lt.files)
+	assert.Equal(t, "/var/lib/fullerite/LogTail.offsets", lt.stateFile)
+	assert.Equal(t, 5, lt.pollInterval)
+	assert.Equal(t, time.Hour, lt.seriesExpiry)
+	assert.True(t, lt.fromBeginning)
+	assert.Equal(t, 3, len(lt.patterns))
+	assert.Equal(t, "timer", lt.patterns[2].metricType)
+	assert.Equal(t, []float64{10, 100, 1000}, lt.patterns[2].buckets)
+	assert.Equal(t, metric.LatencyBuckets[:], lt.patterns[0].buckets)
+}
+
+func TestLogTailConfigureInvalidPollInterval(t *testing.T) {
+	for _, pollInterval := range []interface{}{0, "-1", "soon"} {
+		lt := getTestLogTail(map[string]interface{}{"pollInterval": pollInterval, "seriesExpiry": 60})
+		assert.Equal(t, 1, lt.pollInterval, "pollInterval %v", pollInterval)
+		assert.Equal(t, time.Minute, lt.seriesExpiry)
+	}
+}
+
+func TestLogTailConfigureStateFile(t *testing.T) {
+	lt := newLogTail(make(chan metric.Metric), 10, l.WithField("testing", "log_tail")).(*LogTail)
+	lt.SetCanonicalName("LogTail nginx")
+	lt.Configure(map[string]interface{}{})
+	assert.Equal(t, "/var/lib/fullerite/LogTail_nginx.offsets", lt.stateFile)
+
+	lt = getTestLogTail(map[string]interface{}{"stateFile": ""})
+	assert.Equal(t, "", lt.stateFile)
+}
+
+func TestLogTailConfigureInvalidPatterns(t *testing.T) {
+	lt := getTestLogTail(map[string]interface{}{
+		"patterns": []interface{}{
+			"not a map",
+			map[string]interface{}{"match": "no name"},
+			map[string]interface{}{"name": "bad.regex", "match": "(unclosed"},
+			map[string]interface{}{"name": "bad.grok", "match": "%{NOPE:x}"},
+			map[string]interface{}{"name": "bad.type", "type": "meter", "match": "x"},
+			map[string]interface{}{"name": "no.value", "type": "gauge", "match": "size=%{INT:size}"},
+			map[string]interface{}{"name": "good", "match": "x"},
+		},
+	})
+
+	assert.Equal(t, 1, len(lt.patterns))
+	assert.Equal(t, "good", lt.patterns[0].name)
+}
+
+func TestExpandGrok(t *testing.T) {
+	expanded, err := expandGrok(`^%{IPORHOST:client} %{INT}$`)
+	assert.Nil(t, err)
+	re := regexp.MustCompile(expanded)
+	assert.Equal(t, []string{"", "client"}, re.SubexpNames())
+	for _, client := range []string{"10.0.0.1", "::1", "fe80::1", "web-1.example.com"} {
+		match := re.FindStringSubmatch(client + " 42")
+		if assert.NotNil(t, match, client) {
+			assert.Equal(t, client, match[1])
+		}
+	}
+	assert.False(t, re.MatchString("10.0.0.1 forty-two"))
+
+	_, err = expandGrok(`%{UNKNOWN}`)
+	assert.NotNil(t, err)
+}
+
+func TestLogTailParseLines(t *testing.T) {
+	lt := getTestLogTail(map[string]interface{}{"patterns": logTailPatterns()})
+
+	for _, line := range []string{
+		"INFO queue=mail size=10",
+		"ERROR GET /users took 5ms",
+		"ERROR GET /users took 50ms",
+		"WARN GET /users took 5000ms",
+		"POST /users took 12.5ms",
+		"INFO queue=mail size=12",
+		"no match here",
+	} {
+		lt.parseLine(line)
+	}
+
+	metrics := lt.metrics()
+	assert.Equal(t, 6, len(metrics))
+
+	byKey := map[string]metric.Metric{}
+	for i := range metrics {
+		byKey[seriesKey(&metrics[i])] = metrics[i]
+	}
+
+	info := byKey["app.log_lines|level=INFO"]
+	assert.Equal(t, 2.0, info.Value)
+	assert.Equal(t, metric.CumulativeCounter, info.MetricType)
+	assert.Equal(t, 2.0, byKey["app.log_lines|level=ERROR"].Value)
+	assert.Equal(t, 1.0, byKey["app.log_lines|level=WARN"].Value)
+
+	queue := byKey["app.queue_size|queue=mail"]
+	assert.Equal(t, 12.0, queue.Value)
+	assert.Equal(t, metric.Gauge, queue.MetricType)
+
+	get := byKey["app.request_time|method=GET"]
+	assert.Equal(t, metric.Histogram, get.MetricType)
+	assert.Equal(t, &metric.Distribution{
+		Count: 3,
+		Sum:   5055,
+		Buckets: []metric.DistributionBucket{
+			{UpperBound: 10, Count: 1},
+			{UpperBound: 100, Count: 2},
+			{UpperBound: 1000, Count: 2},
+		},
+	}, get.Distribution)
+	assert.Equal(t, 12.5, byKey["app.request_time|method=POST"].Distribution.Sum)
+}
+
+func TestLogTailPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtail")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	stateFile := filepath.Join(dir, "offsets")
+	ioutil.WriteFile(logFile, []byte("INFO before the start\n"), 0644)
+
+	configMap := map[string]interface{}{
+		"files":     []interface{}{filepath.Join(dir, "*.log"), filepath.Join(dir, "missing.log")},
+		"stateFile": stateFile,
+		"patterns":  logTailPatterns(),
+	}
+	lt := getTestLogTail(configMap)
+	tailer := util.NewTailer(nil)
+	saved := lt.poll(tailer, nil)
+	assert.Equal(t, 0, len(lt.metrics()))
+
+	appendToLog := func(contents string) {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND, 0644)
+		assert.Nil(t, err)
+		file.WriteString(contents)
+		file.Close()
+	}
+	appendToLog("INFO first\n")
+	saved = lt.poll(tailer, saved)
+	metrics := lt.metrics()
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, 1.0, metrics[0].Value)
+	tailer.Close()
+
+	// the lines written while stopped are read after the restart, once
+	appendToLog("INFO while stopped\n")
+	offsets, err := util.LoadTailOffsets(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, saved, offsets)
+
+	lt = getTestLogTail(configMap)
+	tailer = util.NewTailer(offsets)
+	defer tailer.Close()
+	lt.poll(tailer, offsets)
+	metrics = lt.metrics()
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, 1.0, metrics[0].Value)
+}
+
+func TestLogTailSeriesExpiry(t *testing.T) {
+	lt := getTestLogTail(map[string]interface{}{"patterns": logTailPatterns(), "seriesExpiry": 60})
+	lt.parseLine("INFO queue=mail size=10")
+	lt.parseLine("INFO queue=sms size=10")
+	lt.series["app.queue_size|queue=mail"].updated = time.Now().Add(-2 * time.Minute)
+
+	metrics := lt.metrics()
+	assert.Equal(t, 2, len(metrics))
+	assert.Equal(t, 2, len(lt.series))
+	assert.Equal(t, []string{"app.log_lines|level=INFO", "app.queue_size|queue=sms"}, lt.keys)
+
+	// an expired series starts over
+	lt.parseLine("INFO queue=mail size=12")
+	assert.Equal(t, 12.0, lt.series["app.queue_size|queue=mail"].value)
+	assert.Equal(t, 3, len(lt.metrics()))
+}
+
+func TestLogTailPollForgetsRemovedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtail")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(logFile, []byte(""), 0644)
+
+	lt := getTestLogTail(map[string]interface{}{
+		"files":     []interface{}{filepath.Join(dir, "*.log")},
+		"stateFile": "",
+		"patterns":  logTailPatterns(),
+	})
+	tailer := util.NewTailer(nil)
+	defer tailer.Close()
+	lt.poll(tailer, nil)
+	assert.Equal(t, []string{logFile}, tailer.Paths())
+
+	// the lines written before the removal are still read
+	file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	file.WriteString("INFO last line\n")
+	file.Close()
+	assert.Nil(t, os.Remove(logFile))
+
+	lt.poll(tailer, nil)
+	assert.Equal(t, []string{}, tailer.Paths())
+	assert.Equal(t, 1, len(lt.metrics()))
+}
+
+func TestLogTailPollStateDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtail")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(logFile, []byte(""), 0644)
+
+	lt := getTestLogTail(map[string]interface{}{
+		"files":     []interface{}{logFile},
+		"stateFile": filepath.Join(dir, "missing", "offsets"),
+		"patterns":  logTailPatterns(),
+	})
+	tailer := util.NewTailer(nil)
+	defer tailer.Close()
+	lt.poll(tailer, nil)
+	assert.True(t, lt.saveFailed)
+
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "missing"), 0755))
+	saved := lt.poll(tailer, nil)
+	assert.False(t, lt.saveFailed)
+	assert.Equal(t, tailer.Offsets(), saved)
+}
+
+func TestLogTailCollect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtail")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := make(chan metric.Metric)
+	lt := newLogTail(c, 10, l.WithField("testing", "log_tail")).(*LogTail)
+	lt.Configure(map[string]interface{}{
+		"files":     []interface{}{filepath.Join(dir, "app.log")},
+		"stateFile": filepath.Join(dir, "offsets"),
+		"patterns":  logTailPatterns(),
+	})
+	lt.parseLine("INFO queue=mail size=3")
+
+	go lt.Collect()
+	received := []metric.Metric{<-c, <-c}
+	assert.Equal(t, "app.log_lines", received[0].Name)
+	assert.Equal(t, "app.queue_size", received[1].Name)
+	assert.True(t, lt.started)
+}
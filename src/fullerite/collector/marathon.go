@@ -1,9 +1,11 @@
 package collector
 
 // Collects metrics produced by marathon. Simply pulls /metrics from the marathon
-//  leader and sends all well-formated metrics
+//  leader and sends all well-formated metrics, followed by the health of
+//  each app when collectApps is set
 
 import (
+	"encoding/json"
 	"fmt"
 	"fullerite/config"
 	"fullerite/dropwizard"
@@ -18,8 +20,13 @@ import (
 var (
 	sendMarathonMetrics = (*MarathonStats).sendMarathonMetrics
 	getMarathonMetrics  = (*MarathonStats).getMarathonMetrics
+	getMarathonApps     = (*MarathonStats).getMarathonApps
 
-	getMarathonMetricsURL = func(host string) string { return fmt.Sprintf("http://%s/metrics", host) }
+	getMarathonMetricsURL     = func(host string) string { return fmt.Sprintf("http://%s/metrics", host) }
+	getMarathonAppsURL        = func(host string) string { return fmt.Sprintf("http://%s/v2/apps?embed=apps.counts", host) }
+	getMarathonDeploymentsURL = func(host string) string { return fmt.Sprintf("http://%s/v2/deployments", host) }
+
+	marathonNow = time.Now
 )
 
 const (
@@ -32,6 +39,10 @@ type MarathonStats struct {
 	client          http.Client
 	marathonHost    string
 	extraDimensions map[string]string
+	election        leaderElection
+	options         dropwizard.Options
+	// collectApps adds the task counts and the deployments of each app
+	collectApps bool
 }
 
 func init() {
@@ -45,15 +56,18 @@ func newMarathonStats(channel chan metric.Metric, initialInterval int, log *l.En
 	m.channel = channel
 	m.interval = initialInterval
 	m.name = "MarathonStats"
+	m.asyncScrape = true
 	m.client = http.Client{Timeout: marathonGetTimeout}
 	m.extraDimensions = make(map[string]string)
 
 	return m
 }
 
-// Configure just calls the default configure
+// Configure reads the marathon host and the leader election options
 func (m *MarathonStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
+	m.election.configure(configMap)
+	configureDropwizardOptions(configMap, &m.options)
 
 	c := config.GetAsMap(configMap)
 	if marathonHost, exists := c["marathonHost"]; exists && len(marathonHost) > 0 {
@@ -68,22 +82,45 @@ func (m *MarathonStats) Configure(configMap map[string]interface{}) {
 			m.extraDimensions[dim] = value
 		}
 	}
+
+	if collectApps, exists := configMap["collectApps"]; exists {
+		m.collectApps = config.GetAsBool(collectApps, false)
+	}
 }
 
-// Collect compares the leader against this hosts's hostaname and sends metrics if this is the leader
-func (m *MarathonStats) Collect() {
-	// Non-marathon-leaders forward requests to the leader, so only the leader's metrics matter
-	if leader, err := util.IsLeader(m.marathonHost, "v2/leader", m.client, m.log); leader && err == nil {
-		go sendMarathonMetrics(m)
-	} else if err != nil {
-		m.log.Error("Error finding leader: ", err)
-	} else {
-		m.log.Debug("Not the leader, not sending metrics")
+// Targets returns the marathon metrics endpoint scraped by the collector
+func (m *MarathonStats) Targets() []string {
+	if leader := m.election.current(); m.election.remote && leader != "" {
+		return []string{getMarathonMetricsURL(leader)}
 	}
+	return []string{getMarathonMetricsURL(m.marathonHost)}
 }
 
-func (m *MarathonStats) sendMarathonMetrics() {
-	metrics := getMarathonMetrics(m)
+// Collect finds the leader and sends its metrics if this host is the
+// leader, or if it is the designated host scraping the leader remotely
+func (m *MarathonStats) Collect() {
+	m.scrapeInBackground(func() {
+		leader, scrape, err := m.election.resolve(func() (string, error) {
+			return util.LeaderOf(m.marathonHost, "v2/leader", m.client)
+		}, m.log)
+		if err != nil {
+			m.log.Error("Error finding leader: ", err)
+		} else if !scrape {
+			m.log.Debug("Not the leader, not sending metrics")
+		} else if m.election.remote {
+			sendMarathonMetrics(m, leader)
+		} else {
+			// Non-marathon-leaders forward requests to the leader, so only the leader's metrics matter
+			sendMarathonMetrics(m, m.marathonHost)
+		}
+	})
+}
+
+func (m *MarathonStats) sendMarathonMetrics(host string) {
+	metrics := getMarathonMetrics(m, host)
+	if m.collectApps {
+		metrics = append(metrics, getMarathonApps(m, host)...)
+	}
 	for _, metric := range metrics {
 		if !m.ContainsBlacklistedDimension(metric.Dimensions) {
 			m.Channel() <- metric
@@ -91,8 +128,8 @@ func (m *MarathonStats) sendMarathonMetrics() {
 	}
 }
 
-func (m *MarathonStats) getMarathonMetrics() []metric.Metric {
-	url := getMarathonMetricsURL(m.marathonHost)
+func (m *MarathonStats) getMarathonMetrics(host string) []metric.Metric {
+	url := getMarathonMetricsURL(host)
 
 	contents, err := util.GetWrapper(url, m.client)
 	if err != nil {
@@ -100,7 +137,7 @@ func (m *MarathonStats) getMarathonMetrics() []metric.Metric {
 		return nil
 	}
 
-	metrics, err := dropwizard.Parse(contents, "java-1.1", true)
+	metrics, err := dropwizard.ParseWithOptions(contents, "java-1.1", true, m.options)
 
 	if err != nil {
 		m.log.Error("Unable to decode marathon metrics JSON: ", err)
@@ -115,3 +152,86 @@ func (m *MarathonStats) getMarathonMetrics() []metric.Metric {
 
 	return metrics
 }
+
+// marathonApp is an app of /v2/apps with its task counts embedded
+type marathonApp struct {
+	ID             string  `json:"id"`
+	Instances      float64 `json:"instances"`
+	TasksRunning   float64 `json:"tasksRunning"`
+	TasksStaged    float64 `json:"tasksStaged"`
+	TasksHealthy   float64 `json:"tasksHealthy"`
+	TasksUnhealthy float64 `json:"tasksUnhealthy"`
+}
+
+// marathonDeployment is a deployment in progress of /v2/deployments, its
+// version is the time it started at
+type marathonDeployment struct {
+	Version      string   `json:"version"`
+	AffectedApps []string `json:"affectedApps"`
+}
+
+// getMarathonApps returns the instances and the task counts of each app,
+// and for the apps being deployed how long the deployment has been running
+func (m *MarathonStats) getMarathonApps(host string) []metric.Metric {
+	contents, err := util.GetWrapper(getMarathonAppsURL(host), m.client)
+	if err != nil {
+		m.log.Error("Could not load apps from marathon: ", err.Error())
+		return nil
+	}
+	var apps struct {
+		Apps []marathonApp `json:"apps"`
+	}
+	if err := json.Unmarshal(contents, &apps); err != nil {
+		m.log.Error("Unable to decode marathon apps JSON: ", err)
+		return nil
+	}
+
+	metrics := []metric.Metric{}
+	for _, app := range apps.Apps {
+		for name, value := range map[string]float64{
+			"instances":       app.Instances,
+			"tasks_running":   app.TasksRunning,
+			"tasks_staged":    app.TasksStaged,
+			"tasks_healthy":   app.TasksHealthy,
+			"tasks_unhealthy": app.TasksUnhealthy,
+		} {
+			s := metric.WithValue("marathon.app."+name, value)
+			s.AddDimension("app_id", app.ID)
+			metrics = append(metrics, s)
+		}
+	}
+
+	contents, err = util.GetWrapper(getMarathonDeploymentsURL(host), m.client)
+	if err != nil {
+		m.log.Error("Could not load deployments from marathon: ", err.Error())
+		return m.addMarathonDimensions(metrics)
+	}
+	var deployments []marathonDeployment
+	if err := json.Unmarshal(contents, &deployments); err != nil {
+		m.log.Error("Unable to decode marathon deployments JSON: ", err)
+		return m.addMarathonDimensions(metrics)
+	}
+
+	metrics = append(metrics, metric.WithValue("marathon.deployments", float64(len(deployments))))
+	for _, deployment := range deployments {
+		started, err := time.Parse(time.RFC3339Nano, deployment.Version)
+		if err != nil {
+			m.log.Warn("Invalid version of marathon deployment: ", deployment.Version)
+			continue
+		}
+		for _, app := range deployment.AffectedApps {
+			s := metric.WithValue("marathon.app.deployment_duration_seconds", marathonNow().Sub(started).Seconds())
+			s.AddDimension("app_id", app)
+			metrics = append(metrics, s)
+		}
+	}
+	return m.addMarathonDimensions(metrics)
+}
+
+func (m *MarathonStats) addMarathonDimensions(metrics []metric.Metric) []metric.Metric {
+	metric.AddToAll(&metrics, map[string]string{
+		"service": "marathon",
+	})
+	metric.AddToAll(&metrics, m.extraDimensions)
+	return metrics
+}
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"fullerite/dropwizard"
 	"fullerite/metric"
 
 	l "github.com/Sirupsen/logrus"
@@ -84,7 +86,7 @@ func TestMarathonStatsGetMarathonMetrics(t *testing.T) {
 		getMarathonMetricsURL = func(ip string) string { return ts.URL }
 
 		sut := newMarathonStats(nil, 10, defaultLog).(*MarathonStats)
-		actual := getMarathonMetrics(sut)
+		actual := getMarathonMetrics(sut, "")
 
 		if test.err {
 			assert.True(t, actual == nil, test.msg)
@@ -102,7 +104,70 @@ func TestMarathonStatsConfigure(t *testing.T) {
 	sut := newMarathonStats(nil, 10, defaultLog).(*MarathonStats)
 	sut.Configure(map[string]interface{}{
 		"marathonHost":    "foobar",
-		"extraDimensions": "{\"cluster\": \"bar\"}"})
+		"extraDimensions": "{\"cluster\": \"bar\"}",
+		"rollupDimension": true,
+		"durationUnit":    "seconds"})
 
 	assert.Equal(t, sut.extraDimensions["cluster"], "bar")
+	assert.Equal(t, dropwizard.Options{RollupDimension: true, DurationUnit: "seconds"}, sut.options)
+}
+
+func TestMarathonStatsGetMarathonApps(t *testing.T) {
+	oldAppsURL := getMarathonAppsURL
+	oldDeploymentsURL := getMarathonDeploymentsURL
+	oldNow := marathonNow
+	defer func() {
+		getMarathonAppsURL = oldAppsURL
+		getMarathonDeploymentsURL = oldDeploymentsURL
+		marathonNow = oldNow
+	}()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/apps":
+			assert.Equal(t, "apps.counts", r.URL.Query().Get("embed"))
+			fmt.Fprint(w, `{"apps": [
+				{"id": "/web", "instances": 3, "tasksRunning": 2, "tasksStaged": 1, "tasksHealthy": 2, "tasksUnhealthy": 0},
+				{"id": "/worker", "instances": 1, "tasksRunning": 1, "tasksStaged": 0, "tasksHealthy": 0, "tasksUnhealthy": 1}
+			]}`)
+		case "/v2/deployments":
+			fmt.Fprint(w, `[{"id": "d1", "version": "2016-10-18T10:00:00.000Z", "affectedApps": ["/web"]}]`)
+		}
+	}))
+	defer ts.Close()
+	getMarathonAppsURL = func(host string) string { return ts.URL + "/v2/apps?embed=apps.counts" }
+	getMarathonDeploymentsURL = func(host string) string { return ts.URL + "/v2/deployments" }
+	marathonNow = func() time.Time { return time.Date(2016, 10, 18, 10, 1, 30, 0, time.UTC) }
+
+	sut := newMarathonStats(nil, 10, defaultLog).(*MarathonStats)
+	sut.Configure(map[string]interface{}{
+		"marathonHost":    "foobar",
+		"extraDimensions": map[string]interface{}{"cluster": "bar"},
+	})
+
+	actual := map[string]float64{}
+	for _, m := range getMarathonApps(sut, "foobar") {
+		assert.Equal(t, "marathon", m.Dimensions["service"])
+		assert.Equal(t, "bar", m.Dimensions["cluster"])
+		actual[m.Name+m.Dimensions["app_id"]] = m.Value
+	}
+
+	assert.Len(t, actual, 12)
+	assert.Equal(t, 3.0, actual["marathon.app.instances/web"])
+	assert.Equal(t, 2.0, actual["marathon.app.tasks_running/web"])
+	assert.Equal(t, 1.0, actual["marathon.app.tasks_staged/web"])
+	assert.Equal(t, 2.0, actual["marathon.app.tasks_healthy/web"])
+	assert.Equal(t, 1.0, actual["marathon.app.tasks_unhealthy/worker"])
+	assert.Equal(t, 1.0, actual["marathon.deployments"])
+	assert.Equal(t, 90.0, actual["marathon.app.deployment_duration_seconds/web"])
+}
+
+func TestMarathonStatsGetMarathonAppsHandleErrors(t *testing.T) {
+	oldAppsURL := getMarathonAppsURL
+	defer func() { getMarathonAppsURL = oldAppsURL }()
+
+	getMarathonAppsURL = func(host string) string { return "" }
+
+	sut := newMarathonStats(nil, 10, defaultLog).(*MarathonStats)
+	assert.Nil(t, getMarathonApps(sut, "foobar"))
 }
@@ -0,0 +1,277 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// memcachedStatser runs the stats commands of the Memcached collector
+type memcachedStatser interface {
+	Stats(args ...string) (map[string]string, error)
+	Close() error
+}
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	dialMemcached = func(network string, addr string, timeout time.Duration) (memcachedStatser, error) {
+		return util.DialMemcached(network, addr, timeout)
+	}
+
+	// The general stats which are not counters
+	memcachedStatsGauges = map[string]bool{
+		"uptime":                true,
+		"max_connections":       true,
+		"curr_connections":      true,
+		"connection_structures": true,
+		"reserved_fds":          true,
+		"threads":               true,
+		"curr_items":            true,
+		"bytes":                 true,
+		"limit_maxbytes":        true,
+		"hash_power_level":      true,
+		"hash_bytes":            true,
+		"hash_is_expanding":     true,
+		"accepting_conns":       true,
+		"slab_global_page_pool": true,
+		"slab_reassign_running": true,
+		"lru_crawler_running":   true,
+		"curr_unfinished_queue": true,
+		"log_watchers":          true,
+	}
+
+	// The general stats which are not reported, like the ids and times of
+	// the server
+	memcachedStatsIgnored = map[string]bool{
+		"pid":          true,
+		"time":         true,
+		"pointer_size": true,
+	}
+
+	// The per slab stats of stats slabs which are not counters
+	memcachedSlabGauges = map[string]bool{
+		"chunk_size":      true,
+		"chunks_per_page": true,
+		"total_pages":     true,
+		"total_chunks":    true,
+		"used_chunks":     true,
+		"free_chunks":     true,
+		"free_chunks_end": true,
+		"mem_requested":   true,
+	}
+
+	// The per slab stats of stats items which are counters
+	memcachedItemsCounters = map[string]bool{
+		"evicted":               true,
+		"evicted_nonzero":       true,
+		"evicted_unfetched":     true,
+		"evicted_active":        true,
+		"expired_unfetched":     true,
+		"outofmemory":           true,
+		"tailrepairs":           true,
+		"reclaimed":             true,
+		"crawler_reclaimed":     true,
+		"crawler_items_checked": true,
+		"lrutail_reflocked":     true,
+		"moves_to_cold":         true,
+		"moves_to_warm":         true,
+		"moves_within_lru":      true,
+		"direct_reclaims":       true,
+		"hits_to_hot":           true,
+		"hits_to_warm":          true,
+		"hits_to_cold":          true,
+		"hits_to_temp":          true,
+	}
+)
+
+// Memcached collector
+// reads the stats of memcached servers and reports:
+//   - the numeric general stats as memcached.<stat>, the ones which are not
+//     gauges are cumulative
+//   - with collectSlabs, stats slabs as memcached.slabs.<stat> by slab and
+//     the active_slabs and total_malloced totals
+//   - with collectItems, stats items as memcached.items.<stat> by slab
+//
+// The servers are the instances, "host[:port]" or a UNIX socket path, and
+// the instances of the nerveServices found in the nerve config.
+type Memcached struct {
+	baseCollector
+
+	instances       []cacheInstance
+	nerveConfigPath []string
+	nerveServices   []string
+	timeout         int
+	collectSlabs    bool
+	collectItems    bool
+}
+
+func init() {
+	RegisterCollector("Memcached", newMemcached)
+}
+
+func newMemcached(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	m := new(Memcached)
+
+	m.log = log
+	m.channel = channel
+	m.interval = initialInterval
+
+	m.name = "Memcached"
+	m.instances = []cacheInstance{parseCacheInstance("127.0.0.1", 11211)}
+	m.nerveConfigPath = []string{defaultNerveConfigPath}
+	m.timeout = 5
+	m.collectSlabs = true
+	m.collectItems = true
+	return m
+}
+
+// Configure the collector
+func (m *Memcached) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["instances"]; exists {
+		m.instances = parseCacheInstances(val, 11211)
+	}
+	if val, exists := configMap["configFilePath"]; exists {
+		m.nerveConfigPath = getNerveConfigPaths(val)
+	}
+	if val, exists := configMap["nerveServices"]; exists {
+		m.nerveServices = config.GetAsSlice(val)
+	}
+	if val, exists := configMap["timeout"]; exists {
+		m.timeout = config.GetAsInt(val, 5)
+	}
+	if val, exists := configMap["collectSlabs"]; exists {
+		m.collectSlabs = config.GetAsBool(val, true)
+	}
+	if val, exists := configMap["collectItems"]; exists {
+		m.collectItems = config.GetAsBool(val, true)
+	}
+
+	m.configureCommonParams(configMap)
+}
+
+// Collect reads the servers concurrently and sends their metrics
+func (m *Memcached) Collect() {
+	instances := m.instances
+	if len(m.nerveServices) > 0 {
+		instances = append(instances, discoverCacheInstances(m.nerveConfigPath, m.nerveServices, m.log)...)
+	}
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance cacheInstance) {
+			defer wg.Done()
+			for _, metric := range m.instanceMetrics(instance) {
+				m.Channel() <- metric
+			}
+		}(instance)
+	}
+	wg.Wait()
+}
+
+func (m *Memcached) instanceMetrics(instance cacheInstance) []metric.Metric {
+	instanceLog := m.log.WithField("instance", instance.addr)
+	conn, err := dialMemcached(instance.network, instance.addr, time.Duration(m.timeout)*time.Second)
+	if err != nil {
+		instanceLog.Error("Failed to connect to memcached: ", err)
+		return nil
+	}
+	defer conn.Close()
+
+	stats, err := conn.Stats()
+	if err != nil {
+		instanceLog.Error("Failed to read the stats: ", err)
+		return nil
+	}
+	metrics := memcachedStatsMetrics(stats)
+
+	if m.collectSlabs {
+		if slabs, err := conn.Stats("slabs"); err == nil {
+			metrics = append(metrics, memcachedSlabsMetrics(slabs)...)
+		} else {
+			instanceLog.Warn("Failed to read the slabs stats: ", err)
+		}
+	}
+	if m.collectItems {
+		if items, err := conn.Stats("items"); err == nil {
+			metrics = append(metrics, memcachedItemsMetrics(items)...)
+		} else {
+			instanceLog.Warn("Failed to read the items stats: ", err)
+		}
+	}
+
+	metric.AddToAll(&metrics, instance.dimensions)
+	return metrics
+}
+
+func memcachedStatsMetrics(stats map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	for name, value := range stats {
+		if memcachedStatsIgnored[name] {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		m := metric.WithValue("memcached."+name, v)
+		if !memcachedStatsGauges[name] {
+			m.MetricType = metric.CumulativeCounter
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// memcachedSlabsMetrics reports the "<slab>:<stat>" lines of stats slabs by
+// slab and the totals
+func memcachedSlabsMetrics(stats map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	for name, value := range stats {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		parts := strings.SplitN(name, ":", 2)
+		if len(parts) == 1 {
+			metrics = append(metrics, metric.WithValue("memcached."+name, v))
+			continue
+		}
+		m := metric.WithValue("memcached.slabs."+parts[1], v)
+		if !memcachedSlabGauges[parts[1]] {
+			m.MetricType = metric.CumulativeCounter
+		}
+		m.AddDimension("slab", parts[0])
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// memcachedItemsMetrics reports the "items:<slab>:<stat>" lines of stats
+// items by slab
+func memcachedItemsMetrics(stats map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	for name, value := range stats {
+		parts := strings.SplitN(name, ":", 3)
+		if len(parts) != 3 || parts[0] != "items" {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		m := metric.WithValue("memcached.items."+parts[2], v)
+		if memcachedItemsCounters[parts[2]] {
+			m.MetricType = metric.CumulativeCounter
+		}
+		m.AddDimension("slab", parts[1])
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
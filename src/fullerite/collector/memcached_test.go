@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"fullerite/metric"
+	"fullerite/test_utils"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMemcachedConn answers the stats commands with the fixtures
+type fakeMemcachedConn struct {
+	t      *testing.T
+	closed bool
+}
+
+func (c *fakeMemcachedConn) Stats(args ...string) (map[string]string, error) {
+	name := "stats.txt"
+	if len(args) > 0 {
+		name = args[0] + ".txt"
+	}
+	return readMemcachedFixture(c.t, name), nil
+}
+
+func (c *fakeMemcachedConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func getTestMemcached() *Memcached {
+	return newMemcached(make(chan metric.Metric), 10, l.WithField("testing", "memcached")).(*Memcached)
+}
+
+// readMemcachedFixture parses the STAT lines of a fixture
+func readMemcachedFixture(t *testing.T, name string) map[string]string {
+	file, err := os.Open(path.Join(test_utils.DirectoryOfCurrentFile(), "/../../fixtures/memcached", name))
+	assert.Nil(t, err)
+	defer file.Close()
+
+	stats := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+		}
+	}
+	return stats
+}
+
+func TestMemcachedConfigure(t *testing.T) {
+	m := getTestMemcached()
+	m.Configure(map[string]interface{}{})
+	assert.Equal(t, "127.0.0.1:11211", m.instances[0].addr)
+	assert.True(t, m.collectSlabs)
+	assert.True(t, m.collectItems)
+
+	m.Configure(map[string]interface{}{
+		"instances":      []interface{}{"/var/run/memcached.sock", "cache-1"},
+		"configFilePath": "/etc/nerve/*.json",
+		"nerveServices":  []interface{}{"memcached_main"},
+		"collectSlabs":   false,
+		"collectItems":   "false",
+	})
+	assert.Equal(t, "unix", m.instances[0].network)
+	assert.Equal(t, "cache-1:11211", m.instances[1].addr)
+	assert.Equal(t, []string{"/etc/nerve/*.json"}, m.nerveConfigPath)
+	assert.Equal(t, []string{"memcached_main"}, m.nerveServices)
+	assert.False(t, m.collectSlabs)
+	assert.False(t, m.collectItems)
+}
+
+func TestMemcachedStatsMetrics(t *testing.T) {
+	byKey := metricsBySeriesKey(memcachedStatsMetrics(readMemcachedFixture(t, "stats.txt")))
+
+	items := byKey["memcached.curr_items|"]
+	assert.Equal(t, 300.0, items.Value)
+	assert.Equal(t, metric.Gauge, items.MetricType)
+	hits := byKey["memcached.get_hits|"]
+	assert.Equal(t, 9000.0, hits.Value)
+	assert.Equal(t, metric.CumulativeCounter, hits.MetricType)
+	assert.Equal(t, 12.5, byKey["memcached.rusage_user|"].Value)
+	for _, ignored := range []string{"memcached.pid|", "memcached.time|", "memcached.version|", "memcached.pointer_size|"} {
+		_, exists := byKey[ignored]
+		assert.False(t, exists, ignored)
+	}
+}
+
+func TestMemcachedSlabsMetrics(t *testing.T) {
+	byKey := metricsBySeriesKey(memcachedSlabsMetrics(readMemcachedFixture(t, "slabs.txt")))
+
+	assert.Equal(t, 2.0, byKey["memcached.active_slabs|"].Value)
+	assert.Equal(t, 2097152.0, byKey["memcached.total_malloced|"].Value)
+	used := byKey["memcached.slabs.used_chunks|slab=1"]
+	assert.Equal(t, 200.0, used.Value)
+	assert.Equal(t, metric.Gauge, used.MetricType)
+	hits := byKey["memcached.slabs.get_hits|slab=5"]
+	assert.Equal(t, 4000.0, hits.Value)
+	assert.Equal(t, metric.CumulativeCounter, hits.MetricType)
+}
+
+func TestMemcachedItemsMetrics(t *testing.T) {
+	byKey := metricsBySeriesKey(memcachedItemsMetrics(readMemcachedFixture(t, "items.txt")))
+
+	assert.Equal(t, 8, len(byKey))
+	number := byKey["memcached.items.number|slab=1"]
+	assert.Equal(t, 200.0, number.Value)
+	assert.Equal(t, metric.Gauge, number.MetricType)
+	evicted := byKey["memcached.items.evicted|slab=5"]
+	assert.Equal(t, 2.0, evicted.Value)
+	assert.Equal(t, metric.CumulativeCounter, evicted.MetricType)
+	assert.Equal(t, metric.Gauge, byKey["memcached.items.evicted_time|slab=1"].MetricType)
+}
+
+func TestMemcachedInstanceMetrics(t *testing.T) {
+	oldDialMemcached := dialMemcached
+	defer func() { dialMemcached = oldDialMemcached }()
+
+	conn := &fakeMemcachedConn{t: t}
+	dialMemcached = func(network string, addr string, timeout time.Duration) (memcachedStatser, error) {
+		assert.Equal(t, "tcp", network)
+		assert.Equal(t, "10.0.0.1:11211", addr)
+		return conn, nil
+	}
+
+	m := getTestMemcached()
+	m.Configure(map[string]interface{}{"instances": "10.0.0.1", "collectItems": false})
+	byKey := metricsBySeriesKey(m.instanceMetrics(m.instances[0]))
+	assert.Equal(t, 300.0, byKey["memcached.curr_items|instance=10.0.0.1:11211"].Value)
+	assert.Equal(t, 200.0, byKey["memcached.slabs.used_chunks|instance=10.0.0.1:11211,slab=1"].Value)
+	for key := range byKey {
+		assert.False(t, strings.HasPrefix(key, "memcached.items."), key)
+	}
+	assert.True(t, conn.closed)
+
+	dialMemcached = func(network string, addr string, timeout time.Duration) (memcachedStatser, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	assert.Nil(t, m.instanceMetrics(m.instances[0]))
+}
@@ -16,12 +16,14 @@ import (
 
 // Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
 var (
-	externalIP = util.ExternalIP
+	getMesosLeader = util.MesosLeader
 
 	sendMetrics = (*MesosStats).sendMetrics
 	getMetrics  = (*MesosStats).getMetrics
 
-	getMetricsURL = func(ip string) string { return fmt.Sprintf("http://%s:5050/metrics/snapshot", ip) }
+	getMetricsURL = func(host string) string {
+		return fmt.Sprintf("http://%s/metrics/snapshot", util.WithDefaultPort(host, mesosDefaultMasterPort))
+	}
 )
 
 // All mesos metrics are gauges except the ones in this list
@@ -66,13 +68,19 @@ var mesosMasterCumulativeCountersList = map[string]int{
 const (
 	cacheTimeout = 5 * time.Minute
 	getTimeout   = 10 * time.Second
+
+	mesosDefaultMasterPort = 5050
 )
 
 // MesosStats Collector for mesos leader stats.
 type MesosStats struct {
 	baseCollector
-	IP     string
-	client http.Client
+	client   http.Client
+	masters  []string
+	election leaderElection
+	// collectState adds the framework, role and agent breakdowns read
+	// from /master/state, which can be large on big clusters
+	collectState bool
 }
 
 func init() {
@@ -88,22 +96,24 @@ func newMesosStats(channel chan metric.Metric, intialInterval int, log *l.Entry)
 	m.interval = intialInterval
 	m.name = "MesosStats"
 	m.client = http.Client{Timeout: getTimeout}
-
-	if ip, err := externalIP(); err != nil {
-		m.log.Error("Cannot determine IP: ", err.Error())
-	} else {
-		m.IP = ip
-	}
+	m.asyncScrape = true
 
 	return m
 }
 
-// Configure Override *baseCollector.Configure().
+// Configure Override *baseCollector.Configure(). The leader is asked to
+// the comma separated mesosNodes, see leaderElection for the other options.
 func (m *MesosStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
+	m.election.configure(configMap)
+
+	if collectState, exists := configMap["collectState"]; exists {
+		m.collectState = config.GetAsBool(collectState, false)
+	}
 
 	c := config.GetAsMap(configMap)
 	if mesosNodes, exists := c["mesosNodes"]; exists && len(mesosNodes) > 0 {
+		m.masters = strings.Split(mesosNodes, ",")
 		m.log.Info("Successfully configured!")
 	} else {
 		m.log.Error("Require configuration not found: mesosNodes")
@@ -111,22 +121,49 @@ func (m *MesosStats) Configure(configMap map[string]interface{}) {
 	}
 }
 
-// Collect Compares box IP against leader IP and if true, sends data.
+// Targets returns the mesos metrics endpoint of the last leader found
+func (m *MesosStats) Targets() []string {
+	return []string{getMetricsURL(m.election.current())}
+}
+
+// Collect finds the leader and sends its metrics if this box is the
+// leader, or if it is the designated box scraping the leader remotely.
 func (m *MesosStats) Collect() {
-	go sendMetrics(m)
+	m.scrapeInBackground(func() {
+		leader, scrape, err := m.election.resolve(func() (string, error) {
+			return getMesosLeader(m.masters, m.client)
+		}, m.log)
+		if err != nil {
+			m.log.Error("Error finding leader: ", err)
+		} else if scrape {
+			sendMetrics(m, leader)
+		} else {
+			m.log.Debug("Not the leader, not sending metrics")
+		}
+	})
 }
 
-// sendMetrics Send to baseCollector channel.
-func (m *MesosStats) sendMetrics() {
-	for k, v := range getMetrics(m, m.IP) {
+// sendMetrics Send to baseCollector channel, followed by the breakdowns
+// of the state when collectState is set.
+func (m *MesosStats) sendMetrics(leader string) {
+	snapshot := getMetrics(m, leader)
+	for k, v := range snapshot {
 		s := buildMetric(k, v)
 		m.Channel() <- s
 	}
+
+	// an empty snapshot means the leader changed since it was resolved
+	if !m.collectState || len(snapshot) == 0 {
+		return
+	}
+	for _, s := range getMesosState(m, leader) {
+		m.Channel() <- s
+	}
 }
 
 // getMetrics Get metrics from the :5050/metrics/snapshot mesos endpoint.
-func (m *MesosStats) getMetrics(ip string) map[string]float64 {
-	url := getMetricsURL(ip)
+func (m *MesosStats) getMetrics(host string) map[string]float64 {
+	url := getMetricsURL(host)
 	r, err := m.client.Get(url)
 
 	if err != nil {
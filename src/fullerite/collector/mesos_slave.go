@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -23,10 +24,14 @@ const (
 var (
 	getSlaveExternalIP = util.ExternalIP
 	getSlaveMetrics    = (*MesosSlaveStats).getSlaveMetrics
+	getSlaveStatistics = (*MesosSlaveStats).getSlaveStatistics
 
 	getSlaveMetricsURL = func(m *MesosSlaveStats, ip string) string {
 		return fmt.Sprintf("http://%s:%d/metrics/snapshot", ip, m.snapshotPort)
 	}
+	getSlaveStatisticsURL = func(m *MesosSlaveStats, ip string) string {
+		return fmt.Sprintf("http://%s:%d/slave(1)/monitor/statistics", ip, m.snapshotPort)
+	}
 )
 
 // All mesos metrics are gauges except the ones in this list
@@ -42,12 +47,27 @@ var mesosSlaveCumulativeCountersList = map[string]int{
 	"slave.valid_status_udpates":       0,
 }
 
+// The executor statistics summed by framework, all are gauges except the
+// ones in this list
+var mesosMonitorCumulativeCountersList = map[string]int{
+	"cpus_user_time_secs":      0,
+	"cpus_system_time_secs":    0,
+	"cpus_nr_throttled":        0,
+	"cpus_throttled_time_secs": 0,
+}
+
 // MesosSlaveStats Collector for mesos leader stats.
 type MesosSlaveStats struct {
 	baseCollector
-	IP           string
 	client       http.Client
 	snapshotPort int
+	// collectStatistics adds the resource usage of the executors read
+	// from /monitor/statistics, summed by framework
+	collectStatistics bool
+
+	// the IP is resolved again on each collection, mu guards it
+	mu sync.Mutex
+	IP string
 }
 
 func init() {
@@ -63,6 +83,7 @@ func newMesosSlaveStats(channel chan metric.Metric, intialInterval int, log *l.E
 	m.interval = intialInterval
 	m.name = "MesosSlaveStats"
 	m.snapshotPort = mesosDefaultSlaveSnapshotPort
+	m.asyncScrape = true
 	m.client = http.Client{Timeout: time.Duration(httpDefaultTimeout) * time.Second}
 
 	if ip, err := getSlaveExternalIP(); err != nil {
@@ -86,24 +107,52 @@ func (m *MesosSlaveStats) Configure(configMap map[string]interface{}) {
 	if slaveSnapshotPort, exists := c["slaveSnapshotPort"]; exists {
 		m.snapshotPort = config.GetAsInt(slaveSnapshotPort, mesosDefaultSlaveSnapshotPort)
 	}
+
+	if collectStatistics, exists := configMap["collectStatistics"]; exists {
+		m.collectStatistics = config.GetAsBool(collectStatistics, false)
+	}
 }
 
-// Collect Compares box IP against leader IP and if true, sends data.
+// Collect resolves the IP of the box, in case it changed, and sends the
+// metrics of the agent listening on it.
 func (m *MesosSlaveStats) Collect() {
-	if m.IP == "" {
+	if ip, err := getSlaveExternalIP(); err != nil {
+		m.log.Error("Cannot determine IP: ", err.Error())
+	} else {
+		m.mu.Lock()
+		m.IP = ip
+		m.mu.Unlock()
+	}
+
+	if m.currentIP() == "" {
 		m.log.Error("Cannot get external IP. Skipping collection.")
 		return
 	}
-	go m.sendMetrics()
+	m.scrapeInBackground(m.sendMetrics)
 }
 
-// sendMetrics Send to baseCollector channel.
+func (m *MesosSlaveStats) currentIP() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.IP
+}
+
+// sendMetrics Send to baseCollector channel, followed by the executor
+// statistics when collectStatistics is set.
 func (m *MesosSlaveStats) sendMetrics() {
-	for metricName, value := range getSlaveMetrics(m, m.IP) {
+	ip := m.currentIP()
+	for metricName, value := range getSlaveMetrics(m, ip) {
 		s := m.buildMetric(metricName, value)
 
 		m.Channel() <- s
 	}
+
+	if !m.collectStatistics {
+		return
+	}
+	for _, s := range getSlaveStatistics(m, ip) {
+		m.Channel() <- s
+	}
 }
 
 // getMetrics Get metrics from the :5051/metrics/snapshot mesos endpoint.
@@ -141,6 +190,57 @@ func (m *MesosSlaveStats) getSlaveMetrics(ip string) map[string]float64 {
 	return snapshot
 }
 
+// getSlaveStatistics Get the resource usage of the executors from the
+// :5051/slave(1)/monitor/statistics mesos endpoint, summed by framework.
+// The endpoint only has the framework IDs, they are the values of the
+// framework dimension.
+func (m *MesosSlaveStats) getSlaveStatistics(ip string) []metric.Metric {
+	contents, err := util.GetWrapper(getSlaveStatisticsURL(m, ip), m.client)
+	if err != nil {
+		m.log.Error("Could not load statistics from mesos: ", err)
+		return nil
+	}
+
+	var executors []struct {
+		FrameworkID string                 `json:"framework_id"`
+		Statistics  map[string]interface{} `json:"statistics"`
+	}
+	if err := json.Unmarshal(contents, &executors); err != nil {
+		m.log.Error("Unable to decode mesos statistics JSON: ", err)
+		return nil
+	}
+
+	frameworks := map[string]map[string]float64{}
+	for _, executor := range executors {
+		sums, exists := frameworks[executor.FrameworkID]
+		if !exists {
+			sums = map[string]float64{}
+			frameworks[executor.FrameworkID] = sums
+		}
+		sums["executors"]++
+		for name, value := range executor.Statistics {
+			// the timestamp of the sample is not a resource, and nested
+			// statistics like perf are left out
+			if v, ok := value.(float64); ok && name != "timestamp" {
+				sums[name] += v
+			}
+		}
+	}
+
+	metrics := []metric.Metric{}
+	for frameworkID, sums := range frameworks {
+		for name, value := range sums {
+			s := metric.WithValue("mesos.monitor."+name, value)
+			if _, exists := mesosMonitorCumulativeCountersList[name]; exists {
+				s.MetricType = metric.CumulativeCounter
+			}
+			s.AddDimension("framework", frameworkID)
+			metrics = append(metrics, s)
+		}
+	}
+	return metrics
+}
+
 // buildMetric creates the metric and set the correct metricType
 func (m *MesosSlaveStats) buildMetric(name string, value float64) metric.Metric {
 	s := metric.New("mesos." + name)
@@ -69,7 +69,7 @@ func TestMesosSlaveStatsSendMetrics(t *testing.T) {
 	oldGetMetrics := getSlaveMetrics
 	defer func() { getSlaveMetrics = oldGetMetrics }()
 
-	expected := metric.Metric{"mesos.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 	getSlaveMetrics = func(m *MesosSlaveStats, ip string) map[string]float64 {
 		return map[string]float64{
 			"test": 0.1,
@@ -167,3 +167,65 @@ func TestMesosSlaveStatsBuildMetric(t *testing.T) {
 		assert.Equal(t, test.MetricType, metric.MetricType)
 	}
 }
+
+func TestMesosSlaveStatsGetSlaveStatistics(t *testing.T) {
+	oldGetStatisticsURL := getSlaveStatisticsURL
+	defer func() { getSlaveStatisticsURL = oldGetStatisticsURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"executor_id": "e1", "framework_id": "f1", "statistics": {
+				"timestamp": 1500000000, "cpus_limit": 1.1, "cpus_user_time_secs": 10, "mem_rss_bytes": 100,
+				"perf": {"cycles": 1}}},
+			{"executor_id": "e2", "framework_id": "f1", "statistics": {
+				"timestamp": 1500000000, "cpus_limit": 0.6, "cpus_user_time_secs": 5, "mem_rss_bytes": 50}},
+			{"executor_id": "e3", "framework_id": "f2", "statistics": {"cpus_limit": 2}}
+		]`)
+	}))
+	defer ts.Close()
+	getSlaveStatisticsURL = func(m *MesosSlaveStats, ip string) string { return ts.URL }
+
+	sut := newMesosSlaveStats(nil, 10, defaultLog).(*MesosSlaveStats)
+	actual := map[string]metric.Metric{}
+	for _, m := range sut.getSlaveStatistics("ip") {
+		actual[m.Name+","+m.Dimensions["framework"]] = m
+	}
+
+	assert.Len(t, actual, 6)
+	assert.Equal(t, 2.0, actual["mesos.monitor.executors,f1"].Value)
+	assert.InDelta(t, 1.7, actual["mesos.monitor.cpus_limit,f1"].Value, 1e-9)
+	assert.Equal(t, metric.Gauge, actual["mesos.monitor.cpus_limit,f1"].MetricType)
+	assert.Equal(t, 15.0, actual["mesos.monitor.cpus_user_time_secs,f1"].Value)
+	assert.Equal(t, metric.CumulativeCounter, actual["mesos.monitor.cpus_user_time_secs,f1"].MetricType)
+	assert.Equal(t, 150.0, actual["mesos.monitor.mem_rss_bytes,f1"].Value)
+	assert.Equal(t, 1.0, actual["mesos.monitor.executors,f2"].Value)
+	assert.Equal(t, 2.0, actual["mesos.monitor.cpus_limit,f2"].Value)
+}
+
+func TestMesosSlaveStatsSendMetricsWithStatistics(t *testing.T) {
+	oldGetMetrics := getSlaveMetrics
+	oldGetStatistics := getSlaveStatistics
+	defer func() {
+		getSlaveMetrics = oldGetMetrics
+		getSlaveStatistics = oldGetStatistics
+	}()
+
+	getSlaveMetrics = func(m *MesosSlaveStats, ip string) map[string]float64 {
+		return map[string]float64{"test": 0.1}
+	}
+	getSlaveStatistics = func(m *MesosSlaveStats, ip string) []metric.Metric {
+		return []metric.Metric{metric.WithValue("mesos.monitor.executors", 1)}
+	}
+
+	c := make(chan metric.Metric, 2)
+	sut := newMesosSlaveStats(c, 10, defaultLog).(*MesosSlaveStats)
+	sut.Configure(map[string]interface{}{"collectStatistics": true})
+	sut.sendMetrics()
+	close(c)
+
+	names := []string{}
+	for m := range c {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"mesos.test", "mesos.monitor.executors"}, names)
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"fullerite/metric"
+	"fullerite/util"
+	"sort"
+	"strings"
+)
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	getMesosState = (*MesosStats).getState
+
+	getStateURL = func(host string) string {
+		return fmt.Sprintf("http://%s/master/state", util.WithDefaultPort(host, mesosDefaultMasterPort))
+	}
+)
+
+// mesosResources are the scalar resources of an agent or of a framework,
+// other resources like ports are ignored
+type mesosResources struct {
+	CPUs float64 `json:"cpus"`
+	Mem  float64 `json:"mem"`
+	Disk float64 `json:"disk"`
+	GPUs float64 `json:"gpus"`
+}
+
+func (r *mesosResources) add(other mesosResources) {
+	r.CPUs += other.CPUs
+	r.Mem += other.Mem
+	r.Disk += other.Disk
+	r.GPUs += other.GPUs
+}
+
+// mesosState is the part of /master/state the breakdowns are made of
+type mesosState struct {
+	Frameworks []struct {
+		Name             string         `json:"name"`
+		Role             string         `json:"role"`
+		Roles            []string       `json:"roles"`
+		UsedResources    mesosResources `json:"used_resources"`
+		OfferedResources mesosResources `json:"offered_resources"`
+		Tasks            []struct {
+			State string `json:"state"`
+		} `json:"tasks"`
+		UnreachableTasks []struct {
+			State string `json:"state"`
+		} `json:"unreachable_tasks"`
+	} `json:"frameworks"`
+	Slaves []struct {
+		Hostname         string         `json:"hostname"`
+		Resources        mesosResources `json:"resources"`
+		UsedResources    mesosResources `json:"used_resources"`
+		OfferedResources mesosResources `json:"offered_resources"`
+	} `json:"slaves"`
+}
+
+// getState Get the framework, role and agent breakdowns from the
+// :5050/master/state mesos endpoint.
+func (m *MesosStats) getState(host string) []metric.Metric {
+	contents, err := util.GetWrapper(getStateURL(host), m.client)
+	if err != nil {
+		m.log.Error("Could not load state from mesos: ", err)
+		return nil
+	}
+
+	var state mesosState
+	if err := json.Unmarshal(contents, &state); err != nil {
+		m.log.Error("Unable to decode mesos state JSON: ", err)
+		return nil
+	}
+	return state.metrics()
+}
+
+// metrics returns the used and offered resources and the task counts by
+// state of each framework, the used and offered resources summed by role
+// and the total, used and offered resources of each agent. Frameworks
+// subscribed to several roles are counted under their comma separated roles.
+func (state mesosState) metrics() []metric.Metric {
+	metrics := []metric.Metric{}
+
+	type roleResources struct{ used, offered mesosResources }
+	roles := map[string]*roleResources{}
+	for _, framework := range state.Frameworks {
+		role := framework.Role
+		if role == "" {
+			role = strings.Join(framework.Roles, ",")
+		}
+		dimensions := map[string]string{"framework": framework.Name, "role": role}
+		metrics = append(metrics, mesosResourceMetrics("mesos.framework", "used", framework.UsedResources, dimensions)...)
+		metrics = append(metrics, mesosResourceMetrics("mesos.framework", "offered", framework.OfferedResources, dimensions)...)
+
+		tasks := map[string]float64{}
+		for _, task := range framework.Tasks {
+			tasks[task.State]++
+		}
+		for _, task := range framework.UnreachableTasks {
+			tasks[task.State]++
+		}
+		for taskState, count := range tasks {
+			m := metric.WithValue("mesos.framework.tasks", count)
+			m.AddDimensions(dimensions)
+			m.AddDimension("state", taskState)
+			metrics = append(metrics, m)
+		}
+
+		if _, exists := roles[role]; !exists {
+			roles[role] = new(roleResources)
+		}
+		roles[role].used.add(framework.UsedResources)
+		roles[role].offered.add(framework.OfferedResources)
+	}
+
+	names := make([]string, 0, len(roles))
+	for role := range roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	for _, role := range names {
+		dimensions := map[string]string{"role": role}
+		metrics = append(metrics, mesosResourceMetrics("mesos.role", "used", roles[role].used, dimensions)...)
+		metrics = append(metrics, mesosResourceMetrics("mesos.role", "offered", roles[role].offered, dimensions)...)
+	}
+
+	for _, agent := range state.Slaves {
+		dimensions := map[string]string{"agent": agent.Hostname}
+		metrics = append(metrics, mesosResourceMetrics("mesos.agent", "total", agent.Resources, dimensions)...)
+		metrics = append(metrics, mesosResourceMetrics("mesos.agent", "used", agent.UsedResources, dimensions)...)
+		metrics = append(metrics, mesosResourceMetrics("mesos.agent", "offered", agent.OfferedResources, dimensions)...)
+	}
+	return metrics
+}
+
+// mesosResourceMetrics returns one gauge per resource, like
+// mesos.framework.cpus_used
+func mesosResourceMetrics(prefix string, kind string, resources mesosResources, dimensions map[string]string) []metric.Metric {
+	values := []struct {
+		name  string
+		value float64
+	}{
+		{"cpus", resources.CPUs},
+		{"mem", resources.Mem},
+		{"disk", resources.Disk},
+		{"gpus", resources.GPUs},
+	}
+
+	metrics := make([]metric.Metric, 0, len(values))
+	for _, v := range values {
+		m := metric.WithValue(prefix+"."+v.name+"_"+kind, v.value)
+		m.AddDimensions(dimensions)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"fullerite/metric"
 
@@ -12,17 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// mockExternalIP Injectable mock for externalIP, for test assertions.
-func mockExternalIP() (string, error) {
-	return httptest.DefaultRemoteAddr, nil
-}
-
 func TestMesosStatsNewMesosStats(t *testing.T) {
-	oldExternalIP := externalIP
-	defer func() { externalIP = oldExternalIP }()
-
-	externalIP = mockExternalIP
-
 	c := make(chan metric.Metric)
 	i := 10
 	l := defaultLog.WithFields(l.Fields{"collector": "Mesos"})
@@ -32,50 +23,62 @@ func TestMesosStatsNewMesosStats(t *testing.T) {
 	assert.Equal(t, c, sut.channel)
 	assert.Equal(t, i, sut.interval)
 	assert.Equal(t, l, sut.log)
-	assert.Equal(t, httptest.DefaultRemoteAddr, sut.IP)
 	assert.Equal(t, http.Client{Timeout: getTimeout}, sut.client)
 }
 
 func TestMesosStatsCollect(t *testing.T) {
-	oldExternalIP := externalIP
+	oldGetMesosLeader := getMesosLeader
+	oldIsLocalHost := isLocalHost
 	oldSendMetrics := sendMetrics
 	defer func() {
-		externalIP = oldExternalIP
+		getMesosLeader = oldGetMesosLeader
+		isLocalHost = oldIsLocalHost
 		sendMetrics = oldSendMetrics
 	}()
 
-	sendMetricsCalled := false
-	c := make(chan bool)
-	sendMetrics = func(m *MesosStats) {
-		sendMetricsCalled = true
-		c <- true
+	getMesosLeader = func(masters []string, client http.Client) (string, error) {
+		assert.Equal(t, []string{"ip1", "ip2"}, masters)
+		return "ip2:5050", nil
 	}
 
 	tests := []struct {
-		configMap           map[string]interface{}
-		externalIP          string
-		isSendMetricsCalled bool
-		msg                 string
+		configMap    map[string]interface{}
+		isLocal      bool
+		expectedHost string
+		msg          string
 	}{
-		{map[string]interface{}{"mesosNodes": "ip1,ip2"}, "5.6.7.8", true, "Machine IP is not equal to leader IP, therefore we should skip collection."},
-		{map[string]interface{}{"mesosNodes": "ip1,ip2"}, httptest.DefaultRemoteAddr, true, "Current box is leader; therefore, we should be called sendMetrics."},
+		{map[string]interface{}{"mesosNodes": "ip1,ip2"}, false, "", "Current box is not the leader, therefore we should skip collection."},
+		{map[string]interface{}{"mesosNodes": "ip1,ip2"}, true, "ip2:5050", "Current box is leader; therefore, we should be called sendMetrics."},
+		{map[string]interface{}{"mesosNodes": "ip1,ip2", "scrapeLeaderRemotely": true}, false, "ip2:5050", "Current box scrapes the leader remotely."},
 	}
 
-	for _, test := range tests {
-		sendMetricsCalled = false
-		configMap := test.configMap
-		externalIP = func() (string, error) { return test.externalIP, nil }
+	sent := make(chan string, 1)
+	sendMetrics = func(m *MesosStats, leader string) { sent <- leader }
+	isLocal := make(chan bool, 1)
+	isLocalHost = func(host string, log *l.Entry) (bool, error) {
+		assert.Equal(t, "ip2:5050", host)
+		return <-isLocal, nil
+	}
 
+	for _, test := range tests {
+		isLocal <- test.isLocal
 		sut := newMesosStats(nil, 0, defaultLog).(*MesosStats)
-		sut.Configure(configMap)
+		sut.Configure(test.configMap)
 		sut.Collect()
 
-		switch test.isSendMetricsCalled {
-		case false:
-			assert.False(t, sendMetricsCalled, test.msg)
-		case true:
-			<-c
-			assert.True(t, sendMetricsCalled, test.msg)
+		if test.expectedHost == "" {
+			select {
+			case <-sent:
+				t.Error(test.msg)
+			case <-time.After(50 * time.Millisecond):
+			}
+		} else {
+			assert.Equal(t, test.expectedHost, <-sent, test.msg)
+			assert.Equal(t, []string{"http://ip2:5050/metrics/snapshot"}, sut.Targets())
+		}
+		select {
+		case <-isLocal:
+		default:
 		}
 	}
 }
@@ -84,7 +87,7 @@ func TestMesosStatsSendMetrics(t *testing.T) {
 	oldGetMetrics := getMetrics
 	defer func() { getMetrics = oldGetMetrics }()
 
-	expected := metric.Metric{"mesos.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 	getMetrics = func(m *MesosStats, ip string) map[string]float64 {
 		return map[string]float64{
 			"test": 0.1,
@@ -94,7 +97,7 @@ func TestMesosStatsSendMetrics(t *testing.T) {
 	c := make(chan metric.Metric)
 	sut := newMesosStats(c, 10, defaultLog).(*MesosStats)
 
-	go sut.sendMetrics()
+	go sut.sendMetrics("ip1")
 	actual := <-c
 
 	assert.Equal(t, expected, actual)
@@ -124,7 +127,7 @@ func TestMesosStatsGetMetrics(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		getMetricsURL = func(ip string) string { return ts.URL }
+		getMetricsURL = func(host string) string { return ts.URL }
 
 		sut := newMesosStats(nil, 10, defaultLog).(*MesosStats)
 		actual := getMetrics(sut, httptest.DefaultRemoteAddr)
@@ -139,7 +142,7 @@ func TestMesosStatsGetMetricsHandleErrors(t *testing.T) {
 		getMetricsURL = oldGetMetricsURL
 	}()
 
-	getMetricsURL = func(ip string) string { return "" }
+	getMetricsURL = func(host string) string { return "" }
 
 	sut := newMesosStats(nil, 10, defaultLog).(*MesosStats)
 	actual := getMetrics(sut, httptest.DefaultRemoteAddr)
@@ -159,7 +162,7 @@ func TestMesosStatsGetMetricsHandleNon200s(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	getMetricsURL = func(ip string) string { return ts.URL }
+	getMetricsURL = func(host string) string { return ts.URL }
 
 	sut := newMesosStats(nil, 10, defaultLog).(*MesosStats)
 	actual := getMetrics(sut, httptest.DefaultRemoteAddr)
@@ -168,7 +171,7 @@ func TestMesosStatsGetMetricsHandleNon200s(t *testing.T) {
 }
 
 func TestMesosStatsBuildMetric(t *testing.T) {
-	expected := metric.Metric{"mesos.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 
 	actual := buildMetric("test", 0.1)
 
@@ -176,9 +179,116 @@ func TestMesosStatsBuildMetric(t *testing.T) {
 }
 
 func TestMesosStatsBuildMetricCumCounter(t *testing.T) {
-	expected := metric.Metric{"mesos.master.slave_reregistrations", metric.CumulativeCounter, 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "mesos.master.slave_reregistrations", MetricType: metric.CumulativeCounter, Value: 0.1, Dimensions: map[string]string{}}
 
 	actual := buildMetric("master.slave_reregistrations", 0.1)
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestMesosStatsSendMetricsWithState(t *testing.T) {
+	oldGetMetrics := getMetrics
+	oldGetMesosState := getMesosState
+	defer func() {
+		getMetrics = oldGetMetrics
+		getMesosState = oldGetMesosState
+	}()
+
+	getMetrics = func(m *MesosStats, host string) map[string]float64 {
+		return map[string]float64{"master.elected": 1}
+	}
+	getMesosState = func(m *MesosStats, host string) []metric.Metric {
+		assert.Equal(t, "ip1", host)
+		return []metric.Metric{metric.WithValue("mesos.framework.cpus_used", 2)}
+	}
+
+	c := make(chan metric.Metric, 2)
+	sut := newMesosStats(c, 10, defaultLog).(*MesosStats)
+	sut.Configure(map[string]interface{}{"mesosNodes": "ip1", "collectState": true})
+	sut.sendMetrics("ip1")
+	close(c)
+
+	names := []string{}
+	for m := range c {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"mesos.master.elected", "mesos.framework.cpus_used"}, names)
+}
+
+func TestMesosStatsGetState(t *testing.T) {
+	oldGetStateURL := getStateURL
+	defer func() { getStateURL = oldGetStateURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"frameworks": [
+				{
+					"name": "marathon", "role": "services",
+					"used_resources": {"cpus": 2.5, "mem": 1024, "disk": 100, "ports": "[31000-31001]"},
+					"offered_resources": {"cpus": 1, "mem": 512},
+					"tasks": [{"state": "TASK_RUNNING"}, {"state": "TASK_RUNNING"}, {"state": "TASK_STAGING"}],
+					"unreachable_tasks": [{"state": "TASK_UNREACHABLE"}]
+				},
+				{
+					"name": "chronos", "roles": ["batch"],
+					"used_resources": {"cpus": 1, "mem": 256},
+					"offered_resources": {},
+					"tasks": []
+				}
+			],
+			"slaves": [
+				{
+					"hostname": "agent1",
+					"resources": {"cpus": 8, "mem": 16384, "disk": 1000, "gpus": 1},
+					"used_resources": {"cpus": 3.5, "mem": 1280, "disk": 100},
+					"offered_resources": {"cpus": 1, "mem": 512}
+				}
+			]
+		}`)
+	}))
+	defer ts.Close()
+	getStateURL = func(host string) string { return ts.URL }
+
+	sut := newMesosStats(nil, 10, defaultLog).(*MesosStats)
+	actual := map[string]float64{}
+	for _, m := range getMesosState(sut, "ip1") {
+		assert.Equal(t, metric.Gauge, m.MetricType)
+		key := m.Name
+		for _, dimension := range []string{"framework", "role", "state", "agent"} {
+			if value, exists := m.Dimensions[dimension]; exists {
+				key += "," + dimension + "=" + value
+			}
+		}
+		actual[key] = m.Value
+	}
+
+	assert.Equal(t, 2.5, actual["mesos.framework.cpus_used,framework=marathon,role=services"])
+	assert.Equal(t, 512.0, actual["mesos.framework.mem_offered,framework=marathon,role=services"])
+	assert.Equal(t, 2.0, actual["mesos.framework.tasks,framework=marathon,role=services,state=TASK_RUNNING"])
+	assert.Equal(t, 1.0, actual["mesos.framework.tasks,framework=marathon,role=services,state=TASK_STAGING"])
+	assert.Equal(t, 1.0, actual["mesos.framework.tasks,framework=marathon,role=services,state=TASK_UNREACHABLE"])
+	assert.Equal(t, 256.0, actual["mesos.framework.mem_used,framework=chronos,role=batch"])
+	assert.Equal(t, 1.0, actual["mesos.role.cpus_used,role=batch"])
+	assert.Equal(t, 2.5, actual["mesos.role.cpus_used,role=services"])
+	assert.Equal(t, 8.0, actual["mesos.agent.cpus_total,agent=agent1"])
+	assert.Equal(t, 1.0, actual["mesos.agent.gpus_total,agent=agent1"])
+	assert.Equal(t, 1280.0, actual["mesos.agent.mem_used,agent=agent1"])
+	assert.Equal(t, 1.0, actual["mesos.agent.cpus_offered,agent=agent1"])
+}
+
+func TestMesosStatsGetStateHandleErrors(t *testing.T) {
+	oldGetStateURL := getStateURL
+	defer func() { getStateURL = oldGetStateURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"frameworks": `)
+	}))
+	defer ts.Close()
+	getStateURL = func(host string) string { return ts.URL }
+
+	sut := newMesosStats(nil, 10, defaultLog).(*MesosStats)
+	assert.Nil(t, getMesosState(sut, "ip1"), "Invalid JSON should return nil.")
+
+	getStateURL = func(host string) string { return "" }
+	assert.Nil(t, getMesosState(sut, "ip1"), "Invalid URL should return nil.")
+}
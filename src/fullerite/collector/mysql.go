@@ -0,0 +1,468 @@
+package collector
+
+import (
+	"database/sql"
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/alyu/configparser"
+)
+
+// mysqlQuerier runs the queries of the MySQL collector
+type mysqlQuerier interface {
+	Query(query string) (*util.MySQLResult, error)
+	Close() error
+}
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	dialMySQL = func(dsn util.MySQLDSN, timeout time.Duration) (mysqlQuerier, error) {
+		return util.DialMySQL(dsn, timeout)
+	}
+
+	// The global status values which are not counters
+	mysqlStatusGauges = map[string]bool{
+		"uptime":                         true,
+		"max_used_connections":           true,
+		"not_flushed_delayed_rows":       true,
+		"prepared_stmt_count":            true,
+		"innodb_page_size":               true,
+		"innodb_row_lock_current_waits":  true,
+		"innodb_row_lock_time_avg":       true,
+		"innodb_row_lock_time_max":       true,
+		"innodb_num_open_files":          true,
+		"innodb_buffer_pool_pages_total": true,
+		"innodb_buffer_pool_pages_data":  true,
+		"innodb_buffer_pool_pages_dirty": true,
+		"innodb_buffer_pool_pages_free":  true,
+		"innodb_buffer_pool_pages_misc":  true,
+		"innodb_buffer_pool_bytes_data":  true,
+		"innodb_buffer_pool_bytes_dirty": true,
+		"replica_open_temp_tables":       true,
+		"slave_open_temp_tables":         true,
+	}
+	// and the prefixes of the ones which are not counters
+	mysqlStatusGaugePrefixes = []string{
+		"threads_", "open_", "innodb_data_pending_", "innodb_os_log_pending_",
+		"key_blocks_", "qcache_free_", "qcache_queries_in_cache", "qcache_total_blocks",
+	}
+
+	// The global variables reported, the capacity of the server
+	mysqlVariables = []string{
+		"max_connections",
+		"max_user_connections",
+		"max_allowed_packet",
+		"open_files_limit",
+		"table_open_cache",
+		"table_definition_cache",
+		"thread_cache_size",
+		"innodb_buffer_pool_size",
+		"innodb_buffer_pool_instances",
+		"innodb_log_file_size",
+		"innodb_log_buffer_size",
+		"key_buffer_size",
+		"query_cache_size",
+		"long_query_time",
+		"read_only",
+		"super_read_only",
+	}
+
+	mysqlSchemaSizesQuery = `SELECT table_schema, COUNT(*), SUM(table_rows), SUM(data_length), SUM(index_length), SUM(data_free)
+FROM information_schema.tables
+WHERE table_schema NOT IN ('information_schema', 'performance_schema', 'mysql', 'sys')
+GROUP BY table_schema`
+)
+
+// MySQL collector
+// connects to a MySQL server over its wire protocol and reports:
+//   - SHOW GLOBAL STATUS as mysql.<variable>, counters are cumulative
+//   - a few SHOW GLOBAL VARIABLES as mysql.variables.<variable>
+//   - the lag and the IO and SQL threads of SHOW REPLICA STATUS, SHOW SLAVE
+//     STATUS before MySQL 8.0.22, per replication channel
+//   - the buffer pool usage and the enabled counters of
+//     information_schema.INNODB_METRICS as mysql.innodb.<name>
+//   - with collectSchemaSizes, the tables, rows and bytes of each schema,
+//     which scans information_schema.tables
+//
+// The dsn looks like "user:password@tcp(127.0.0.1:3306)/", see
+// util.ParseMySQLDSN. The user, password, host, port and socket missing
+// from it are read from the [client] section of the mycnf file. Over TCP,
+// users of caching_sha2_password whose password is not cached by the
+// server need "?allowPublicKeyRetrieval=true" at the end of the dsn.
+type MySQL struct {
+	baseCollector
+
+	dsn                string
+	myCnfPath          string
+	timeout            int
+	collectVariables   bool
+	collectReplication bool
+	collectInnoDB      bool
+	collectSchemaSizes bool
+}
+
+func init() {
+	RegisterCollector("MySQL", newMySQL)
+}
+
+func newMySQL(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	m := new(MySQL)
+
+	m.log = log
+	m.channel = channel
+	m.interval = initialInterval
+
+	m.name = "MySQL"
+	m.myCnfPath = defaultCnfPath
+	m.timeout = 5
+	m.collectVariables = true
+	m.collectReplication = true
+	m.collectInnoDB = true
+	return m
+}
+
+// Configure the collector
+func (m *MySQL) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["dsn"]; exists {
+		m.dsn = val.(string)
+	}
+	if val, exists := configMap["mycnf"]; exists {
+		m.myCnfPath = val.(string)
+	}
+	if val, exists := configMap["timeout"]; exists {
+		m.timeout = config.GetAsInt(val, 5)
+	}
+	if val, exists := configMap["collectVariables"]; exists {
+		m.collectVariables = config.GetAsBool(val, true)
+	}
+	if val, exists := configMap["collectReplication"]; exists {
+		m.collectReplication = config.GetAsBool(val, true)
+	}
+	if val, exists := configMap["collectInnoDB"]; exists {
+		m.collectInnoDB = config.GetAsBool(val, true)
+	}
+	if val, exists := configMap["collectSchemaSizes"]; exists {
+		m.collectSchemaSizes = config.GetAsBool(val, false)
+	}
+
+	m.configureCommonParams(configMap)
+}
+
+// Collect connects to the server and sends its metrics
+func (m *MySQL) Collect() {
+	dsn, err := m.dataSource()
+	if err != nil {
+		m.log.Error(err)
+		return
+	}
+	conn, err := dialMySQL(dsn, time.Duration(m.timeout)*time.Second)
+	if err != nil {
+		m.log.Error("Failed to connect to MySQL at ", dsn.Addr, ": ", err)
+		return
+	}
+	defer conn.Close()
+
+	for _, metric := range m.metrics(conn) {
+		m.Channel() <- metric
+	}
+}
+
+// metrics runs the queries of the enabled groups, a failed group is logged
+// and the others are still reported
+func (m *MySQL) metrics(conn mysqlQuerier) []metric.Metric {
+	metrics := []metric.Metric{}
+
+	status, err := mysqlKeyValues(conn, "SHOW GLOBAL STATUS")
+	if err != nil {
+		m.log.Error("Failed to read the global status: ", err)
+		return metrics
+	}
+	metrics = append(metrics, mysqlStatusMetrics(status)...)
+
+	if m.collectVariables {
+		if variables, err := mysqlKeyValues(conn, "SHOW GLOBAL VARIABLES"); err == nil {
+			metrics = append(metrics, mysqlVariableMetrics(variables)...)
+		} else {
+			m.log.Warn("Failed to read the global variables: ", err)
+		}
+	}
+	if m.collectReplication {
+		if replication, err := m.replicationMetrics(conn); err == nil {
+			metrics = append(metrics, replication...)
+		} else {
+			m.log.Warn("Failed to read the replica status: ", err)
+		}
+	}
+	if m.collectInnoDB {
+		metrics = append(metrics, mysqlBufferPoolMetrics(status)...)
+		if innodb, err := mysqlInnoDBMetrics(conn); err == nil {
+			metrics = append(metrics, innodb...)
+		} else {
+			m.log.Warn("Failed to read the InnoDB metrics: ", err)
+		}
+	}
+	if m.collectSchemaSizes {
+		if schemas, err := mysqlSchemaMetrics(conn); err == nil {
+			metrics = append(metrics, schemas...)
+		} else {
+			m.log.Warn("Failed to read the schema sizes: ", err)
+		}
+	}
+	return metrics
+}
+
+// dataSource returns the DSN completed with the client section of my.cnf
+func (m *MySQL) dataSource() (util.MySQLDSN, error) {
+	dsn := m.dsn
+	if dsn == "" {
+		dsn = "/"
+	}
+	parsed, err := util.ParseMySQLDSN(dsn)
+	if err != nil {
+		return parsed, err
+	}
+
+	client, err := readMyCnfClient(m.myCnfPath)
+	if err != nil {
+		m.log.Warn("Failed to read the client section of ", m.myCnfPath, ": ", err)
+		return parsed, nil
+	}
+	if parsed.User == "" {
+		parsed.User = client["user"]
+		if parsed.Password == "" {
+			parsed.Password = client["password"]
+		}
+	}
+	if m.dsn == "" {
+		if client["socket"] != "" {
+			parsed.Net, parsed.Addr = "unix", client["socket"]
+		} else if client["host"] != "" {
+			parsed.Addr = client["host"] + ":3306"
+			if client["port"] != "" {
+				parsed.Addr = client["host"] + ":" + client["port"]
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// readMyCnfClient returns the options of the [client] section of a my.cnf,
+// there are none if the file does not exist
+func readMyCnfClient(path string) (map[string]string, error) {
+	client := map[string]string{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return client, nil
+	}
+	cnf, err := configparser.Read(path)
+	if err != nil {
+		return client, err
+	}
+	section, err := cnf.Section("client")
+	if err != nil {
+		return client, nil
+	}
+	for _, option := range []string{"user", "password", "host", "port", "socket"} {
+		client[option] = strings.Trim(section.ValueOf(option), `"'`)
+	}
+	return client, nil
+}
+
+// replicationMetrics reports each replication channel, SHOW SLAVE STATUS
+// and its column names are used by servers without SHOW REPLICA STATUS
+func (m *MySQL) replicationMetrics(conn mysqlQuerier) ([]metric.Metric, error) {
+	result, err := conn.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		if _, ok := err.(util.MySQLError); !ok {
+			return nil, err
+		}
+		if result, err = conn.Query("SHOW SLAVE STATUS"); err != nil {
+			return nil, err
+		}
+	}
+
+	metrics := []metric.Metric{}
+	for _, record := range result.Records() {
+		column := func(replica string, slave string) sql.NullString {
+			if value, exists := record[replica]; exists {
+				return value
+			}
+			return record[slave]
+		}
+		running := func(value sql.NullString) float64 {
+			if value.String == "Yes" {
+				return 1
+			}
+			return 0
+		}
+
+		dimensions := map[string]string{"source_host": column("Source_Host", "Master_Host").String}
+		if channel := record["Channel_Name"].String; channel != "" {
+			dimensions["channel"] = channel
+		}
+		add := func(name string, value float64) {
+			replicaMetric := metric.WithValue("mysql.replication."+name, value)
+			replicaMetric.AddDimensions(dimensions)
+			metrics = append(metrics, replicaMetric)
+		}
+
+		// the lag is NULL when the SQL thread is not running
+		if lag, err := strconv.ParseFloat(column("Seconds_Behind_Source", "Seconds_Behind_Master").String, 64); err == nil {
+			add("seconds_behind_source", lag)
+		}
+		add("io_running", running(column("Replica_IO_Running", "Slave_IO_Running")))
+		add("sql_running", running(column("Replica_SQL_Running", "Slave_SQL_Running")))
+		for name, value := range map[string]sql.NullString{
+			"relay_log_space": record["Relay_Log_Space"],
+			"last_io_errno":   record["Last_IO_Errno"],
+			"last_sql_errno":  record["Last_SQL_Errno"],
+		} {
+			if v, err := strconv.ParseFloat(value.String, 64); err == nil {
+				add(name, v)
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// mysqlKeyValues returns the two columns of SHOW STATUS and SHOW VARIABLES
+// as a map, with lower case keys
+func mysqlKeyValues(conn mysqlQuerier, query string) (map[string]string, error) {
+	result, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for _, row := range result.Rows {
+		if len(row) == 2 && row[1].Valid {
+			values[strings.ToLower(row[0].String)] = row[1].String
+		}
+	}
+	return values, nil
+}
+
+// mysqlStatusMetrics reports the numeric status variables, ON and OFF are 1
+// and 0
+func mysqlStatusMetrics(status map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	for name, value := range status {
+		v, ok := mysqlValue(value)
+		if !ok {
+			continue
+		}
+		m := metric.WithValue("mysql."+name, v)
+		if !mysqlStatusIsGauge(name) {
+			m.MetricType = metric.CumulativeCounter
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func mysqlStatusIsGauge(name string) bool {
+	if mysqlStatusGauges[name] {
+		return true
+	}
+	for _, prefix := range mysqlStatusGaugePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return name != "threads_created"
+		}
+	}
+	return false
+}
+
+func mysqlVariableMetrics(variables map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	for _, name := range mysqlVariables {
+		if v, ok := mysqlValue(variables[name]); ok {
+			metrics = append(metrics, metric.WithValue("mysql.variables."+name, v))
+		}
+	}
+	return metrics
+}
+
+// mysqlBufferPoolMetrics reports the share of the buffer pool in use and
+// dirty, and how often reads missed it since the start
+func mysqlBufferPoolMetrics(status map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	value := func(name string) float64 {
+		v, _ := strconv.ParseFloat(status[name], 64)
+		return v
+	}
+
+	if total := value("innodb_buffer_pool_pages_total"); total > 0 {
+		free := value("innodb_buffer_pool_pages_free")
+		metrics = append(metrics, metric.WithValue("mysql.innodb.buffer_pool_utilization", (total-free)/total))
+		metrics = append(metrics, metric.WithValue("mysql.innodb.buffer_pool_dirty_ratio", value("innodb_buffer_pool_pages_dirty")/total))
+	}
+	if requests := value("innodb_buffer_pool_read_requests"); requests > 0 {
+		metrics = append(metrics, metric.WithValue("mysql.innodb.buffer_pool_miss_ratio", value("innodb_buffer_pool_reads")/requests))
+	}
+	return metrics
+}
+
+// mysqlInnoDBMetrics reports the enabled InnoDB metrics, like the row lock
+// waits and the history list length. Counters are cumulative.
+func mysqlInnoDBMetrics(conn mysqlQuerier) ([]metric.Metric, error) {
+	result, err := conn.Query("SELECT NAME, COUNT, TYPE FROM information_schema.INNODB_METRICS WHERE STATUS = 'enabled'")
+	if err != nil {
+		return nil, err
+	}
+	metrics := []metric.Metric{}
+	for _, row := range result.Rows {
+		v, err := strconv.ParseFloat(row[1].String, 64)
+		if err != nil {
+			continue
+		}
+		m := metric.WithValue("mysql.innodb."+strings.ToLower(row[0].String), v)
+		if row[2].String == "counter" || row[2].String == "status_counter" {
+			m.MetricType = metric.CumulativeCounter
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// mysqlSchemaMetrics reports the tables, rows and bytes of each schema,
+// the rows of InnoDB tables are estimates
+func mysqlSchemaMetrics(conn mysqlQuerier) ([]metric.Metric, error) {
+	result, err := conn.Query(mysqlSchemaSizesQuery)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{"tables", "rows", "data_bytes", "index_bytes", "free_bytes"}
+	metrics := []metric.Metric{}
+	for _, row := range result.Rows {
+		if len(row) != len(names)+1 {
+			return nil, fmt.Errorf("unexpected columns %v", result.Columns)
+		}
+		for i, name := range names {
+			v, err := strconv.ParseFloat(row[i+1].String, 64)
+			if err != nil {
+				continue
+			}
+			m := metric.WithValue("mysql.schema."+name, v)
+			m.AddDimension("schema", row[0].String)
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+// mysqlValue parses a numeric value, ON and OFF being 1 and 0
+func mysqlValue(value string) (float64, bool) {
+	switch value {
+	case "ON":
+		return 1, true
+	case "OFF":
+		return 0, true
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	return v, err == nil
+}
@@ -0,0 +1,249 @@
+package collector
+
+import (
+	"database/sql"
+	"fmt"
+	"fullerite/metric"
+	"fullerite/util"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMySQLQuerier answers the queries with canned results, the queries
+// without one fail with a MySQL error
+type fakeMySQLQuerier struct {
+	results map[string]*util.MySQLResult
+	closed  bool
+}
+
+func (f *fakeMySQLQuerier) Query(query string) (*util.MySQLResult, error) {
+	if result, exists := f.results[query]; exists {
+		return result, nil
+	}
+	return nil, util.MySQLError{Code: 1064, State: "42000", Message: "syntax error"}
+}
+
+func (f *fakeMySQLQuerier) Close() error {
+	f.closed = true
+	return nil
+}
+
+func mysqlResult(columns []string, rows ...[]interface{}) *util.MySQLResult {
+	result := &util.MySQLResult{Columns: columns}
+	for _, row := range rows {
+		values := make([]sql.NullString, len(row))
+		for i, v := range row {
+			if v != nil {
+				values[i] = sql.NullString{String: fmt.Sprint(v), Valid: true}
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+func getTestMySQL() *MySQL {
+	return newMySQL(make(chan metric.Metric), 10, l.WithField("testing", "mysql")).(*MySQL)
+}
+
+func getFakeMySQLServer() *fakeMySQLQuerier {
+	keyValue := []string{"Variable_name", "Value"}
+	return &fakeMySQLQuerier{results: map[string]*util.MySQLResult{
+		"SHOW GLOBAL STATUS": mysqlResult(keyValue,
+			[]interface{}{"Com_select", "1000"},
+			[]interface{}{"Threads_connected", "12"},
+			[]interface{}{"Threads_created", "40"},
+			[]interface{}{"Open_tables", "300"},
+			[]interface{}{"Opened_tables", "500"},
+			[]interface{}{"Innodb_buffer_pool_pages_total", "1000"},
+			[]interface{}{"Innodb_buffer_pool_pages_free", "250"},
+			[]interface{}{"Innodb_buffer_pool_pages_dirty", "100"},
+			[]interface{}{"Innodb_buffer_pool_read_requests", "10000"},
+			[]interface{}{"Innodb_buffer_pool_reads", "100"},
+			[]interface{}{"Innodb_row_lock_waits", "7"},
+			[]interface{}{"Innodb_row_lock_current_waits", "1"},
+			[]interface{}{"Ssl_cipher", ""},
+			[]interface{}{"Rpl_semi_sync_master_status", "ON"},
+		),
+		"SHOW GLOBAL VARIABLES": mysqlResult(keyValue,
+			[]interface{}{"max_connections", "151"},
+			[]interface{}{"read_only", "OFF"},
+			[]interface{}{"version", "8.0.30"},
+		),
+		"SHOW SLAVE STATUS": mysqlResult(
+			[]string{"Master_Host", "Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master", "Relay_Log_Space", "Last_IO_Errno", "Last_SQL_Errno"},
+			[]interface{}{"db1", "Yes", "No", nil, "2048", "0", "1062"},
+		),
+		"SELECT NAME, COUNT, TYPE FROM information_schema.INNODB_METRICS WHERE STATUS = 'enabled'": mysqlResult(
+			[]string{"NAME", "COUNT", "TYPE"},
+			[]interface{}{"trx_rseg_history_len", "42", "value"},
+			[]interface{}{"lock_row_lock_waits", "7", "counter"},
+		),
+		mysqlSchemaSizesQuery: mysqlResult(
+			[]string{"table_schema", "COUNT(*)", "SUM(table_rows)", "SUM(data_length)", "SUM(index_length)", "SUM(data_free)"},
+			[]interface{}{"app", "3", "1200", "16384", "8192", "0"},
+		),
+	}}
+}
+
+func mysqlMetricsByName(metrics []metric.Metric) map[string]metric.Metric {
+	byName := map[string]metric.Metric{}
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+	return byName
+}
+
+func TestMySQLConfigure(t *testing.T) {
+	m := getTestMySQL()
+	m.Configure(map[string]interface{}{})
+	assert.Equal(t, "", m.dsn)
+	assert.Equal(t, defaultCnfPath, m.myCnfPath)
+	assert.Equal(t, 5, m.timeout)
+	assert.True(t, m.collectVariables)
+	assert.True(t, m.collectReplication)
+	assert.True(t, m.collectInnoDB)
+	assert.False(t, m.collectSchemaSizes)
+
+	m.Configure(map[string]interface{}{
+		"dsn":                "monitor@tcp(db1:3306)/",
+		"mycnf":              "/etc/mysql/monitor.cnf",
+		"timeout":            "2",
+		"collectReplication": "false",
+		"collectSchemaSizes": true,
+	})
+	assert.Equal(t, "monitor@tcp(db1:3306)/", m.dsn)
+	assert.Equal(t, "/etc/mysql/monitor.cnf", m.myCnfPath)
+	assert.Equal(t, 2, m.timeout)
+	assert.False(t, m.collectReplication)
+	assert.True(t, m.collectSchemaSizes)
+}
+
+func TestMySQLDataSource(t *testing.T) {
+	cnf, err := ioutil.TempFile("", "my.cnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(cnf.Name())
+	cnf.WriteString("[mysqld]\ndatadir = /var/lib/mysql\n[client]\nuser = monitor\npassword = \"s3cret\"\nsocket = /run/mysqld/mysqld.sock\n")
+	cnf.Close()
+
+	tests := []struct {
+		dsn      string
+		expected util.MySQLDSN
+		msg      string
+	}{
+		{"", util.MySQLDSN{User: "monitor", Password: "s3cret", Net: "unix", Addr: "/run/mysqld/mysqld.sock"}, "Should connect as configured in my.cnf"},
+		{"tcp(db1)/", util.MySQLDSN{User: "monitor", Password: "s3cret", Net: "tcp", Addr: "db1:3306"}, "Should use the credentials of my.cnf"},
+		{"root:pw@tcp(db1:3307)/", util.MySQLDSN{User: "root", Password: "pw", Net: "tcp", Addr: "db1:3307"}, "Should keep the credentials of the DSN"},
+	}
+	for _, test := range tests {
+		m := getTestMySQL()
+		m.Configure(map[string]interface{}{"dsn": test.dsn, "mycnf": cnf.Name()})
+		dsn, err := m.dataSource()
+		assert.Nil(t, err, test.msg)
+		assert.Equal(t, test.expected, dsn, test.msg)
+	}
+
+	m := getTestMySQL()
+	m.Configure(map[string]interface{}{"mycnf": "/does/not/exist"})
+	dsn, err := m.dataSource()
+	assert.Nil(t, err)
+	assert.Equal(t, util.MySQLDSN{Net: "tcp", Addr: "127.0.0.1:3306"}, dsn)
+}
+
+func TestMySQLMetrics(t *testing.T) {
+	m := getTestMySQL()
+	m.Configure(map[string]interface{}{"collectSchemaSizes": true})
+	metrics := mysqlMetricsByName(m.metrics(getFakeMySQLServer()))
+
+	assert.Equal(t, 1000.0, metrics["mysql.com_select"].Value)
+	assert.Equal(t, metric.CumulativeCounter, metrics["mysql.com_select"].MetricType)
+	assert.Equal(t, metric.Gauge, metrics["mysql.threads_connected"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, metrics["mysql.threads_created"].MetricType)
+	assert.Equal(t, metric.Gauge, metrics["mysql.open_tables"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, metrics["mysql.opened_tables"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, metrics["mysql.innodb_row_lock_waits"].MetricType)
+	assert.Equal(t, metric.Gauge, metrics["mysql.innodb_row_lock_current_waits"].MetricType)
+	assert.Equal(t, 1.0, metrics["mysql.rpl_semi_sync_master_status"].Value)
+	assert.NotContains(t, metrics, "mysql.ssl_cipher")
+
+	assert.Equal(t, 151.0, metrics["mysql.variables.max_connections"].Value)
+	assert.Equal(t, 0.0, metrics["mysql.variables.read_only"].Value)
+	assert.NotContains(t, metrics, "mysql.variables.version")
+
+	assert.NotContains(t, metrics, "mysql.replication.seconds_behind_source")
+	assert.Equal(t, 1.0, metrics["mysql.replication.io_running"].Value)
+	assert.Equal(t, 0.0, metrics["mysql.replication.sql_running"].Value)
+	assert.Equal(t, 1062.0, metrics["mysql.replication.last_sql_errno"].Value)
+	assert.Equal(t, "db1", metrics["mysql.replication.io_running"].Dimensions["source_host"])
+
+	assert.Equal(t, 0.75, metrics["mysql.innodb.buffer_pool_utilization"].Value)
+	assert.Equal(t, 0.1, metrics["mysql.innodb.buffer_pool_dirty_ratio"].Value)
+	assert.Equal(t, 0.01, metrics["mysql.innodb.buffer_pool_miss_ratio"].Value)
+	assert.Equal(t, 42.0, metrics["mysql.innodb.trx_rseg_history_len"].Value)
+	assert.Equal(t, metric.Gauge, metrics["mysql.innodb.trx_rseg_history_len"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, metrics["mysql.innodb.lock_row_lock_waits"].MetricType)
+
+	assert.Equal(t, 16384.0, metrics["mysql.schema.data_bytes"].Value)
+	assert.Equal(t, 3.0, metrics["mysql.schema.tables"].Value)
+	assert.Equal(t, "app", metrics["mysql.schema.tables"].Dimensions["schema"])
+}
+
+func TestMySQLReplicaStatus(t *testing.T) {
+	server := getFakeMySQLServer()
+	server.results["SHOW REPLICA STATUS"] = mysqlResult(
+		[]string{"Source_Host", "Replica_IO_Running", "Replica_SQL_Running", "Seconds_Behind_Source", "Channel_Name"},
+		[]interface{}{"db1", "Yes", "Yes", "3", "east"},
+		[]interface{}{"db2", "Connecting", "Yes", "0", "west"},
+	)
+
+	m := getTestMySQL()
+	metrics, err := m.replicationMetrics(server)
+	assert.Nil(t, err)
+
+	lags := map[string]float64{}
+	running := map[string]float64{}
+	for _, metric := range metrics {
+		switch metric.Name {
+		case "mysql.replication.seconds_behind_source":
+			lags[metric.Dimensions["channel"]] = metric.Value
+		case "mysql.replication.io_running":
+			running[metric.Dimensions["channel"]] = metric.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{"east": 3, "west": 0}, lags)
+	assert.Equal(t, map[string]float64{"east": 1, "west": 0}, running)
+}
+
+func TestMySQLCollect(t *testing.T) {
+	oldDialMySQL := dialMySQL
+	defer func() { dialMySQL = oldDialMySQL }()
+
+	server := getFakeMySQLServer()
+	var dialed util.MySQLDSN
+	dialMySQL = func(dsn util.MySQLDSN, timeout time.Duration) (mysqlQuerier, error) {
+		dialed = dsn
+		return server, nil
+	}
+
+	m := newMySQL(make(chan metric.Metric, 100), 10, l.WithField("testing", "mysql")).(*MySQL)
+	m.Configure(map[string]interface{}{"dsn": "monitor:pw@tcp(db1:3306)/", "mycnf": "/does/not/exist"})
+	m.Collect()
+
+	assert.Equal(t, "db1:3306", dialed.Addr)
+	assert.True(t, server.closed)
+	assert.NotEqual(t, 0, len(m.Channel()))
+
+	dialMySQL = func(dsn util.MySQLDSN, timeout time.Duration) (mysqlQuerier, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	m = newMySQL(make(chan metric.Metric, 100), 10, l.WithField("testing", "mysql")).(*MySQL)
+	m.Collect()
+	assert.Equal(t, 0, len(m.Channel()))
+}
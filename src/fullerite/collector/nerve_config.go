@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"fullerite/config"
+	"strings"
+)
+
+const defaultNerveConfigPath = "/etc/nerve/nerve.conf.json"
+
+// getNerveConfigPaths reads the configFilePath option of the nerve based
+// collectors, either a glob or a list of globs matching nerve and synapse
+// config files
+func getNerveConfigPaths(value interface{}) []string {
+	if path, ok := value.(string); ok && !strings.HasPrefix(strings.TrimSpace(path), "[") {
+		return []string{path}
+	}
+	return config.GetAsSlice(value)
+}
@@ -0,0 +1,14 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNerveConfigPaths(t *testing.T) {
+	assert.Equal(t, []string{"/etc/nerve/nerve.conf.json"}, getNerveConfigPaths("/etc/nerve/nerve.conf.json"))
+	assert.Equal(t, []string{"/etc/nerve/*.json", "/etc/synapse/synapse.conf.json"},
+		getNerveConfigPaths(`["/etc/nerve/*.json", "/etc/synapse/synapse.conf.json"]`))
+	assert.Equal(t, []string{"/etc/nerve/*.json"}, getNerveConfigPaths([]interface{}{"/etc/nerve/*.json"}))
+}
@@ -43,12 +43,14 @@ var (
 )
 
 // NerveHTTPD discovers Apache servers via Nerve config
-// and reports metric for them
+// and reports metric for them. The statusFormat option reads the html or
+// json ExtendedStatus pages instead of the ?auto output, see apache_status.go
 type NerveHTTPD struct {
 	baseCollector
 
-	configFilePath    string
+	configFilePaths   []string
 	queryPath         string
+	statusFormat      string
 	timeout           int
 	statusTTL         time.Duration
 	servicesWhitelist []string
@@ -71,8 +73,9 @@ func newNerveHTTPD(channel chan metric.Metric, initialInterval int, log *l.Entry
 	c.log = log
 
 	c.name = "NerveHTTPD"
-	c.configFilePath = "/etc/nerve/nerve.conf.json"
-	c.queryPath = "server-status?auto"
+	c.configFilePaths = []string{defaultNerveConfigPath}
+	c.queryPath = apacheStatusQueryPaths[apacheStatusAuto]
+	c.statusFormat = apacheStatusAuto
 	c.timeout = 2
 	c.statusTTL = time.Duration(60) * time.Minute
 	return c
@@ -80,12 +83,21 @@ func newNerveHTTPD(channel chan metric.Metric, initialInterval int, log *l.Entry
 
 // Configure the collector
 func (c *NerveHTTPD) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["statusFormat"]; exists {
+		if _, known := apacheStatusQueryPaths[val.(string)]; known {
+			c.statusFormat = val.(string)
+			c.queryPath = apacheStatusQueryPaths[c.statusFormat]
+		} else {
+			c.log.Warn("Unknown statusFormat ", val, ", using ", c.statusFormat)
+		}
+	}
+
 	if val, exists := configMap["queryPath"]; exists {
 		c.queryPath = val.(string)
 	}
 
 	if val, exists := configMap["configFilePath"]; exists {
-		c.configFilePath = val.(string)
+		c.configFilePaths = getNerveConfigPaths(val)
 	}
 
 	if val, exists := configMap["status_ttl"]; exists {
@@ -102,15 +114,9 @@ func (c *NerveHTTPD) Configure(configMap map[string]interface{}) {
 
 // Collect the metrics
 func (c *NerveHTTPD) Collect() {
-	rawFileContents, err := ioutil.ReadFile(c.configFilePath)
-	if err != nil {
-		c.log.Warn("Failed to read the contents of file ", c.configFilePath, " because ", err)
-		return
-	}
-	services, err := util.ParseNerveConfig(&rawFileContents, true)
+	services, err := util.ReadNerveConfigs(c.configFilePaths, true)
 	if err != nil {
-		c.log.Warn("Failed to parse the nerve config at ", c.configFilePath, ": ", err)
-		return
+		c.log.Warn("Failed to read the nerve configs: ", err)
 	}
 	c.log.Debug("Finished parsing Nerve config into ", services)
 
@@ -151,7 +157,17 @@ func (c *NerveHTTPD) getMetrics(service util.NerveService) []metric.Metric {
 		serviceLog.Warn("Failed to query endpoint ", endpoint, ": ", httpResponse.err)
 		return results
 	}
-	apacheMetrics := extractApacheMetrics(httpResponse.data)
+	var apacheMetrics []metric.Metric
+	if c.statusFormat == apacheStatusAuto {
+		apacheMetrics = extractApacheMetrics(httpResponse.data)
+	} else {
+		var err error
+		apacheMetrics, err = extractExtendedApacheMetrics(httpResponse.data, c.statusFormat)
+		if err != nil {
+			serviceLog.Warn("Failed to parse the status of ", endpoint, ": ", err)
+			return results
+		}
+	}
 	metric.AddToAll(&apacheMetrics, map[string]string{
 		"service_name":      service.Name,
 		"service_namespace": service.Namespace,
@@ -41,7 +41,7 @@ func TestDefaultConfigNerveHTTPD(t *testing.T) {
 	collector.Configure(make(map[string]interface{}))
 
 	assert.Equal(t, 10, collector.Interval())
-	assert.Equal(t, "/etc/nerve/nerve.conf.json", collector.configFilePath)
+	assert.Equal(t, []string{"/etc/nerve/nerve.conf.json"}, collector.configFilePaths)
 	assert.Equal(t, "server-status?auto", collector.queryPath)
 	assert.Equal(t, time.Duration(1)*time.Hour, collector.statusTTL)
 	assert.Equal(t, "NerveHTTPD", collector.Name())
@@ -58,12 +58,29 @@ func TestCustomConfigNerveHTTPD(t *testing.T) {
 	collector.Configure(configMap)
 
 	assert.Equal(t, 10, collector.Interval())
-	assert.Equal(t, "/tmp/foobar", collector.configFilePath)
+	assert.Equal(t, []string{"/tmp/foobar"}, collector.configFilePaths)
 	assert.Equal(t, "server-status?auto", collector.queryPath)
 	assert.Equal(t, time.Duration(120)*time.Second, collector.statusTTL)
 	assert.Equal(t, []string{"serv1.ns1", "serv2.ns2"}, collector.servicesWhitelist)
 }
 
+func TestStatusFormatConfigNerveHTTPD(t *testing.T) {
+	collector := getNerveHTTPDCollector()
+	collector.Configure(map[string]interface{}{"statusFormat": "html"})
+	assert.Equal(t, "html", collector.statusFormat)
+	assert.Equal(t, "server-status", collector.queryPath)
+
+	collector = getNerveHTTPDCollector()
+	collector.Configure(map[string]interface{}{"statusFormat": "json", "queryPath": "status?json"})
+	assert.Equal(t, "json", collector.statusFormat)
+	assert.Equal(t, "status?json", collector.queryPath)
+
+	collector = getNerveHTTPDCollector()
+	collector.Configure(map[string]interface{}{"statusFormat": "xml"})
+	assert.Equal(t, "auto", collector.statusFormat)
+	assert.Equal(t, "server-status?auto", collector.queryPath)
+}
+
 func TestExtractApacheMetrics(t *testing.T) {
 	metrics := extractApacheMetrics(getRawApacheStat())
 	metricMap := map[string]metric.Metric{}
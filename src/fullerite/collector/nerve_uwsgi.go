@@ -27,13 +27,14 @@ const (
 type nerveUWSGICollector struct {
 	baseCollector
 
-	configFilePath        string
+	configFilePaths       []string
 	queryPath             string
 	timeout               int
 	servicesWhitelist     []string
 	workersStatsEnabled   bool
 	workersStatsQueryPath string
 	workersStatsBlacklist []string
+	options               dropwizard.Options
 }
 
 func init() {
@@ -49,7 +50,7 @@ func newNerveUWSGI(channel chan metric.Metric, initialInterval int, log *l.Entry
 	col.interval = initialInterval
 
 	col.name = "NerveUWSGI"
-	col.configFilePath = "/etc/nerve/nerve.conf.json"
+	col.configFilePaths = []string{defaultNerveConfigPath}
 	col.queryPath = "status/metrics"
 	col.workersStatsQueryPath = "status/uwsgi"
 	col.timeout = 2
@@ -63,7 +64,7 @@ func (n *nerveUWSGICollector) Configure(configMap map[string]interface{}) {
 		n.queryPath = val.(string)
 	}
 	if val, exists := configMap["configFilePath"]; exists {
-		n.configFilePath = val.(string)
+		n.configFilePaths = getNerveConfigPaths(val)
 	}
 	if val, exists := configMap["servicesWhitelist"]; exists {
 		n.servicesWhitelist = config.GetAsSlice(val)
@@ -80,22 +81,16 @@ func (n *nerveUWSGICollector) Configure(configMap map[string]interface{}) {
 	if val, exists := configMap["http_timeout"]; exists {
 		n.timeout = config.GetAsInt(val, 2)
 	}
+	configureDropwizardOptions(configMap, &n.options)
 
 	n.configureCommonParams(configMap)
 }
 
 // Parses nerve config from HTTP uWSGI stats endpoints
 func (n *nerveUWSGICollector) Collect() {
-	rawFileContents, err := ioutil.ReadFile(n.configFilePath)
+	services, err := util.ReadNerveConfigs(n.configFilePaths, false)
 	if err != nil {
-		n.log.Warn("Failed to read the contents of file ", n.configFilePath, " because ", err)
-		return
-	}
-
-	services, err := util.ParseNerveConfig(&rawFileContents, false)
-	if err != nil {
-		n.log.Warn("Failed to parse the nerve config at ", n.configFilePath, ": ", err)
-		return
+		n.log.Warn("Failed to read the nerve configs: ", err)
 	}
 	n.log.Debug("Finished parsing Nerve config into ", services)
 
@@ -115,7 +110,7 @@ func (n *nerveUWSGICollector) queryService(serviceName string, host string, port
 		serviceLog.Warn("Failed to query endpoint ", endpoint, ": ", err)
 		return
 	}
-	metrics, err := dropwizard.Parse(rawResponse, schemaVer, n.serviceInWhitelist(serviceName))
+	metrics, err := dropwizard.ParseWithOptions(rawResponse, schemaVer, n.serviceInWhitelist(serviceName), n.options)
 	if err != nil {
 		serviceLog.Warn("Failed to parse response into metrics: ", err)
 		return
@@ -156,11 +151,27 @@ func (n *nerveUWSGICollector) queryService(serviceName string, host string, port
 }
 
 func queryEndpoint(endpoint string, timeout int) ([]byte, string, error) {
+	return requestEndpoint("GET", endpoint, "", timeout)
+}
+
+// postEndpoint posts a JSON body instead of getting the endpoint
+func postEndpoint(endpoint string, body string, timeout int) ([]byte, string, error) {
+	return requestEndpoint("POST", endpoint, body, timeout)
+}
+
+func requestEndpoint(method string, endpoint string, body string, timeout int) ([]byte, string, error) {
 	client := http.Client{
 		Timeout: time.Duration(timeout) * time.Second,
 	}
 
-	rsp, err := client.Get(endpoint)
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+	if err != nil {
+		return []byte{}, "", err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rsp, err := client.Do(req)
 
 	if rsp != nil {
 		defer func() {
@@ -363,7 +363,7 @@ func TestDefaultConfigNerveUWSGI(t *testing.T) {
 
 	assert.Equal(t, 12, inst.Interval())
 	assert.Equal(t, 2, inst.timeout)
-	assert.Equal(t, "/etc/nerve/nerve.conf.json", inst.configFilePath)
+	assert.Equal(t, []string{"/etc/nerve/nerve.conf.json"}, inst.configFilePaths)
 	assert.Equal(t, "status/metrics", inst.queryPath)
 	assert.Equal(t, "status/uwsgi", inst.workersStatsQueryPath)
 }
@@ -374,15 +374,18 @@ func TestConfigNerveUWSGI(t *testing.T) {
 		"configFilePath": "/etc/your/moms/house",
 		"queryPath":      "littlepiggies",
 		"http_timeout":   12,
+		"durationUnit":   "seconds",
 	}
 
 	inst := getTestNerveUWSGI()
 	inst.Configure(cfg)
 
 	assert.Equal(t, 345, inst.Interval())
-	assert.Equal(t, "/etc/your/moms/house", inst.configFilePath)
+	assert.Equal(t, []string{"/etc/your/moms/house"}, inst.configFilePaths)
 	assert.Equal(t, "littlepiggies", inst.queryPath)
 	assert.Equal(t, 12, inst.timeout)
+	assert.Equal(t, "seconds", inst.options.DurationUnit)
+	assert.False(t, inst.options.RollupDimension)
 }
 
 func TestErrorQueryEndpointResponse(t *testing.T) {
@@ -0,0 +1,253 @@
+package collector
+
+import (
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+var (
+	// The counters reported by default, matched against Protocol.Counter
+	defaultNetStackCounters = []string{
+		`Ip6?\.(InReceives|InHdrErrors|InAddrErrors|InDiscards|InDelivers|OutRequests|OutDiscards|OutNoRoutes)`,
+		`Tcp\.(ActiveOpens|PassiveOpens|AttemptFails|EstabResets|CurrEstab|InSegs|OutSegs|RetransSegs|InErrs|OutRsts)`,
+		`TcpExt\.(ListenOverflows|ListenDrops|SyncookiesSent|SyncookiesFailed|TW|PruneCalled|TCPTimeouts|TCPLostRetransmit|TCPFastRetrans|TCPSlowStartRetrans|TCPSynRetrans|TCPBacklogDrop|TCPReqQFullDrop|TCPAbortOnData|TCPAbortOnTimeout|TCPAbortOnMemory)`,
+		`Udp6?\.(InDatagrams|OutDatagrams|NoPorts|InErrors|RcvbufErrors|SndbufErrors)`,
+		`Softnet\..*`,
+	}
+
+	// The values which are not counters
+	netStackGauges = map[string]bool{
+		"Ip.Forwarding":       true,
+		"Ip.DefaultTTL":       true,
+		"Tcp.RtoAlgorithm":    true,
+		"Tcp.RtoMin":          true,
+		"Tcp.RtoMax":          true,
+		"Tcp.MaxConn":         true,
+		"Tcp.CurrEstab":       true,
+		"Softnet.backlog_len": true,
+	}
+
+	// The columns of /proc/net/softnet_stat, the others are unused
+	softnetColumns = map[int]string{
+		0:  "processed",
+		1:  "dropped",
+		2:  "time_squeeze",
+		9:  "received_rps",
+		10: "flow_limit_count",
+		11: "backlog_len",
+	}
+
+	// The protocols of /proc/net/snmp6, longest first
+	snmp6Protocols = []string{"UdpLite6", "Icmp6", "Udp6", "Ip6"}
+)
+
+// NetStack collector
+// reports the counters of the TCP/IP stack read from /proc/net/snmp,
+// /proc/net/netstat and /proc/net/snmp6 as net.<Counter> with a protocol
+// dimension, tcp, tcpext, udp6..., and the packets processed and dropped
+// by each CPU from /proc/net/softnet_stat as net.<column> with the softnet
+// protocol and a cpu dimension.
+//
+// The counters reported are the ones matching one of the counters regexes,
+// like "TcpExt\.Listen.*" or ".*" for all of them. The defaults cover the
+// segments, the retransmits, the errors and the drops.
+type NetStack struct {
+	baseCollector
+
+	procPath string
+	counters *regexp.Regexp
+}
+
+// netStackValue is a value of one of the files
+type netStackValue struct {
+	protocol string
+	name     string
+	value    float64
+	cpu      string
+}
+
+func init() {
+	RegisterCollector("NetStack", newNetStack)
+}
+
+func newNetStack(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	n := new(NetStack)
+
+	n.log = log
+	n.channel = channel
+	n.interval = initialInterval
+
+	n.name = "NetStack"
+	n.procPath = "/proc"
+	n.counters = compileNetStackCounters(defaultNetStackCounters)
+	return n
+}
+
+// Configure takes a dictionary of values with which the handler can configure itself
+func (n *NetStack) Configure(configMap map[string]interface{}) {
+	if procPath, exists := configMap["procPath"]; exists {
+		n.procPath = procPath.(string)
+	}
+	if counters, exists := configMap["counters"]; exists {
+		patterns := []string{}
+		for _, pattern := range config.GetAsSlice(counters) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				n.log.Warn(fmt.Sprintf("Failed to compile regex %s. Error: %s", pattern, err))
+				continue
+			}
+			patterns = append(patterns, pattern)
+		}
+		n.counters = compileNetStackCounters(patterns)
+	}
+	n.configureCommonParams(configMap)
+}
+
+// compileNetStackCounters matches any of the patterns on the whole name
+func compileNetStackCounters(patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`^(?:(?:` + strings.Join(patterns, `)|(?:`) + `))$`)
+}
+
+// Collect reads the files and emits the counters
+func (n *NetStack) Collect() {
+	for _, m := range n.netStackMetrics() {
+		n.Channel() <- m
+	}
+}
+
+func (n *NetStack) netStackMetrics() []metric.Metric {
+	values := []netStackValue{}
+	for _, file := range []string{"snmp", "netstat", "snmp6", "softnet_stat"} {
+		path := filepath.Join(n.procPath, "net", file)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			// there is no snmp6 when IPv6 is disabled
+			if file == "snmp6" && os.IsNotExist(err) {
+				n.log.Debug("Skipping missing ", path)
+			} else {
+				n.log.Error("Error while reading ", path, ": ", err)
+			}
+			continue
+		}
+
+		switch file {
+		case "snmp6":
+			values = append(values, parseSNMP6(string(contents))...)
+		case "softnet_stat":
+			values = append(values, parseSoftnetStat(string(contents))...)
+		default:
+			values = append(values, parseNetStackTables(string(contents))...)
+		}
+	}
+
+	metrics := []metric.Metric{}
+	for _, value := range values {
+		key := value.protocol + "." + value.name
+		if n.counters == nil || !n.counters.MatchString(key) {
+			continue
+		}
+		m := metric.WithValue("net."+value.name, value.value)
+		if !netStackGauges[key] {
+			m.MetricType = metric.CumulativeCounter
+		}
+		m.AddDimension("protocol", strings.ToLower(value.protocol))
+		if value.cpu != "" {
+			m.AddDimension("cpu", value.cpu)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// parseNetStackTables parses /proc/net/snmp and /proc/net/netstat, made of
+// pairs of lines, the names then the values of a protocol:
+//
+//	Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens ...
+//	Tcp: 1 200 120000 -1 1364 ...
+func parseNetStackTables(contents string) []netStackValue {
+	values := []netStackValue{}
+	lines := strings.Split(strings.TrimSpace(contents), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		numbers := strings.Fields(lines[i+1])
+		if len(names) < 2 || len(names) != len(numbers) || names[0] != numbers[0] {
+			continue
+		}
+		protocol := strings.TrimSuffix(names[0], ":")
+		for j := 1; j < len(names); j++ {
+			value, err := strconv.ParseFloat(numbers[j], 64)
+			if err != nil {
+				continue
+			}
+			values = append(values, netStackValue{protocol: protocol, name: names[j], value: value})
+		}
+	}
+	return values
+}
+
+// parseSNMP6 parses /proc/net/snmp6, one counter per line prefixed by its
+// protocol:
+//
+//	Ip6InReceives                   	3
+func parseSNMP6(contents string) []netStackValue {
+	values := []netStackValue{}
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		for _, protocol := range snmp6Protocols {
+			if strings.HasPrefix(fields[0], protocol) {
+				name := strings.TrimPrefix(fields[0], protocol)
+				values = append(values, netStackValue{protocol: protocol, name: name, value: value})
+				break
+			}
+		}
+	}
+	return values
+}
+
+// parseSoftnetStat parses /proc/net/softnet_stat, one line of hexadecimal
+// columns per CPU. The CPU is the 13th column on recent kernels, the line
+// number otherwise, which is wrong with offline CPUs.
+func parseSoftnetStat(contents string) []netStackValue {
+	values := []netStackValue{}
+	for i, line := range strings.Split(strings.TrimSpace(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		cpu := strconv.Itoa(i)
+		if len(fields) > 12 {
+			if index, err := strconv.ParseUint(fields[12], 16, 32); err == nil {
+				cpu = strconv.FormatUint(index, 10)
+			}
+		}
+		for column, name := range softnetColumns {
+			if column >= len(fields) {
+				continue
+			}
+			value, err := strconv.ParseUint(fields[column], 16, 64)
+			if err != nil {
+				continue
+			}
+			values = append(values, netStackValue{protocol: "Softnet", name: name, value: float64(value), cpu: cpu})
+		}
+	}
+	return values
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"fullerite/metric"
+	"fullerite/test_utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestNetStack(config map[string]interface{}) *NetStack {
+	if _, exists := config["procPath"]; !exists {
+		config["procPath"] = path.Join(test_utils.DirectoryOfCurrentFile(), "/../../fixtures/proc")
+	}
+	n := newNetStack(make(chan metric.Metric), 10, test_utils.BuildLogger()).(*NetStack)
+	n.Configure(config)
+	return n
+}
+
+func netStackByName(metrics []metric.Metric) map[string]metric.Metric {
+	byName := map[string]metric.Metric{}
+	for _, m := range metrics {
+		key := m.Name + "," + m.Dimensions["protocol"]
+		if cpu, exists := m.Dimensions["cpu"]; exists {
+			key += "," + cpu
+		}
+		byName[key] = m
+	}
+	return byName
+}
+
+func TestNetStackConfigure(t *testing.T) {
+	n := newNetStack(make(chan metric.Metric), 10, test_utils.BuildLogger()).(*NetStack)
+	assert.Equal(t, "/proc", n.procPath)
+	assert.True(t, n.counters.MatchString("TcpExt.ListenOverflows"))
+	assert.False(t, n.counters.MatchString("TcpExt.TCPHPHits"))
+
+	n.Configure(map[string]interface{}{
+		"procPath": "/host/proc",
+		"counters": []interface{}{`TcpExt\.TCPHP.*`, "(invalid"},
+	})
+	assert.Equal(t, "/host/proc", n.procPath)
+	assert.True(t, n.counters.MatchString("TcpExt.TCPHPHits"))
+	assert.False(t, n.counters.MatchString("TcpExt.ListenOverflows"))
+	assert.False(t, n.counters.MatchString("XTcpExt.TCPHPHits"))
+}
+
+func TestNetStackDefaultCounters(t *testing.T) {
+	n := getTestNetStack(map[string]interface{}{})
+	metrics := netStackByName(n.netStackMetrics())
+
+	retrans := metrics["net.RetransSegs,tcp"]
+	assert.Equal(t, 4.0, retrans.Value)
+	assert.Equal(t, metric.CumulativeCounter, retrans.MetricType)
+
+	established := metrics["net.CurrEstab,tcp"]
+	assert.Equal(t, 2.0, established.Value)
+	assert.Equal(t, metric.Gauge, established.MetricType)
+
+	assert.Equal(t, 17.0, metrics["net.ListenOverflows,tcpext"].Value)
+	assert.Equal(t, 19.0, metrics["net.ListenDrops,tcpext"].Value)
+	assert.Equal(t, 3.0, metrics["net.InReceives,ip6"].Value)
+	assert.Equal(t, 10.0, metrics["net.InDatagrams,udp6"].Value)
+	assert.Equal(t, 2.0, metrics["net.RcvbufErrors,udp6"].Value)
+	assert.Equal(t, 32.0, metrics["net.InDatagrams,udp"].Value)
+
+	_, exists := metrics["net.RtoMin,tcp"]
+	assert.False(t, exists)
+	_, exists = metrics["net.InMsgs,icmp6"]
+	assert.False(t, exists)
+	_, exists = metrics["net.InDatagrams,udplite6"]
+	assert.False(t, exists)
+}
+
+func TestNetStackSoftnet(t *testing.T) {
+	n := getTestNetStack(map[string]interface{}{"counters": []interface{}{`Softnet\..*`}})
+	metrics := n.netStackMetrics()
+	assert.Equal(t, 12, len(metrics))
+
+	byName := netStackByName(metrics)
+	assert.Equal(t, 26958.0, byName["net.processed,softnet,0"].Value)
+	assert.Equal(t, 2.0, byName["net.dropped,softnet,0"].Value)
+	assert.Equal(t, 17.0, byName["net.time_squeeze,softnet,0"].Value)
+	assert.Equal(t, 4096.0, byName["net.processed,softnet,1"].Value)
+	assert.Equal(t, 4.0, byName["net.received_rps,softnet,1"].Value)
+	assert.Equal(t, 5.0, byName["net.flow_limit_count,softnet,1"].Value)
+	assert.Equal(t, 2.0, byName["net.backlog_len,softnet,1"].Value)
+	assert.Equal(t, metric.Gauge, byName["net.backlog_len,softnet,1"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, byName["net.dropped,softnet,1"].MetricType)
+}
+
+func TestNetStackAllCounters(t *testing.T) {
+	n := getTestNetStack(map[string]interface{}{"counters": []interface{}{".*"}})
+	metrics := netStackByName(n.netStackMetrics())
+
+	assert.Equal(t, 120000.0, metrics["net.RtoMax,tcp"].Value)
+	assert.Equal(t, metric.Gauge, metrics["net.RtoMax,tcp"].MetricType)
+	assert.Equal(t, -1.0, metrics["net.MaxConn,tcp"].Value)
+	assert.Equal(t, 5.0, metrics["net.OutMsgs,icmp6"].Value)
+	assert.Equal(t, 0.0, metrics["net.InDatagrams,udplite6"].Value)
+	_, exists := metrics["net.InOctets,ipext"]
+	assert.True(t, exists)
+}
+
+func TestNetStackMissingFiles(t *testing.T) {
+	procPath, err := ioutil.TempDir("", "proc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(procPath)
+	os.Mkdir(path.Join(procPath, "net"), 0755)
+	ioutil.WriteFile(path.Join(procPath, "net", "snmp"), []byte("Tcp: RetransSegs\nTcp: 12\n"), 0644)
+
+	n := getTestNetStack(map[string]interface{}{"procPath": procPath})
+	metrics := n.netStackMetrics()
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, "net.RetransSegs", metrics[0].Name)
+	assert.Equal(t, 12.0, metrics[0].Value)
+}
+
+func TestParseSoftnetStatWithoutCPU(t *testing.T) {
+	values := parseSoftnetStat("00000001 00000002 00000003\n0000000a 0000000b 0000000c\n")
+	assert.Equal(t, 6, len(values))
+	for _, value := range values {
+		if value.cpu == "1" && value.name == "dropped" {
+			assert.Equal(t, 11.0, value.value)
+		}
+	}
+}
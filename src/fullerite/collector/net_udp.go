@@ -10,7 +10,7 @@ import (
 	"fmt"
 	"fullerite/metric"
 	"fullerite/util"
-	"os/exec"
+	"io/ioutil"
 	"regexp"
 	"strings"
 
@@ -40,6 +40,9 @@ type procNetUpdLine struct {
 	drops         string
 }
 
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var procNetUDPPath = "/proc/net/udp"
+
 type procNetUDPStats struct {
 	baseCollector
 	localAddressWhitelist  *regexp.Regexp
@@ -119,32 +122,13 @@ func (s *procNetUDPStats) createMetric(name string, value float64, dims map[stri
 }
 
 func (s *procNetUDPStats) getProcNetUDPStats() []procNetUpdLine {
-	cmdLine := []string{
-		"cat",
-		"/proc/net/udp",
-	}
-
-	out := s.runCommand(cmdLine)
-
-	if out == nil {
-		return nil
-	}
-
-	return s.parseProcNetUDPLines(string(out))
-}
-
-func (s *procNetUDPStats) runCommand(cmdLine []string) []byte {
-	var out []byte
-	var err error
-
-	cmd := exec.Command(cmdLine[0], cmdLine[1:]...)
-
-	if out, err = (*exec.Cmd).Output(cmd); err != nil {
+	out, err := ioutil.ReadFile(procNetUDPPath)
+	if err != nil {
 		s.log.Error(err.Error())
 		return nil
 	}
 
-	return out
+	return s.parseProcNetUDPLines(string(out))
 }
 
 func (s *procNetUDPStats) parseProcNetUDPLines(out string) []procNetUpdLine {
@@ -2,6 +2,8 @@ package collector
 
 import (
 	"fullerite/metric"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	l "github.com/Sirupsen/logrus"
@@ -74,3 +76,27 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "FEFFFEA9:1FBD", lines[1].remoteAddress)
 	assert.Equal(t, "100", lines[1].drops)
 }
+
+func TestProcNetUDPStatsCollect(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "udp")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+ 3152: FEFFFEA9:4ED6 00000000:0000 07 00000000:00000000 00:00000000 00000000 65534        0 3841266873 2 ffff88021734b480 12
+`)
+	tmpFile.Close()
+
+	oldPath := procNetUDPPath
+	defer func() { procNetUDPPath = oldPath }()
+	procNetUDPPath = tmpFile.Name()
+
+	c := make(chan metric.Metric, 1)
+	fakeCollector := newProcNetUDPStats(c, 10, l.WithField("testing", "net_udp")).(*procNetUDPStats)
+	fakeCollector.Configure(map[string]interface{}{"localAddressWhitelist": ":4ED6$"})
+	fakeCollector.Collect()
+
+	m := <-c
+	assert.Equal(t, "udp.drops", m.Name)
+	assert.Equal(t, 12.0, m.Value)
+	assert.Equal(t, map[string]string{"local_address": "FEFFFEA9:4ED6"}, m.Dimensions)
+}
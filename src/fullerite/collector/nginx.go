@@ -19,6 +19,7 @@ type nginxStats struct {
 	baseCollector
 	client   http.Client
 	statsURL string
+	format   string
 }
 
 const (
@@ -50,6 +51,7 @@ func newNginxStats(channel chan metric.Metric, initialInterval int, log *l.Entry
 	m.interval = initialInterval
 	m.name = "NginxStats"
 	m.client = http.Client{Timeout: nginxGetTimeout}
+	m.format = nginxStubStatusFormat
 
 	return m
 }
@@ -74,10 +76,23 @@ func (m *nginxStats) Configure(configMap map[string]interface{}) {
 	}
 
 	m.statsURL = fmt.Sprintf("http://%s:%s%s", host, port, path)
+
+	if val, exists := c["format"]; exists {
+		if validNginxFormat(val) {
+			m.format = val
+		} else {
+			m.log.Error("Unknown nginx status format ", val, ", using ", m.format)
+		}
+	}
+}
+
+// Targets returns the nginx status page scraped by the collector
+func (m *nginxStats) Targets() []string {
+	return []string{m.statsURL}
 }
 
 func (m *nginxStats) Collect() {
-	for _, metric := range getNginxMetrics(m.client, m.statsURL, m.log) {
+	for _, metric := range getNginxFormatMetrics(m.client, m.statsURL, m.format, m.log) {
 		m.Channel() <- metric
 	}
 }
@@ -0,0 +1,269 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"fullerite/metric"
+	"net/http"
+	"strings"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// The formats of the nginx status pages
+const (
+	nginxStubStatusFormat = "stub_status"
+	nginxVTSFormat        = "vts"
+	nginxPlusFormat       = "plus"
+)
+
+var nginxResponseClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// nginxResponses are the responses counted by status code class, the same in
+// the nginx-module-vts and nginx Plus APIs
+type nginxResponses struct {
+	C1xx float64 `json:"1xx"`
+	C2xx float64 `json:"2xx"`
+	C3xx float64 `json:"3xx"`
+	C4xx float64 `json:"4xx"`
+	C5xx float64 `json:"5xx"`
+}
+
+func (r nginxResponses) byClass() []float64 {
+	return []float64{r.C1xx, r.C2xx, r.C3xx, r.C4xx, r.C5xx}
+}
+
+// nginxVTSStatus is the status of nginx-module-vts, /status/format/json
+type nginxVTSStatus struct {
+	Connections struct {
+		Active   float64 `json:"active"`
+		Reading  float64 `json:"reading"`
+		Writing  float64 `json:"writing"`
+		Waiting  float64 `json:"waiting"`
+		Accepted float64 `json:"accepted"`
+		Handled  float64 `json:"handled"`
+		Requests float64 `json:"requests"`
+	} `json:"connections"`
+	ServerZones   map[string]nginxVTSZone   `json:"serverZones"`
+	UpstreamZones map[string][]nginxVTSZone `json:"upstreamZones"`
+}
+
+type nginxVTSZone struct {
+	Server         string         `json:"server"`
+	RequestCounter float64        `json:"requestCounter"`
+	InBytes        float64        `json:"inBytes"`
+	OutBytes       float64        `json:"outBytes"`
+	Responses      nginxResponses `json:"responses"`
+	RequestMsec    float64        `json:"requestMsec"`
+	ResponseMsec   float64        `json:"responseMsec"`
+	Backup         bool           `json:"backup"`
+	Down           bool           `json:"down"`
+}
+
+// nginxPlusZone is a server zone of the nginx Plus API, /api/N/http/server_zones
+type nginxPlusZone struct {
+	Processing float64        `json:"processing"`
+	Requests   float64        `json:"requests"`
+	Responses  nginxResponses `json:"responses"`
+	Discarded  float64        `json:"discarded"`
+	Received   float64        `json:"received"`
+	Sent       float64        `json:"sent"`
+}
+
+// nginxPlusUpstream is an upstream of the nginx Plus API, /api/N/http/upstreams
+type nginxPlusUpstream struct {
+	Peers []struct {
+		Server       string         `json:"server"`
+		Backup       bool           `json:"backup"`
+		State        string         `json:"state"`
+		Active       float64        `json:"active"`
+		Requests     float64        `json:"requests"`
+		Responses    nginxResponses `json:"responses"`
+		Sent         float64        `json:"sent"`
+		Received     float64        `json:"received"`
+		Fails        float64        `json:"fails"`
+		Unavail      float64        `json:"unavail"`
+		HealthChecks struct {
+			Checks    float64 `json:"checks"`
+			Fails     float64 `json:"fails"`
+			Unhealthy float64 `json:"unhealthy"`
+		} `json:"health_checks"`
+		HeaderTime   float64 `json:"header_time"`
+		ResponseTime float64 `json:"response_time"`
+	} `json:"peers"`
+}
+
+func validNginxFormat(format string) bool {
+	switch format {
+	case nginxStubStatusFormat, nginxVTSFormat, nginxPlusFormat:
+		return true
+	}
+	return false
+}
+
+// getNginxFormatMetrics queries the status page in the format given, the
+// base path of the API for nginx Plus
+func getNginxFormatMetrics(client http.Client, statsURL string, format string, log *l.Entry) []metric.Metric {
+	var metrics []metric.Metric
+	var err error
+	switch format {
+	case nginxVTSFormat:
+		metrics, err = getNginxVTSMetrics(client, statsURL)
+	case nginxPlusFormat:
+		metrics, err = getNginxPlusMetrics(client, statsURL)
+	default:
+		return getNginxMetrics(client, statsURL, log)
+	}
+	if err != nil {
+		log.Error("Could not load stats from nginx: ", err.Error())
+		return nil
+	}
+	return metrics
+}
+
+func getNginxVTSMetrics(client http.Client, statsURL string) ([]metric.Metric, error) {
+	contents, err := queryNginxStats(client, statsURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseNginxVTSMetrics([]byte(contents))
+}
+
+func parseNginxVTSMetrics(contents []byte) ([]metric.Metric, error) {
+	var status nginxVTSStatus
+	if err := json.Unmarshal(contents, &status); err != nil {
+		return nil, fmt.Errorf("invalid vts status: %s", err)
+	}
+
+	conn := status.Connections
+	metrics := []metric.Metric{
+		buildNginxMetric("nginx.active_connections", metric.Gauge, conn.Active),
+		buildNginxMetric("nginx.conn_accepted", metric.CumulativeCounter, conn.Accepted),
+		buildNginxMetric("nginx.conn_handled", metric.CumulativeCounter, conn.Handled),
+		buildNginxMetric("nginx.req_handled", metric.CumulativeCounter, conn.Requests),
+		buildNginxMetric("nginx.act_reads", metric.Gauge, conn.Reading),
+		buildNginxMetric("nginx.act_writes", metric.Gauge, conn.Writing),
+		buildNginxMetric("nginx.act_waits", metric.Gauge, conn.Waiting),
+	}
+
+	for name, zone := range status.ServerZones {
+		dims := map[string]string{"zone": name}
+		metrics = append(metrics, buildNginxTrafficMetrics(
+			"nginx.zone", dims, zone.RequestCounter, zone.Responses, zone.InBytes, zone.OutBytes,
+		)...)
+		metrics = append(metrics, buildNginxDimensionedMetric(
+			"nginx.zone.request_time_ms", metric.Gauge, zone.RequestMsec, dims,
+		))
+	}
+
+	for name, peers := range status.UpstreamZones {
+		for _, peer := range peers {
+			dims := map[string]string{"upstream": name, "peer": peer.Server}
+			metrics = append(metrics, buildNginxTrafficMetrics(
+				"nginx.upstream", dims, peer.RequestCounter, peer.Responses, peer.InBytes, peer.OutBytes,
+			)...)
+			metrics = append(
+				metrics,
+				buildNginxDimensionedMetric("nginx.upstream.response_time_ms", metric.Gauge, peer.ResponseMsec, dims),
+				buildNginxDimensionedMetric("nginx.upstream.peer_up", metric.Gauge, nginxBool(!peer.Down), dims),
+				buildNginxDimensionedMetric("nginx.upstream.peer_backup", metric.Gauge, nginxBool(peer.Backup), dims),
+			)
+		}
+	}
+
+	return metrics, nil
+}
+
+func getNginxPlusMetrics(client http.Client, apiURL string) ([]metric.Metric, error) {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	zones, err := queryNginxStats(client, apiURL+"/http/server_zones")
+	if err != nil {
+		return nil, err
+	}
+	upstreams, err := queryNginxStats(client, apiURL+"/http/upstreams")
+	if err != nil {
+		return nil, err
+	}
+	return parseNginxPlusMetrics([]byte(zones), []byte(upstreams))
+}
+
+func parseNginxPlusMetrics(zonesContents []byte, upstreamsContents []byte) ([]metric.Metric, error) {
+	var zones map[string]nginxPlusZone
+	if err := json.Unmarshal(zonesContents, &zones); err != nil {
+		return nil, fmt.Errorf("invalid nginx Plus server zones: %s", err)
+	}
+	var upstreams map[string]nginxPlusUpstream
+	if err := json.Unmarshal(upstreamsContents, &upstreams); err != nil {
+		return nil, fmt.Errorf("invalid nginx Plus upstreams: %s", err)
+	}
+
+	metrics := []metric.Metric{}
+	for name, zone := range zones {
+		dims := map[string]string{"zone": name}
+		metrics = append(metrics, buildNginxTrafficMetrics(
+			"nginx.zone", dims, zone.Requests, zone.Responses, zone.Received, zone.Sent,
+		)...)
+		metrics = append(
+			metrics,
+			buildNginxDimensionedMetric("nginx.zone.processing", metric.Gauge, zone.Processing, dims),
+			buildNginxDimensionedMetric("nginx.zone.discarded", metric.CumulativeCounter, zone.Discarded, dims),
+		)
+	}
+
+	for name, upstream := range upstreams {
+		for _, peer := range upstream.Peers {
+			dims := map[string]string{"upstream": name, "peer": peer.Server}
+			metrics = append(metrics, buildNginxTrafficMetrics(
+				"nginx.upstream", dims, peer.Requests, peer.Responses, peer.Received, peer.Sent,
+			)...)
+			metrics = append(
+				metrics,
+				buildNginxDimensionedMetric("nginx.upstream.active", metric.Gauge, peer.Active, dims),
+				buildNginxDimensionedMetric("nginx.upstream.header_time_ms", metric.Gauge, peer.HeaderTime, dims),
+				buildNginxDimensionedMetric("nginx.upstream.response_time_ms", metric.Gauge, peer.ResponseTime, dims),
+				buildNginxDimensionedMetric("nginx.upstream.peer_up", metric.Gauge, nginxBool(peer.State == "up"), dims),
+				buildNginxDimensionedMetric("nginx.upstream.peer_backup", metric.Gauge, nginxBool(peer.Backup), dims),
+				buildNginxDimensionedMetric("nginx.upstream.fails", metric.CumulativeCounter, peer.Fails, dims),
+				buildNginxDimensionedMetric("nginx.upstream.unavail", metric.CumulativeCounter, peer.Unavail, dims),
+				buildNginxDimensionedMetric("nginx.upstream.health_checks", metric.CumulativeCounter, peer.HealthChecks.Checks, dims),
+				buildNginxDimensionedMetric("nginx.upstream.health_check_fails", metric.CumulativeCounter, peer.HealthChecks.Fails, dims),
+				buildNginxDimensionedMetric("nginx.upstream.unhealthy", metric.CumulativeCounter, peer.HealthChecks.Unhealthy, dims),
+			)
+			peerState := buildNginxDimensionedMetric("nginx.upstream.peer_state", metric.Gauge, 1, dims)
+			peerState.AddDimension("state", peer.State)
+			metrics = append(metrics, peerState)
+		}
+	}
+
+	return metrics, nil
+}
+
+// buildNginxTrafficMetrics builds the requests, responses by code class and
+// bytes of a server zone or an upstream peer
+func buildNginxTrafficMetrics(prefix string, dims map[string]string,
+	requests float64, responses nginxResponses, bytesIn float64, bytesOut float64) []metric.Metric {
+	metrics := []metric.Metric{
+		buildNginxDimensionedMetric(prefix+".requests", metric.CumulativeCounter, requests, dims),
+		buildNginxDimensionedMetric(prefix+".bytes_in", metric.CumulativeCounter, bytesIn, dims),
+		buildNginxDimensionedMetric(prefix+".bytes_out", metric.CumulativeCounter, bytesOut, dims),
+	}
+	for i, count := range responses.byClass() {
+		m := buildNginxDimensionedMetric(prefix+".responses", metric.CumulativeCounter, count, dims)
+		m.AddDimension("code_class", nginxResponseClasses[i])
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func buildNginxDimensionedMetric(name string, metricType string, value float64, dims map[string]string) metric.Metric {
+	m := buildNginxMetric(name, metricType, value)
+	m.AddDimensions(dims)
+	return m
+}
+
+func nginxBool(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"fullerite/metric"
+	"fullerite/test_utils"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func readNginxFixture(t *testing.T, name string) []byte {
+	contents, err := ioutil.ReadFile(path.Join(test_utils.DirectoryOfCurrentFile(), "/../../fixtures/nginx", name))
+	assert.Nil(t, err)
+	return contents
+}
+
+func nginxMetricsByKey(metrics []metric.Metric) map[string]metric.Metric {
+	byKey := map[string]metric.Metric{}
+	for i := range metrics {
+		byKey[seriesKey(&metrics[i])] = metrics[i]
+	}
+	return byKey
+}
+
+func TestParseNginxVTSMetrics(t *testing.T) {
+	metrics, err := parseNginxVTSMetrics(readNginxFixture(t, "vts.json"))
+	assert.Nil(t, err)
+	// 7 connection metrics, 9 per zone and 11 per peer
+	assert.Equal(t, 7+2*9+2*11, len(metrics))
+	byKey := nginxMetricsByKey(metrics)
+
+	assert.Equal(t, 12.0, byKey["nginx.active_connections|"].Value)
+	assert.Equal(t, 12000.0, byKey["nginx.req_handled|"].Value)
+
+	requests := byKey["nginx.zone.requests|zone=example.com"]
+	assert.Equal(t, 1000.0, requests.Value)
+	assert.Equal(t, metric.CumulativeCounter, requests.MetricType)
+	assert.Equal(t, 10.0, byKey["nginx.zone.responses|code_class=5xx,zone=example.com"].Value)
+	assert.Equal(t, 200000.0, byKey["nginx.zone.bytes_in|zone=example.com"].Value)
+	assert.Equal(t, 3500000.0, byKey["nginx.zone.bytes_out|zone=*"].Value)
+	assert.Equal(t, 12.0, byKey["nginx.zone.request_time_ms|zone=example.com"].Value)
+
+	assert.Equal(t, 600.0, byKey["nginx.upstream.requests|peer=10.0.0.1:8080,upstream=backend"].Value)
+	assert.Equal(t, 560.0, byKey["nginx.upstream.responses|code_class=2xx,peer=10.0.0.1:8080,upstream=backend"].Value)
+	responseTime := byKey["nginx.upstream.response_time_ms|peer=10.0.0.2:8080,upstream=backend"]
+	assert.Equal(t, 13.0, responseTime.Value)
+	assert.Equal(t, metric.Gauge, responseTime.MetricType)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_up|peer=10.0.0.1:8080,upstream=backend"].Value)
+	assert.Equal(t, 0.0, byKey["nginx.upstream.peer_up|peer=10.0.0.2:8080,upstream=backend"].Value)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_backup|peer=10.0.0.2:8080,upstream=backend"].Value)
+}
+
+func TestParseNginxVTSMetricsInvalid(t *testing.T) {
+	_, err := parseNginxVTSMetrics([]byte("Active connections: 2"))
+	assert.NotNil(t, err)
+}
+
+func TestParseNginxPlusMetrics(t *testing.T) {
+	metrics, err := parseNginxPlusMetrics(
+		readNginxFixture(t, "plus_server_zones.json"),
+		readNginxFixture(t, "plus_upstreams.json"),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 10+2*19, len(metrics))
+	byKey := nginxMetricsByKey(metrics)
+
+	assert.Equal(t, 1000.0, byKey["nginx.zone.requests|zone=example.com"].Value)
+	assert.Equal(t, 40.0, byKey["nginx.zone.responses|code_class=4xx,zone=example.com"].Value)
+	assert.Equal(t, 200000.0, byKey["nginx.zone.bytes_in|zone=example.com"].Value)
+	assert.Equal(t, 3000000.0, byKey["nginx.zone.bytes_out|zone=example.com"].Value)
+	assert.Equal(t, 2.0, byKey["nginx.zone.processing|zone=example.com"].Value)
+	assert.Equal(t, 3.0, byKey["nginx.zone.discarded|zone=example.com"].Value)
+
+	peer := "|peer=10.0.0.1:8080,upstream=backend"
+	assert.Equal(t, 600.0, byKey["nginx.upstream.requests"+peer].Value)
+	assert.Equal(t, 1800000.0, byKey["nginx.upstream.bytes_in"+peer].Value)
+	assert.Equal(t, 8.0, byKey["nginx.upstream.header_time_ms"+peer].Value)
+	assert.Equal(t, 9.0, byKey["nginx.upstream.response_time_ms"+peer].Value)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_up"+peer].Value)
+	assert.Equal(t, 2.0, byKey["nginx.upstream.fails"+peer].Value)
+	assert.Equal(t, 3.0, byKey["nginx.upstream.health_check_fails"+peer].Value)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_state|peer=10.0.0.1:8080,state=up,upstream=backend"].Value)
+
+	backup := "|peer=10.0.0.2:8080,upstream=backend"
+	assert.Equal(t, 0.0, byKey["nginx.upstream.peer_up"+backup].Value)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_backup"+backup].Value)
+	assert.Equal(t, 3.0, byKey["nginx.upstream.unhealthy"+backup].Value)
+	assert.Equal(t, 1.0, byKey["nginx.upstream.peer_state|peer=10.0.0.2:8080,state=unhealthy,upstream=backend"].Value)
+}
+
+func TestGetNginxFormatMetricsPlus(t *testing.T) {
+	zones := readNginxFixture(t, "plus_server_zones.json")
+	upstreams := readNginxFixture(t, "plus_upstreams.json")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/6/http/server_zones":
+			w.Write(zones)
+		case "/api/6/http/upstreams":
+			w.Write(upstreams)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	log := defaultLog.WithFields(l.Fields{"collector": "Nginx"})
+	client := http.Client{Timeout: getTimeout}
+	metrics := getNginxFormatMetrics(client, ts.URL+"/api/6/", nginxPlusFormat, log)
+	assert.Equal(t, 48, len(metrics))
+
+	assert.Nil(t, getNginxFormatMetrics(client, ts.URL+"/api/5", nginxPlusFormat, log))
+}
+
+func TestGetNginxFormatMetricsVTS(t *testing.T) {
+	vts := readNginxFixture(t, "vts.json")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(vts)
+	}))
+	defer ts.Close()
+
+	log := defaultLog.WithFields(l.Fields{"collector": "Nginx"})
+	client := http.Client{Timeout: getTimeout}
+	metrics := getNginxFormatMetrics(client, ts.URL+"/status/format/json", nginxVTSFormat, log)
+	assert.Equal(t, 47, len(metrics))
+}
@@ -5,7 +5,6 @@ import (
 	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
-	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -16,8 +15,9 @@ import (
 type nginxNerveStats struct {
 	baseCollector
 	client            http.Client
-	nerveConfigPath   string
+	nerveConfigPaths  []string
 	serviceNameToPath map[string]string
+	format            string
 }
 
 var (
@@ -35,14 +35,18 @@ func newNginxNerveStats(channel chan metric.Metric, initialInterval int, log *l.
 	m.channel = channel
 	m.interval = initialInterval
 	m.name = "NginxNerveStats"
-	m.nerveConfigPath = "/etc/nerve/nerve.conf.json"
+	m.nerveConfigPaths = []string{defaultNerveConfigPath}
 	m.client = http.Client{Timeout: nginxGetTimeout}
+	m.format = nginxStubStatusFormat
 
 	return m
 }
 
 func (m *nginxNerveStats) Configure(configMap map[string]interface{}) {
 	m.configureCommonParams(configMap)
+	if val, exists := configMap["configFilePath"]; exists {
+		m.nerveConfigPaths = getNerveConfigPaths(val)
+	}
 	c := config.GetAsMap(configMap)
 
 	// Convert config keys/values like "servicePath.routing" into a mapping of
@@ -53,18 +57,20 @@ func (m *nginxNerveStats) Configure(configMap map[string]interface{}) {
 			m.serviceNameToPath[match[1]] = value
 		}
 	}
+
+	if val, exists := c["format"]; exists {
+		if validNginxFormat(val) {
+			m.format = val
+		} else {
+			m.log.Error("Unknown nginx status format ", val, ", using ", m.format)
+		}
+	}
 }
 
 func (m *nginxNerveStats) Collect() {
-	rawFileContents, err := ioutil.ReadFile(m.nerveConfigPath)
-	if err != nil {
-		m.log.Warn("Failed to read the contents of file ", m.nerveConfigPath, " because ", err)
-		return
-	}
-	services, err := util.ParseNerveConfig(&rawFileContents, true)
+	services, err := util.ReadNerveConfigs(m.nerveConfigPaths, true)
 	if err != nil {
-		m.log.Warn("Failed to parse the nerve config at ", m.nerveConfigPath, ": ", err)
-		return
+		m.log.Warn("Failed to read the nerve configs: ", err)
 	}
 	m.log.Debug("Finished parsing Nerve config into ", services)
 
@@ -80,7 +86,7 @@ func (m *nginxNerveStats) collectMetricsForService(service util.NerveService, pa
 	statsURL := fmt.Sprintf("http://%s:%d%s", service.Host, service.Port, path)
 
 	serviceLog.Debug("Fetching nginx stats from", statsURL)
-	metrics := getNginxMetrics(m.client, statsURL, serviceLog)
+	metrics := getNginxFormatMetrics(m.client, statsURL, m.format, serviceLog)
 
 	metric.AddToAll(&metrics, map[string]string{
 		"service_name":      service.Name,
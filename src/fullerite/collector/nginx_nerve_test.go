@@ -52,6 +52,10 @@ func TestNginxNerveStatsConfigure(t *testing.T) {
 		"routing": "/_routing/nginx-status",
 		"spectre": "/nginx_status",
 	})
+	assert.Equal(t, nginxStubStatusFormat, stats.format)
+
+	stats.Configure(map[string]interface{}{"format": "plus"})
+	assert.Equal(t, nginxPlusFormat, stats.format)
 }
 
 func TestNginxNerveStatsCollect(t *testing.T) {
@@ -81,7 +85,7 @@ func TestNginxNerveStatsCollect(t *testing.T) {
 	channel := make(chan metric.Metric)
 	log := defaultLog.WithFields(l.Fields{"collector": "NginxNerveStats"})
 	inst := newNginxNerveStats(channel, 10, log).(*nginxNerveStats)
-	inst.nerveConfigPath = tmpFile.Name()
+	inst.nerveConfigPaths = []string{tmpFile.Name()}
 	inst.Configure(cfg)
 
 	inst.Collect()
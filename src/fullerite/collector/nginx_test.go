@@ -75,7 +75,7 @@ func TestNginxStatsQueryNginxStatsFailure(t *testing.T) {
 }
 
 func TestBuildNginxMetric(t *testing.T) {
-	expected := metric.Metric{"nginx.test", "gauge", 0.1, map[string]string{}}
+	expected := metric.Metric{Name: "nginx.test", MetricType: "gauge", Value: 0.1, Dimensions: map[string]string{}}
 	actual := buildNginxMetric("nginx.test", metric.Gauge, 0.1)
 	assert.Equal(t, expected, actual)
 }
@@ -107,3 +107,16 @@ func TestGetNginxMetrics(t *testing.T) {
 		buildNginxMetric("nginx.act_waits", metric.Gauge, 1),
 	})
 }
+
+func TestNginxStatsConfigureFormat(t *testing.T) {
+	channel := make(chan metric.Metric)
+	log := defaultLog.WithFields(l.Fields{"collector": "Nginx"})
+	stats := newNginxStats(channel, 10, log).(*nginxStats)
+	assert.Equal(t, nginxStubStatusFormat, stats.format)
+
+	stats.Configure(map[string]interface{}{"format": "vts"})
+	assert.Equal(t, nginxVTSFormat, stats.format)
+
+	stats.Configure(map[string]interface{}{"format": "json"})
+	assert.Equal(t, nginxVTSFormat, stats.format)
+}
@@ -4,17 +4,41 @@ import (
 	"fullerite/config"
 	"fullerite/metric"
 
+	"fmt"
+	"io/ioutil"
+	"math"
 	"regexp"
+	"strconv"
+	"strings"
 
 	l "github.com/Sirupsen/logrus"
 )
 
 // ProcStatus collector type
+// With aggregate, the metrics of the processes are summed per group of
+// generatedDimensions, without the pid and processName dimensions, and
+// ProcessCount counts the processes of each group. StartTime and Uptime
+// are the ones of the oldest process and MaxFDs the lowest limit. The
+// cumulative counters add up what each process counted since the previous
+// collection, so that they do not drop when a process exits.
 type ProcStatus struct {
 	baseCollector
 	compiledRegex    map[string]*regexp.Regexp
 	pattern          *regexp.Regexp
 	matchCommandLine bool
+	aggregate        bool
+	counters         *procStatusCounters
+}
+
+// procStatusCounters are the last values of the cumulative counters of the
+// processes and the aggregates they were added to
+type procStatusCounters struct {
+	last   map[string]float64
+	totals map[string]float64
+}
+
+func newProcStatusCounters() *procStatusCounters {
+	return &procStatusCounters{last: map[string]float64{}, totals: map[string]float64{}}
 }
 
 // Pattern returns ProcStatus collectors search pattern
@@ -27,6 +51,11 @@ func (ps ProcStatus) MatchCommandLine() bool {
 	return ps.matchCommandLine
 }
 
+// Aggregate returns whether ProcStatus collectors sum the processes per group
+func (ps ProcStatus) Aggregate() bool {
+	return ps.aggregate
+}
+
 func init() {
 	RegisterCollector("ProcStatus", newProcStatus)
 }
@@ -43,6 +72,7 @@ func newProcStatus(channel chan metric.Metric, initialInterval int, log *l.Entry
 	ps.pattern = regexp.MustCompile("")
 	ps.matchCommandLine = true
 	ps.compiledRegex = make(map[string]*regexp.Regexp)
+	ps.counters = newProcStatusCounters()
 
 	return ps
 }
@@ -62,6 +92,10 @@ func (ps *ProcStatus) Configure(configMap map[string]interface{}) {
 		ps.matchCommandLine = matchCommandLine.(bool)
 	}
 
+	if aggregate, exists := configMap["aggregate"]; exists {
+		ps.aggregate = config.GetAsBool(aggregate, false)
+	}
+
 	if generatedDimensions, exists := configMap["generatedDimensions"]; exists {
 		for dimension, generator := range config.GetAsMap(generatedDimensions) {
 			//don't use MustCompile otherwise program will panic due to misformated regex
@@ -76,3 +110,102 @@ func (ps *ProcStatus) Configure(configMap map[string]interface{}) {
 
 	ps.configureCommonParams(configMap)
 }
+
+// aggregateProcStatus sums the metrics of the processes having the same
+// generated dimensions, keeping the oldest start time and the lowest limit
+// of file descriptors. The cumulative counters are added the increase of
+// each process since its last value kept in counters.
+func aggregateProcStatus(metrics []metric.Metric, counters *procStatusCounters) []metric.Metric {
+	aggregated := map[string]*metric.Metric{}
+	keys := []string{}
+	last := map[string]float64{}
+
+	for _, m := range metrics {
+		point := metric.New(m.Name)
+		point.Value = m.Value
+		point.MetricType = m.MetricType
+		point.AddDimensions(m.Dimensions)
+		point.RemoveDimension("pid")
+		point.RemoveDimension("processName")
+		key := seriesKey(&point)
+
+		if m.MetricType == metric.CumulativeCounter {
+			// a process seen for the first time, or whose pid was reused,
+			// adds all it counted
+			processKey := seriesKey(&m)
+			increase := m.Value
+			if previous, seen := counters.last[processKey]; seen && m.Value >= previous {
+				increase = m.Value - previous
+			}
+			last[processKey] = m.Value
+			counters.totals[key] += increase
+		}
+
+		existing, exists := aggregated[key]
+		if !exists {
+			aggregated[key] = &point
+			keys = append(keys, key)
+			continue
+		}
+		switch m.Name {
+		case "StartTime", "MaxFDs":
+			existing.Value = math.Min(existing.Value, m.Value)
+		case "Uptime":
+			existing.Value = math.Max(existing.Value, m.Value)
+		default:
+			existing.Value += m.Value
+		}
+	}
+	// the processes which exited are forgotten
+	counters.last = last
+
+	ret := make([]metric.Metric, 0, len(keys))
+	for _, key := range keys {
+		point := aggregated[key]
+		if point.MetricType == metric.CumulativeCounter {
+			point.Value = counters.totals[key]
+		}
+		ret = append(ret, *point)
+	}
+	return ret
+}
+
+// readProcValues reads the numeric values of a /proc/<pid> file made of
+// "name: value" lines like status or io, the units are ignored
+func readProcValues(path string) (map[string]float64, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseProcValues(string(contents)), nil
+}
+
+func parseProcValues(contents string) map[string]float64 {
+	values := map[string]float64{}
+	for _, line := range strings.Split(contents, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			values[strings.TrimSpace(parts[0])] = value
+		}
+	}
+	return values
+}
+
+// parseBootTime reads the btime line of /proc/stat, the boot time in
+// seconds since the epoch
+func parseBootTime(contents string) (float64, error) {
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseFloat(fields[1], 64)
+		}
+	}
+	return 0, fmt.Errorf("no btime in /proc/stat")
+}
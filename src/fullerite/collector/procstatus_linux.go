@@ -5,12 +5,32 @@ package collector
 import (
 	"fullerite/metric"
 
+	"io/ioutil"
+	"math"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/procfs"
 )
 
+// userHZ is the unit of the start time of /proc/<pid>/stat
+const userHZ = 100
+
+// The states of /proc/<pid>/stat
+var procStates = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "disk_sleep",
+	"Z": "zombie",
+	"T": "stopped",
+	"t": "tracing_stop",
+	"X": "dead",
+	"I": "idle",
+	"P": "parked",
+}
+
 // Collect produces some random test metrics.
 func (ps ProcStatus) Collect() {
 	for _, m := range ps.procStatusMetrics() {
@@ -26,7 +46,7 @@ func procStatusPoint(name string, value float64, dimensions map[string]string, m
 	return m
 }
 
-func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.Metric {
+func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string, bootTime float64) []metric.Metric {
 	stat, err := proc.NewStat()
 	if err != nil {
 		ps.log.Warn("Error getting stats: ", err)
@@ -44,6 +64,58 @@ func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.M
 		procStatusPoint("VirtualMemory", float64(stat.VirtualMemory()), dim, metric.Gauge),
 		procStatusPoint("ResidentMemory", float64(stat.ResidentMemory()), dim, metric.Gauge),
 		procStatusPoint("CPUTime", float64(stat.CPUTime()), dim, metric.CumulativeCounter),
+		procStatusPoint("Threads", float64(stat.NumThreads), dim, metric.Gauge),
+	}
+
+	state, known := procStates[stat.State]
+	if !known {
+		state = "unknown"
+	}
+	stateDim := map[string]string{"state": state}
+	for k, v := range dim {
+		stateDim[k] = v
+	}
+	ret = append(ret, procStatusPoint("State", 1, stateDim, metric.Gauge))
+
+	if bootTime > 0 {
+		// the boot time is rounded to the second
+		startTime := bootTime + float64(stat.Starttime)/userHZ
+		uptime := math.Max(0, float64(time.Now().UnixNano())/float64(time.Second)-startTime)
+		ret = append(ret,
+			procStatusPoint("StartTime", startTime, dim, metric.Gauge),
+			procStatusPoint("Uptime", uptime, dim, metric.Gauge),
+		)
+	}
+
+	// The file descriptors and the IO of the processes of other users are
+	// only readable by root
+	if fds, err := proc.FileDescriptorsLen(); err == nil {
+		ret = append(ret, procStatusPoint("OpenFDs", float64(fds), dim, metric.Gauge))
+	}
+	if limits, err := proc.NewLimits(); err == nil {
+		ret = append(ret, procStatusPoint("MaxFDs", float64(limits.OpenFiles), dim, metric.Gauge))
+	}
+
+	procDir := filepath.Join(procfs.DefaultMountPoint, pid)
+	if status, err := readProcValues(filepath.Join(procDir, "status")); err == nil {
+		if value, exists := status["voluntary_ctxt_switches"]; exists {
+			ret = append(ret, procStatusPoint("VoluntaryContextSwitches", value, dim, metric.CumulativeCounter))
+		}
+		if value, exists := status["nonvoluntary_ctxt_switches"]; exists {
+			ret = append(ret, procStatusPoint("InvoluntaryContextSwitches", value, dim, metric.CumulativeCounter))
+		}
+	}
+	if io, err := readProcValues(filepath.Join(procDir, "io")); err == nil {
+		if value, exists := io["read_bytes"]; exists {
+			ret = append(ret, procStatusPoint("IOReadBytes", value, dim, metric.CumulativeCounter))
+		}
+		if value, exists := io["write_bytes"]; exists {
+			ret = append(ret, procStatusPoint("IOWriteBytes", value, dim, metric.CumulativeCounter))
+		}
+	}
+
+	if ps.aggregate {
+		ret = append(ret, procStatusPoint("ProcessCount", 1, dim, metric.Gauge))
 	}
 
 	if len(cmdOutput) > 0 {
@@ -54,6 +126,21 @@ func (ps ProcStatus) getMetrics(proc procfs.Proc, cmdOutput []string) []metric.M
 	return ret
 }
 
+// bootTime returns the boot time of the host, 0 when unknown
+func (ps ProcStatus) bootTime() float64 {
+	contents, err := ioutil.ReadFile(filepath.Join(procfs.DefaultMountPoint, "stat"))
+	if err != nil {
+		ps.log.Warn("Error reading the boot time: ", err)
+		return 0
+	}
+	bootTime, err := parseBootTime(string(contents))
+	if err != nil {
+		ps.log.Warn("Error reading the boot time: ", err)
+		return 0
+	}
+	return bootTime
+}
+
 func (ps ProcStatus) procStatusMetrics() []metric.Metric {
 	procs, err := procfs.AllProcs()
 	if err != nil {
@@ -62,6 +149,7 @@ func (ps ProcStatus) procStatusMetrics() []metric.Metric {
 	}
 
 	ret := []metric.Metric{}
+	bootTime := ps.bootTime()
 
 	for _, proc := range procs {
 		cmd, err := proc.CmdLine()
@@ -71,10 +159,13 @@ func (ps ProcStatus) procStatusMetrics() []metric.Metric {
 		}
 
 		if ps.matches(cmd, proc.Comm) {
-			ret = append(ret, ps.getMetrics(proc, cmd)...)
+			ret = append(ret, ps.getMetrics(proc, cmd, bootTime)...)
 		}
 	}
 
+	if ps.aggregate {
+		return aggregateProcStatus(ret, ps.counters)
+	}
 	return ret
 }
 
@@ -7,6 +7,8 @@ import (
 	"fullerite/test_utils"
 
 	"errors"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -59,9 +61,16 @@ func TestProcStatusCollectMetricTypes(t *testing.T) {
 
 	select {
 	case <-ps.Channel():
+		counters := map[string]bool{
+			"CPUTime":                    true,
+			"VoluntaryContextSwitches":   true,
+			"InvoluntaryContextSwitches": true,
+			"IOReadBytes":                true,
+			"IOWriteBytes":               true,
+		}
 		for _, m := range ps.procStatusMetrics() {
-			if m.Name == "CPUTime" {
-				assert.Equal(t, m.MetricType, metric.CumulativeCounter, "CPUTime is a CumulativeCounter")
+			if counters[m.Name] {
+				assert.Equal(t, m.MetricType, metric.CumulativeCounter, m.Name+" is a CumulativeCounter")
 			} else {
 				assert.Equal(t, m.MetricType, metric.Gauge, "All others are a Gauge")
 			}
@@ -184,3 +193,60 @@ func TestProcStatusMatches(t *testing.T) {
 	match = ps.matches([]string{"proc", "status"}, commGenerator("proc", nil))
 	assert.True(match)
 }
+
+func TestProcStatusMetricsOwnProcess(t *testing.T) {
+	testLog := test_utils.BuildLogger()
+	ps := newProcStatus(nil, 12, testLog).(*ProcStatus)
+	ps.Configure(make(map[string]interface{}))
+
+	pid := strconv.Itoa(os.Getpid())
+	values := map[string]metric.Metric{}
+	for _, m := range ps.procStatusMetrics() {
+		if m.Dimensions["pid"] == pid {
+			values[m.Name] = m
+		}
+	}
+
+	for _, name := range []string{
+		"Threads", "OpenFDs", "MaxFDs", "VoluntaryContextSwitches", "InvoluntaryContextSwitches",
+		"IOReadBytes", "IOWriteBytes", "StartTime", "Uptime", "State",
+	} {
+		_, exists := values[name]
+		assert.True(t, exists, name+" is reported")
+	}
+	assert.True(t, values["Threads"].Value >= 1)
+	assert.True(t, values["OpenFDs"].Value >= 3)
+	assert.True(t, values["MaxFDs"].Value >= values["OpenFDs"].Value)
+	assert.True(t, values["Uptime"].Value >= 0)
+	assert.InDelta(t, float64(time.Now().Unix()), values["StartTime"].Value+values["Uptime"].Value, 2)
+	assert.Contains(t, []string{"running", "sleeping"}, values["State"].Dimensions["state"])
+	_, exists := values["ProcessCount"]
+	assert.False(t, exists)
+}
+
+func TestProcStatusMetricsAggregate(t *testing.T) {
+	testLog := test_utils.BuildLogger()
+	ps := newProcStatus(nil, 12, testLog).(*ProcStatus)
+	ps.Configure(map[string]interface{}{
+		"aggregate":           true,
+		"generatedDimensions": map[string]string{"binary": "^(.*)$"},
+	})
+
+	self, err := os.Readlink("/proc/self/exe")
+	assert.Nil(t, err)
+	executable, err := os.Executable()
+	assert.Nil(t, err)
+
+	found := false
+	for _, m := range ps.procStatusMetrics() {
+		_, hasPid := m.Dimensions["pid"]
+		_, hasName := m.Dimensions["processName"]
+		assert.False(t, hasPid)
+		assert.False(t, hasName)
+		if m.Name == "ProcessCount" && (m.Dimensions["binary"] == self || m.Dimensions["binary"] == executable || m.Dimensions["binary"] == os.Args[0]) {
+			found = true
+			assert.True(t, m.Value >= 1)
+		}
+	}
+	assert.True(t, found)
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fullerite/metric"
 	"regexp"
 	"testing"
 
@@ -42,3 +43,97 @@ func TestProcStatusConfigure(t *testing.T) {
 	assert.Equal(t, false, ps.MatchCommandLine())
 	assert.Equal(t, compRegex, ps.compiledRegex)
 }
+
+func TestProcStatusConfigureAggregate(t *testing.T) {
+	ps := newProcStatus(nil, 123, nil).(*ProcStatus)
+	assert.False(t, ps.Aggregate())
+
+	ps.Configure(map[string]interface{}{"aggregate": true})
+	assert.True(t, ps.Aggregate())
+}
+
+func TestAggregateProcStatus(t *testing.T) {
+	point := func(name string, value float64, pid string, service string, metricType string) metric.Metric {
+		m := metric.WithValue(name, value)
+		m.MetricType = metricType
+		m.AddDimensions(map[string]string{"pid": pid, "processName": "python", "service": service})
+		return m
+	}
+
+	aggregated := aggregateProcStatus([]metric.Metric{
+		point("ResidentMemory", 100, "1", "api", metric.Gauge),
+		point("StartTime", 1000, "1", "api", metric.Gauge),
+		point("Uptime", 50, "1", "api", metric.Gauge),
+		point("CPUTime", 3, "1", "api", metric.CumulativeCounter),
+		point("ProcessCount", 1, "1", "api", metric.Gauge),
+		point("ResidentMemory", 200, "2", "api", metric.Gauge),
+		point("StartTime", 900, "2", "api", metric.Gauge),
+		point("Uptime", 150, "2", "api", metric.Gauge),
+		point("CPUTime", 4, "2", "api", metric.CumulativeCounter),
+		point("ProcessCount", 1, "2", "api", metric.Gauge),
+		point("MaxFDs", 1024, "2", "api", metric.Gauge),
+		point("MaxFDs", 4096, "1", "api", metric.Gauge),
+		point("ResidentMemory", 50, "3", "worker", metric.Gauge),
+		point("ProcessCount", 1, "3", "worker", metric.Gauge),
+	}, newProcStatusCounters())
+
+	values := map[string]metric.Metric{}
+	for _, m := range aggregated {
+		assert.Equal(t, map[string]string{"service": m.Dimensions["service"]}, m.Dimensions)
+		values[m.Name+"|"+m.Dimensions["service"]] = m
+	}
+	assert.Equal(t, 8, len(aggregated))
+	assert.Equal(t, 1024.0, values["MaxFDs|api"].Value)
+	assert.Equal(t, 300.0, values["ResidentMemory|api"].Value)
+	assert.Equal(t, 900.0, values["StartTime|api"].Value)
+	assert.Equal(t, 150.0, values["Uptime|api"].Value)
+	assert.Equal(t, 7.0, values["CPUTime|api"].Value)
+	assert.Equal(t, metric.CumulativeCounter, values["CPUTime|api"].MetricType)
+	assert.Equal(t, 2.0, values["ProcessCount|api"].Value)
+	assert.Equal(t, 50.0, values["ResidentMemory|worker"].Value)
+	assert.Equal(t, 1.0, values["ProcessCount|worker"].Value)
+}
+
+func TestAggregateProcStatusCounters(t *testing.T) {
+	point := func(name string, value float64, pid string) metric.Metric {
+		m := metric.WithValue(name, value)
+		m.MetricType = metric.CumulativeCounter
+		m.AddDimensions(map[string]string{"pid": pid, "processName": "python", "service": "api"})
+		return m
+	}
+	counters := newProcStatusCounters()
+	cpuTime := func(metrics ...metric.Metric) float64 {
+		aggregated := aggregateProcStatus(metrics, counters)
+		assert.Equal(t, 1, len(aggregated))
+		assert.Equal(t, metric.CumulativeCounter, aggregated[0].MetricType)
+		return aggregated[0].Value
+	}
+
+	assert.Equal(t, 7.0, cpuTime(point("CPUTime", 3, "1"), point("CPUTime", 4, "2")))
+	// the process 2 exited, the aggregate does not drop
+	assert.Equal(t, 9.0, cpuTime(point("CPUTime", 5, "1")))
+	// the process 3 started
+	assert.Equal(t, 11.0, cpuTime(point("CPUTime", 6, "1"), point("CPUTime", 1, "3")))
+	// the pid 3 was reused by a new process
+	assert.Equal(t, 13.0, cpuTime(point("CPUTime", 6, "1"), point("CPUTime", 0.5, "3"), point("CPUTime", 1.5, "4")))
+	assert.Equal(t, 3, len(counters.last))
+}
+
+func TestParseProcValues(t *testing.T) {
+	values := parseProcValues("Name:\tbash\nThreads:\t4\nVmRSS:\t    1234 kB\nvoluntary_ctxt_switches:\t150\nrchar: 3980\n")
+	assert.Equal(t, map[string]float64{
+		"Threads":                 4,
+		"VmRSS":                   1234,
+		"voluntary_ctxt_switches": 150,
+		"rchar":                   3980,
+	}, values)
+}
+
+func TestParseBootTime(t *testing.T) {
+	bootTime, err := parseBootTime("cpu  1 2 3\nintr 5\nbtime 1792323904\nprocesses 42\n")
+	assert.Nil(t, err)
+	assert.Equal(t, 1792323904.0, bootTime)
+
+	_, err = parseBootTime("cpu  1 2 3\n")
+	assert.NotNil(t, err)
+}
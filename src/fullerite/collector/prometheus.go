@@ -2,6 +2,7 @@ package collector
 
 import (
 	"fmt"
+	"sync"
 
 	l "github.com/Sirupsen/logrus"
 
@@ -55,6 +56,7 @@ func newPrometheus(channel chan metric.Metric, initialInterval int, log *l.Entry
 	p.interval = initialInterval
 
 	p.name = "Prometheus"
+	p.asyncScrape = true
 	return p
 }
 
@@ -223,12 +225,29 @@ func (p *Prometheus) getSet(endpoint map[string]interface{}, name string) *map[s
 	return ret
 }
 
+// Targets returns the endpoints scraped by the collector
+func (p *Prometheus) Targets() []string {
+	targets := []string{}
+	for _, endpoint := range p.endpoints {
+		targets = append(targets, endpoint.url)
+	}
+	return targets
+}
+
 // Collect iterates on all Prometheus endpoints and collect the corresponding metrics
 // For each endpoint a gorutine is started to spin up the collection process.
 func (p *Prometheus) Collect() {
-	for _, endpoint := range p.endpoints {
-		go p.collectFromEndpoint(endpoint)
-	}
+	p.scrapeInBackground(func() {
+		var wg sync.WaitGroup
+		for _, endpoint := range p.endpoints {
+			wg.Add(1)
+			go func(endpoint *Endpoint) {
+				defer wg.Done()
+				p.collectFromEndpoint(endpoint)
+			}(endpoint)
+		}
+		wg.Wait()
+	})
 }
 
 func (p *Prometheus) scrape(endpoint *Endpoint) ([]byte, string, error) {
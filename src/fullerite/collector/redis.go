@@ -0,0 +1,334 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// redisCommander runs the commands of the Redis collector
+type redisCommander interface {
+	Do(args ...string) (interface{}, error)
+	Close() error
+}
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var (
+	dialRedis = func(options util.RedisOptions, timeout time.Duration) (redisCommander, error) {
+		return util.DialRedis(options, timeout)
+	}
+
+	// The INFO fields which are counters on top of the total_ ones
+	redisInfoCounters = map[string]bool{
+		"rejected_connections":           true,
+		"expired_keys":                   true,
+		"evicted_keys":                   true,
+		"keyspace_hits":                  true,
+		"keyspace_misses":                true,
+		"sync_full":                      true,
+		"sync_partial_ok":                true,
+		"sync_partial_err":               true,
+		"used_cpu_sys":                   true,
+		"used_cpu_user":                  true,
+		"used_cpu_sys_children":          true,
+		"used_cpu_user_children":         true,
+		"expired_time_cap_reached_count": true,
+	}
+
+	// The INFO sections reported field by field
+	redisInfoSections = []string{
+		"server", "clients", "memory", "persistence", "stats", "replication", "cpu",
+	}
+
+	// The INFO fields which are not reported, like the ids and times of the
+	// server
+	redisInfoIgnored = map[string]bool{
+		"redis_git_sha1":     true,
+		"redis_git_dirty":    true,
+		"arch_bits":          true,
+		"process_id":         true,
+		"tcp_port":           true,
+		"server_time_usec":   true,
+		"configured_hz":      true,
+		"lru_clock":          true,
+		"executable":         true,
+		"master_port":        true,
+		"rdb_last_save_time": true,
+	}
+)
+
+// Redis collector
+// reads INFO all and SLOWLOG LEN of Redis servers and reports:
+//   - the numeric fields of the server, clients, memory, persistence, stats,
+//     replication and cpu sections as redis.<field>, the total_ ones and
+//     the other counters are cumulative
+//   - redis.keyspace.<keys|expires|avg_ttl> by db
+//   - redis.command.<calls|usec|usec_per_call> by command
+//   - redis.errors by error prefix
+//   - redis.is_master, redis.master_link_up on replicas and, on masters,
+//     redis.replica.<offset|lag|offset_lag> by replica
+//   - redis.slowlog.length
+//
+// The servers are the instances, "host[:port]" or a UNIX socket path, and
+// the instances of the nerveServices found in the nerve config. They share
+// the user, password and TLS settings. With tls on, nothing is collected
+// when the TLS settings are invalid.
+type Redis struct {
+	baseCollector
+
+	instances       []cacheInstance
+	nerveConfigPath []string
+	nerveServices   []string
+	user            string
+	password        string
+	useTLS          bool
+	tlsConfig       *tls.Config
+	timeout         int
+}
+
+func init() {
+	RegisterCollector("Redis", newRedis)
+}
+
+func newRedis(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	m := new(Redis)
+
+	m.log = log
+	m.channel = channel
+	m.interval = initialInterval
+
+	m.name = "Redis"
+	m.instances = []cacheInstance{parseCacheInstance("127.0.0.1", 6379)}
+	m.nerveConfigPath = []string{defaultNerveConfigPath}
+	m.timeout = 5
+	return m
+}
+
+// Configure the collector
+func (m *Redis) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["instances"]; exists {
+		m.instances = parseCacheInstances(val, 6379)
+	}
+	if val, exists := configMap["configFilePath"]; exists {
+		m.nerveConfigPath = getNerveConfigPaths(val)
+	}
+	if val, exists := configMap["nerveServices"]; exists {
+		m.nerveServices = config.GetAsSlice(val)
+	}
+	if val, exists := configMap["user"]; exists {
+		m.user = val.(string)
+	}
+	if val, exists := configMap["password"]; exists {
+		m.password = val.(string)
+	}
+	if val, exists := configMap["timeout"]; exists {
+		m.timeout = config.GetAsInt(val, 5)
+	}
+	if val, exists := configMap["tls"]; exists {
+		m.useTLS = config.GetAsBool(val, false)
+	}
+	m.tlsConfig = nil
+	if m.useTLS {
+		tlsConfig, err := redisTLSConfig(configMap)
+		if err != nil {
+			m.log.Error("Invalid TLS settings, not collecting: ", err)
+		}
+		m.tlsConfig = tlsConfig
+	}
+
+	m.configureCommonParams(configMap)
+}
+
+// redisTLSConfig builds the TLS settings of the serverCaFile, the client
+// certificate of clientCertFile and clientKeyFile, the serverName and
+// insecureSkipVerify options
+func redisTLSConfig(configMap map[string]interface{}) (*tls.Config, error) {
+	serverName, _ := configMap["serverName"].(string)
+	serverCaFile, _ := configMap["serverCaFile"].(string)
+	clientCertFile, _ := configMap["clientCertFile"].(string)
+	clientKeyFile, _ := configMap["clientKeyFile"].(string)
+
+	tlsConfig := &tls.Config{ServerName: serverName}
+	if val, exists := configMap["insecureSkipVerify"]; exists {
+		tlsConfig.InsecureSkipVerify = config.GetAsBool(val, false)
+	}
+
+	if serverCaFile != "" {
+		caCert, err := ioutil.ReadFile(serverCaFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate in %s", serverCaFile)
+		}
+	}
+	if clientCertFile != "" && clientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Collect reads the servers concurrently and sends their metrics
+func (m *Redis) Collect() {
+	instances := m.instances
+	if len(m.nerveServices) > 0 {
+		instances = append(instances, discoverCacheInstances(m.nerveConfigPath, m.nerveServices, m.log)...)
+	}
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance cacheInstance) {
+			defer wg.Done()
+			for _, metric := range m.instanceMetrics(instance) {
+				m.Channel() <- metric
+			}
+		}(instance)
+	}
+	wg.Wait()
+}
+
+func (m *Redis) instanceMetrics(instance cacheInstance) []metric.Metric {
+	instanceLog := m.log.WithField("instance", instance.addr)
+	if m.useTLS && m.tlsConfig == nil {
+		// never fall back to plaintext, it would send the credentials in clear
+		instanceLog.Error("TLS is on but its settings are invalid, not connecting")
+		return nil
+	}
+	options := util.RedisOptions{
+		Net:      instance.network,
+		Addr:     instance.addr,
+		User:     m.user,
+		Password: m.password,
+		TLS:      m.tlsConfig,
+	}
+	conn, err := dialRedis(options, time.Duration(m.timeout)*time.Second)
+	if err != nil {
+		instanceLog.Error("Failed to connect to Redis: ", err)
+		return nil
+	}
+	defer conn.Close()
+
+	info, err := conn.Do("INFO", "all")
+	if err != nil {
+		instanceLog.Error("Failed to read the info: ", err)
+		return nil
+	}
+	infoText, _ := info.(string)
+	metrics := redisInfoMetrics(util.ParseRedisInfo(infoText))
+
+	if length, err := conn.Do("SLOWLOG", "LEN"); err == nil {
+		if value, ok := length.(int64); ok {
+			metrics = append(metrics, metric.WithValue("redis.slowlog.length", float64(value)))
+		}
+	} else {
+		instanceLog.Warn("Failed to read the slowlog length: ", err)
+	}
+
+	metric.AddToAll(&metrics, instance.dimensions)
+	return metrics
+}
+
+// redisInfoMetrics reports the sections of INFO all
+func redisInfoMetrics(info map[string]map[string]string) []metric.Metric {
+	metrics := []metric.Metric{}
+	add := func(name string, metricType string, value float64, dimensions map[string]string) {
+		m := metric.WithValue("redis."+name, value)
+		m.MetricType = metricType
+		m.AddDimensions(dimensions)
+		metrics = append(metrics, m)
+	}
+
+	for _, section := range redisInfoSections {
+		for name, value := range info[section] {
+			if redisInfoIgnored[name] {
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			metricType := metric.Gauge
+			if strings.HasPrefix(name, "total_") || redisInfoCounters[name] {
+				metricType = metric.CumulativeCounter
+			}
+			add(name, metricType, v, nil)
+		}
+	}
+
+	replication := info["replication"]
+	if role := replication["role"]; role != "" {
+		add("is_master", metric.Gauge, redisBool(role == "master"), nil)
+	}
+	if status := replication["master_link_status"]; status != "" {
+		add("master_link_up", metric.Gauge, redisBool(status == "up"), nil)
+	}
+	masterOffset, _ := strconv.ParseFloat(replication["master_repl_offset"], 64)
+	for name, value := range replication {
+		if !strings.HasPrefix(name, "slave") || strings.Contains(name, "_") {
+			continue
+		}
+		fields := util.ParseRedisInfoFields(value)
+		dimensions := map[string]string{"replica": fields["ip"] + ":" + fields["port"]}
+		if offset, err := strconv.ParseFloat(fields["offset"], 64); err == nil {
+			add("replica.offset", metric.Gauge, offset, dimensions)
+			add("replica.offset_lag", metric.Gauge, masterOffset-offset, dimensions)
+		}
+		if lag, err := strconv.ParseFloat(fields["lag"], 64); err == nil {
+			add("replica.lag", metric.Gauge, lag, dimensions)
+		}
+	}
+
+	for db, value := range info["keyspace"] {
+		for name, field := range util.ParseRedisInfoFields(value) {
+			if v, err := strconv.ParseFloat(field, 64); err == nil {
+				add("keyspace."+name, metric.Gauge, v, map[string]string{"db": db})
+			}
+		}
+	}
+
+	for name, value := range info["commandstats"] {
+		command := strings.TrimPrefix(name, "cmdstat_")
+		for field, stat := range util.ParseRedisInfoFields(value) {
+			v, err := strconv.ParseFloat(stat, 64)
+			if err != nil {
+				continue
+			}
+			metricType := metric.CumulativeCounter
+			if field == "usec_per_call" {
+				metricType = metric.Gauge
+			}
+			add("command."+field, metricType, v, map[string]string{"command": command})
+		}
+	}
+
+	for name, value := range info["errorstats"] {
+		if count, err := strconv.ParseFloat(util.ParseRedisInfoFields(value)["count"], 64); err == nil {
+			add("errors", metric.CumulativeCounter, count, map[string]string{"error": strings.TrimPrefix(name, "errorstat_")})
+		}
+	}
+
+	return metrics
+}
+
+func redisBool(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
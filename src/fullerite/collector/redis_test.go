@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"fullerite/metric"
+	"fullerite/test_utils"
+	"fullerite/util"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedisConn answers the commands with canned replies
+type fakeRedisConn struct {
+	replies map[string]interface{}
+	closed  bool
+}
+
+func (c *fakeRedisConn) Do(args ...string) (interface{}, error) {
+	reply, exists := c.replies[fmt.Sprint(args)]
+	if !exists {
+		return nil, util.RedisError("ERR unknown command")
+	}
+	return reply, nil
+}
+
+func (c *fakeRedisConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func getTestRedis() *Redis {
+	return newRedis(make(chan metric.Metric), 10, l.WithField("testing", "redis")).(*Redis)
+}
+
+func readRedisFixture(t *testing.T, name string) string {
+	contents, err := ioutil.ReadFile(path.Join(test_utils.DirectoryOfCurrentFile(), "/../../fixtures/redis", name))
+	assert.Nil(t, err)
+	return string(contents)
+}
+
+func TestRedisConfigure(t *testing.T) {
+	r := getTestRedis()
+	r.Configure(map[string]interface{}{})
+	assert.Equal(t, []cacheInstance{{network: "tcp", addr: "127.0.0.1:6379", dimensions: map[string]string{"instance": "127.0.0.1:6379"}}}, r.instances)
+	assert.Nil(t, r.tlsConfig)
+
+	r.Configure(map[string]interface{}{
+		"interval":           30,
+		"instances":          []interface{}{"10.0.0.1", "unix:///var/run/redis/redis.sock"},
+		"nerveServices":      []interface{}{"redis_main"},
+		"user":               "fullerite",
+		"password":           "secret",
+		"timeout":            "2",
+		"tls":                true,
+		"serverName":         "redis.example.com",
+		"insecureSkipVerify": true,
+	})
+	assert.Equal(t, 30, r.Interval())
+	assert.Equal(t, 2, len(r.instances))
+	assert.Equal(t, "10.0.0.1:6379", r.instances[0].addr)
+	assert.Equal(t, "unix", r.instances[1].network)
+	assert.Equal(t, "/var/run/redis/redis.sock", r.instances[1].addr)
+	assert.Equal(t, []string{"redis_main"}, r.nerveServices)
+	assert.Equal(t, "fullerite", r.user)
+	assert.Equal(t, "secret", r.password)
+	assert.Equal(t, 2, r.timeout)
+	assert.Equal(t, "redis.example.com", r.tlsConfig.ServerName)
+	assert.True(t, r.tlsConfig.InsecureSkipVerify)
+}
+
+func TestRedisConfigureInvalidTLS(t *testing.T) {
+	oldDialRedis := dialRedis
+	defer func() { dialRedis = oldDialRedis }()
+	dialed := false
+	dialRedis = func(options util.RedisOptions, timeout time.Duration) (redisCommander, error) {
+		dialed = true
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	r := getTestRedis()
+	r.Configure(map[string]interface{}{"tls": true, "serverCaFile": "/does/not/exist.pem", "password": "secret"})
+	assert.True(t, r.useTLS)
+	assert.Nil(t, r.tlsConfig)
+	assert.Nil(t, r.instanceMetrics(r.instances[0]))
+	assert.False(t, dialed)
+}
+
+func TestRedisInfoMetricsMaster(t *testing.T) {
+	byKey := metricsBySeriesKey(redisInfoMetrics(util.ParseRedisInfo(readRedisFixture(t, "info_master.txt"))))
+
+	assert.Equal(t, 12.0, byKey["redis.connected_clients|"].Value)
+	assert.Equal(t, metric.Gauge, byKey["redis.connected_clients|"].MetricType)
+	assert.Equal(t, 2.0, byKey["redis.mem_fragmentation_ratio|"].Value)
+	commands := byKey["redis.total_commands_processed|"]
+	assert.Equal(t, 50000.0, commands.Value)
+	assert.Equal(t, metric.CumulativeCounter, commands.MetricType)
+	assert.Equal(t, metric.CumulativeCounter, byKey["redis.keyspace_hits|"].MetricType)
+	assert.Equal(t, 30.25, byKey["redis.used_cpu_user|"].Value)
+	for _, ignored := range []string{"redis.process_id|", "redis.tcp_port|", "redis.lru_clock|", "redis.rdb_last_save_time|"} {
+		_, exists := byKey[ignored]
+		assert.False(t, exists, ignored)
+	}
+
+	assert.Equal(t, 1.0, byKey["redis.is_master|"].Value)
+	assert.Equal(t, 1100.0, byKey["redis.master_repl_offset|"].Value)
+	assert.Equal(t, 1000.0, byKey["redis.replica.offset|replica=10.0.0.2:6379"].Value)
+	assert.Equal(t, 200.0, byKey["redis.replica.offset_lag|replica=10.0.0.3:6379"].Value)
+	assert.Equal(t, 2.0, byKey["redis.replica.lag|replica=10.0.0.3:6379"].Value)
+	_, exists := byKey["redis.master_link_up|"]
+	assert.False(t, exists)
+
+	assert.Equal(t, 100.0, byKey["redis.keyspace.keys|db=db0"].Value)
+	assert.Equal(t, 10.0, byKey["redis.keyspace.expires|db=db0"].Value)
+	assert.Equal(t, 5.0, byKey["redis.keyspace.keys|db=db3"].Value)
+
+	calls := byKey["redis.command.calls|command=get"]
+	assert.Equal(t, 900.0, calls.Value)
+	assert.Equal(t, metric.CumulativeCounter, calls.MetricType)
+	perCall := byKey["redis.command.usec_per_call|command=set"]
+	assert.Equal(t, 5.0, perCall.Value)
+	assert.Equal(t, metric.Gauge, perCall.MetricType)
+
+	assert.Equal(t, 2.0, byKey["redis.errors|error=ERR"].Value)
+	assert.Equal(t, 1.0, byKey["redis.errors|error=WRONGTYPE"].Value)
+}
+
+func TestRedisInfoMetricsReplica(t *testing.T) {
+	byKey := metricsBySeriesKey(redisInfoMetrics(util.ParseRedisInfo(readRedisFixture(t, "info_replica.txt"))))
+
+	assert.Equal(t, 0.0, byKey["redis.is_master|"].Value)
+	assert.Equal(t, 0.0, byKey["redis.master_link_up|"].Value)
+	assert.Equal(t, 1000.0, byKey["redis.slave_repl_offset|"].Value)
+	assert.Equal(t, -1.0, byKey["redis.master_last_io_seconds_ago|"].Value)
+	_, exists := byKey["redis.master_port|"]
+	assert.False(t, exists)
+	for key := range byKey {
+		assert.NotContains(t, key, "redis.replica.")
+	}
+}
+
+func TestRedisInstanceMetrics(t *testing.T) {
+	oldDialRedis := dialRedis
+	defer func() { dialRedis = oldDialRedis }()
+
+	conn := &fakeRedisConn{replies: map[string]interface{}{
+		"[INFO all]":    readRedisFixture(t, "info_replica.txt"),
+		"[SLOWLOG LEN]": int64(4),
+	}}
+	var dialed util.RedisOptions
+	dialRedis = func(options util.RedisOptions, timeout time.Duration) (redisCommander, error) {
+		dialed = options
+		assert.Equal(t, 5*time.Second, timeout)
+		return conn, nil
+	}
+
+	r := getTestRedis()
+	r.Configure(map[string]interface{}{"instances": "/var/run/redis.sock", "password": "secret"})
+	byKey := metricsBySeriesKey(r.instanceMetrics(r.instances[0]))
+	assert.Equal(t, util.RedisOptions{Net: "unix", Addr: "/var/run/redis.sock", Password: "secret"}, dialed)
+	assert.Equal(t, 4.0, byKey["redis.slowlog.length|instance=/var/run/redis.sock"].Value)
+	assert.Equal(t, 3600.0, byKey["redis.uptime_in_seconds|instance=/var/run/redis.sock"].Value)
+	assert.True(t, conn.closed)
+
+	dialRedis = func(options util.RedisOptions, timeout time.Duration) (redisCommander, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	assert.Nil(t, r.instanceMetrics(r.instances[0]))
+}
+
+func TestRedisCollect(t *testing.T) {
+	oldDialRedis := dialRedis
+	defer func() { dialRedis = oldDialRedis }()
+	dialRedis = func(options util.RedisOptions, timeout time.Duration) (redisCommander, error) {
+		return &fakeRedisConn{replies: map[string]interface{}{
+			"[INFO all]":    "# Server\r\nuptime_in_seconds:" + options.Addr[len(options.Addr)-1:] + "\r\n",
+			"[SLOWLOG LEN]": int64(0),
+		}}, nil
+	}
+
+	c := make(chan metric.Metric)
+	r := newRedis(c, 10, l.WithField("testing", "redis")).(*Redis)
+	r.Configure(map[string]interface{}{"instances": []interface{}{"10.0.0.1:6371", "10.0.0.1:6372"}})
+	go r.Collect()
+
+	uptimes := map[string]float64{}
+	for i := 0; i < 4; i++ {
+		m := <-c
+		if m.Name == "redis.uptime_in_seconds" {
+			uptimes[m.Dimensions["instance"]] = m.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{"10.0.0.1:6371": 1, "10.0.0.1:6372": 2}, uptimes)
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"fullerite/config"
+
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// Supported values of the "splay" collector config option
+const (
+	// SplayNone does not offset collections
+	SplayNone = ""
+	// SplayRandom delays the first collection by a random amount within the interval
+	SplayRandom = "random"
+	// SplayHostname delays the first collection by an offset derived from the
+	// hostname. Combined with "align_to_interval" a host always collects at
+	// the same point in the interval, otherwise the offset is relative to
+	// when fullerite started.
+	SplayHostname = "hostname"
+)
+
+var (
+	hostname = os.Hostname
+
+	randomSplay = func(n int64) int64 { return rand.Int63n(n) }
+)
+
+// configureSchedule reads the scheduling options out of the collector config:
+//
+//	"splay": "random" | "hostname"
+//	"align_to_interval": true
+//
+// An invalid splay value is logged and ignored.
+func (col *baseCollector) configureSchedule(configMap map[string]interface{}) {
+	if asInterface, exists := configMap["splay"]; exists {
+		splay, _ := asInterface.(string)
+		switch splay {
+		case SplayNone, SplayRandom, SplayHostname:
+			col.splay = splay
+		default:
+			col.log.Warn("Ignoring unknown splay mode: ", asInterface)
+		}
+	}
+
+	if asInterface, exists := configMap["align_to_interval"]; exists {
+		col.alignToInterval = config.GetAsBool(asInterface, false)
+	}
+}
+
+// InitialDelay returns how long to wait, starting at now, before the
+// collection ticker of this collector is started. Since the ticker fires
+// every interval from then on, all collections happen at the same offset
+// within the interval. The delay is always shorter than the interval.
+func (col *baseCollector) InitialDelay(now time.Time) time.Duration {
+	interval := time.Duration(col.interval) * time.Second
+	if interval <= 0 {
+		return 0
+	}
+
+	var offset time.Duration
+	switch col.splay {
+	case SplayRandom:
+		offset = time.Duration(randomSplay(int64(interval)))
+	case SplayHostname:
+		offset = hostOffset(col.log, interval)
+	}
+
+	if !col.alignToInterval {
+		return offset
+	}
+
+	sinceBoundary := now.Sub(now.Truncate(interval))
+	return (interval - sinceBoundary + offset) % interval
+}
+
+// hostOffset deterministically maps the hostname to an offset within the interval
+func hostOffset(log *l.Entry, interval time.Duration) time.Duration {
+	h, err := hostname()
+	if err != nil {
+		log.Warn("Unable to get hostname for splay, not delaying collection: ", err)
+		return 0
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(h))
+	return time.Duration(hash.Sum64() % uint64(interval))
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureScheduleDefaults(t *testing.T) {
+	col := New("Test")
+	col.Configure(map[string]interface{}{})
+
+	now := time.Date(2016, 1, 1, 0, 0, 3, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), col.InitialDelay(now))
+}
+
+func TestConfigureScheduleUnknownSplay(t *testing.T) {
+	col := New("Test").(*Test)
+	col.Configure(map[string]interface{}{"splay": "sometimes"})
+
+	assert.Equal(t, SplayNone, col.splay)
+}
+
+func TestInitialDelayAlignToInterval(t *testing.T) {
+	col := New("Test")
+	col.Configure(map[string]interface{}{
+		"interval":          10,
+		"align_to_interval": "true",
+	})
+
+	now := time.Date(2016, 1, 1, 0, 0, 3, 0, time.UTC)
+	assert.Equal(t, 7*time.Second, col.InitialDelay(now))
+
+	now = time.Date(2016, 1, 1, 0, 0, 10, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), col.InitialDelay(now))
+}
+
+func TestInitialDelayRandomSplay(t *testing.T) {
+	oldRandomSplay := randomSplay
+	defer func() { randomSplay = oldRandomSplay }()
+	randomSplay = func(n int64) int64 { return n / 2 }
+
+	col := New("Test")
+	col.Configure(map[string]interface{}{
+		"interval": 10,
+		"splay":    SplayRandom,
+	})
+
+	now := time.Date(2016, 1, 1, 0, 0, 3, 0, time.UTC)
+	assert.Equal(t, 5*time.Second, col.InitialDelay(now))
+}
+
+func TestInitialDelayRandomSplayWithinInterval(t *testing.T) {
+	col := New("Test")
+	col.Configure(map[string]interface{}{
+		"interval":          10,
+		"splay":             SplayRandom,
+		"align_to_interval": true,
+	})
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		delay := col.InitialDelay(now)
+		assert.True(t, delay >= 0 && delay < 10*time.Second)
+	}
+}
+
+func TestInitialDelayHostnameSplay(t *testing.T) {
+	oldHostname := hostname
+	defer func() { hostname = oldHostname }()
+	hostname = func() (string, error) { return "host1", nil }
+
+	col := New("Test")
+	col.Configure(map[string]interface{}{
+		"interval":          10,
+		"splay":             SplayHostname,
+		"align_to_interval": true,
+	})
+
+	now := time.Date(2016, 1, 1, 0, 0, 3, 0, time.UTC)
+	delay := col.InitialDelay(now)
+	assert.True(t, delay >= 0 && delay < 10*time.Second)
+
+	// every host collects at the same point of the interval no matter when
+	// fullerite was started
+	later := now.Add(42 * time.Second)
+	firstRun := now.Add(delay).UnixNano() % int64(10*time.Second)
+	laterFirstRun := later.Add(col.InitialDelay(later)).UnixNano() % int64(10*time.Second)
+	assert.Equal(t, firstRun, laterFirstRun)
+
+	hostname = func() (string, error) { return "host2", nil }
+	assert.NotEqual(t, delay, col.InitialDelay(now))
+}
+
+func TestInitialDelayHostnameError(t *testing.T) {
+	oldHostname := hostname
+	defer func() { hostname = oldHostname }()
+	hostname = func() (string, error) { return "", errors.New("no hostname") }
+
+	col := New("Test")
+	col.Configure(map[string]interface{}{
+		"interval": 10,
+		"splay":    SplayHostname,
+	})
+
+	assert.Equal(t, time.Duration(0), col.InitialDelay(time.Now()))
+}
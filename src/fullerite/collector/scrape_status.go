@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"fullerite/metric"
+
+	"time"
+)
+
+// scrapeInBackground runs the scrape in a goroutine, for collectors whose
+// Collect returns before the metrics are gathered. The collector has to set
+// asyncScrape when created so its status reflects the scrapes rather than
+// the calls to Collect.
+func (col *baseCollector) scrapeInBackground(scrape func()) {
+	go func() {
+		start := time.Now()
+		scrape()
+		col.lastScrape.Store(metric.RunStatus{
+			LastRunStart:    start,
+			LastRunDuration: time.Since(start).Seconds(),
+		})
+	}()
+}
+
+// LastScrape returns when the last background scrape started and how long
+// it took, and whether the collector scrapes in the background at all
+func (col *baseCollector) LastScrape() (metric.RunStatus, bool) {
+	status, _ := col.lastScrape.Load().(metric.RunStatus)
+	return status, col.asyncScrape
+}
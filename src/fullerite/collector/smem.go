@@ -1,13 +1,16 @@
-// Requirements: smem (https://www.selenic.com/smem/).
-//
 // Config file: SmemStats.conf
 // Example: {
-//   "user": "some-user", <-- This user should be able to access the /proc/<pid>/smaps files listed in the whitelist below
-//   "procsWhitelist": "apache2|tmux",
-//   "smemPath": "/usr/bin/smem", <-- Path to the smem executable
+//   "procsWhitelist": "apache2|tmux", <-- Regex matched against the command line of the processes
+//   "procPath": "/proc",
+//   "useCapabilities": true, <-- Raise CAP_SYS_PTRACE to read the smaps of the processes of other users
 //   "dimensionsFromCmdline": {"worker_id": "apache worker ([0-9]+)"},
 //   "dimensionsFromEnv": {"env_var_1": "ENV_VAR_1", "env_var_2": "ENV_VAR_2"}, <-- Environment variables gotten from /proc/<pid>/environ
 // }
+//
+// The smaps and environ files of the processes of other users are only
+// readable with CAP_SYS_PTRACE. With useCapabilities, it is raised from the
+// permitted set of fullerite while reading them, e.g. after
+// setcap cap_sys_ptrace+p /usr/bin/fullerite.
 
 package collector
 
@@ -16,7 +19,8 @@ import (
 	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
-	"os/exec"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -30,28 +34,30 @@ type smemStatLine struct {
 	uss  float64
 	rss  float64
 	vss  float64
+	swap float64
 	pid  int
 }
 
-// SmemStats Collector to record smem stats
+// SmemStats Collector to record the memory of processes like smem does, in
+// kB: the proportional (pss), unique (uss), resident (rss), virtual (vss)
+// and swapped (swap) memory
 type SmemStats struct {
 	baseCollector
-	user                  string
 	whitelistedProcs      string
-	smemPath              string
+	procPath              string
+	useCapabilities       bool
 	whitelistedMetrics    []string
 	dimensionsFromCmdline map[string]string
 	dimensionsFromEnv     map[string]string
 }
 
 var (
-	requiredConfigs      = []string{"user", "procsWhitelist"}
-	execCommand          = exec.Command
-	commandOutput        = (*exec.Cmd).Output
+	readProcFile         = ioutil.ReadFile
 	getSmemStats         = (*SmemStats).getSmemStats
 	getCmdLineDimensions = (*SmemStats).getCmdLineDimensions
 	getEnvDimensions     = (*SmemStats).getEnvDimensions
-	allMetrics           = []string{"rss", "vss", "pss", "uss"}
+	withCapabilities     = util.WithCapabilities
+	allMetrics           = []string{"rss", "vss", "pss", "uss", "swap"}
 )
 
 func init() {
@@ -64,6 +70,7 @@ func newSmemStats(channel chan metric.Metric, initialInterval int, log *l.Entry)
 	s.channel = channel
 	s.interval = initialInterval
 	s.name = "SmemStats"
+	s.procPath = "/proc"
 
 	return s
 }
@@ -72,22 +79,24 @@ func newSmemStats(channel chan metric.Metric, initialInterval int, log *l.Entry)
 func (s *SmemStats) Configure(configMap map[string]interface{}) {
 	s.configureCommonParams(configMap)
 
-	if user, exists := configMap["user"]; exists {
-		s.user = user.(string)
-	} else {
-		s.log.Warn("Required config does not exist for SmemStats: user")
-	}
-
 	if whitelist, exists := configMap["procsWhitelist"]; exists {
 		s.whitelistedProcs = whitelist.(string)
 	} else {
 		s.log.Warn("Required config does not exist for SmemStats: procsWhitelist")
 	}
 
-	if smemPath, exists := configMap["smemPath"]; exists {
-		s.smemPath = smemPath.(string)
-	} else {
-		s.log.Warn("Required config does not exist for SmemStats: smemPath")
+	for _, deprecated := range []string{"user", "smemPath"} {
+		if _, exists := configMap[deprecated]; exists {
+			s.log.Warn("Config ", deprecated, " is no longer used by SmemStats, see useCapabilities")
+		}
+	}
+
+	if procPath, exists := configMap["procPath"]; exists {
+		s.procPath = procPath.(string)
+	}
+
+	if useCapabilities, exists := configMap["useCapabilities"]; exists {
+		s.useCapabilities = config.GetAsBool(useCapabilities, false)
 	}
 
 	if blacklist, exists := configMap["metricsBlacklist"]; exists {
@@ -105,30 +114,61 @@ func (s *SmemStats) Configure(configMap map[string]interface{}) {
 	}
 }
 
-// Collect calls smem periodically
+// Collect reads the memory of the whitelisted processes periodically
 func (s *SmemStats) Collect() {
-	if s.whitelistedProcs == "" || s.user == "" || s.smemPath == "" {
+	if s.whitelistedProcs == "" {
 		return
 	}
 
-	for _, stat := range getSmemStats(s) {
-		dims := s.getCustomDimensions(stat.pid)
-		for _, element := range s.whitelistedMetrics {
-			var m metric.Metric
-			switch element {
-			case "pss":
-				m = metric.WithValue(stat.proc+".smem.pss", stat.pss)
-			case "uss":
-				m = metric.WithValue(stat.proc+".smem.uss", stat.uss)
-			case "vss":
-				m = metric.WithValue(stat.proc+".smem.vss", stat.vss)
-			case "rss":
-				m = metric.WithValue(stat.proc+".smem.rss", stat.rss)
-			}
-			m.AddDimensions(dims)
-			s.Channel() <- m
+	metrics := []metric.Metric{}
+	readDone := false
+	read := func() {
+		readDone = true
+		for _, stat := range getSmemStats(s) {
+			metrics = append(metrics, s.smemMetrics(stat)...)
+		}
+	}
+
+	if s.useCapabilities {
+		err := withCapabilities(read, util.CapSysPtrace)
+		switch {
+		case err != nil && readDone:
+			// the processes were read, only dropping the capability failed
+			s.log.Warn("Failed to drop CAP_SYS_PTRACE: ", err)
+		case err != nil:
+			s.log.Warn("Reading the processes without CAP_SYS_PTRACE: ", err)
+			read()
+		}
+	} else {
+		read()
+	}
+
+	for _, m := range metrics {
+		s.Channel() <- m
+	}
+}
+
+func (s *SmemStats) smemMetrics(stat smemStatLine) []metric.Metric {
+	dims := s.getCustomDimensions(stat.pid)
+	metrics := []metric.Metric{}
+	for _, element := range s.whitelistedMetrics {
+		var m metric.Metric
+		switch element {
+		case "pss":
+			m = metric.WithValue(stat.proc+".smem.pss", stat.pss)
+		case "uss":
+			m = metric.WithValue(stat.proc+".smem.uss", stat.uss)
+		case "vss":
+			m = metric.WithValue(stat.proc+".smem.vss", stat.vss)
+		case "rss":
+			m = metric.WithValue(stat.proc+".smem.rss", stat.rss)
+		case "swap":
+			m = metric.WithValue(stat.proc+".smem.swap", stat.swap)
 		}
+		m.AddDimensions(dims)
+		metrics = append(metrics, m)
 	}
+	return metrics
 }
 
 func (s *SmemStats) getCustomDimensions(pid int) map[string]string {
@@ -190,82 +230,115 @@ func (s *SmemStats) getCmdLineDimensions(pid int) map[string]string {
 	return dims
 }
 
+// getSmemStats reads the memory of the processes whose command line
+// matches the whitelist, skipping the ones which exited or can't be read
 func (s *SmemStats) getSmemStats() []smemStatLine {
-	cmdLine := []string{
-		"/usr/bin/sudo",
-		"-u", s.user,
-		s.smemPath,
-		"-P", s.whitelistedProcs,
-		"-c", "pss uss rss vss name pid"}
-
-	out := s.runCommand(cmdLine)
-
-	if out == nil {
+	whitelist, err := regexp.Compile(s.whitelistedProcs)
+	if err != nil {
+		s.log.Error("Failed to compile regex: ", err)
 		return nil
 	}
 
-	return s.parseSmemLines(string(out))
-}
+	dirs, err := ioutil.ReadDir(s.procPath)
+	if err != nil {
+		s.log.Error(err.Error())
+		return nil
+	}
 
-func (s *SmemStats) getCmdLine(pid int) string {
-	cmdLine := []string{
-		"/bin/cat",
-		fmt.Sprintf("/proc/%d/cmdline", pid),
+	stats := []smemStatLine{}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil || !dir.IsDir() {
+			continue
+		}
+		// like smem, the arguments are matched separated by spaces, kernel
+		// threads have no command line
+		cmdLine := strings.TrimSpace(strings.Replace(s.getCmdLine(pid), "\000", " ", -1))
+		if cmdLine == "" || !whitelist.MatchString(cmdLine) {
+			continue
+		}
+		stat, err := s.readSmemStat(pid)
+		if err != nil {
+			s.log.Debug("Skipping process ", pid, ": ", err)
+			continue
+		}
+		stats = append(stats, stat)
 	}
 
-	return string(s.runCommand(cmdLine))
+	return stats
 }
 
-func (s *SmemStats) getEnviron(pid int) string {
-	cmdLine := []string{
-		"/usr/bin/sudo",
-		"-u", s.user,
-		"/bin/cat",
-		fmt.Sprintf("/proc/%d/environ", pid),
-	}
+// readSmemStat computes the memory of a process from its smaps_rollup, or
+// from its smaps before Linux 4.14
+func (s *SmemStats) readSmemStat(pid int) (smemStatLine, error) {
+	stat := smemStatLine{pid: pid}
 
-	environ := s.runCommand(cmdLine)
+	comm, err := readProcFile(s.pidPath(pid, "comm"))
+	if err != nil {
+		return stat, err
+	}
+	stat.proc = strings.TrimSpace(string(comm))
 
-	if environ == nil {
-		return ""
+	smaps, err := readProcFile(s.pidPath(pid, "smaps_rollup"))
+	if err != nil {
+		if smaps, err = readProcFile(s.pidPath(pid, "smaps")); err != nil {
+			return stat, err
+		}
 	}
+	values := parseSmaps(string(smaps))
+	stat.pss = values["Pss"]
+	stat.uss = values["Private_Clean"] + values["Private_Dirty"]
+	stat.rss = values["Rss"]
+	stat.swap = values["Swap"]
+
+	status, err := readProcFile(s.pidPath(pid, "status"))
+	if err != nil {
+		return stat, err
+	}
+	stat.vss = parseProcValues(string(status))["VmSize"]
 
-	return string(environ)
+	return stat, nil
 }
 
-func (s *SmemStats) runCommand(cmdLine []string) []byte {
-	var out []byte
-	var err error
+func (s *SmemStats) pidPath(pid int, file string) string {
+	return filepath.Join(s.procPath, strconv.Itoa(pid), file)
+}
 
-	cmd := execCommand(cmdLine[0], cmdLine[1:]...)
+// parseSmaps sums the values in kB of the mappings of a smaps file, the
+// lines look like "Pss:   461 kB"
+func parseSmaps(contents string) map[string]float64 {
+	values := map[string]float64{}
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[2] != "kB" || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] += value
+	}
+	return values
+}
 
-	if out, err = commandOutput(cmd); err != nil {
-		s.log.Error(err.Error())
-		return nil
+func (s *SmemStats) getCmdLine(pid int) string {
+	cmdLine, err := readProcFile(s.pidPath(pid, "cmdline"))
+	if err != nil {
+		return ""
 	}
 
-	return out
+	return string(cmdLine)
 }
 
-func (s *SmemStats) parseSmemLines(out string) []smemStatLine {
-	raw := strings.Trim(out, "\n")
-	lines := strings.Split(raw, "\n")
-	stats := []smemStatLine{}
-
-	for _, line := range lines {
-		parts := strings.Fields(line)
-		pid, _ := strconv.Atoi(parts[5])
-		stats = append(stats, smemStatLine{
-			proc: parts[4],
-			pss:  util.StrToFloat(parts[0]),
-			uss:  util.StrToFloat(parts[1]),
-			rss:  util.StrToFloat(parts[2]),
-			vss:  util.StrToFloat(parts[3]),
-			pid:  pid,
-		})
+func (s *SmemStats) getEnviron(pid int) string {
+	environ, err := readProcFile(s.pidPath(pid, "environ"))
+	if err != nil {
+		s.log.Debug(err.Error())
+		return ""
 	}
 
-	return stats
+	return string(environ)
 }
 
 func getWhitelistedMetrics(blacklist []string) []string {
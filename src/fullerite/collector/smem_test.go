@@ -3,14 +3,42 @@ package collector
 import (
 	"errors"
 	"fullerite/metric"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
-var smemOutput = `    5     343     864    2442180 apache2 1234
+var smapsRollup = `559a1b0d6000-7ffd0a5f5000 ---p 00000000 00:00 0                          [rollup]
+Rss:                 864 kB
+Pss:                   5 kB
+Shared_Clean:        521 kB
+Shared_Dirty:          0 kB
+Private_Clean:        43 kB
+Private_Dirty:       300 kB
+Swap:                 12 kB
+SwapPss:              12 kB
+`
+
+var smaps = `559a1b0d6000-559a1b0d8000 r--p 00000000 fd:01 1234                       /usr/sbin/apache2
+Size:                  8 kB
+Rss:                   8 kB
+Pss:                   2 kB
+Private_Clean:         0 kB
+Private_Dirty:         0 kB
+Swap:                  0 kB
+VmFlags: rd mr mw me sd
+7ffd0a5d4000-7ffd0a5f5000 rw-p 00000000 00:00 0                          [stack]
+Size:                132 kB
+Rss:                  16 kB
+Pss:                  16 kB
+Private_Clean:         4 kB
+Private_Dirty:        12 kB
+Swap:                  4 kB
+VmFlags: rd wr mr mw me gd ac
 `
 
 func TestNewSmemStats(t *testing.T) {
@@ -28,32 +56,31 @@ func TestNewSmemStats(t *testing.T) {
 
 func TestSmemStatsConfigure(t *testing.T) {
 	tests := []struct {
-		config              map[string]interface{}
-		expectedWhitelist   string
-		expectedUser        string
-		expectedSmemPath    string
-		expectedMetricslist []string
-		msg                 string
+		config                  map[string]interface{}
+		expectedWhitelist       string
+		expectedProcPath        string
+		expectedUseCapabilities bool
+		expectedMetricslist     []string
+		msg                     string
 	}{
 		{
 			config: map[string]interface{}{
-				"user":             "fullerite",
 				"procsWhitelist":   "apache2|tmux",
-				"smemPath":         "/path/to/smem",
+				"procPath":         "/host/proc",
+				"useCapabilities":  true,
 				"metricsBlacklist": []string{"rss", "vss"},
 			},
-			expectedWhitelist:   "apache2|tmux",
-			expectedUser:        "fullerite",
-			expectedSmemPath:    "/path/to/smem",
-			expectedMetricslist: []string{"pss", "uss"},
-			msg:                 "All configs are valid, so no errors",
+			expectedWhitelist:       "apache2|tmux",
+			expectedProcPath:        "/host/proc",
+			expectedUseCapabilities: true,
+			expectedMetricslist:     []string{"pss", "uss", "swap"},
+			msg:                     "All configs are valid, so no errors",
 		},
 		{
 			config:              map[string]interface{}{},
 			expectedWhitelist:   "",
-			expectedUser:        "",
-			expectedSmemPath:    "",
-			expectedMetricslist: []string{"rss", "vss", "pss", "uss"},
+			expectedProcPath:    "/proc",
+			expectedMetricslist: []string{"rss", "vss", "pss", "uss", "swap"},
 			msg:                 "Required configs missing",
 		},
 	}
@@ -64,32 +91,28 @@ func TestSmemStatsConfigure(t *testing.T) {
 		sut := newSmemStats(nil, 0, l).(*SmemStats)
 		sut.Configure(test.config)
 
-		assert.Equal(t, test.expectedUser, sut.user, test.msg)
 		assert.Equal(t, test.expectedWhitelist, sut.whitelistedProcs, test.msg)
-		assert.Equal(t, test.expectedSmemPath, sut.smemPath, test.msg)
+		assert.Equal(t, test.expectedProcPath, sut.procPath, test.msg)
+		assert.Equal(t, test.expectedUseCapabilities, sut.useCapabilities, test.msg)
 		assert.Equal(t, test.expectedMetricslist, sut.whitelistedMetrics, test.msg)
 	}
 }
 
 func TestSmemStatsCollect(t *testing.T) {
-	oldExecCommand := execCommand
-	oldCommandOutput := commandOutput
+	oldGetSmemStats := getSmemStats
 	oldGetCmdLineDimensions := getCmdLineDimensions
 	oldGetEnvDimensions := getEnvDimensions
 
 	defer func() {
-		execCommand = oldExecCommand
-		commandOutput = oldCommandOutput
+		getSmemStats = oldGetSmemStats
 		getCmdLineDimensions = oldGetCmdLineDimensions
 		getEnvDimensions = oldGetEnvDimensions
 	}()
 
-	execCommand = func(string, ...string) *exec.Cmd {
-		return &exec.Cmd{}
-	}
-
-	commandOutput = func(*exec.Cmd) ([]byte, error) {
-		return []byte(smemOutput), nil
+	getSmemStats = func(*SmemStats) []smemStatLine {
+		return []smemStatLine{
+			{proc: "apache2", pss: 5, uss: 343, rss: 864, vss: 2442180, swap: 12, pid: 1234},
+		}
 	}
 
 	getCmdLineDimensions = func(*SmemStats, int) map[string]string {
@@ -111,14 +134,13 @@ func TestSmemStatsCollect(t *testing.T) {
 		metric.Metric{Name: "apache2.smem.uss", MetricType: "gauge", Value: 343, Dimensions: expectedDims},
 		metric.Metric{Name: "apache2.smem.vss", MetricType: "gauge", Value: 2.44218e+06, Dimensions: expectedDims},
 		metric.Metric{Name: "apache2.smem.rss", MetricType: "gauge", Value: 864, Dimensions: expectedDims},
+		metric.Metric{Name: "apache2.smem.swap", MetricType: "gauge", Value: 12, Dimensions: expectedDims},
 	}
 
 	c := make(chan metric.Metric)
 	sut := newSmemStats(c, 0, defaultLog).(*SmemStats)
-	sut.user = "user"
 	sut.whitelistedProcs = "some|whitelist"
-	sut.smemPath = "/path/to/smem"
-	sut.whitelistedMetrics = []string{"pss", "uss", "vss", "rss"}
+	sut.whitelistedMetrics = []string{"pss", "uss", "vss", "rss", "swap"}
 	go sut.Collect()
 
 	for i := 0; i < len(expected); i++ {
@@ -128,31 +150,70 @@ func TestSmemStatsCollect(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
-func TestSmemStatsCollectNotCalled(t *testing.T) {
+func TestSmemStatsCollectCapabilities(t *testing.T) {
 	oldGetSmemStats := getSmemStats
-	defer func() { getSmemStats = oldGetSmemStats }()
+	oldWithCapabilities := withCapabilities
+	defer func() {
+		getSmemStats = oldGetSmemStats
+		withCapabilities = oldWithCapabilities
+	}()
 
-	getSmemStatsCalled := false
+	getSmemStatsCalls := 0
 	getSmemStats = func(*SmemStats) []smemStatLine {
-		getSmemStatsCalled = true
+		getSmemStatsCalls++
 		return nil
 	}
 
 	tests := []struct {
-		user               string
-		whitelistedProcs   string
-		smemPath           string
-		whitelistedMetrics []string
+		msg              string
+		withCapabilities func(func(), ...int) error
+		expectedCalls    int
 	}{
 		{
-			user: "user",
+			msg:              "capability raised and dropped",
+			withCapabilities: func(fn func(), _ ...int) error { fn(); return nil },
+			expectedCalls:    1,
 		},
 		{
-			whitelistedProcs: "apache2",
+			msg:              "capability not raised, read without it",
+			withCapabilities: func(fn func(), _ ...int) error { return errors.New("not permitted") },
+			expectedCalls:    1,
 		},
 		{
-			smemPath: "/path/to/smem",
+			msg:              "capability not dropped, not read twice",
+			withCapabilities: func(fn func(), _ ...int) error { fn(); return errors.New("capset failed") },
+			expectedCalls:    1,
 		},
+	}
+
+	for _, test := range tests {
+		getSmemStatsCalls = 0
+		withCapabilities = test.withCapabilities
+
+		sut := newSmemStats(nil, 0, defaultLog).(*SmemStats)
+		sut.whitelistedProcs = "some|whitelist"
+		sut.useCapabilities = true
+		sut.Collect()
+
+		assert.Equal(t, test.expectedCalls, getSmemStatsCalls, test.msg)
+	}
+}
+
+func TestSmemStatsCollectNotCalled(t *testing.T) {
+	oldGetSmemStats := getSmemStats
+	defer func() { getSmemStats = oldGetSmemStats }()
+
+	getSmemStatsCalled := false
+	getSmemStats = func(*SmemStats) []smemStatLine {
+		getSmemStatsCalled = true
+		return nil
+	}
+
+	tests := []struct {
+		whitelistedProcs   string
+		whitelistedMetrics []string
+	}{
+		{},
 		{
 			whitelistedMetrics: []string{"pss", "uss"},
 		},
@@ -160,9 +221,7 @@ func TestSmemStatsCollectNotCalled(t *testing.T) {
 
 	for _, test := range tests {
 		sut := newSmemStats(nil, 0, nil).(*SmemStats)
-		sut.user = test.user
 		sut.whitelistedProcs = test.whitelistedProcs
-		sut.smemPath = test.smemPath
 		sut.whitelistedMetrics = test.whitelistedMetrics
 
 		sut.Collect()
@@ -171,34 +230,9 @@ func TestSmemStatsCollectNotCalled(t *testing.T) {
 	}
 }
 
-// func TestGetCmdLineDimensions(t *testing.T) {
-// 	oldReadCmdline := readCmdline
-// 	defer func() { readCmdline = oldReadCmdline }()
-
-// 	readCmdline = func(*SmemStats, string) ([]byte, error) {
-// 		return []byte("apache worker 1"), nil
-// 	}
-
-// 	s := newSmemStats(nil, 0, nil).(*SmemStats)
-// 	s.dimensionsFromCmdline = map[string]string{
-// 		"worker_id": ".* worker ([0-9]+)$",
-// 	}
-
-// 	expectedDims := map[string]string{
-// 		"worker_id": "1",
-// 	}
-
-// 	assert.Equal(t, getCmdLineDimensions(s, 123), expectedDims)
-// }
-
 func TestGetCmdLineDimensions(t *testing.T) {
-	oldExecCommand := execCommand
-	oldCommandOutput := commandOutput
-
-	defer func() {
-		execCommand = oldExecCommand
-		commandOutput = oldCommandOutput
-	}()
+	oldReadProcFile := readProcFile
+	defer func() { readProcFile = oldReadProcFile }()
 
 	tests := []struct {
 		pid                   int
@@ -250,11 +284,7 @@ func TestGetCmdLineDimensions(t *testing.T) {
 		s := newSmemStats(nil, 0, defaultLog.WithFields(l.Fields{"collector": "SmemStats"})).(*SmemStats)
 		s.dimensionsFromCmdline = test.dimensionsFromCmdLine
 
-		execCommand = func(string, ...string) *exec.Cmd {
-			return &exec.Cmd{}
-		}
-
-		commandOutput = func(*exec.Cmd) ([]byte, error) {
+		readProcFile = func(string) ([]byte, error) {
 			return []byte(test.cmdLineData), test.cmdLineReadError
 		}
 
@@ -263,13 +293,8 @@ func TestGetCmdLineDimensions(t *testing.T) {
 }
 
 func TestGetEnvDimensions(t *testing.T) {
-	oldExecCommand := execCommand
-	oldCommandOutput := commandOutput
-
-	defer func() {
-		execCommand = oldExecCommand
-		commandOutput = oldCommandOutput
-	}()
+	oldReadProcFile := readProcFile
+	defer func() { readProcFile = oldReadProcFile }()
 
 	tests := []struct {
 		pid                int
@@ -321,14 +346,67 @@ func TestGetEnvDimensions(t *testing.T) {
 		s := newSmemStats(nil, 0, defaultLog.WithFields(l.Fields{"collector": "SmemStats"})).(*SmemStats)
 		s.dimensionsFromEnv = test.dimensionsFromEnv
 
-		execCommand = func(string, ...string) *exec.Cmd {
-			return &exec.Cmd{}
-		}
-
-		commandOutput = func(*exec.Cmd) ([]byte, error) {
+		readProcFile = func(string) ([]byte, error) {
 			return []byte(test.environ), test.environReadError
 		}
 
 		assert.Equal(t, test.expectedDimensions, getEnvDimensions(s, test.pid), test.msg)
 	}
 }
+
+func writeSmemProcess(t *testing.T, procPath string, pid string, files map[string]string) {
+	dir := filepath.Join(procPath, pid)
+	assert.Nil(t, os.MkdirAll(dir, 0755))
+	for name, contents := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+}
+
+func TestGetSmemStats(t *testing.T) {
+	procPath, err := ioutil.TempDir("", "proc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(procPath)
+
+	status := "Name:\tapache2\nVmSize:\t 2442180 kB\nVmRSS:\t     864 kB\n"
+	writeSmemProcess(t, procPath, "1234", map[string]string{
+		"comm": "apache2\n", "cmdline": "/usr/sbin/apache2\000-k\000start\000",
+		"smaps_rollup": smapsRollup, "status": status,
+	})
+	// before Linux 4.14, without smaps_rollup
+	writeSmemProcess(t, procPath, "1235", map[string]string{
+		"comm": "apache2\n", "cmdline": "/usr/sbin/apache2\000-k\000start\000",
+		"smaps": smaps, "status": status,
+	})
+	writeSmemProcess(t, procPath, "1236", map[string]string{
+		"comm": "tmux\n", "cmdline": "tmux\000", "smaps_rollup": smapsRollup, "status": status,
+	})
+	// a kernel thread
+	writeSmemProcess(t, procPath, "2", map[string]string{"comm": "kthreadd\n", "cmdline": ""})
+	writeSmemProcess(t, procPath, "self", map[string]string{"comm": "apache2 -k start\n"})
+
+	s := newSmemStats(nil, 0, defaultLog.WithFields(l.Fields{"collector": "SmemStats"})).(*SmemStats)
+	s.Configure(map[string]interface{}{"procsWhitelist": "apache2 -k", "procPath": procPath})
+
+	assert.Equal(t, []smemStatLine{
+		{proc: "apache2", pss: 5, uss: 343, rss: 864, vss: 2442180, swap: 12, pid: 1234},
+		{proc: "apache2", pss: 18, uss: 16, rss: 24, vss: 2442180, swap: 4, pid: 1235},
+	}, s.getSmemStats())
+}
+
+func TestGetSmemStatsInvalidWhitelist(t *testing.T) {
+	s := newSmemStats(nil, 0, defaultLog.WithFields(l.Fields{"collector": "SmemStats"})).(*SmemStats)
+	s.Configure(map[string]interface{}{"procsWhitelist": "(apache2"})
+
+	assert.Nil(t, s.getSmemStats())
+}
+
+func TestParseSmaps(t *testing.T) {
+	assert.Equal(t, map[string]float64{
+		"Size":          140,
+		"Rss":           24,
+		"Pss":           18,
+		"Private_Clean": 4,
+		"Private_Dirty": 12,
+		"Swap":          4,
+	}, parseSmaps(smaps))
+}
@@ -4,25 +4,18 @@ import (
 	"fullerite/config"
 	"fullerite/metric"
 	"fullerite/util"
-	"os/exec"
-	"regexp"
-	"strings"
+	"strconv"
 
 	l "github.com/Sirupsen/logrus"
 )
 
-// SocketQueue reports output of "ss" command and reports
-// the socket RecvQ value as a metric.
+// SocketQueue reports the accept queue (RecvQ) of the listening
+// TCP sockets of some ports as a metric.
 type SocketQueue struct {
 	baseCollector
 	portList []string
 }
 
-var (
-	cmdOutput      = (*exec.Cmd).CombinedOutput
-	queueDataRegex = regexp.MustCompile(`\S+\s+(\S+)\s+\S+\s+\S+:(\S+).*`)
-)
-
 func init() {
 	RegisterCollector("SocketQueue", newSocketQueue)
 }
@@ -54,30 +47,26 @@ func (ss SocketQueue) Collect() {
 		return
 	}
 
-	/** Run the command 'ss -ntl sport = :<port_num> or sport = :<port_num> ...'
-	  to obtain the recvQ value
-	*/
-	filter := "sport = :" + strings.Join(ss.portList, " or sport = :")
-
-	cmd := exec.Command("ss", "-ntl", filter)
-	output, err := cmdOutput(cmd)
+	sockets, err := listSockets(socketSourceAuto, "/proc", "tcp")
 	if err != nil {
 		ss.log.Error("Error while collecting metrics: ", err)
 		return
 	}
-	ss.emitSocketQueueMetrics(output)
+	ss.emitSocketQueueMetrics(sockets)
 }
 
-func (ss SocketQueue) emitSocketQueueMetrics(output []byte) {
-	// Capture the receive queue size and the corres. port number from the output.
-	lines := strings.Split(string(output), "\n")
+func (ss SocketQueue) emitSocketQueueMetrics(sockets []util.Socket) {
+	ports := make(map[string]bool)
+	for _, port := range ss.portList {
+		ports[port] = true
+	}
 
+	// The accept queues of the IPv4 and IPv6 sockets of a port add up
 	pmap := make(map[string]float64)
-	for _, line := range lines[1:] {
-		res := queueDataRegex.FindAllStringSubmatch(line, -1)
-		for _, v := range res {
-			sport, qsize := v[2], v[1]
-			pmap[sport] = util.StrToFloat(qsize)
+	for _, socket := range sockets {
+		sport := strconv.Itoa(socket.LocalPort)
+		if socket.State == "listen" && ports[sport] {
+			pmap[sport] += float64(socket.RecvQ)
 		}
 	}
 
@@ -2,7 +2,8 @@ package collector
 
 import (
 	"fullerite/metric"
-	"os/exec"
+	"fullerite/util"
+	"net"
 	"testing"
 
 	l "github.com/Sirupsen/logrus"
@@ -13,10 +14,14 @@ func getSocketQueueCollector() *SocketQueue {
 	return newSocketQueue(make(chan metric.Metric), 10, l.WithField("testing", "socket_queue")).(*SocketQueue)
 }
 
-var socketStatsOut = `State      Recv-Q Send-Q        Local Address:Port          Peer Address:Port
-LISTEN      10      128     *:9080    *:*
-LISTEN      0      128     *:1224    *:*
-LISTEN      0      128     *:1234    *:*`
+var socketQueueSockets = []util.Socket{
+	{Protocol: "tcp", Family: "ipv4", State: "listen", LocalIP: net.IPv4zero, LocalPort: 9080, RecvQ: 6, SendQ: 128},
+	{Protocol: "tcp", Family: "ipv6", State: "listen", LocalIP: net.IPv6zero, LocalPort: 9080, RecvQ: 4, SendQ: 128},
+	{Protocol: "tcp", Family: "ipv4", State: "listen", LocalIP: net.IPv4zero, LocalPort: 1224, SendQ: 128},
+	{Protocol: "tcp", Family: "ipv4", State: "listen", LocalIP: net.IPv4zero, LocalPort: 1234, SendQ: 128},
+	{Protocol: "tcp", Family: "ipv4", State: "listen", LocalIP: net.IPv4zero, LocalPort: 22, RecvQ: 3, SendQ: 128},
+	{Protocol: "tcp", Family: "ipv4", State: "established", LocalIP: net.IPv4zero, LocalPort: 9080, RecvQ: 100},
+}
 
 func TestDefaultConfigSocketQueue(t *testing.T) {
 	sscol := getSocketQueueCollector()
@@ -36,14 +41,14 @@ func TestCustomConfigSocketQueue(t *testing.T) {
 }
 
 func TestCollectSocketQueue(t *testing.T) {
-	oldCommandOutput := cmdOutput
+	oldListSockets := listSockets
 
 	defer func() {
-		cmdOutput = oldCommandOutput
+		listSockets = oldListSockets
 	}()
 
-	cmdOutput = func(*exec.Cmd) ([]byte, error) {
-		return []byte(socketStatsOut), nil
+	listSockets = func(source string, procPath string, protocol string) ([]util.Socket, error) {
+		return socketQueueSockets, nil
 	}
 
 	expected := []metric.Metric{
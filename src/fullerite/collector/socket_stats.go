@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"fmt"
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+	"net"
+	"strconv"
+	"strings"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// The sources of the sockets
+const (
+	socketSourceAuto    = "auto"
+	socketSourceNetlink = "netlink"
+	socketSourceProc    = "proc"
+)
+
+// Dependency injection: Makes writing unit tests much easier, by being able to override these values in the *_test.go files.
+var listSockets = func(source string, procPath string, protocol string) ([]util.Socket, error) {
+	switch source {
+	case socketSourceNetlink:
+		return util.SockDiagSockets(protocol)
+	case socketSourceProc:
+		return util.ProcNetSockets(procPath, protocol)
+	default:
+		return util.ListSockets(procPath, protocol)
+	}
+}
+
+// SocketStats collector
+// lists the TCP and UDP sockets, IPv4 and IPv6, over netlink sock_diag or
+// from /proc/net and reports:
+//   - socket.listen.recvq and socket.listen.sendq, the accept queue of the
+//     listening TCP sockets and its maximum, the maximum is 0 from /proc
+//   - socket.tcp.connections, the TCP connections per state of a local
+//     port, or of a remote port for the outgoing ones, without ports the
+//     local ports are the ones listened on
+//   - socket.tcp.sockets, all the TCP sockets per state and family
+//   - socket.udp.drops, recvq, sendq, rcvbuf and sndbuf of the unconnected
+//     UDP sockets, summed over the sockets sharing a port and an address
+//     with SO_REUSEPORT, the buffer sizes are only read over netlink
+//
+// The sockets are filtered by the ports and the local addresses, IPs or
+// CIDRs, an empty list matches them all. The sockets bound to any address
+// have the address 0.0.0.0 or ::.
+type SocketStats struct {
+	baseCollector
+
+	source    string
+	procPath  string
+	ports     map[int]bool
+	addresses []*net.IPNet
+}
+
+type socketKey struct {
+	port    int
+	address string
+	family  string
+}
+
+type connectionKey struct {
+	dimension string
+	port      int
+	state     string
+}
+
+func init() {
+	RegisterCollector("SocketStats", newSocketStats)
+}
+
+func newSocketStats(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	s := new(SocketStats)
+
+	s.log = log
+	s.channel = channel
+	s.interval = initialInterval
+
+	s.name = "SocketStats"
+	s.source = socketSourceAuto
+	s.procPath = "/proc"
+	s.ports = map[int]bool{}
+	return s
+}
+
+// Configure takes a dictionary of values with which the handler can configure itself
+func (s *SocketStats) Configure(configMap map[string]interface{}) {
+	if source, exists := configMap["source"]; exists {
+		switch source.(string) {
+		case socketSourceAuto, socketSourceNetlink, socketSourceProc:
+			s.source = source.(string)
+		default:
+			s.log.Warn("Unknown source ", source, ", using ", s.source)
+		}
+	}
+	if procPath, exists := configMap["procPath"]; exists {
+		s.procPath = procPath.(string)
+	}
+	if ports, exists := configMap["ports"]; exists {
+		for _, port := range config.GetAsSlice(ports) {
+			value, err := strconv.Atoi(port)
+			if err != nil {
+				s.log.Warn("Ignoring invalid port ", port)
+				continue
+			}
+			s.ports[value] = true
+		}
+	}
+	if addresses, exists := configMap["addresses"]; exists {
+		for _, address := range config.GetAsSlice(addresses) {
+			network, err := parseAddressWhitelist(address)
+			if err != nil {
+				s.log.Warn("Ignoring invalid address ", address)
+				continue
+			}
+			s.addresses = append(s.addresses, network)
+		}
+	}
+	s.configureCommonParams(configMap)
+}
+
+// parseAddressWhitelist reads an IP or a CIDR
+func parseAddressWhitelist(address string) (*net.IPNet, error) {
+	if strings.Contains(address, "/") {
+		_, network, err := net.ParseCIDR(address)
+		return network, err
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %s", address)
+	}
+	if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Collect lists the sockets and emits their metrics
+func (s *SocketStats) Collect() {
+	tcpSockets, err := listSockets(s.source, s.procPath, "tcp")
+	if err != nil {
+		s.log.Error("Error while listing the TCP sockets: ", err)
+	} else {
+		for _, m := range s.tcpMetrics(tcpSockets) {
+			s.Channel() <- m
+		}
+	}
+
+	udpSockets, err := listSockets(s.source, s.procPath, "udp")
+	if err != nil {
+		s.log.Error("Error while listing the UDP sockets: ", err)
+	} else {
+		for _, m := range s.udpMetrics(udpSockets) {
+			s.Channel() <- m
+		}
+	}
+}
+
+func (s *SocketStats) portMatches(port int) bool {
+	return len(s.ports) == 0 || s.ports[port]
+}
+
+func (s *SocketStats) addressMatches(ip net.IP) bool {
+	if len(s.addresses) == 0 {
+		return true
+	}
+	for _, network := range s.addresses {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SocketStats) tcpMetrics(sockets []util.Socket) []metric.Metric {
+	recvQ := map[socketKey]float64{}
+	sendQ := map[socketKey]float64{}
+	connections := map[connectionKey]float64{}
+	totals := map[[2]string]float64{}
+
+	// without ports the connections are only counted by the local ports
+	// listened on, the ephemeral ports would make a series each
+	listening := map[int]bool{}
+	for _, socket := range sockets {
+		if socket.State == "listen" {
+			listening[socket.LocalPort] = true
+		}
+	}
+
+	for _, socket := range sockets {
+		totals[[2]string{socket.State, socket.Family}]++
+
+		if socket.State == "listen" {
+			if s.portMatches(socket.LocalPort) && s.addressMatches(socket.LocalIP) {
+				key := socketKey{socket.LocalPort, socket.LocalIP.String(), socket.Family}
+				recvQ[key] += float64(socket.RecvQ)
+				sendQ[key] += float64(socket.SendQ)
+			}
+			continue
+		}
+		if !s.addressMatches(socket.LocalIP) {
+			continue
+		}
+		if len(s.ports) == 0 {
+			if listening[socket.LocalPort] {
+				connections[connectionKey{"port", socket.LocalPort, socket.State}]++
+			}
+		} else if s.ports[socket.LocalPort] {
+			connections[connectionKey{"port", socket.LocalPort, socket.State}]++
+		} else if s.ports[socket.RemotePort] {
+			connections[connectionKey{"remote_port", socket.RemotePort, socket.State}]++
+		}
+	}
+
+	metrics := []metric.Metric{}
+	for key, value := range recvQ {
+		metrics = append(metrics, socketMetric("socket.listen.recvq", value, key))
+		metrics = append(metrics, socketMetric("socket.listen.sendq", sendQ[key], key))
+	}
+	for key, value := range connections {
+		m := metric.WithValue("socket.tcp.connections", value)
+		m.AddDimension(key.dimension, strconv.Itoa(key.port))
+		m.AddDimension("state", key.state)
+		metrics = append(metrics, m)
+	}
+	for key, value := range totals {
+		m := metric.WithValue("socket.tcp.sockets", value)
+		m.AddDimension("state", key[0])
+		m.AddDimension("family", key[1])
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func (s *SocketStats) udpMetrics(sockets []util.Socket) []metric.Metric {
+	drops := map[socketKey]float64{}
+	recvQ := map[socketKey]float64{}
+	sendQ := map[socketKey]float64{}
+	rcvBuf := map[socketKey]float64{}
+	sndBuf := map[socketKey]float64{}
+	for _, socket := range sockets {
+		// the connected UDP sockets are the clients
+		if socket.State != "close" {
+			continue
+		}
+		if !s.portMatches(socket.LocalPort) || !s.addressMatches(socket.LocalIP) {
+			continue
+		}
+		key := socketKey{socket.LocalPort, socket.LocalIP.String(), socket.Family}
+		drops[key] += float64(socket.Drops)
+		recvQ[key] += float64(socket.RecvQ)
+		sendQ[key] += float64(socket.SendQ)
+		rcvBuf[key] += float64(socket.RcvBuf)
+		sndBuf[key] += float64(socket.SndBuf)
+	}
+
+	metrics := []metric.Metric{}
+	for key, value := range drops {
+		m := socketMetric("socket.udp.drops", value, key)
+		m.MetricType = metric.CumulativeCounter
+		metrics = append(metrics, m)
+		metrics = append(metrics, socketMetric("socket.udp.recvq", recvQ[key], key))
+		metrics = append(metrics, socketMetric("socket.udp.sendq", sendQ[key], key))
+		if rcvBuf[key] > 0 {
+			metrics = append(metrics, socketMetric("socket.udp.rcvbuf", rcvBuf[key], key))
+		}
+		if sndBuf[key] > 0 {
+			metrics = append(metrics, socketMetric("socket.udp.sndbuf", sndBuf[key], key))
+		}
+	}
+	return metrics
+}
+
+func socketMetric(name string, value float64, key socketKey) metric.Metric {
+	m := metric.WithValue(name, value)
+	m.AddDimension("port", strconv.Itoa(key.port))
+	m.AddDimension("address", key.address)
+	m.AddDimension("family", key.family)
+	return m
+}
@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"fmt"
+	"fullerite/metric"
+	"fullerite/util"
+	"net"
+	"testing"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestSocketStats() *SocketStats {
+	return newSocketStats(make(chan metric.Metric), 10, l.WithField("testing", "socket_stats")).(*SocketStats)
+}
+
+var (
+	testTCPSockets = []util.Socket{
+		{Family: "ipv4", State: "listen", LocalIP: net.ParseIP("0.0.0.0").To4(), LocalPort: 8080, RecvQ: 3, SendQ: 128},
+		{Family: "ipv4", State: "listen", LocalIP: net.ParseIP("0.0.0.0").To4(), LocalPort: 8080, RecvQ: 2, SendQ: 128},
+		{Family: "ipv6", State: "listen", LocalIP: net.ParseIP("::"), LocalPort: 8080, RecvQ: 1, SendQ: 64},
+		{Family: "ipv4", State: "listen", LocalIP: net.ParseIP("127.0.0.1").To4(), LocalPort: 22, SendQ: 128},
+		{Family: "ipv4", State: "established", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 8080, RemotePort: 41000},
+		{Family: "ipv4", State: "established", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 8080, RemotePort: 41001},
+		{Family: "ipv4", State: "time_wait", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 8080, RemotePort: 41002},
+		{Family: "ipv4", State: "established", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 52000, RemotePort: 3306},
+		{Family: "ipv4", State: "established", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 22, RemotePort: 50000},
+	}
+	testUDPSockets = []util.Socket{
+		{Family: "ipv4", State: "close", LocalIP: net.ParseIP("0.0.0.0").To4(), LocalPort: 8125, RecvQ: 512, Drops: 7, RcvBuf: 212992, SndBuf: 212992},
+		{Family: "ipv6", State: "close", LocalIP: net.ParseIP("::1"), LocalPort: 53, Drops: 1},
+		{Family: "ipv4", State: "established", LocalIP: net.ParseIP("10.0.0.1").To4(), LocalPort: 40000, RemotePort: 8125},
+	}
+)
+
+func socketStatsByName(metrics []metric.Metric) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range metrics {
+		key := m.Name
+		for _, dim := range []string{"port", "remote_port", "address", "family", "state"} {
+			if value, ok := m.Dimensions[dim]; ok {
+				key += fmt.Sprintf(",%s=%s", dim, value)
+			}
+		}
+		values[key] = m.Value
+	}
+	return values
+}
+
+func TestSocketStatsConfigure(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{
+		"interval":  5,
+		"source":    "proc",
+		"procPath":  "/host/proc",
+		"ports":     []interface{}{"8080", "not a port", "3306"},
+		"addresses": []interface{}{"10.0.0.0/8", "::1", "nope"},
+	})
+
+	assert.Equal(t, 5, s.Interval())
+	assert.Equal(t, "proc", s.source)
+	assert.Equal(t, "/host/proc", s.procPath)
+	assert.Equal(t, map[int]bool{8080: true, 3306: true}, s.ports)
+	assert.Equal(t, 2, len(s.addresses))
+	assert.True(t, s.addressMatches(net.ParseIP("10.1.2.3")))
+	assert.True(t, s.addressMatches(net.ParseIP("::1")))
+	assert.False(t, s.addressMatches(net.ParseIP("127.0.0.1")))
+}
+
+func TestSocketStatsConfigureDefaults(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{"source": "ss"})
+
+	assert.Equal(t, "auto", s.source)
+	assert.Equal(t, "/proc", s.procPath)
+	assert.True(t, s.portMatches(1234))
+	assert.True(t, s.addressMatches(net.ParseIP("192.168.0.1")))
+}
+
+func TestSocketStatsTCPMetrics(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{"ports": []interface{}{"8080", "3306"}})
+
+	values := socketStatsByName(s.tcpMetrics(testTCPSockets))
+	assert.Equal(t, map[string]float64{
+		"socket.listen.recvq,port=8080,address=0.0.0.0,family=ipv4": 5,
+		"socket.listen.sendq,port=8080,address=0.0.0.0,family=ipv4": 256,
+		"socket.listen.recvq,port=8080,address=::,family=ipv6":      1,
+		"socket.listen.sendq,port=8080,address=::,family=ipv6":      64,
+		"socket.tcp.connections,port=8080,state=established":        2,
+		"socket.tcp.connections,port=8080,state=time_wait":          1,
+		"socket.tcp.connections,remote_port=3306,state=established": 1,
+		"socket.tcp.sockets,family=ipv4,state=listen":               3,
+		"socket.tcp.sockets,family=ipv6,state=listen":               1,
+		"socket.tcp.sockets,family=ipv4,state=established":          4,
+		"socket.tcp.sockets,family=ipv4,state=time_wait":            1,
+	}, values)
+}
+
+func TestSocketStatsTCPMetricsListeningPorts(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{})
+
+	values := socketStatsByName(s.tcpMetrics(testTCPSockets))
+	assert.Equal(t, 2.0, values["socket.tcp.connections,port=8080,state=established"])
+	assert.Equal(t, 1.0, values["socket.tcp.connections,port=8080,state=time_wait"])
+	assert.Equal(t, 1.0, values["socket.tcp.connections,port=22,state=established"])
+	// the outgoing connection from an ephemeral port is left out
+	for key := range values {
+		assert.NotContains(t, key, "port=52000")
+		assert.NotContains(t, key, "remote_port")
+	}
+}
+
+func TestSocketStatsTCPMetricsAddresses(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{"addresses": []interface{}{"127.0.0.1"}})
+
+	values := socketStatsByName(s.tcpMetrics(testTCPSockets))
+	assert.Equal(t, 0.0, values["socket.listen.recvq,port=22,address=127.0.0.1,family=ipv4"])
+	assert.Equal(t, 128.0, values["socket.listen.sendq,port=22,address=127.0.0.1,family=ipv4"])
+	_, exists := values["socket.listen.recvq,port=8080,address=0.0.0.0,family=ipv4"]
+	assert.False(t, exists)
+	_, exists = values["socket.tcp.connections,port=8080,state=established"]
+	assert.False(t, exists)
+}
+
+func TestSocketStatsUDPMetrics(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{})
+
+	metrics := s.udpMetrics(testUDPSockets)
+	values := socketStatsByName(metrics)
+	assert.Equal(t, map[string]float64{
+		"socket.udp.drops,port=8125,address=0.0.0.0,family=ipv4":  7,
+		"socket.udp.recvq,port=8125,address=0.0.0.0,family=ipv4":  512,
+		"socket.udp.sendq,port=8125,address=0.0.0.0,family=ipv4":  0,
+		"socket.udp.rcvbuf,port=8125,address=0.0.0.0,family=ipv4": 212992,
+		"socket.udp.sndbuf,port=8125,address=0.0.0.0,family=ipv4": 212992,
+		"socket.udp.drops,port=53,address=::1,family=ipv6":        1,
+		"socket.udp.recvq,port=53,address=::1,family=ipv6":        0,
+		"socket.udp.sendq,port=53,address=::1,family=ipv6":        0,
+	}, values)
+	for _, m := range metrics {
+		if m.Name == "socket.udp.drops" {
+			assert.Equal(t, metric.CumulativeCounter, m.MetricType)
+		} else {
+			assert.Equal(t, metric.Gauge, m.MetricType)
+		}
+	}
+}
+
+func TestSocketStatsUDPMetricsReusePort(t *testing.T) {
+	s := getTestSocketStats()
+	s.Configure(map[string]interface{}{})
+
+	socket := util.Socket{Family: "ipv4", State: "close", LocalIP: net.ParseIP("0.0.0.0").To4(), LocalPort: 8125, RecvQ: 512, Drops: 7, RcvBuf: 1024}
+	metrics := s.udpMetrics([]util.Socket{socket, socket})
+	assert.Equal(t, 4, len(metrics))
+	values := socketStatsByName(metrics)
+	assert.Equal(t, 14.0, values["socket.udp.drops,port=8125,address=0.0.0.0,family=ipv4"])
+	assert.Equal(t, 1024.0, values["socket.udp.recvq,port=8125,address=0.0.0.0,family=ipv4"])
+	assert.Equal(t, 2048.0, values["socket.udp.rcvbuf,port=8125,address=0.0.0.0,family=ipv4"])
+}
+
+func TestSocketStatsCollect(t *testing.T) {
+	oldListSockets := listSockets
+	defer func() { listSockets = oldListSockets }()
+
+	listed := []string{}
+	listSockets = func(source string, procPath string, protocol string) ([]util.Socket, error) {
+		listed = append(listed, source+":"+procPath+":"+protocol)
+		if protocol == "tcp" {
+			return nil, fmt.Errorf("no sockets")
+		}
+		return testUDPSockets[:1], nil
+	}
+
+	c := make(chan metric.Metric, 10)
+	s := newSocketStats(c, 10, l.WithField("testing", "socket_stats")).(*SocketStats)
+	s.Configure(map[string]interface{}{"source": "netlink"})
+	s.Collect()
+	close(c)
+
+	names := []string{}
+	for m := range c {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"netlink:/proc:tcp", "netlink:/proc:udp"}, listed)
+	assert.Equal(t, []string{
+		"socket.udp.drops", "socket.udp.recvq", "socket.udp.sendq", "socket.udp.rcvbuf", "socket.udp.sndbuf",
+	}, names)
+}
@@ -22,7 +22,7 @@ import (
 type uWSGINerveWorkerStatsCollector struct {
 	baseCollector
 
-	configFilePath    string
+	configFilePaths   []string
 	queryPath         string
 	timeout           int
 	servicesWhitelist []string
@@ -41,7 +41,7 @@ func newUWSGINerveWorkerStats(channel chan metric.Metric, initialInterval int, l
 	col.interval = initialInterval
 
 	col.name = "UWSGINerveWorkerStats"
-	col.configFilePath = "/etc/nerve/nerve.conf.json"
+	col.configFilePaths = []string{defaultNerveConfigPath}
 	col.queryPath = "status/uwsgi"
 	col.timeout = 2
 
@@ -54,7 +54,7 @@ func (n *uWSGINerveWorkerStatsCollector) Configure(configMap map[string]interfac
 		n.queryPath = val.(string)
 	}
 	if val, exists := configMap["configFilePath"]; exists {
-		n.configFilePath = val.(string)
+		n.configFilePaths = getNerveConfigPaths(val)
 	}
 	if val, exists := configMap["servicesWhitelist"]; exists {
 		n.servicesWhitelist = config.GetAsSlice(val)
@@ -69,16 +69,9 @@ func (n *uWSGINerveWorkerStatsCollector) Configure(configMap map[string]interfac
 
 // Parses nerve config from HTTP uWSGI stats endpoints
 func (n *uWSGINerveWorkerStatsCollector) Collect() {
-	rawFileContents, err := ioutil.ReadFile(n.configFilePath)
+	services, err := util.ReadNerveConfigs(n.configFilePaths, false)
 	if err != nil {
-		n.log.Warn("Failed to read the contents of file ", n.configFilePath, " because ", err)
-		return
-	}
-
-	services, err := util.ParseNerveConfig(&rawFileContents, false)
-	if err != nil {
-		n.log.Warn("Failed to parse the nerve config at ", n.configFilePath, ": ", err)
-		return
+		n.log.Warn("Failed to read the nerve configs: ", err)
 	}
 	n.log.Debug("Finished parsing Nerve config into ", services)
 
@@ -357,7 +357,7 @@ func TestDefaultConfigNerveUWSGIWorkerStats(t *testing.T) {
 
 	assert.Equal(t, 12, inst.Interval())
 	assert.Equal(t, 2, inst.timeout)
-	assert.Equal(t, "/etc/nerve/nerve.conf.json", inst.configFilePath)
+	assert.Equal(t, []string{"/etc/nerve/nerve.conf.json"}, inst.configFilePaths)
 	assert.Equal(t, "status/uwsgi", inst.queryPath)
 }
 
@@ -373,7 +373,7 @@ func TestConfigNerveUWSGIWorkerStats(t *testing.T) {
 	inst.Configure(cfg)
 
 	assert.Equal(t, 345, inst.Interval())
-	assert.Equal(t, "/etc/your/moms/house", inst.configFilePath)
+	assert.Equal(t, []string{"/etc/your/moms/house"}, inst.configFilePaths)
 	assert.Equal(t, "littlepiggies", inst.queryPath)
 	assert.Equal(t, 12, inst.timeout)
 }
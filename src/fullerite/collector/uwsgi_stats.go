@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+	"fullerite/util"
+
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// uWSGI stats collector
+// reads the stats servers of uWSGI on TCP or UNIX sockets
+// http://uwsgi-docs.readthedocs.io/en/latest/StatsServer.html
+// The sockets are either configured, UNIX socket paths can be globs, or
+// derived from the services found in the nerve configs with a template like
+// "/var/run/uwsgi/{service}.{namespace}.stats" or "127.0.0.1:1{port}".
+type uWSGIStatsCollector struct {
+	baseCollector
+
+	sockets           []string
+	configFilePaths   []string
+	nerveStatsSocket  string
+	servicesWhitelist []string
+	timeout           int
+}
+
+func init() {
+	RegisterCollector("UWSGIStats", newUWSGIStats)
+}
+
+// Default values of configuration fields
+func newUWSGIStats(channel chan metric.Metric, initialInterval int, log *l.Entry) Collector {
+	col := new(uWSGIStatsCollector)
+
+	col.log = log
+	col.channel = channel
+	col.interval = initialInterval
+
+	col.name = "UWSGIStats"
+	col.configFilePaths = []string{defaultNerveConfigPath}
+	col.timeout = 2
+
+	return col
+}
+
+// Rewrites config variables from the global config
+func (n *uWSGIStatsCollector) Configure(configMap map[string]interface{}) {
+	if val, exists := configMap["sockets"]; exists {
+		n.sockets = config.GetAsSlice(val)
+	}
+	if val, exists := configMap["configFilePath"]; exists {
+		n.configFilePaths = getNerveConfigPaths(val)
+	}
+	if val, exists := configMap["nerveStatsSocket"]; exists {
+		n.nerveStatsSocket = val.(string)
+	}
+	if val, exists := configMap["servicesWhitelist"]; exists {
+		n.servicesWhitelist = config.GetAsSlice(val)
+	}
+	if val, exists := configMap["timeout"]; exists {
+		n.timeout = config.GetAsInt(val, 2)
+	}
+
+	n.configureCommonParams(configMap)
+}
+
+// Collect reads each configured stats socket, then the stats socket of
+// each whitelisted nerve service
+func (n *uWSGIStatsCollector) Collect() {
+	for _, socket := range n.sockets {
+		for _, address := range expandUWSGIStatsSocket(socket) {
+			go n.querySocket(address, map[string]string{"stats_socket": address})
+		}
+	}
+
+	if n.nerveStatsSocket == "" || len(n.servicesWhitelist) == 0 {
+		return
+	}
+	services, err := util.ReadNerveConfigs(n.configFilePaths, true)
+	if err != nil {
+		n.log.Warn("Failed to read the nerve configs: ", err)
+	}
+	for _, service := range services {
+		if n.serviceInWhitelist(service) {
+			go n.querySocket(n.serviceStatsSocket(service), map[string]string{
+				"service":   service.Name,
+				"namespace": service.Namespace,
+				"port":      strconv.Itoa(service.Port),
+			})
+		}
+	}
+}
+
+// querySocket sends the metrics of the stats server at address with the
+// given dimensions
+func (n *uWSGIStatsCollector) querySocket(address string, dimensions map[string]string) {
+	socketLog := n.log.WithField("stats_socket", address)
+
+	raw, err := readUWSGIStatsSocket(address, time.Duration(n.timeout)*time.Second)
+	if err != nil {
+		socketLog.Warn("Failed to read the uWSGI stats: ", err)
+		return
+	}
+	metrics, err := util.ParseUWSGIStats(raw)
+	if err != nil {
+		socketLog.Warn("Failed to parse the uWSGI stats: ", err)
+		return
+	}
+
+	metric.AddToAll(&metrics, dimensions)
+	socketLog.Debug("Sending ", len(metrics), " to channel")
+	for _, m := range metrics {
+		n.Channel() <- m
+	}
+}
+
+// serviceStatsSocket fills the nerveStatsSocket template for the service
+func (n *uWSGIStatsCollector) serviceStatsSocket(service util.NerveService) string {
+	return strings.NewReplacer(
+		"{service}", service.Name,
+		"{namespace}", service.Namespace,
+		"{port}", strconv.Itoa(service.Port),
+	).Replace(n.nerveStatsSocket)
+}
+
+// serviceInWhitelist returns true if the service name passed as argument
+// is found among the ones whitelisted by the user
+func (n *uWSGIStatsCollector) serviceInWhitelist(service util.NerveService) bool {
+	for _, s := range n.servicesWhitelist {
+		if s == service.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// expandUWSGIStatsSocket returns the UNIX sockets matching a glob, other
+// addresses are returned as is
+func expandUWSGIStatsSocket(address string) []string {
+	network, path := uWSGIStatsNetwork(address)
+	if network != "unix" || !strings.ContainsAny(path, "*?[") {
+		return []string{address}
+	}
+	matches, _ := filepath.Glob(path)
+	return matches
+}
+
+// uWSGIStatsNetwork tells whether address is a UNIX socket, a path or
+// "unix:" followed by a path, or a TCP address, "tcp:" being optional
+func uWSGIStatsNetwork(address string) (string, string) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "/"):
+		return "unix", address
+	}
+	return "tcp", strings.TrimPrefix(address, "tcp:")
+}
+
+// readUWSGIStatsSocket reads the JSON the stats server writes before
+// closing the connection
+func readUWSGIStatsSocket(address string, timeout time.Duration) ([]byte, error) {
+	network, addr := uWSGIStatsNetwork(address)
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	return ioutil.ReadAll(conn)
+}
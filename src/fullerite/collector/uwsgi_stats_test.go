@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"fullerite/metric"
+	"fullerite/util"
+
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const uWSGIStatsForTest = `{
+	"listen_queue": 3,
+	"workers": [{"id": 1, "status": "idle", "requests": 10, "cores": [{"in_request": 0}]}]
+}`
+
+// serveUWSGIStats writes the stats to each connection like a uWSGI stats
+// server does
+func serveUWSGIStats(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(uWSGIStatsForTest))
+		conn.Close()
+	}
+}
+
+func TestUWSGIStatsConfigure(t *testing.T) {
+	inst := newUWSGIStats(nil, 10, defaultLog).(*uWSGIStatsCollector)
+	assert.Equal(t, []string{defaultNerveConfigPath}, inst.configFilePaths)
+	assert.Equal(t, 2, inst.timeout)
+
+	inst.Configure(map[string]interface{}{
+		"sockets":           []interface{}{"/run/uwsgi/*.stats", "127.0.0.1:1717"},
+		"nerveStatsSocket":  "/run/uwsgi/{service}.{namespace}.stats",
+		"servicesWhitelist": []interface{}{"example_service"},
+		"timeout":           "5",
+	})
+	assert.Equal(t, []string{"/run/uwsgi/*.stats", "127.0.0.1:1717"}, inst.sockets)
+	assert.Equal(t, []string{"example_service"}, inst.servicesWhitelist)
+	assert.Equal(t, 5, inst.timeout)
+	assert.Equal(t, "/run/uwsgi/example_service.main.stats",
+		inst.serviceStatsSocket(util.NerveService{Name: "example_service", Namespace: "main", Port: 13752}))
+}
+
+func TestUWSGIStatsNetwork(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"/run/uwsgi.stats", "unix", "/run/uwsgi.stats"},
+		{"unix:/run/uwsgi.stats", "unix", "/run/uwsgi.stats"},
+		{"127.0.0.1:1717", "tcp", "127.0.0.1:1717"},
+		{"tcp:127.0.0.1:1717", "tcp", "127.0.0.1:1717"},
+	}
+	for _, test := range tests {
+		network, addr := uWSGIStatsNetwork(test.address)
+		assert.Equal(t, test.network, network, test.address)
+		assert.Equal(t, test.addr, addr, test.address)
+	}
+}
+
+func TestUWSGIStatsCollectSockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uwsgi")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	unixListener, err := net.Listen("unix", filepath.Join(dir, "app.stats"))
+	assert.Nil(t, err)
+	defer unixListener.Close()
+	go serveUWSGIStats(unixListener)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer tcpListener.Close()
+	go serveUWSGIStats(tcpListener)
+
+	c := make(chan metric.Metric)
+	inst := newUWSGIStats(c, 10, defaultLog).(*uWSGIStatsCollector)
+	inst.Configure(map[string]interface{}{
+		"sockets": []interface{}{filepath.Join(dir, "*.stats"), tcpListener.Addr().String()},
+	})
+	go inst.Collect()
+
+	sockets := make(map[string]float64)
+	for len(sockets) < 2 {
+		m := <-c
+		if m.Name == "uwsgi.listen_queue" {
+			sockets[m.Dimensions["stats_socket"]] = m.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		filepath.Join(dir, "app.stats"): 3,
+		tcpListener.Addr().String():     3,
+	}, sockets)
+}
+
+func TestUWSGIStatsCollectNerve(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer tcpListener.Close()
+	go serveUWSGIStats(tcpListener)
+
+	tmpFile, err := ioutil.TempFile("", "fullerite_testing")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Write(getNerveConfigForTest())
+	tmpFile.Close()
+
+	c := make(chan metric.Metric)
+	inst := newUWSGIStats(c, 10, defaultLog).(*uWSGIStatsCollector)
+	inst.Configure(map[string]interface{}{
+		"configFilePath":    tmpFile.Name(),
+		"nerveStatsSocket":  tcpListener.Addr().String(),
+		"servicesWhitelist": []interface{}{"example_service"},
+	})
+	go inst.Collect()
+
+	for {
+		m := <-c
+		if m.Name == "uwsgi.listen_queue" {
+			assert.Equal(t, "example_service", m.Dimensions["service"])
+			assert.NotEmpty(t, m.Dimensions["namespace"])
+			assert.NotEmpty(t, m.Dimensions["port"])
+			break
+		}
+	}
+}
@@ -33,6 +33,7 @@ func (hook *LogErrorHook) Fire(entry *logrus.Entry) error {
 		return err
 	}
 
+	componentStatus.recordError(entry)
 	go hook.reportErrors(entry)
 	return nil
 }
@@ -11,7 +11,7 @@ import (
 	"time"
 )
 
-func startCollectors(c config.Config) (collectors []collector.Collector) {
+func startCollectors(c config.Config, stats *metric.StatsRegistry) (collectors []collector.Collector) {
 	log.Info("Starting collectors...")
 
 	for _, name := range c.Collectors {
@@ -21,7 +21,7 @@ func startCollectors(c config.Config) (collectors []collector.Collector) {
 			continue
 		}
 
-		collectorInst := startCollector(name, c, conf)
+		collectorInst := startCollector(name, c, conf, stats)
 		if collectorInst != nil {
 			collectors = append(collectors, collectorInst)
 		}
@@ -29,7 +29,7 @@ func startCollectors(c config.Config) (collectors []collector.Collector) {
 	return collectors
 }
 
-func startCollector(name string, globalConfig config.Config, instanceConfig map[string]interface{}) collector.Collector {
+func startCollector(name string, globalConfig config.Config, instanceConfig map[string]interface{}, stats *metric.StatsRegistry) collector.Collector {
 	log.Debug("Starting collector ", name)
 	collectorInst := collector.New(name)
 	if collectorInst == nil {
@@ -41,14 +41,20 @@ func startCollector(name string, globalConfig config.Config, instanceConfig map[
 
 	// apply the instance configs
 	collectorInst.Configure(instanceConfig)
+	componentStatus.addCollector(collectorInst, instanceConfig)
 
-	go runCollector(collectorInst)
+	go runCollector(collectorInst, stats)
 	return collectorInst
 }
 
-func runCollector(collector collector.Collector) {
+func runCollector(collector collector.Collector, stats *metric.StatsRegistry) {
 	log.Info("Running ", collector)
 
+	if delay := collector.InitialDelay(time.Now()); delay > 0 {
+		log.Debug("Delaying ", collector, " by ", delay)
+		time.Sleep(delay)
+	}
+
 	ticker := time.NewTicker(time.Duration(collector.Interval()) * time.Second)
 	collect := ticker.C
 
@@ -58,6 +64,8 @@ func runCollector(collector collector.Collector) {
 	for {
 		select {
 		case <-collect:
+			start := time.Now()
+			componentStatus.collectionStarted(collector.CanonicalName(), start)
 			if collector.CollectorType() == "listener" {
 				collector.Collect()
 			} else {
@@ -67,27 +75,21 @@ func runCollector(collector collector.Collector) {
 				collector.Collect()
 				countdownTimer.Stop()
 			}
+			componentStatus.collectionFinished(collector.CanonicalName(), time.Now())
+			stats.Collector(collector.CanonicalName()).ObserveCollection(time.Since(start))
 		}
 	}
 	ticker.Stop()
 }
 
-func readFromCollectors(collectors []collector.Collector,
-	handlers []handler.Handler,
-	collectorStatChans ...chan<- metric.CollectorEmission) {
+func readFromCollectors(collectors []collector.Collector, handlers []handler.Handler, stats *metric.StatsRegistry) {
 	for i := range collectors {
-		go readFromCollector(collectors[i], handlers, collectorStatChans...)
+		go readFromCollector(collectors[i], handlers, stats)
 	}
 }
 
-func readFromCollector(collector collector.Collector,
-	handlers []handler.Handler,
-	collectorStatChans ...chan<- metric.CollectorEmission) {
-	// In case of Diamond collectors, metric from multiple collectors are read
-	// from Single channel (owned by Go Diamond Collector) and hence we use a map
-	// for keeping track of metrics from each individual collector
-	emissionCounter := map[string]uint64{}
-	lastEmission := time.Now()
+func readFromCollector(collector collector.Collector, handlers []handler.Handler, stats *metric.StatsRegistry) {
+	lastLimitedEmission := time.Now()
 	statDuration := time.Duration(collector.Interval()) * time.Second
 	for m := range collector.Channel() {
 		var exists bool
@@ -103,44 +105,71 @@ func readFromCollector(collector collector.Collector,
 			c = val
 			m.RemoveDimension("collectorCanonicalName")
 		}
-		// check if the metric is blacklisted, if so skip it and
-		// process the next one
-		if stringInSlice(m.Name, collector.Blacklist()) {
-			continue
-		}
-		emissionCounter[c]++
-		// collectorStatChans is an optional parameter. In case of ad-hoc collector
-		// this parameter is not supplied at all. Using variadic arguments is pretty much
-		// only way of doing this in go.
-		if len(collectorStatChans) > 0 {
-			collectorStatChan := collectorStatChans[0]
-			currentTime := time.Now()
-			if currentTime.After(lastEmission.Add(statDuration)) {
-				emitCollectorStats(emissionCounter, collectorStatChan)
-				lastEmission = time.Now()
+		if time.Now().After(lastLimitedEmission.Add(statDuration)) {
+			for _, limited := range collector.CardinalityLimitedMetrics() {
+				name, _ := limited.GetDimensionValue("collector")
+				writeToCollectorEndpoints(handlers, name, limited)
 			}
+			lastLimitedEmission = time.Now()
 		}
-
-		if len(collector.Prefix()) > 0 {
-			m.Name = collector.Prefix() + m.Name
+		admitted, whole := admitMetric(collector, c, m, stats)
+		if whole {
+			admitted = []metric.Metric{m}
 		}
 
-		for i := range handlers {
-			if _, exists := handlers[i].CollectorEndpoints()[c]; exists {
-				handlers[i].CollectorEndpoints()[c].Channel <- m
+		for _, series := range admitted {
+			if len(collector.Prefix()) > 0 {
+				series.Name = collector.Prefix() + series.Name
 			}
+
+			metric.DefaultTap.Publish(metric.TapEvent{
+				Stage:     metric.TapCollected,
+				Collector: c,
+				Metric:    series,
+			})
+
+			writeToCollectorEndpoints(handlers, c, series)
 		}
 	}
-	// Closing the stat channel after collector loop finishes
-	for _, statChannel := range collectorStatChans {
-		close(statChannel)
+}
+
+// admitMetric checks the series a metric is flattened into, one unless it
+// is a distribution, against the blacklist and the cardinality limits of
+// the collector. It returns the series admitted, and whether the metric
+// can be forwarded whole because all of them were admitted unchanged.
+func admitMetric(collector collector.Collector, c string, m metric.Metric, stats *metric.StatsRegistry) ([]metric.Metric, bool) {
+	flattened := m.Flatten()
+	admitted := make([]metric.Metric, 0, len(flattened))
+	stripped := false
+	for _, series := range flattened {
+		// check if the series is blacklisted, if so skip it and
+		// process the next one
+		if stringInSlice(series.Name, collector.Blacklist()) {
+			stats.Collector(c).AddBlacklisted(1)
+			continue
+		}
+		// new series beyond the configured cardinality limits are dropped
+		// or stripped
+		dimensions := len(series.Dimensions)
+		if !collector.LimitCardinality(c, &series) {
+			stats.Collector(c).AddLimited(1)
+			continue
+		}
+		stripped = stripped || len(series.Dimensions) != dimensions
+		admitted = append(admitted, series)
 	}
+	// In case of Diamond collectors, metric from multiple collectors are read
+	// from Single channel (owned by Go Diamond Collector) and hence the
+	// stats are kept per canonical collector name
+	stats.Collector(c).AddDatapoints(uint64(len(admitted)))
+	return admitted, !stripped && len(admitted) == len(flattened)
 }
 
-func emitCollectorStats(data map[string]uint64,
-	collectorStatChan chan<- metric.CollectorEmission) {
-	for collectorName, count := range data {
-		collectorStatChan <- metric.CollectorEmission{collectorName, count}
+func writeToCollectorEndpoints(handlers []handler.Handler, collectorName string, m metric.Metric) {
+	for i := range handlers {
+		if _, exists := handlers[i].CollectorEndpoints()[collectorName]; exists {
+			handlers[i].CollectorEndpoints()[collectorName].Channel <- m
+		}
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"fullerite/config"
 	"fullerite/handler"
 	"fullerite/metric"
+	"fullerite/test_utils"
 	"sync"
 
 	"io/ioutil"
@@ -62,7 +63,7 @@ func TestMain(m *testing.M) {
 
 func TestStartCollectorsEmptyConfig(t *testing.T) {
 	logrus.SetLevel(logrus.ErrorLevel)
-	collectors := startCollectors(config.Config{})
+	collectors := startCollectors(config.Config{}, metric.NewStatsRegistry())
 
 	assert.NotEqual(t, len(collectors), 1, "should create a Collector")
 }
@@ -70,7 +71,7 @@ func TestStartCollectorsEmptyConfig(t *testing.T) {
 func TestStartCollectorUnknownCollector(t *testing.T) {
 	logrus.SetLevel(logrus.ErrorLevel)
 	c := make(map[string]interface{})
-	collector := startCollector("unknown collector", config.Config{}, c)
+	collector := startCollector("unknown collector", config.Config{}, c, metric.NewStatsRegistry())
 
 	assert.Nil(t, collector, "should NOT create a Collector")
 }
@@ -78,7 +79,7 @@ func TestStartCollectorUnknownCollector(t *testing.T) {
 func TestStartCollectorsMixedConfig(t *testing.T) {
 	logrus.SetLevel(logrus.ErrorLevel)
 	conf, _ := config.ReadConfig(tmpTestFakeFile)
-	collectors := startCollectors(conf)
+	collectors := startCollectors(conf, metric.NewStatsRegistry())
 
 	for _, c := range collectors {
 		assert.Equal(t, c.Name(), "Test", "Only create valid collectors")
@@ -89,7 +90,7 @@ func TestStartCollectorTooLong(t *testing.T) {
 	logrus.SetLevel(logrus.ErrorLevel)
 	c := make(map[string]interface{})
 	c["interval"] = 1
-	collector := startCollector("Test", config.Config{}, c)
+	collector := startCollector("Test", config.Config{}, c, metric.NewStatsRegistry())
 
 	select {
 	case m := <-collector.Channel():
@@ -111,33 +112,29 @@ func TestReadFromCollector(t *testing.T) {
 	collector.SetInterval(1)
 	collector.Configure(c)
 
+	stats := metric.NewStatsRegistry()
+
 	var wg sync.WaitGroup
-	wg.Add(2)
-	collectorStatChannel := make(chan metric.CollectorEmission)
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		collector.Channel() <- metric.New("hello")
-		time.Sleep(time.Duration(2) * time.Second)
 		m2 := metric.New("world")
 		m2.AddDimension("collectorCanonicalName", "Foobar")
 		collector.Channel() <- m2
-		time.Sleep(time.Duration(2) * time.Second)
 		m3 := metric.New("world")
 		m3.AddDimension("collectorCanonicalName", "Foobar")
 		collector.Channel() <- m3
 		close(collector.Channel())
 	}()
-	collectorMetrics := map[string]uint64{}
-	go func() {
-		defer wg.Done()
-		for collectorMetric := range collectorStatChannel {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
-	}()
-	readFromCollector(collector, []handler.Handler{}, collectorStatChannel)
+	readFromCollector(collector, []handler.Handler{}, stats)
 	wg.Wait()
-	assert.Equal(t, uint64(1), collectorMetrics["Test"])
-	assert.Equal(t, uint64(2), collectorMetrics["Foobar"])
+	assert.Equal(t, 1.0, collectorCounter(stats, "Test", "fullerite.collector_datapoints"))
+	assert.Equal(t, 2.0, collectorCounter(stats, "Foobar", "fullerite.collector_datapoints"))
+}
+
+func collectorCounter(stats *metric.StatsRegistry, name, counter string) float64 {
+	return stats.Collector(name).InternalMetrics().Counters[counter]
 }
 
 func TestCollectorPrefix(t *testing.T) {
@@ -168,7 +165,7 @@ func TestCollectorPrefix(t *testing.T) {
 		testMetric := <-collectorChannel["Test"].Channel
 		assert.Equal(t, "px.hello", testMetric.Name)
 	}()
-	readFromCollector(collector, []handler.Handler{testHandler})
+	readFromCollector(collector, []handler.Handler{testHandler}, metric.NewStatsRegistry())
 	wg.Wait()
 }
 
@@ -182,28 +179,106 @@ func TestCollectorBlacklist(t *testing.T) {
 	col.SetInterval(1)
 	col.Configure(c)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	collectorStatChannel := make(chan metric.CollectorEmission)
+	stats := metric.NewStatsRegistry()
 
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		col.Channel() <- metric.New("m1")
-		time.Sleep(time.Duration(2) * time.Second)
 		col.Channel() <- metric.New("m2")
-		time.Sleep(time.Duration(2) * time.Second)
 		col.Channel() <- metric.New("metric3")
 		close(col.Channel())
 	}()
-	collectorMetrics := map[string]uint64{}
+	readFromCollector(col, []handler.Handler{}, stats)
+	wg.Wait()
+
+	assert.Equal(t, 1.0, collectorCounter(stats, "Test", "fullerite.collector_datapoints"))
+	assert.Equal(t, 2.0, collectorCounter(stats, "Test", "fullerite.collector_blacklisted"))
+}
+
+func TestCollectorBlacklistDistribution(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	c := make(map[string]interface{})
+	c["interval"] = 1
+	c["metrics_blacklist"] = []string{"latency_bucket$"}
+	col := collector.New("Test")
+	col.SetInterval(1)
+	col.Configure(c)
+
+	collectorChannel := map[string]handler.CollectorEnd{
+		"Test": handler.CollectorEnd{make(chan metric.Metric, 10), 1},
+	}
+	testHandler := handler.New("Log")
+	testHandler.SetCollectorEndpoints(collectorChannel)
+	stats := metric.NewStatsRegistry()
+
+	distribution := metric.Distribution{
+		Count:   3,
+		Sum:     6,
+		Buckets: []metric.DistributionBucket{{UpperBound: 1, Count: 1}},
+	}
+	go func() {
+		col.Channel() <- metric.WithDistribution("latency", distribution)
+		col.Channel() <- metric.WithDistribution("size", distribution)
+		close(col.Channel())
+	}()
+	readFromCollector(col, []handler.Handler{testHandler}, stats)
+	close(collectorChannel["Test"].Channel)
+
+	names := []string{}
+	for m := range collectorChannel["Test"].Channel {
+		names = append(names, m.Name)
+		assert.Equal(t, m.Name == "size", m.Distribution != nil)
+	}
+	// the buckets of latency are blacklisted, size is forwarded whole
+	assert.Equal(t, []string{"latency", "latency_count", "size"}, names)
+	assert.Equal(t, 2.0, collectorCounter(stats, "Test", "fullerite.collector_blacklisted"))
+	assert.Equal(t, 6.0, collectorCounter(stats, "Test", "fullerite.collector_datapoints"))
+}
+
+func TestCollectorCardinalityLimit(t *testing.T) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	c := make(map[string]interface{})
+	c["interval"] = 1
+	c["max_series_per_metric"] = 1
+	col := collector.New("Test")
+	col.SetInterval(1)
+	col.Configure(c)
+
+	stats := metric.NewStatsRegistry()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for collectorMetric := range collectorStatChannel {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
+		m1 := metric.New("hello")
+		m1.AddDimension("id", "1")
+		col.Channel() <- m1
+		m2 := metric.New("hello")
+		m2.AddDimension("id", "2")
+		col.Channel() <- m2
+		col.Channel() <- metric.New("world")
+		close(col.Channel())
 	}()
-	readFromCollector(col, []handler.Handler{}, collectorStatChannel)
+	readFromCollector(col, []handler.Handler{}, stats)
 	wg.Wait()
 
-	assert.Equal(t, uint64(1), collectorMetrics["Test"])
+	assert.Equal(t, 2.0, collectorCounter(stats, "Test", "fullerite.collector_datapoints"))
+	assert.Equal(t, 1.0, collectorCounter(stats, "Test", "fullerite.collector_limited"))
+	assert.Equal(t, uint64(1), col.CardinalityReport(10)["Test"].Limited)
+}
+
+func TestCollectorStatFunc(t *testing.T) {
+	stats := metric.NewStatsRegistry()
+	stats.Collector("Test").AddDatapoints(3)
+
+	h := handler.NewTest(make(chan metric.Metric), 10, 10, time.Second, test_utils.BuildLogger())
+	statFunc := collectorStatFunc(stats, []handler.Handler{h})
+
+	collectorStats := statFunc()
+	assert.Equal(t, 3.0, collectorStats["Test"].Counters["fullerite.collector_datapoints"])
+	assert.Equal(t, 0.0, collectorStats["Test"].Counters["fullerite.collector_dropped"])
 }
@@ -28,6 +28,9 @@ type Parser interface {
 	parseMapOfMap(map[string]map[string]interface{}, string) []metric.Metric
 	// is Cumulative Counter enabled for this metric
 	isCCEnabled() bool
+	setOptions(Options)
+	// group the rollups of timers and histograms into distributions
+	toDistributions([]metric.Metric) []metric.Metric
 }
 
 // Format defines format in which dropwizard metrics are emitted
@@ -75,19 +78,15 @@ type BaseParser struct {
 	log       *l.Entry
 	ccEnabled bool // Enable cumulative counters
 	schemaVer string
+	options   Options
 }
 
-// Parse can be called from collector code to parse results
+// Parse can be called from collector code to parse results. Dropwizard 4
+// JSON has the same layout as java-1.1, Micrometer meters and the Spring
+// Boot Actuator metrics API use the micrometer and actuator schemas and
+// Jolokia read responses the jolokia one.
 func Parse(raw []byte, schemaVer string, ccEnabled bool) ([]metric.Metric, error) {
-	var parser Parser
-	if schemaVer == "uwsgi.1.0" || schemaVer == "uwsgi.1.1" {
-		parser = NewUWSGIMetric(raw, schemaVer, ccEnabled)
-	} else if schemaVer == "java-1.1" {
-		parser = NewJavaMetric(raw, schemaVer, ccEnabled)
-	} else {
-		parser = NewLegacyMetric(raw, schemaVer, ccEnabled)
-	}
-	return parser.Parse()
+	return ParseWithOptions(raw, schemaVer, ccEnabled, Options{})
 }
 
 // metricFromMap takes in flattened maps formatted like this::
@@ -128,11 +127,12 @@ func (parser *BaseParser) metricFromMap(metricMap map[string]interface{},
 			continue
 		}
 		if parser.ccEnabled && rollup != "value" {
-			mName = metricName + "." + rollup
+			mName = parser.rollupName(metricName, rollup)
 			if rollup == "count" {
 				mType = metric.CumulativeCounter
 			}
 		}
+		value = parser.convertUnits(rollup, value, metricMap)
 		tempMetric, ok := parser.createMetricFromDatam(rollup, value, mName, mType)
 		if ok {
 			results = append(results, tempMetric)
@@ -143,6 +143,32 @@ func (parser *BaseParser) metricFromMap(metricMap map[string]interface{},
 	return results
 }
 
+// rollupMetric returns the metric of one rollup of a metric the way
+// metricFromMap does: when cumulative counters are enabled the rollup is
+// appended to the name, unless the rollup is only a dimension, and
+// cumulative rollups become cumulative counters, otherwise the metric keeps
+// the name and the type given. Rates are dropped when cumulative counters
+// are enabled, ok is false then.
+func (parser *BaseParser) rollupMetric(name string, rollup string, value float64, metricType string, cumulative bool) (metric.Metric, bool) {
+	mName := name
+	mType := metricType
+	if parser.ccEnabled {
+		if matched, _ := regexp.MatchString("m[0-9]+_rate", rollup); matched {
+			return metric.Metric{}, false
+		}
+		mName = parser.rollupName(name, rollup)
+		if cumulative {
+			mType = metric.CumulativeCounter
+		}
+	}
+
+	m, ok := parser.createMetricFromDatam(rollup, value, mName, mType)
+	if ok && !parser.options.RollupDimension {
+		addNameDimension(&m, name)
+	}
+	return m, ok
+}
+
 func (parser *BaseParser) isCCEnabled() bool {
 	return parser.ccEnabled
 }
@@ -179,9 +205,9 @@ func extractParsedMetric(parser Parser, parsed *Format) []metric.Metric {
 
 	appendIt(parser.parseMapOfMap(parsed.Gauges, metric.Gauge), "gauge")
 	appendIt(parser.parseMapOfMap(parsed.Counters, metric.Counter), "counter")
-	appendIt(parser.parseMapOfMap(parsed.Histograms, metric.Gauge), "histogram")
+	appendIt(parser.toDistributions(parser.parseMapOfMap(parsed.Histograms, metric.Gauge)), "histogram")
 	appendIt(parser.parseMapOfMap(parsed.Meters, metric.Gauge), "meter")
-	appendIt(parser.parseMapOfMap(parsed.Timers, metric.Gauge), "timer")
+	appendIt(parser.toDistributions(parser.parseMapOfMap(parsed.Timers, metric.Gauge)), "timer")
 
 	return results
 }
@@ -0,0 +1,111 @@
+package dropwizard
+
+import (
+	"fullerite/metric"
+	"sort"
+	"strings"
+)
+
+// The rollups of timers and histograms which are quantiles
+var quantileRollups = map[string]float64{
+	"median": 0.5, "p50": 0.5, "p75": 0.75, "p95": 0.95, "p98": 0.98, "p99": 0.99, "p999": 0.999,
+}
+
+// rollupGroup is the rollups of one timer or histogram
+type rollupGroup struct {
+	name       string
+	dimensions map[string]string
+	rollups    []metric.Metric
+}
+
+// toDistributions replaces the rollups of each timer or histogram by a
+// single Histogram metric when Options.Distributions is set. Its
+// distribution has the quantiles and the count of the rollups, the sum
+// being the mean times the count, and keeps the rollups so that handlers
+// flattening distributions send the same series as without the option.
+// Metrics without quantile rollups are returned as is.
+func (parser *BaseParser) toDistributions(metrics []metric.Metric) []metric.Metric {
+	if !parser.options.Distributions {
+		return metrics
+	}
+
+	groups := map[string]*rollupGroup{}
+	keys := []string{}
+	for _, m := range metrics {
+		rollup := m.Dimensions["rollup"]
+		name := strings.TrimSuffix(m.Name, "."+rollup)
+		dimensions := map[string]string{}
+		for k, v := range m.Dimensions {
+			if k != "rollup" {
+				dimensions[k] = v
+			}
+		}
+
+		key := rollupGroupKey(name, dimensions)
+		group, exists := groups[key]
+		if !exists {
+			group = &rollupGroup{name: name, dimensions: dimensions}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.rollups = append(group.rollups, m)
+	}
+
+	results := []metric.Metric{}
+	for _, key := range keys {
+		group := groups[key]
+		if m, ok := group.distribution(); ok {
+			results = append(results, m)
+		} else {
+			results = append(results, group.rollups...)
+		}
+	}
+	return results
+}
+
+// distribution returns the Histogram metric of the rollups, ok is false
+// when none of them is a quantile
+func (group *rollupGroup) distribution() (metric.Metric, bool) {
+	d := metric.Distribution{}
+	seen := map[float64]bool{}
+	mean, hasMean := 0.0, false
+	for _, m := range group.rollups {
+		rollup := m.Dimensions["rollup"]
+		if quantile, exists := quantileRollups[rollup]; exists && !seen[quantile] {
+			seen[quantile] = true
+			d.Quantiles = append(d.Quantiles, metric.DistributionQuantile{Quantile: quantile, Value: m.Value})
+		}
+		switch rollup {
+		case "count":
+			d.Count = m.Value
+		case "mean":
+			mean, hasMean = m.Value, true
+		}
+		d.Rollups = append(d.Rollups, metric.DistributionRollup{
+			Suffix:     strings.TrimPrefix(m.Name, group.name),
+			MetricType: m.MetricType,
+			Value:      m.Value,
+			Dimensions: map[string]string{"rollup": rollup},
+		})
+	}
+	if len(d.Quantiles) == 0 {
+		return metric.Metric{}, false
+	}
+	sort.Slice(d.Quantiles, func(i, j int) bool { return d.Quantiles[i].Quantile < d.Quantiles[j].Quantile })
+	if hasMean {
+		d.Sum = mean * d.Count
+	}
+
+	m := metric.WithDistribution(group.name, d)
+	m.AddDimensions(group.dimensions)
+	return m, true
+}
+
+func rollupGroupKey(name string, dimensions map[string]string) string {
+	parts := make([]string, 0, len(dimensions))
+	for k, v := range dimensions {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return name + "|" + strings.Join(parts, ",")
+}
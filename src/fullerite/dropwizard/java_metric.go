@@ -62,15 +62,18 @@ func (parser *JavaMetric) parseMapOfMap(
 
 			if parser.ccEnabled && rollup != "value" {
 				// For legacy reasons we append the rollup to the metric name
-				mNameWithSuffix = mName + "." + rollup
+				mNameWithSuffix = parser.rollupName(mName, rollup)
 				if rollup == "count" {
 					mType = metric.CumulativeCounter
 				}
 			}
 
+			value = parser.convertUnits(rollup, value, metricData)
 			tmpMetric, ok := parser.createMetricFromDatam(rollup, value, mNameWithSuffix, mType)
 			if ok {
-				addNameDimension(&tmpMetric, mName)
+				if !parser.options.RollupDimension {
+					addNameDimension(&tmpMetric, mName)
+				}
 				addDimensionsFromName(&tmpMetric, values)
 				results = append(results, tmpMetric)
 			}
@@ -84,7 +87,7 @@ func (parser *JavaMetric) parseMapOfMap(
 // we need to have a common dimension to link them. Name isn't because we happened a suffix to it
 // It would be better to use only one metric name with many values for rollup dimensions
 // but that involve updating all our dashbords. Hence the gross hack of adding the metric name as
-// a dimension.
+// a dimension. Options.RollupDimension does that instead, without the hack.
 func addNameDimension(m *metric.Metric, mName string) {
 	m.AddDimension("java_metric", mName)
 }
@@ -0,0 +1,247 @@
+package dropwizard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"fullerite/metric"
+	"strings"
+)
+
+// JolokiaMetric is a parser for the responses of Jolokia read requests
+type JolokiaMetric struct {
+	BaseParser
+}
+
+// jolokiaResponse is the response to a read request, alone or in the list
+// answering a bulk request::
+//
+//	{
+//	    "request": {"type": "read", "mbean": "java.lang:type=Memory"},
+//	    "value": {"HeapMemoryUsage": {"used": ###, "max": ###}, ...},
+//	    "status": 200
+//	}
+//
+// When the mbean is a pattern the value maps each matching mbean to its
+// attributes. When a single attribute is read the value is the attribute.
+type jolokiaResponse struct {
+	Request struct {
+		MBean     string      `json:"mbean"`
+		Attribute interface{} `json:"attribute"`
+	} `json:"request"`
+	Value  interface{} `json:"value"`
+	Status int         `json:"status"`
+	Error  string      `json:"error"`
+}
+
+// The attributes of the metrics reported to JMX by Dropwizard (and Yammer)
+// and their rollups, the mbean name is the metric name
+var jolokiaDropwizardRollups = map[string]string{
+	"Count":             "count",
+	"Value":             "value",
+	"Mean":              "mean",
+	"Min":               "min",
+	"Max":               "max",
+	"StdDev":            "stddev",
+	"50thPercentile":    "p50",
+	"75thPercentile":    "p75",
+	"95thPercentile":    "p95",
+	"98thPercentile":    "p98",
+	"99thPercentile":    "p99",
+	"999thPercentile":   "p999",
+	"MeanRate":          "mean_rate",
+	"OneMinuteRate":     "m1_rate",
+	"FiveMinuteRate":    "m5_rate",
+	"FifteenMinuteRate": "m15_rate",
+}
+
+// The JVM attributes which only go up
+var jolokiaCumulativeAttributes = map[string]bool{
+	"CollectionCount":         true,
+	"CollectionTime":          true,
+	"TotalStartedThreadCount": true,
+	"TotalLoadedClassCount":   true,
+	"UnloadedClassCount":      true,
+	"TotalCompilationTime":    true,
+	"ProcessCpuTime":          true,
+}
+
+// NewJolokiaMetric new parser for jolokia metrics
+func NewJolokiaMetric(data []byte, schemaVer string, ccEnabled bool) *JolokiaMetric {
+	parser := new(JolokiaMetric)
+	parser.data = data
+	parser.schemaVer = schemaVer
+	parser.ccEnabled = ccEnabled
+	return parser
+}
+
+// Parse returns a metric for each numeric attribute of the mbeans read.
+// The metric is named after the domain, the type and the attribute of the
+// mbean, the other keys of the mbean name are dimensions. Attributes of
+// Dropwizard metrics are rollups of the metric named after the mbean name
+// key instead, converted from their DurationUnit and RateUnit attributes
+// like other Dropwizard metrics. Failed reads are skipped.
+func (parser *JolokiaMetric) Parse() ([]metric.Metric, error) {
+	var responses []jolokiaResponse
+	data := bytes.TrimSpace(parser.data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &responses); err != nil {
+			return []metric.Metric{}, err
+		}
+	} else {
+		var response jolokiaResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return []metric.Metric{}, err
+		}
+		responses = append(responses, response)
+	}
+
+	results := []metric.Metric{}
+	for _, response := range responses {
+		if response.Status != 200 {
+			defaultLog.Warn("Failed Jolokia read of ", response.Request.MBean, ": ", response.Error)
+			continue
+		}
+
+		// a single attribute read returns its value directly
+		value := response.Value
+		if attribute, ok := response.Request.Attribute.(string); ok {
+			value = map[string]interface{}{attribute: value}
+		}
+
+		if strings.ContainsAny(response.Request.MBean, "*?") {
+			mbeans, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for mbean, attributes := range mbeans {
+				if attributes, ok := attributes.(map[string]interface{}); ok {
+					results = append(results, parser.mbeanMetrics(mbean, attributes)...)
+				}
+			}
+		} else if attributes, ok := value.(map[string]interface{}); ok {
+			results = append(results, parser.mbeanMetrics(response.Request.MBean, attributes)...)
+		}
+	}
+	return results, nil
+}
+
+// mbeanMetrics returns the metrics of the attributes of an mbean
+func (parser *JolokiaMetric) mbeanMetrics(mbean string, attributes map[string]interface{}) []metric.Metric {
+	domain, properties, err := parseMBeanName(mbean)
+	if err != nil {
+		defaultLog.Warn(err)
+		return nil
+	}
+
+	base := domain
+	if mbeanType, exists := properties["type"]; exists {
+		base += "." + mbeanType
+		delete(properties, "type")
+	}
+
+	results := []metric.Metric{}
+	add := func(m metric.Metric, dimensions map[string]string) {
+		m.AddDimensions(dimensions)
+		if !parser.ccEnabled {
+			m.AddDimension("type", "jmx")
+		}
+		results = append(results, m)
+	}
+
+	dropwizardName, isDropwizard := properties["name"]
+	dropwizardUnits := map[string]interface{}{
+		"duration_units": attributes["DurationUnit"],
+		"rate_units":     attributes["RateUnit"],
+	}
+	dropwizardDimensions := map[string]string{}
+	for key, value := range properties {
+		if key != "name" {
+			dropwizardDimensions[key] = value
+		}
+	}
+
+	for attribute, value := range attributes {
+		if rollup, exists := jolokiaDropwizardRollups[attribute]; exists && isDropwizard {
+			v, ok := parser.convertUnits(rollup, value, dropwizardUnits).(float64)
+			if !ok {
+				continue
+			}
+			if m, ok := parser.rollupMetric(base+"."+dropwizardName, rollup, v, metric.Gauge, rollup == "count"); ok {
+				add(m, dropwizardDimensions)
+			}
+			continue
+		}
+
+		for name, v := range flattenJolokiaValue(attribute, value) {
+			metricType := metric.Gauge
+			if parser.ccEnabled && jolokiaCumulativeAttributes[attribute] {
+				metricType = metric.CumulativeCounter
+			}
+			if m, ok := parser.createMetricFromDatam("value", v, base+"."+name, metricType); ok {
+				add(m, properties)
+			}
+		}
+	}
+	return results
+}
+
+// flattenJolokiaValue returns the numeric values of an attribute, composite
+// values like HeapMemoryUsage give one value per key
+func flattenJolokiaValue(name string, value interface{}) map[string]float64 {
+	values := map[string]float64{}
+	switch v := value.(type) {
+	case float64:
+		values[name] = v
+	case bool:
+		if v {
+			values[name] = 1
+		} else {
+			values[name] = 0
+		}
+	case map[string]interface{}:
+		for key, nested := range v {
+			for nestedName, nestedValue := range flattenJolokiaValue(name+"."+key, nested) {
+				values[nestedName] = nestedValue
+			}
+		}
+	}
+	return values
+}
+
+// parseMBeanName splits an mbean name like java.lang:type=Memory into its
+// domain and its key properties. Quoted values are unquoted.
+func parseMBeanName(mbean string) (string, map[string]string, error) {
+	i := strings.Index(mbean, ":")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("Invalid mbean name %s", mbean)
+	}
+
+	properties := map[string]string{}
+	for _, property := range splitMBeanProperties(mbean[i+1:]) {
+		kv := strings.SplitN(property, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("Invalid mbean name %s", mbean)
+		}
+		properties[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+	return mbean[:i], properties, nil
+}
+
+// splitMBeanProperties splits the key properties on the commas outside of
+// quoted values
+func splitMBeanProperties(list string) []string {
+	properties := []string{}
+	quoted := false
+	start := 0
+	for i, c := range list {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			properties = append(properties, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(properties, list[start:])
+}
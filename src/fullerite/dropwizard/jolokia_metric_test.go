@@ -0,0 +1,99 @@
+package dropwizard
+
+import (
+	"fullerite/metric"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJolokiaMetricBulkRead(t *testing.T) {
+	var jsonBlob = []byte(`[
+		{
+			"request": {"type": "read", "mbean": "java.lang:type=Memory"},
+			"value": {"HeapMemoryUsage": {"used": 100, "max": 200}, "Verbose": false, "ObjectName": {"objectName": "java.lang:type=Memory"}},
+			"status": 200
+		},
+		{
+			"request": {"type": "read", "mbean": "java.lang:type=GarbageCollector,name=*"},
+			"value": {
+				"java.lang:name=G1 Young Generation,type=GarbageCollector": {"CollectionCount": 5, "CollectionTime": 30}
+			},
+			"status": 200
+		},
+		{
+			"request": {"type": "read", "mbean": "kafka.server:type=BrokerTopicMetrics,name=MessagesInPerSec,topic=foo"},
+			"value": {"Count": 1000, "OneMinuteRate": 3.5, "MeanRate": 2.5, "RateUnit": "SECONDS"},
+			"status": 200
+		},
+		{
+			"request": {"type": "read", "mbean": "java.lang:type=Threading", "attribute": "ThreadCount"},
+			"value": 12,
+			"status": 200
+		},
+		{
+			"request": {"type": "read", "mbean": "java.lang:type=Missing"},
+			"error": "javax.management.InstanceNotFoundException",
+			"status": 404
+		}
+	]`)
+
+	actual, err := Parse(jsonBlob, "jolokia", true)
+	assert.Nil(t, err)
+
+	metrics := make(map[string]metric.Metric)
+	for _, m := range actual {
+		metrics[m.Name] = m
+	}
+	assert.Equal(t, 8, len(metrics))
+
+	assert.Equal(t, 100.0, metrics["java.lang.Memory.HeapMemoryUsage.used"].Value)
+	assert.Equal(t, 200.0, metrics["java.lang.Memory.HeapMemoryUsage.max"].Value)
+	assert.Equal(t, 0.0, metrics["java.lang.Memory.Verbose"].Value)
+	assert.Equal(t, metric.Gauge, metrics["java.lang.Memory.HeapMemoryUsage.used"].MetricType)
+
+	gc := metrics["java.lang.GarbageCollector.CollectionCount"]
+	assert.Equal(t, 5.0, gc.Value)
+	assert.Equal(t, metric.CumulativeCounter, gc.MetricType)
+	assert.Equal(t, "G1 Young Generation", gc.Dimensions["name"])
+	assert.Equal(t, 30.0, metrics["java.lang.GarbageCollector.CollectionTime"].Value)
+
+	count := metrics["kafka.server.BrokerTopicMetrics.MessagesInPerSec.count"]
+	assert.Equal(t, 1000.0, count.Value)
+	assert.Equal(t, metric.CumulativeCounter, count.MetricType)
+	assert.Equal(t, "count", count.Dimensions["rollup"])
+	assert.Equal(t, "foo", count.Dimensions["topic"])
+	assert.Equal(t, "kafka.server.BrokerTopicMetrics.MessagesInPerSec", count.Dimensions["java_metric"])
+	assert.Equal(t, 2.5, metrics["kafka.server.BrokerTopicMetrics.MessagesInPerSec.mean_rate"].Value)
+	_, exists := metrics["kafka.server.BrokerTopicMetrics.MessagesInPerSec.m1_rate"]
+	assert.False(t, exists, "Rates should be dropped with cumulative counters")
+
+	assert.Equal(t, 12.0, metrics["java.lang.Threading.ThreadCount"].Value)
+}
+
+func TestJolokiaMetricSingleRead(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"request": {"type": "read", "mbean": "kafka.server:type=BrokerTopicMetrics,name=BytesInPerSec"},
+		"value": {"Count": 10, "OneMinuteRate": 3.5},
+		"status": 200
+	}`)
+
+	actual, err := Parse(jsonBlob, "jolokia", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(actual))
+	for _, m := range actual {
+		assert.Equal(t, "kafka.server.BrokerTopicMetrics.BytesInPerSec", m.Name)
+		assert.Equal(t, metric.Gauge, m.MetricType)
+		assert.Equal(t, "jmx", m.Dimensions["type"])
+	}
+}
+
+func TestParseMBeanName(t *testing.T) {
+	domain, properties, err := parseMBeanName(`org.apache.cassandra.metrics:type=Table,keyspace=ks,scope="a,b",name=ReadLatency`)
+	assert.Nil(t, err)
+	assert.Equal(t, "org.apache.cassandra.metrics", domain)
+	assert.Equal(t, map[string]string{"type": "Table", "keyspace": "ks", "scope": "a,b", "name": "ReadLatency"}, properties)
+
+	_, _, err = parseMBeanName("nodomain")
+	assert.NotNil(t, err)
+}
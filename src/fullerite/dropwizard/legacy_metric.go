@@ -30,6 +30,7 @@ func NewLegacyMetric(data []byte, schemaVer string, ccEnabled bool) *LegacyMetri
 // missing from metrics
 func (parser *LegacyMetric) Parse() ([]metric.Metric, error) {
 	uwsgiMetric := NewUWSGIMetric(parser.data, parser.schemaVer, parser.ccEnabled)
+	uwsgiMetric.setOptions(parser.options)
 
 	results, err := uwsgiMetric.Parse()
 
@@ -247,7 +248,7 @@ func (parser *LegacyMetric) collectHistogram(jsonMap map[string]interface{},
 			}
 		}
 	}
-	return results
+	return parser.toDistributions(results)
 }
 
 // collectCounter returns metric list for data that looks like:
@@ -285,6 +286,8 @@ func (parser *LegacyMetric) collectRate(jsonMap map[string]interface{}, metricNa
 
 			if key == "count" {
 				metricType = MetricTypeCounter
+			} else {
+				value = convertUnit(value, unit.(string), parser.options.RateUnit, true)
 			}
 
 			compositeMetricName := strings.Join(metricName, ".")
@@ -321,6 +324,8 @@ func (parser *LegacyMetric) collectMeter(jsonMap map[string]interface{}, metricN
 			metricType := MetricTypeGauge
 			if key == "count" {
 				metricType = MetricTypeCounter
+			} else {
+				value = convertUnit(value, jsonMap["unit"].(string), parser.options.RateUnit, true)
 			}
 
 			compositeMetricName := strings.Join(metricName, ".")
@@ -0,0 +1,132 @@
+package dropwizard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"fullerite/metric"
+	"strings"
+)
+
+// MicrometerMetric is a parser for Micrometer meters and for the Spring
+// Boot Actuator metrics API
+type MicrometerMetric struct {
+	BaseParser
+}
+
+// micrometerMeter is a meter with its measurements, the format is::
+//
+//	{
+//	    "name": "http.server.requests",
+//	    "type": "TIMER",
+//	    "tags": {"uri": "/status", "method": "GET"},
+//	    "measurements": [
+//	        {"statistic": "COUNT", "value": ###},
+//	        {"statistic": "TOTAL_TIME", "value": ###},
+//	        {"statistic": "MAX", "value": ###}
+//	    ]
+//	}
+//
+// Tags can also be a list of {"key": "uri", "value": "/status"}. A response
+// of /actuator/metrics/{name} is a meter without type nor tags, its
+// availableTags are not dimensions since the measurements are summed over
+// all of them.
+type micrometerMeter struct {
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	Tags         json.RawMessage `json:"tags"`
+	Measurements []struct {
+		Statistic string  `json:"statistic"`
+		Value     float64 `json:"value"`
+	} `json:"measurements"`
+}
+
+// The statistics which only go up
+var micrometerCumulativeStatistics = map[string]bool{
+	"COUNT":      true,
+	"TOTAL":      true,
+	"TOTAL_TIME": true,
+}
+
+// NewMicrometerMetric new parser for micrometer metrics
+func NewMicrometerMetric(data []byte, schemaVer string, ccEnabled bool) *MicrometerMetric {
+	parser := new(MicrometerMetric)
+	parser.data = data
+	parser.schemaVer = schemaVer
+	parser.ccEnabled = ccEnabled
+	return parser
+}
+
+// Parse returns a metric for each measurement of each meter. The data is
+// a list of meters, an object with the list under "meters", or a single
+// meter.
+func (parser *MicrometerMetric) Parse() ([]metric.Metric, error) {
+	var meters []micrometerMeter
+	data := bytes.TrimSpace(parser.data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &meters); err != nil {
+			return []metric.Metric{}, err
+		}
+	} else {
+		var parsed struct {
+			micrometerMeter
+			Meters []micrometerMeter `json:"meters"`
+		}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return []metric.Metric{}, err
+		}
+		meters = parsed.Meters
+		if parsed.Name != "" {
+			meters = append(meters, parsed.micrometerMeter)
+		}
+	}
+
+	results := []metric.Metric{}
+	for _, meter := range meters {
+		tags, err := micrometerTags(meter.Tags)
+		if err != nil {
+			return []metric.Metric{}, fmt.Errorf("Invalid tags of %s: %s", meter.Name, err)
+		}
+
+		metricType := metric.Gauge
+		if meter.Type == "COUNTER" || meter.Type == "FUNCTION_COUNTER" {
+			metricType = metric.Counter
+		}
+		for _, measurement := range meter.Measurements {
+			rollup := strings.ToLower(measurement.Statistic)
+			m, ok := parser.rollupMetric(meter.Name, rollup, measurement.Value, metricType, micrometerCumulativeStatistics[measurement.Statistic])
+			if !ok {
+				continue
+			}
+			m.AddDimensions(tags)
+			if !parser.ccEnabled && meter.Type != "" {
+				m.AddDimension("type", strings.ToLower(meter.Type))
+			}
+			results = append(results, m)
+		}
+	}
+	return results, nil
+}
+
+// micrometerTags reads tags given as an object or as a list of key/value
+func micrometerTags(raw json.RawMessage) (map[string]string, error) {
+	tags := map[string]string{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return tags, nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var list []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		for _, tag := range list {
+			tags[tag.Key] = tag.Value
+		}
+		return tags, nil
+	}
+	err := json.Unmarshal(raw, &tags)
+	return tags, err
+}
@@ -0,0 +1,101 @@
+package dropwizard
+
+import (
+	"fullerite/metric"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMicrometerMetric(t *testing.T) {
+	var jsonBlob = []byte(`[
+		{
+			"name": "http.server.requests",
+			"type": "TIMER",
+			"tags": {"uri": "/status", "method": "GET"},
+			"measurements": [
+				{"statistic": "COUNT", "value": 10},
+				{"statistic": "TOTAL_TIME", "value": 1.5},
+				{"statistic": "MAX", "value": 0.3}
+			]
+		},
+		{
+			"name": "jvm.threads.live",
+			"type": "GAUGE",
+			"tags": [{"key": "area", "value": "heap"}],
+			"measurements": [{"statistic": "VALUE", "value": 42}]
+		}
+	]`)
+
+	actual, err := Parse(jsonBlob, "micrometer", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(actual))
+
+	for _, m := range actual {
+		switch m.Name {
+		case "http.server.requests.count":
+			assert.Equal(t, 10.0, m.Value)
+			assert.Equal(t, metric.CumulativeCounter, m.MetricType)
+			assert.Equal(t, "count", m.Dimensions["rollup"])
+			assert.Equal(t, "http.server.requests", m.Dimensions["java_metric"])
+			assert.Equal(t, "/status", m.Dimensions["uri"])
+			assert.Equal(t, "GET", m.Dimensions["method"])
+		case "http.server.requests.total_time":
+			assert.Equal(t, 1.5, m.Value)
+			assert.Equal(t, metric.CumulativeCounter, m.MetricType)
+		case "http.server.requests.max":
+			assert.Equal(t, 0.3, m.Value)
+			assert.Equal(t, metric.Gauge, m.MetricType)
+		case "jvm.threads.live":
+			assert.Equal(t, 42.0, m.Value)
+			assert.Equal(t, metric.Gauge, m.MetricType)
+			assert.Equal(t, "value", m.Dimensions["rollup"])
+			assert.Equal(t, "heap", m.Dimensions["area"])
+		default:
+			t.Fatalf("unknown metric name %s", m.Name)
+		}
+	}
+}
+
+func TestMicrometerMetricWithoutCumulativeCounters(t *testing.T) {
+	var jsonBlob = []byte(`{"meters": [{
+		"name": "cache.gets",
+		"type": "COUNTER",
+		"tags": {"result": "hit"},
+		"measurements": [{"statistic": "COUNT", "value": 7}]
+	}]}`)
+
+	actual, err := Parse(jsonBlob, "micrometer", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(actual))
+	assert.Equal(t, "cache.gets", actual[0].Name)
+	assert.Equal(t, metric.Counter, actual[0].MetricType)
+	assert.Equal(t, "counter", actual[0].Dimensions["type"])
+	assert.Equal(t, "hit", actual[0].Dimensions["result"])
+}
+
+func TestActuatorMetric(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"name": "jvm.memory.used",
+		"description": "The amount of used memory",
+		"baseUnit": "bytes",
+		"measurements": [{"statistic": "VALUE", "value": 1024}],
+		"availableTags": [{"tag": "area", "values": ["heap", "nonheap"]}]
+	}`)
+
+	actual, err := Parse(jsonBlob, "actuator", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(actual))
+	assert.Equal(t, "jvm.memory.used", actual[0].Name)
+	assert.Equal(t, 1024.0, actual[0].Value)
+	assert.Equal(t, metric.Gauge, actual[0].MetricType)
+	assert.Equal(t, 2, len(actual[0].Dimensions))
+}
+
+func TestMicrometerMetricInvalidJSON(t *testing.T) {
+	_, err := Parse([]byte(`[{"name": `), "micrometer", true)
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte(`[{"name": "a", "tags": 3, "measurements": []}]`), "micrometer", true)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,116 @@
+package dropwizard
+
+import (
+	"fullerite/metric"
+	"strings"
+)
+
+// Options are the opt-in behaviours of the parsers
+type Options struct {
+	// RollupDimension keeps the base name of a metric for all its
+	// rollups, the rollup dimension telling them apart, instead of
+	// appending the rollup to the name
+	RollupDimension bool
+	// DurationUnit converts the durations of timers, read in the unit
+	// given by duration_units, to this unit, like "seconds"
+	DurationUnit string
+	// RateUnit converts the rates of meters and timers, read in the unit
+	// given by rate_units, to events per this unit, like "second"
+	RateUnit string
+	// Distributions reports each timer and histogram as a Histogram
+	// metric holding its quantiles, count and sum instead of one metric
+	// per rollup, for the handlers sending distributions natively
+	Distributions bool
+}
+
+// The rollups of timers which are durations and rates
+var (
+	durationRollups = map[string]bool{
+		"min": true, "max": true, "mean": true, "median": true, "stddev": true, "std_dev": true,
+		"p50": true, "p75": true, "p95": true, "p98": true, "p99": true, "p999": true,
+	}
+	rateRollups = map[string]bool{
+		"mean_rate": true, "m1_rate": true, "m5_rate": true, "m15_rate": true,
+	}
+)
+
+// The length of the time units in seconds
+var unitSeconds = map[string]float64{
+	"nanosecond":  1e-9,
+	"microsecond": 1e-6,
+	"millisecond": 1e-3,
+	"second":      1,
+	"minute":      60,
+	"hour":        3600,
+	"day":         86400,
+}
+
+// ParseWithOptions parses like Parse does with the given options
+func ParseWithOptions(raw []byte, schemaVer string, ccEnabled bool, options Options) ([]metric.Metric, error) {
+	var parser Parser
+	if schemaVer == "uwsgi.1.0" || schemaVer == "uwsgi.1.1" {
+		parser = NewUWSGIMetric(raw, schemaVer, ccEnabled)
+	} else if schemaVer == "java-1.1" {
+		parser = NewJavaMetric(raw, schemaVer, ccEnabled)
+	} else if schemaVer == "micrometer" || schemaVer == "actuator" {
+		parser = NewMicrometerMetric(raw, schemaVer, ccEnabled)
+	} else if schemaVer == "jolokia" {
+		parser = NewJolokiaMetric(raw, schemaVer, ccEnabled)
+	} else {
+		parser = NewLegacyMetric(raw, schemaVer, ccEnabled)
+	}
+	parser.setOptions(options)
+	return parser.Parse()
+}
+
+func (parser *BaseParser) setOptions(options Options) {
+	parser.options = options
+}
+
+// rollupName returns the name of the metric of a rollup, the rollup is
+// appended for legacy reasons when cumulative counters are enabled
+func (parser *BaseParser) rollupName(name string, rollup string) string {
+	if !parser.ccEnabled || rollup == "value" || parser.options.RollupDimension {
+		return name
+	}
+	return name + "." + rollup
+}
+
+// convertUnits converts the value of a duration or a rate rollup to the
+// units of the options, the units of the value are read from the
+// duration_units and rate_units of the metric
+func (parser *BaseParser) convertUnits(rollup string, value interface{}, metricMap map[string]interface{}) interface{} {
+	if durationRollups[rollup] {
+		from, _ := metricMap["duration_units"].(string)
+		return convertUnit(value, from, parser.options.DurationUnit, false)
+	}
+	if rateRollups[rollup] {
+		from, _ := metricMap["rate_units"].(string)
+		return convertUnit(value, from, parser.options.RateUnit, true)
+	}
+	return value
+}
+
+// convertUnit converts a duration, or a rate per unit of time, between
+// units like "milliseconds" and "seconds". Rates can also be given in
+// units like "calls/second". The value is returned as is when a unit is
+// not known.
+func convertUnit(value interface{}, from string, to string, rate bool) interface{} {
+	v, ok := value.(float64)
+	fromSeconds, fromKnown := unitSeconds[normalizeUnit(from)]
+	toSeconds, toKnown := unitSeconds[normalizeUnit(to)]
+	if !ok || !fromKnown || !toKnown {
+		return value
+	}
+	if rate {
+		return v * toSeconds / fromSeconds
+	}
+	return v * fromSeconds / toSeconds
+}
+
+func normalizeUnit(unit string) string {
+	if i := strings.LastIndex(unit, "/"); i >= 0 {
+		unit = unit[i+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), "s")
+}
@@ -0,0 +1,228 @@
+package dropwizard
+
+import (
+	"fullerite/metric"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func metricsByRollup(metrics []metric.Metric) map[string]metric.Metric {
+	byRollup := make(map[string]metric.Metric)
+	for _, m := range metrics {
+		byRollup[m.Dimensions["rollup"]] = m
+	}
+	return byRollup
+}
+
+func TestParseWithRollupDimension(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"timers": {
+			"com.yelp.service.endpoint": {
+				"count": 10,
+				"p99": 250,
+				"mean_rate": 2,
+				"duration_units": "milliseconds",
+				"rate_units": "calls/minute"
+			}
+		}
+	}`)
+
+	options := Options{RollupDimension: true, DurationUnit: "seconds", RateUnit: "second"}
+	actual, err := ParseWithOptions(jsonBlob, "java-1.1", true, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(actual))
+
+	metrics := metricsByRollup(actual)
+	for _, m := range actual {
+		assert.Equal(t, "com.yelp.service.endpoint", m.Name)
+		assert.NotContains(t, m.Dimensions, "java_metric")
+	}
+	assert.Equal(t, 10.0, metrics["count"].Value)
+	assert.Equal(t, metric.CumulativeCounter, metrics["count"].MetricType)
+	assert.Equal(t, 0.25, metrics["p99"].Value)
+	assert.InDelta(t, 2.0/60, metrics["mean_rate"].Value, 1e-9)
+}
+
+func TestParseWithoutOptions(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"timers": {
+			"com.yelp.service.endpoint": {"p99": 250, "duration_units": "milliseconds"}
+		}
+	}`)
+
+	actual, err := ParseWithOptions(jsonBlob, "java-1.1", true, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(actual))
+	assert.Equal(t, "com.yelp.service.endpoint.p99", actual[0].Name)
+	assert.Equal(t, "com.yelp.service.endpoint", actual[0].Dimensions["java_metric"])
+	assert.Equal(t, 250.0, actual[0].Value)
+}
+
+func TestUWSGIMetricWithRollupDimension(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"format": 2,
+		"timers": [
+			{"name": "tests.my_timer", "count": 3, "p50": 1500000, "duration_units": "microseconds", "dimensions": {"test": "a"}}
+		]
+	}`)
+
+	options := Options{RollupDimension: true, DurationUnit: "milliseconds"}
+	actual, err := ParseWithOptions(jsonBlob, "uwsgi.1.1", true, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(actual))
+
+	metrics := metricsByRollup(actual)
+	assert.Equal(t, "tests.my_timer", metrics["count"].Name)
+	assert.Equal(t, "tests.my_timer", metrics["p50"].Name)
+	assert.Equal(t, 1500.0, metrics["p50"].Value)
+	assert.Equal(t, "a", metrics["p50"].Dimensions["test"])
+}
+
+func TestParseWithDistributions(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"timers": {
+			"com.yelp.service.endpoint": {"count": 10, "mean": 20, "p50": 15, "p99": 250, "mean_rate": 2}
+		},
+		"meters": {
+			"com.yelp.service.requests": {"count": 10, "mean_rate": 2}
+		}
+	}`)
+
+	actual, err := ParseWithOptions(jsonBlob, "java-1.1", true, Options{Distributions: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(actual))
+
+	var timer metric.Metric
+	for _, m := range actual {
+		if m.Distribution != nil {
+			timer = m
+		}
+	}
+	assert.Equal(t, "com.yelp.service.endpoint", timer.Name)
+	assert.Equal(t, metric.Histogram, timer.MetricType)
+	assert.Equal(t, map[string]string{"java_metric": "com.yelp.service.endpoint"}, timer.Dimensions)
+	assert.Equal(t, 10.0, timer.Distribution.Count)
+	assert.Equal(t, 200.0, timer.Distribution.Sum)
+	assert.Equal(t, []metric.DistributionQuantile{
+		{Quantile: 0.5, Value: 15},
+		{Quantile: 0.99, Value: 250},
+	}, timer.Distribution.Quantiles)
+
+	// flattened, the timer gives the rollups parsed without the option
+	expected, err := ParseWithOptions(jsonBlob, "java-1.1", true, Options{})
+	assert.Nil(t, err)
+	flattened := timer.Flatten()
+	assert.Equal(t, 5, len(flattened))
+	for _, m := range flattened {
+		assert.Contains(t, expected, m)
+	}
+}
+
+func TestUWSGIMetricWithDistributions(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"format": 2,
+		"timers": [
+			{"name": "tests.my_timer", "count": 3, "p50": 1.5, "dimensions": {"test": "a"}},
+			{"name": "tests.my_timer", "count": 4, "p50": 2.5, "dimensions": {"test": "b"}}
+		],
+		"gauges": [
+			{"name": "tests.my_gauge", "value": 1}
+		]
+	}`)
+
+	actual, err := ParseWithOptions(jsonBlob, "uwsgi.1.1", false, Options{Distributions: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(actual))
+
+	byTest := map[string]metric.Metric{}
+	for _, m := range actual {
+		if m.Distribution != nil {
+			byTest[m.Dimensions["test"]] = m
+		}
+	}
+	assert.Equal(t, 2, len(byTest))
+	assert.Equal(t, "tests.my_timer", byTest["a"].Name)
+	assert.Equal(t, "timer", byTest["a"].Dimensions["type"])
+	assert.Equal(t, 3.0, byTest["a"].Distribution.Count)
+	assert.Equal(t, []metric.DistributionQuantile{{Quantile: 0.5, Value: 2.5}}, byTest["b"].Distribution.Quantiles)
+
+	flattened := metricsByRollup(byTest["b"].Flatten())
+	assert.Equal(t, "tests.my_timer", flattened["p50"].Name)
+	assert.Equal(t, metric.Gauge, flattened["p50"].MetricType)
+	assert.Equal(t, map[string]string{"test": "b", "type": "timer", "rollup": "count"}, flattened["count"].Dimensions)
+}
+
+func TestLegacyMetricWithDistributions(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"jetty": {
+			"prefix-length": {"type": "histogram", "count": 2, "mean": 3, "median": 2, "p99": 4}
+		}
+	}`)
+
+	actual, err := ParseWithOptions(jsonBlob, "", false, Options{Distributions: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(actual))
+	assert.Equal(t, "jetty.prefix-length", actual[0].Name)
+	assert.Equal(t, 6.0, actual[0].Distribution.Sum)
+	assert.Equal(t, 2, len(actual[0].Distribution.Quantiles))
+	assert.Equal(t, 4, len(actual[0].Flatten()))
+	assert.Equal(t, MetricTypeCounter, metricsByRollup(actual[0].Flatten())["count"].MetricType)
+}
+
+func TestLegacyMetricRateUnit(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"jetty": {
+			"requests": {"count": 60, "m1": 120, "unit": "minutes", "event_type": "requests", "type": "meter"}
+		}
+	}`)
+
+	actual, err := ParseWithOptions(jsonBlob, "", false, Options{RateUnit: "second"})
+	assert.Nil(t, err)
+
+	metrics := metricsByRollup(actual)
+	assert.Equal(t, 60.0, metrics["count"].Value)
+	assert.Equal(t, 2.0, metrics["m1"].Value)
+}
+
+func TestJolokiaMetricUnits(t *testing.T) {
+	var jsonBlob = []byte(`{
+		"request": {"type": "read", "mbean": "metrics:type=timers,name=requests"},
+		"value": {"Count": 10, "99thPercentile": 250, "DurationUnit": "milliseconds", "MeanRate": 0.5, "RateUnit": "events/second"},
+		"status": 200
+	}`)
+
+	options := Options{RollupDimension: true, DurationUnit: "seconds", RateUnit: "minute"}
+	actual, err := ParseWithOptions(jsonBlob, "jolokia", true, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(actual))
+
+	metrics := metricsByRollup(actual)
+	assert.Equal(t, "metrics.timers.requests", metrics["p99"].Name)
+	assert.Equal(t, 0.25, metrics["p99"].Value)
+	assert.Equal(t, 30.0, metrics["mean_rate"].Value)
+	assert.Equal(t, 10.0, metrics["count"].Value)
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		from     string
+		to       string
+		rate     bool
+		expected interface{}
+	}{
+		{1500.0, "milliseconds", "seconds", false, 1.5},
+		{2.0, "minutes", "seconds", false, 120.0},
+		{3.0, "events/second", "minute", true, 180.0},
+		{60.0, "calls/minute", "seconds", true, 1.0},
+		{5.0, "SECONDS", "second", true, 5.0},
+		{5.0, "milliseconds", "", false, 5.0},
+		{5.0, "fortnights", "seconds", false, 5.0},
+		{"5", "milliseconds", "seconds", false, "5"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, convertUnit(test.value, test.from, test.to, test.rate), "%v %s to %s", test.value, test.from, test.to)
+	}
+}
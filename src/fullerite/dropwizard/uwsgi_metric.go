@@ -141,9 +141,9 @@ func extractNewUWSGIParsedMetric(parser *UWSGIMetric, parsed *newUWSGIFormat) []
 
 	appendIt(parser.parseArrOfMap(parsed.Gauges, metric.Gauge), "gauge")
 	appendIt(parser.parseArrOfMap(parsed.Counters, metric.Counter), "counter")
-	appendIt(parser.parseArrOfMap(parsed.Histograms, metric.Gauge), "histogram")
+	appendIt(parser.toDistributions(parser.parseArrOfMap(parsed.Histograms, metric.Gauge)), "histogram")
 	appendIt(parser.parseArrOfMap(parsed.Meters, metric.Gauge), "meter")
-	appendIt(parser.parseArrOfMap(parsed.Timers, metric.Gauge), "timer")
+	appendIt(parser.toDistributions(parser.parseArrOfMap(parsed.Timers, metric.Gauge)), "timer")
 
 	return results
 }
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"fullerite/config"
 	"fullerite/metric"
 
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -18,11 +23,29 @@ func init() {
 	RegisterHandler("Datadog", newDatadog)
 }
 
+// defaultMaxDistributionSamples caps the values sent for each distribution
+// point, the counts of the buckets are scaled down above it
+const defaultMaxDistributionSamples = 1000
+
 // Datadog handler
 type Datadog struct {
 	BaseHandler
 	endpoint string
 	apiKey   string
+
+	maxDistributionSamples int
+	// Datadog distributions are made of the values observed since the
+	// previous point, so the last distribution of each series is kept
+	// until the series is not received for an interval
+	distributionsMu   sync.Mutex
+	lastDistributions map[string]lastDistribution
+}
+
+// lastDistribution is the last distribution of a series and when it was
+// received
+type lastDistribution struct {
+	distribution metric.Distribution
+	updated      time.Time
 }
 
 type datadogPayload struct {
@@ -39,6 +62,20 @@ type datadogMetric struct {
 
 type datadogPoint [2]float64
 
+type distributionPayload struct {
+	Series []datadogDistribution `json:"series"`
+}
+
+type datadogDistribution struct {
+	Metric string                     `json:"metric"`
+	Points []datadogDistributionPoint `json:"points"`
+	Host   string                     `json:"host"`
+	Tags   []string                   `json:"tags"`
+}
+
+// datadogDistributionPoint is a timestamp and the values observed
+type datadogDistributionPoint [2]interface{}
+
 // newDatadog returns a new Datadog handler
 func newDatadog(
 	channel chan metric.Metric,
@@ -55,6 +92,16 @@ func newDatadog(
 	inst.timeout = initialTimeout
 	inst.log = log
 	inst.channel = channel
+	inst.maxDistributionSamples = defaultMaxDistributionSamples
+	inst.lastDistributions = make(map[string]lastDistribution)
+	inst.SendDistributionsNatively()
+	inst.tapFormatter = func(m metric.Metric) interface{} {
+		if m.Distribution != nil && len(m.Distribution.Buckets) > 0 {
+			return inst.convertToDatadogDistribution(m, nil)
+		}
+		return inst.convertToDatadog(m)
+	}
+
 	return inst
 }
 
@@ -70,14 +117,22 @@ func (d *Datadog) Configure(configMap map[string]interface{}) {
 	} else {
 		d.log.Error("There was no endpoint specified for the Datadog Handler, there won't be any emissions")
 	}
+	if maxSamples, exists := configMap["maxDistributionSamples"]; exists {
+		d.maxDistributionSamples = config.GetAsInt(maxSamples, defaultMaxDistributionSamples)
+	}
 	d.configureCommonParams(configMap)
 }
 
 // Endpoint returns the Datadog API endpoint
-func (d Datadog) Endpoint() string {
+func (d *Datadog) Endpoint() string {
 	return d.endpoint
 }
 
+// Targets returns the Datadog endpoint the metrics are sent to
+func (d *Datadog) Targets() []string {
+	return []string{d.endpoint}
+}
+
 // Run runs the handler main loop
 func (d *Datadog) Run() {
 	d.run(d.emitMetrics)
@@ -88,18 +143,34 @@ func (d *Datadog) convertToDatadog(incomingMetric metric.Metric) (datapoint data
 	dog.Metric = d.Prefix() + incomingMetric.Name
 	dog.Points = makeDatadogPoints(incomingMetric)
 	dog.MetricType = incomingMetric.MetricType
+	dog.Host = d.host(incomingMetric)
+	dog.Tags = d.serializedDimensions(incomingMetric)
+	return *dog
+}
+
+// convertToDatadogDistribution converts a Histogram metric with buckets to
+// a Datadog distribution made of the given samples
+func (d *Datadog) convertToDatadogDistribution(incomingMetric metric.Metric, samples []float64) datadogDistribution {
+	dist := datadogDistribution{
+		Metric: d.Prefix() + incomingMetric.Name,
+		Points: []datadogDistributionPoint{},
+		Host:   d.host(incomingMetric),
+		Tags:   d.serializedDimensions(incomingMetric),
+	}
+	if len(samples) > 0 {
+		dist.Points = append(dist.Points, datadogDistributionPoint{time.Now().Unix(), samples})
+	}
+	return dist
+}
 
+func (d *Datadog) host(incomingMetric metric.Metric) string {
 	// first check the defaults
 	if host, ok := d.DefaultDimensions()["host"]; ok {
-		dog.Host = host
+		return host
 	} else if host, ok := incomingMetric.GetDimensionValue("host"); ok {
-		dog.Host = host
-	} else {
-		dog.Host = "unknown"
+		return host
 	}
-
-	dog.Tags = d.serializedDimensions(incomingMetric)
-	return *dog
+	return "unknown"
 }
 
 func (d *Datadog) emitMetrics(metrics []metric.Metric) bool {
@@ -111,19 +182,46 @@ func (d *Datadog) emitMetrics(metrics []metric.Metric) bool {
 	}
 
 	series := make([]datadogMetric, 0, len(metrics))
+	distributions := []datadogDistribution{}
 	for _, m := range metrics {
-		series = append(series, d.convertToDatadog(m))
+		if m.Distribution == nil {
+			series = append(series, d.convertToDatadog(m))
+			continue
+		}
+		if len(m.Distribution.Buckets) == 0 {
+			// the quantiles of a summary cannot be turned into a distribution
+			for _, flat := range m.Flatten() {
+				series = append(series, d.convertToDatadog(flat))
+			}
+			continue
+		}
+		dist := d.convertToDatadogDistribution(m, nil)
+		if samples := d.distributionSamples(dist, *m.Distribution); len(samples) > 0 {
+			dist.Points = append(dist.Points, datadogDistributionPoint{time.Now().Unix(), samples})
+			distributions = append(distributions, dist)
+		}
 	}
+	d.pruneDistributions(time.Now())
 
-	p := datadogPayload{Series: series}
+	success := true
+	if len(series) > 0 {
+		success = d.post("series", datadogPayload{Series: series}, len(series))
+	}
+	if len(distributions) > 0 {
+		success = d.post("distribution_points", distributionPayload{Series: distributions}, len(distributions)) && success
+	}
+	return success
+}
+
+func (d *Datadog) post(api string, p interface{}, count int) bool {
 	payload, err := json.Marshal(p)
 	if err != nil {
 		d.log.Error("Failed marshaling datapoints to Datadog format")
-		d.log.Error("Dropping Datadog datapoints ", series)
+		d.log.Error("Dropping Datadog datapoints ", p)
 		return false
 	}
 
-	apiURL := fmt.Sprintf("%s/series?api_key=%s", d.endpoint, d.apiKey)
+	apiURL := fmt.Sprintf("%s/%s?api_key=%s", d.endpoint, api, d.apiKey)
 	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(payload))
 	if err != nil {
 		d.log.Error("Failed to create a request to endpoint ", d.endpoint)
@@ -142,10 +240,11 @@ func (d *Datadog) emitMetrics(metrics []metric.Metric) bool {
 		d.log.Error("Failed to complete POST ", err)
 		return false
 	}
+	d.addBytesSent(len(payload))
 
 	defer rsp.Body.Close()
 	if (rsp.StatusCode == http.StatusOK) || (rsp.StatusCode == http.StatusAccepted) {
-		d.log.Info("Successfully sent ", len(series), " datapoints to Datadog")
+		d.log.Info("Successfully sent ", count, " datapoints to Datadog")
 		return true
 	}
 
@@ -157,11 +256,80 @@ func (d *Datadog) emitMetrics(metrics []metric.Metric) bool {
 	return false
 }
 
-func (d Datadog) dialTimeout(network, addr string) (net.Conn, error) {
+// pruneDistributions forgets the series not received within the last
+// interval
+func (d *Datadog) pruneDistributions(now time.Time) {
+	expiry := time.Duration(d.Interval()) * time.Second
+	d.distributionsMu.Lock()
+	defer d.distributionsMu.Unlock()
+	for key, last := range d.lastDistributions {
+		if now.Sub(last.updated) > expiry {
+			delete(d.lastDistributions, key)
+		}
+	}
+}
+
+// distributionSamples returns values spread across the buckets like the
+// observations made since the last distribution of the same series. Each
+// value is the middle of its bucket, the values of the +Inf bucket are the
+// largest bound. Nothing is returned the first time a series is seen.
+func (d *Datadog) distributionSamples(dist datadogDistribution, current metric.Distribution) []float64 {
+	tags := append([]string{}, dist.Tags...)
+	sort.Strings(tags)
+	key := dist.Metric + "|" + dist.Host + "|" + strings.Join(tags, ",")
+
+	d.distributionsMu.Lock()
+	last, seen := d.lastDistributions[key]
+	d.lastDistributions[key] = lastDistribution{distribution: current, updated: time.Now()}
+	d.distributionsMu.Unlock()
+	if !seen {
+		return nil
+	}
+	previous := last.distribution
+	if current.Count < previous.Count || len(current.Buckets) != len(previous.Buckets) {
+		// the process restarted or the buckets changed
+		previous = metric.Distribution{Buckets: make([]metric.DistributionBucket, len(current.Buckets))}
+	}
+
+	total := current.Count - previous.Count
+	if total <= 0 {
+		return nil
+	}
+	scale := 1.0
+	if total > float64(d.maxDistributionSamples) {
+		scale = float64(d.maxDistributionSamples) / total
+	}
+
+	samples := []float64{}
+	lowerBound, lastCount := 0.0, 0.0
+	for i := 0; i <= len(current.Buckets); i++ {
+		var value, count float64
+		if i < len(current.Buckets) {
+			bucket := current.Buckets[i]
+			value = bucket.UpperBound
+			if i > 0 || bucket.UpperBound > 0 {
+				value = (lowerBound + bucket.UpperBound) / 2
+			}
+			lowerBound = bucket.UpperBound
+			count = bucket.Count - previous.Buckets[i].Count
+		} else {
+			value = lowerBound
+			count = total
+		}
+		n := int(math.Round((count - lastCount) * scale))
+		lastCount = count
+		for j := 0; j < n; j++ {
+			samples = append(samples, value)
+		}
+	}
+	return samples
+}
+
+func (d *Datadog) dialTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, d.timeout)
 }
 
-func (d Datadog) serializedDimensions(m metric.Metric) (dimensions []string) {
+func (d *Datadog) serializedDimensions(m metric.Metric) (dimensions []string) {
 	for name, value := range m.GetDimensions(d.DefaultDimensions()) {
 		dimensions = append(dimensions, name+":"+value)
 	}
@@ -3,6 +3,11 @@ package handler
 import (
 	"fullerite/metric"
 
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,3 +48,85 @@ func TestDatadogConfigure(t *testing.T) {
 	assert.Equal(t, 100, d.MaxBufferSize())
 	assert.Equal(t, "datadog.server", d.Endpoint())
 }
+
+func TestDatadogEmitDistributions(t *testing.T) {
+	var mu sync.Mutex
+	posted := map[string][]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		posted[r.URL.Path] = append(posted[r.URL.Path], string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	d := getTestDataDogHandler(12, 13, 14)
+	d.Configure(map[string]interface{}{
+		"apiKey":   "secret",
+		"endpoint": ts.URL + "/api/v1",
+	})
+
+	histogram := func(count, first, second float64) metric.Metric {
+		m := metric.WithDistribution("latency", metric.Distribution{
+			Count: count,
+			Buckets: []metric.DistributionBucket{
+				{UpperBound: 1, Count: first},
+				{UpperBound: 3, Count: second},
+			},
+		})
+		m.AddDimension("host", "web1")
+		return m
+	}
+	summary := metric.WithDistribution("size", metric.Distribution{
+		Count:     1,
+		Quantiles: []metric.DistributionQuantile{{Quantile: 0.5, Value: 2}},
+	})
+
+	// the first distribution is only remembered
+	assert.True(t, d.emitMetrics([]metric.Metric{histogram(2, 1, 2), summary}))
+	assert.Len(t, posted["/api/v1/distribution_points"], 0)
+	assert.Len(t, posted["/api/v1/series"], 1)
+
+	assert.True(t, d.emitMetrics([]metric.Metric{histogram(6, 2, 4)}))
+	assert.Len(t, posted["/api/v1/distribution_points"], 1)
+
+	var payload struct {
+		Series []struct {
+			Metric string
+			Host   string
+			Points [][2]interface{}
+		}
+	}
+	assert.Nil(t, json.Unmarshal([]byte(posted["/api/v1/distribution_points"][0]), &payload))
+	assert.Len(t, payload.Series, 1)
+	assert.Equal(t, "latency", payload.Series[0].Metric)
+	assert.Equal(t, "web1", payload.Series[0].Host)
+	assert.Equal(t, []interface{}{0.5, 2.0, 3.0, 3.0}, payload.Series[0].Points[0][1])
+}
+
+func TestDatadogDistributionSamplesLimit(t *testing.T) {
+	d := getTestDataDogHandler(12, 13, 14)
+	d.Configure(map[string]interface{}{"maxDistributionSamples": 10})
+
+	dist := datadogDistribution{Metric: "latency"}
+	d.distributionSamples(dist, metric.Distribution{})
+	samples := d.distributionSamples(dist, metric.Distribution{
+		Count:   1000,
+		Buckets: []metric.DistributionBucket{{UpperBound: 1, Count: 500}},
+	})
+	assert.Equal(t, []float64{0.5, 0.5, 0.5, 0.5, 0.5, 1, 1, 1, 1, 1}, samples)
+}
+
+func TestDatadogPruneDistributions(t *testing.T) {
+	d := getTestDataDogHandler(10, 13, 14)
+	d.distributionSamples(datadogDistribution{Metric: "latency"}, metric.Distribution{})
+	d.distributionSamples(datadogDistribution{Metric: "size"}, metric.Distribution{})
+	last := d.lastDistributions["size||"]
+	last.updated = time.Now().Add(-11 * time.Second)
+	d.lastDistributions["size||"] = last
+
+	d.pruneDistributions(time.Now())
+	assert.Len(t, d.lastDistributions, 1)
+	assert.Contains(t, d.lastDistributions, "latency||")
+}
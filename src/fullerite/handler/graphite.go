@@ -42,19 +42,26 @@ func newGraphite(
 	inst.log = log
 	inst.channel = channel
 
+	inst.tapFormatter = func(m metric.Metric) interface{} { return inst.convertToGraphite(m) }
+
 	return inst
 }
 
 // Server returns the Graphite server's name or IP
-func (g Graphite) Server() string {
+func (g *Graphite) Server() string {
 	return g.server
 }
 
 // Port returns the Graphite server's port number
-func (g Graphite) Port() string {
+func (g *Graphite) Port() string {
 	return g.port
 }
 
+// Targets returns the Graphite server the metrics are sent to
+func (g *Graphite) Targets() []string {
+	return []string{fmt.Sprintf("%s:%s", g.server, g.port)}
+}
+
 // Configure accepts the different configuration options for the Graphite handler
 func (g *Graphite) Configure(configMap map[string]interface{}) {
 	if server, exists := configMap["server"]; exists {
@@ -76,7 +83,7 @@ func (g *Graphite) Run() {
 	g.run(g.emitMetrics)
 }
 
-func (g Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint string) {
+func (g *Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint string) {
 	//orders dimensions so datapoint keeps consistent name
 	var keys []string
 	dimensions := g.getSanitizedDimensions(incomingMetric)
@@ -93,7 +100,7 @@ func (g Graphite) convertToGraphite(incomingMetric metric.Metric) (datapoint str
 	return datapoint
 }
 
-func (g Graphite) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
+func (g *Graphite) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
 	dimSanitized := make(map[string]string)
 	dimensions := incomingMetric.GetDimensions(g.DefaultDimensions())
 	for key, value := range dimensions {
@@ -118,7 +125,8 @@ func (g *Graphite) emitMetrics(metrics []metric.Metric) bool {
 	}
 
 	for _, m := range metrics {
-		fmt.Fprintf(conn, g.convertToGraphite(m))
+		n, _ := fmt.Fprintf(conn, g.convertToGraphite(m))
+		g.addBytesSent(n)
 	}
 	return true
 }
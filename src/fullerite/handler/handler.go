@@ -27,8 +27,6 @@ var defaultLog = l.WithFields(l.Fields{"app": "fullerite", "pkg": "handler"})
 
 var handlerConstructs map[string]func(chan metric.Metric, int, int, time.Duration, *l.Entry) Handler
 
-var mu sync.Mutex
-
 // RegisterHandler takes handler name and constructor function and returns handler
 func RegisterHandler(name string, f func(chan metric.Metric, int, int, time.Duration, *l.Entry) Handler) {
 	if handlerConstructs == nil {
@@ -114,6 +112,14 @@ type Handler interface {
 	// takes care of reporting emission metrics
 	OverrideBaseEmissionMetricsReporter()
 	UseCustomEmissionMetricsReporter() bool
+
+	// RunStatus returns when the last emission
+	// happened and whether it succeeded
+	RunStatus() metric.RunStatus
+
+	// DroppedPerCollector returns the number of metrics
+	// which failed to be sent per collector
+	DroppedPerCollector() map[string]uint64
 }
 
 type emissionTiming struct {
@@ -152,11 +158,22 @@ type BaseHandler struct {
 	// in the handler specific implementation
 	useCustomEmissionMetricsReporter bool
 
-	// for tracking
-	emissionTimes  list.List
-	totalEmissions uint64
-	metricsSent    uint64
-	metricsDropped uint64
+	// When set to true, Histogram metrics are buffered as is and the
+	// handler implementation sends their distribution. Otherwise they
+	// are flattened on arrival, see metric.Metric.Flatten
+	nativeDistributions bool
+
+	// for tracking, the counters are updated atomically and mu guards
+	// the rolling window of emissions
+	mu              sync.Mutex
+	emissionTimes   list.List
+	totalEmissions  uint64
+	metricsSent     uint64
+	metricsDropped  uint64
+	bytesSent       uint64
+	emissionLatency metric.LatencyHistogram
+	// metrics which failed to be sent, per canonical collector name
+	droppedPerCollector map[string]uint64
 
 	// List of blacklisted collectors
 	// the handler won't accept metrics from
@@ -165,6 +182,13 @@ type BaseHandler struct {
 	// List of whitelisted collectors
 	// the handler will accept metrics from
 	whiteListedCollectors map[string]bool
+
+	// Converts a metric to the representation sent by the handler,
+	// used to show tapped metrics
+	tapFormatter func(metric.Metric) interface{}
+
+	// Last emission as a metric.RunStatus, shown in the status page
+	runStatus atomic.Value
 }
 
 // SetMaxBufferSize : set the buffer size
@@ -219,6 +243,11 @@ func (base *BaseHandler) UseCustomEmissionMetricsReporter() bool {
 	return base.useCustomEmissionMetricsReporter
 }
 
+// SendDistributionsNatively : Do not flatten the Histogram metrics, the handler sends their distribution
+func (base *BaseHandler) SendDistributionsNatively() {
+	base.nativeDistributions = true
+}
+
 // Name : the name of the handler
 func (base *BaseHandler) Name() string {
 	return base.name
@@ -331,8 +360,8 @@ func (base *BaseHandler) InitListeners(globalConfig config.Config) {
 
 // GetEmissionTimesLen returns base.emissionTimes.Len thread-safe
 func (base *BaseHandler) GetEmissionTimesLen() int {
-	mu.Lock()
-	defer mu.Unlock()
+	base.mu.Lock()
+	defer base.mu.Unlock()
 	return base.emissionTimes.Len()
 }
 
@@ -363,12 +392,13 @@ func (base *BaseHandler) String() string {
 
 // InternalMetrics : Returns the internal metrics that are being collected by this handler
 func (base *BaseHandler) InternalMetrics() metric.InternalMetrics {
-	mu.Lock()
-	defer mu.Unlock()
+	base.mu.Lock()
+	defer base.mu.Unlock()
 	counters := map[string]float64{
-		"totalEmissions": float64(base.totalEmissions),
-		"metricsDropped": float64(base.metricsDropped),
-		"metricsSent":    float64(base.metricsSent),
+		"totalEmissions": float64(atomic.LoadUint64(&base.totalEmissions)),
+		"metricsDropped": float64(atomic.LoadUint64(&base.metricsDropped)),
+		"metricsSent":    float64(atomic.LoadUint64(&base.metricsSent)),
+		"bytesSent":      float64(atomic.LoadUint64(&base.bytesSent)),
 	}
 	gauges := map[string]float64{
 		"intervalLength":    float64(base.interval),
@@ -393,10 +423,22 @@ func (base *BaseHandler) InternalMetrics() metric.InternalMetrics {
 		gauges["maxEmissionTiming"] = max
 	}
 
-	return metric.InternalMetrics{
+	im := metric.InternalMetrics{
 		Counters: counters,
 		Gauges:   gauges,
 	}
+	if latency := base.emissionLatency.Snapshot(); latency.Count > 0 {
+		im.Histograms = map[string]metric.HistogramSnapshot{
+			"emissionLatency": latency,
+		}
+	}
+	return im
+}
+
+// RunStatus : Returns when the last emission happened and whether it succeeded
+func (base *BaseHandler) RunStatus() metric.RunStatus {
+	status, _ := base.runStatus.Load().(metric.RunStatus)
+	return status
 }
 
 // configureCommonParams will extract the common parameters that are used and set them in the handler
@@ -468,7 +510,7 @@ func (base *BaseHandler) listenForMetrics(
 	flusher := ticker.C
 
 	flushFunction := func() {
-		go base.emitAndTime(metrics, emitFunc)
+		go base.emitAndTime(collectorName, metrics, emitFunc)
 
 		// will get copied into this call, meaning it's ok to clear it
 		metrics = make([]metric.Metric, 0, collectorEnd.BufferSize)
@@ -492,13 +534,20 @@ stopReading:
 				continue
 			}
 
-			base.log.Debug(base.Name(), " metric: ", incomingMetric)
-			metrics = append(metrics, incomingMetric)
-			currentBufferSize++
-
-			if int(currentBufferSize) >= collectorEnd.BufferSize {
-				base.log.Debug("Full: ", currentBufferSize, " col: ", collectorName)
-				flushFunction()
+			incomingMetrics := []metric.Metric{incomingMetric}
+			if !base.nativeDistributions {
+				incomingMetrics = incomingMetric.Flatten()
+			}
+			for _, m := range incomingMetrics {
+				base.log.Debug(base.Name(), " metric: ", m)
+				base.tap(collectorName, m)
+				metrics = append(metrics, m)
+				currentBufferSize++
+
+				if int(currentBufferSize) >= collectorEnd.BufferSize {
+					base.log.Debug("Full: ", currentBufferSize, " col: ", collectorName)
+					flushFunction()
+				}
 			}
 		case <-flusher:
 			if currentBufferSize > 0 {
@@ -511,15 +560,47 @@ stopReading:
 
 }
 
+// tap publishes the metric along with its handler specific
+// representation if anyone is listening
+func (base *BaseHandler) tap(collectorName string, m metric.Metric) {
+	if collectorName == "" {
+		collectorName, _ = m.GetDimensionValue("collector")
+	}
+	if !metric.DefaultTap.Wants(metric.TapHandler, collectorName, base.Name()) {
+		return
+	}
+
+	var formatted interface{}
+	if base.tapFormatter != nil {
+		formatted = base.tapFormatter(m)
+	} else {
+		formatted = metric.Metric{
+			Name:       base.Prefix() + m.Name,
+			MetricType: m.MetricType,
+			Value:      m.Value,
+			Dimensions: m.GetDimensions(base.DefaultDimensions()),
+		}
+	}
+
+	metric.DefaultTap.Publish(metric.TapEvent{
+		Stage:     metric.TapHandler,
+		Collector: collectorName,
+		Handler:   base.Name(),
+		Metric:    m,
+		Formatted: formatted,
+	})
+}
+
 // manages the rolling window of emissions
 // the emissions are a timesorted list, and we purge things older than
 // the base handler's interval
 func (base *BaseHandler) recordEmissions() {
 	for timing := range base.emissionTimingChannel {
 		atomic.AddUint64(&base.totalEmissions, 1)
+		base.emissionLatency.Observe(timing.duration)
 		now := time.Now()
 
-		mu.Lock()
+		base.mu.Lock()
 		base.emissionTimes.PushBack(timing)
 
 		// now kill the list of old times, iterate through the list until we find
@@ -534,7 +615,7 @@ func (base *BaseHandler) recordEmissions() {
 			base.emissionTimes.Remove(toRemove[i])
 		}
 		base.log.Debug("We removed ", len(toRemove), " entries and now have ", base.emissionTimes.Len())
-		mu.Unlock()
+		base.mu.Unlock()
 	}
 }
 
@@ -555,10 +636,76 @@ func (base *BaseHandler) reportEmissionMetrics(emissionResult bool, timing emiss
 	}
 }
 
-func (base *BaseHandler) emitAndTime(metrics []metric.Metric, emitFunc func([]metric.Metric) bool) {
+// recordRunStatus keeps the outcome of the last emission for the status page
+func (base *BaseHandler) recordRunStatus(start time.Time, elapsed time.Duration, result bool) {
+	// batches may be emitted concurrently
+	base.mu.Lock()
+	defer base.mu.Unlock()
+
+	status := base.RunStatus()
+	status.LastRunStart = start
+	status.LastRunDuration = elapsed.Seconds()
+	if result {
+		status.LastSuccess = start.Add(elapsed)
+	}
+	base.runStatus.Store(status)
+}
+
+// addBytesSent counts the size of the payloads sent by the handler
+func (base *BaseHandler) addBytesSent(n int) {
+	if n > 0 {
+		atomic.AddUint64(&base.bytesSent, uint64(n))
+	}
+}
+
+// countDroppedMetrics attributes the metrics the handler failed to send
+// to the collectors they came from. Metrics read from the default channel
+// are attributed using their collector dimension.
+func (base *BaseHandler) countDroppedMetrics(collectorName string, metrics []metric.Metric) {
+	base.mu.Lock()
+	defer base.mu.Unlock()
+
+	if base.droppedPerCollector == nil {
+		base.droppedPerCollector = make(map[string]uint64)
+	}
+	if collectorName != "" {
+		base.droppedPerCollector[collectorName] += uint64(len(metrics))
+		return
+	}
+	for _, m := range metrics {
+		name, _ := m.GetDimensionValue("collector")
+		base.droppedPerCollector[name]++
+	}
+}
+
+// DroppedPerCollector : Returns the number of metrics which failed to be
+// sent per canonical collector name
+func (base *BaseHandler) DroppedPerCollector() map[string]uint64 {
+	base.mu.Lock()
+	defer base.mu.Unlock()
+
+	dropped := make(map[string]uint64, len(base.droppedPerCollector))
+	for name, count := range base.droppedPerCollector {
+		dropped[name] = count
+	}
+	return dropped
+}
+
+func (base *BaseHandler) emitAndTime(collectorName string, metrics []metric.Metric, emitFunc func([]metric.Metric) bool) {
 	start := time.Now()
 	result := emitFunc(metrics)
 	elapsed := time.Since(start)
+
+	// handlers reporting their own emission metrics emit in the background
+	// and record the status of each emission themselves
+	if !base.useCustomEmissionMetricsReporter {
+		base.recordRunStatus(start, elapsed, result)
+	}
+
+	if !result {
+		base.countDroppedMetrics(collectorName, metrics)
+	}
+
 	if !base.useCustomEmissionMetricsReporter {
 		timing := emissionTiming{
 			timestamp:   time.Now(),
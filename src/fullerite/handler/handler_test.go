@@ -4,6 +4,7 @@ import (
 	"fullerite/metric"
 
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -106,7 +107,7 @@ func TestEmissionAndRecord(t *testing.T) {
 		emissionTimingChannel: make(chan emissionTiming),
 	}
 	base.log = l.WithField("testing", "basehandler_emit")
-	go base.emitAndTime(metrics, emitFunc)
+	go base.emitAndTime("", metrics, emitFunc)
 
 	select {
 	case timing := <-base.emissionTimingChannel:
@@ -122,6 +123,25 @@ func TestEmissionAndRecord(t *testing.T) {
 	base.emissionTimingChannel = nil
 }
 
+func TestEmitAndTimeRunStatus(t *testing.T) {
+	base := BaseHandler{
+		emissionTimingChannel: make(chan emissionTiming, 2),
+	}
+	base.log = l.WithField("testing", "basehandler_status")
+	assert.True(t, base.RunStatus().LastRunStart.IsZero())
+
+	metrics := []metric.Metric{metric.New("example")}
+	base.emitAndTime("", metrics, func([]metric.Metric) bool { return true })
+	status := base.RunStatus()
+	assert.False(t, status.LastRunStart.IsZero())
+	assert.Equal(t, status.LastRunStart.Add(time.Duration(status.LastRunDuration*float64(time.Second))).Unix(), status.LastSuccess.Unix())
+
+	base.emitAndTime("", metrics, func([]metric.Metric) bool { return false })
+	failed := base.RunStatus()
+	assert.True(t, !failed.LastRunStart.Before(status.LastRunStart))
+	assert.Equal(t, status.LastSuccess, failed.LastSuccess)
+}
+
 func TestRecordTimings(t *testing.T) {
 	base := BaseHandler{
 		emissionTimingChannel: make(chan emissionTiming),
@@ -148,6 +168,53 @@ func TestRecordTimings(t *testing.T) {
 	base.emissionTimingChannel = nil
 }
 
+func TestRecordEmissionLatency(t *testing.T) {
+	base := BaseHandler{
+		emissionTimingChannel: make(chan emissionTiming),
+	}
+	base.log = l.WithField("testing", "basehandler_latency")
+	base.interval = 2
+
+	go func() {
+		base.emissionTimingChannel <- emissionTiming{time.Now(), 20 * time.Millisecond, 1}
+		base.emissionTimingChannel <- emissionTiming{time.Now(), 3 * time.Second, 1}
+		close(base.emissionTimingChannel)
+	}()
+	base.recordEmissions()
+
+	latency := base.InternalMetrics().Histograms["emissionLatency"]
+	assert.Equal(t, uint64(2), latency.Count)
+	assert.InDelta(t, 3.02, latency.Sum, 1e-9)
+	assert.Equal(t, metric.HistogramBucket{UpperBound: 0.025, Count: 1}, latency.Buckets[2])
+	assert.Equal(t, metric.HistogramBucket{UpperBound: 5, Count: 2}, latency.Buckets[9])
+}
+
+func TestEmitAndTimeCountsDroppedMetrics(t *testing.T) {
+	base := BaseHandler{
+		emissionTimingChannel: make(chan emissionTiming, 3),
+	}
+	base.log = l.WithField("testing", "basehandler_dropped")
+	assert.Equal(t, map[string]uint64{}, base.DroppedPerCollector())
+
+	m := metric.New("example")
+	base.emitAndTime("Test", []metric.Metric{m, m}, func([]metric.Metric) bool { return false })
+
+	// metrics read from the default channel are attributed by dimension
+	m.AddDimension("collector", "Other")
+	base.emitAndTime("", []metric.Metric{m}, func([]metric.Metric) bool { return false })
+
+	base.emitAndTime("Test", []metric.Metric{m}, func([]metric.Metric) bool { return true })
+	assert.Equal(t, map[string]uint64{"Test": 2, "Other": 1}, base.DroppedPerCollector())
+}
+
+func TestAddBytesSent(t *testing.T) {
+	base := BaseHandler{}
+	base.addBytesSent(10)
+	base.addBytesSent(-1)
+	base.addBytesSent(5)
+	assert.Equal(t, 15.0, base.InternalMetrics().Counters["bytesSent"])
+}
+
 func TestHandlerRunFlushInterval(t *testing.T) {
 	var mu sync.Mutex
 	base := BaseHandler{}
@@ -310,6 +377,7 @@ func TestInternalMetrics(t *testing.T) {
 			"metricsDropped": 100,
 			"metricsSent":    2,
 			"totalEmissions": 10,
+			"bytesSent":      0,
 		},
 		Gauges: map[string]float64{
 			"averageEmissionTiming": 7,
@@ -329,6 +397,7 @@ func TestInternalMetricsWithNan(t *testing.T) {
 			"metricsDropped": 0,
 			"metricsSent":    0,
 			"totalEmissions": 0,
+			"bytesSent":      0,
 		},
 		// specifically missing the averageEmissionTiming
 		// because we have no emissions yet
@@ -347,3 +416,41 @@ func TestKeepAliveConfig(t *testing.T) {
 	assert.Equal(t, 0, base.KeepAliveInterval())
 	assert.Equal(t, 0, base.MaxIdleConnectionsPerHost())
 }
+
+func TestTapHandlerMetrics(t *testing.T) {
+	subscription := metric.DefaultTap.Subscribe(metric.TapFilter{Handler: "Graphite"}, 10)
+	defer metric.DefaultTap.Unsubscribe(subscription)
+
+	g := New("Graphite").(*Graphite)
+	g.SetPrefix("prefix.")
+
+	m := metric.New("foo")
+	m.AddDimension("collector", "Test")
+	g.tap("", m)
+
+	event := <-subscription.Events
+	assert.Equal(t, metric.TapHandler, event.Stage)
+	assert.Equal(t, "Test", event.Collector)
+	assert.Equal(t, "Graphite", event.Handler)
+	assert.Equal(t, m, event.Metric)
+	assert.True(t, strings.HasPrefix(event.Formatted.(string), "prefix.foo.collector.Test "))
+
+	// other handlers are not formatting metrics nobody is tapping
+	New("Log").(*Log).tap("Test", m)
+	assert.Equal(t, 0, len(subscription.Events))
+}
+
+func TestTapHandlerMetricsDefaultFormat(t *testing.T) {
+	subscription := metric.DefaultTap.Subscribe(metric.TapFilter{Handler: "Log"}, 10)
+	defer metric.DefaultTap.Unsubscribe(subscription)
+
+	h := New("Log").(*Log)
+	h.SetPrefix("prefix.")
+	h.SetDefaultDimensions(map[string]string{"host": "a"})
+	h.tap("Test", metric.New("foo"))
+
+	event := <-subscription.Events
+	formatted := event.Formatted.(metric.Metric)
+	assert.Equal(t, "prefix.foo", formatted.Name)
+	assert.Equal(t, map[string]string{"host": "a"}, formatted.Dimensions)
+}
@@ -56,6 +56,8 @@ func newKairos(
 	inst.log = log
 	inst.channel = channel
 
+	inst.tapFormatter = func(m metric.Metric) interface{} { return inst.convertToKairos(m) }
+
 	return inst
 }
 
@@ -76,21 +78,26 @@ func (k *Kairos) Configure(configMap map[string]interface{}) {
 }
 
 // Server returns the Kairos server's hostname or IP address
-func (k Kairos) Server() string {
+func (k *Kairos) Server() string {
 	return k.server
 }
 
 // Port returns the Kairos server's port number
-func (k Kairos) Port() string {
+func (k *Kairos) Port() string {
 	return k.port
 }
 
+// Targets returns the Kairos server the metrics are sent to
+func (k *Kairos) Targets() []string {
+	return []string{fmt.Sprintf("%s:%s", k.server, k.port)}
+}
+
 // Run runs the handler main loop
 func (k *Kairos) Run() {
 	k.run(k.emitMetrics)
 }
 
-func (k Kairos) convertToKairos(incomingMetric metric.Metric) (datapoint KairosMetric) {
+func (k *Kairos) convertToKairos(incomingMetric metric.Metric) (datapoint KairosMetric) {
 	km := new(KairosMetric)
 	km.Name = k.Prefix() + kairosSanitize(incomingMetric.Name)
 	km.Value = incomingMetric.Value
@@ -142,6 +149,7 @@ func (k *Kairos) emitMetrics(metrics []metric.Metric) bool {
 		k.log.Error("Failed to complete POST ", err)
 		return false
 	}
+	k.addBytesSent(len(payload))
 
 	defer rsp.Body.Close()
 	if rsp.StatusCode == http.StatusNoContent {
@@ -164,11 +172,11 @@ func (k *Kairos) emitMetrics(metrics []metric.Metric) bool {
 	return false
 }
 
-func (k Kairos) dialTimeout(network, addr string) (net.Conn, error) {
+func (k *Kairos) dialTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, k.timeout)
 }
 
-func (k Kairos) parseServerError(errMsg string, metrics []KairosMetric) string {
+func (k *Kairos) parseServerError(errMsg string, metrics []KairosMetric) string {
 	re, err := regexp.Compile(`metric\[([0-9]+)\]`)
 	if err != nil {
 		return ""
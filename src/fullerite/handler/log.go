@@ -48,7 +48,7 @@ func (h *Log) Run() {
 	h.run(h.emitMetrics)
 }
 
-func (h Log) convertToLog(incomingMetric metric.Metric) (string, error) {
+func (h *Log) convertToLog(incomingMetric metric.Metric) (string, error) {
 	jsonOut, err := json.Marshal(incomingMetric)
 	return string(jsonOut), err
 }
@@ -0,0 +1,411 @@
+package handler
+
+import (
+	"fullerite/config"
+	"fullerite/metric"
+
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultPrometheusPort      = 9437
+	defaultPrometheusPath      = "/metrics"
+	defaultPrometheusStaleness = 300
+
+	prometheusTextContentType   = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsTextContentType  = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	openMetricsTextAcceptHeader = "application/openmetrics-text"
+)
+
+func init() {
+	RegisterHandler("Prometheus", newPrometheus)
+}
+
+// Prometheus handler keeps the last value of every series it receives and
+// serves them to Prometheus servers scraping it
+type Prometheus struct {
+	BaseHandler
+	port      int
+	path      string
+	staleness time.Duration
+
+	tlsCertFile       string
+	tlsKeyFile        string
+	basicAuthUsername string
+	basicAuthPassword string
+
+	seriesMu sync.Mutex
+	series   map[string]prometheusSeries
+}
+
+type prometheusSeries struct {
+	metric   metric.Metric
+	lastSeen time.Time
+}
+
+// prometheusFamily is the series sharing a name, the first series gives
+// the type of the family
+type prometheusFamily struct {
+	name       string
+	metricType string
+	series     []metric.Metric
+}
+
+// newPrometheus returns a new Prometheus handler
+func newPrometheus(
+	channel chan metric.Metric,
+	initialInterval int,
+	initialBufferSize int,
+	initialTimeout time.Duration,
+	log *l.Entry) Handler {
+
+	inst := new(Prometheus)
+	inst.name = "Prometheus"
+
+	inst.interval = initialInterval
+	inst.maxBufferSize = initialBufferSize
+	inst.timeout = initialTimeout
+	inst.log = log
+	inst.channel = channel
+
+	inst.port = defaultPrometheusPort
+	inst.path = defaultPrometheusPath
+	inst.staleness = defaultPrometheusStaleness * time.Second
+	inst.series = make(map[string]prometheusSeries)
+	inst.SendDistributionsNatively()
+
+	return inst
+}
+
+// Configure accepts the different configuration options for the Prometheus handler
+func (p *Prometheus) Configure(configMap map[string]interface{}) {
+	if port, exists := configMap["port"]; exists {
+		p.port = config.GetAsInt(port, defaultPrometheusPort)
+	}
+	if path, exists := configMap["path"]; exists {
+		p.path = path.(string)
+	}
+	if staleness, exists := configMap["staleness"]; exists {
+		p.staleness = time.Duration(config.GetAsInt(staleness, defaultPrometheusStaleness)) * time.Second
+	}
+	if certFile, exists := configMap["tlsCertFile"]; exists {
+		p.tlsCertFile = certFile.(string)
+	}
+	if keyFile, exists := configMap["tlsKeyFile"]; exists {
+		p.tlsKeyFile = keyFile.(string)
+	}
+	if username, exists := configMap["basicAuthUsername"]; exists {
+		p.basicAuthUsername = username.(string)
+	}
+	if password, exists := configMap["basicAuthPassword"]; exists {
+		p.basicAuthPassword = password.(string)
+	}
+	p.configureCommonParams(configMap)
+}
+
+// Port returns the port the metrics are served on
+func (p *Prometheus) Port() int {
+	return p.port
+}
+
+// Path returns the path the metrics are served on
+func (p *Prometheus) Path() string {
+	return p.path
+}
+
+// Staleness returns how long a series is served after it was last received
+func (p *Prometheus) Staleness() time.Duration {
+	return p.staleness
+}
+
+// Targets returns the address Prometheus scrapes the metrics from
+func (p *Prometheus) Targets() []string {
+	return []string{net.JoinHostPort("", strconv.Itoa(p.port)) + p.path}
+}
+
+// Run serves the metrics and runs the handler main loop
+func (p *Prometheus) Run() {
+	go p.serve()
+	p.run(p.emitMetrics)
+}
+
+func (p *Prometheus) serve() {
+	mux := http.NewServeMux()
+	mux.HandleFunc(p.path, p.handleScrape)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", p.port),
+		Handler: mux,
+	}
+
+	var err error
+	if p.tlsCertFile != "" && p.tlsKeyFile != "" {
+		p.log.Info("Serving metrics on https port ", p.port, " path ", p.path)
+		err = server.ListenAndServeTLS(p.tlsCertFile, p.tlsKeyFile)
+	} else {
+		p.log.Info("Serving metrics on http port ", p.port, " path ", p.path)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		p.log.Error("Failed to serve metrics: ", err)
+	}
+}
+
+// emitMetrics keeps the last value of each series until the next scrape
+func (p *Prometheus) emitMetrics(metrics []metric.Metric) bool {
+	if len(metrics) == 0 {
+		p.log.Warn("Skipping send because of an empty payload")
+		return false
+	}
+
+	now := time.Now()
+	p.seriesMu.Lock()
+	defer p.seriesMu.Unlock()
+	for _, m := range metrics {
+		m.Name = prometheusName(p.Prefix() + m.Name)
+		m.Dimensions = p.prometheusLabels(m.Name, m.GetDimensions(p.DefaultDimensions()))
+		p.series[prometheusSeriesKey(m)] = prometheusSeries{metric: m, lastSeen: now}
+	}
+	p.pruneSeries(now)
+	p.log.Debug("Keeping ", len(p.series), " series for the next scrape")
+	return true
+}
+
+// prometheusLabels returns the dimensions with sanitized names. When
+// several dimensions have the same sanitized name the first one in the
+// order of their original names is kept and the others are skipped.
+func (p *Prometheus) prometheusLabels(name string, dimensions map[string]string) map[string]string {
+	labels := make(map[string]string, len(dimensions))
+	for _, k := range sortedDimensionNames(dimensions) {
+		label := prometheusLabelName(k)
+		if _, exists := labels[label]; exists {
+			p.log.Debug("Skipping dimension ", k, " of ", name, " colliding with label ", label)
+			continue
+		}
+		labels[label] = dimensions[k]
+	}
+	return labels
+}
+
+// pruneSeries drops the series not received for longer than the
+// staleness, the caller holds seriesMu
+func (p *Prometheus) pruneSeries(now time.Time) {
+	for key, series := range p.series {
+		if now.Sub(series.lastSeen) > p.staleness {
+			delete(p.series, key)
+		}
+	}
+}
+
+// families drops the stale series and returns the others grouped by
+// name, both sorted by name and dimensions
+func (p *Prometheus) families(now time.Time) []prometheusFamily {
+	p.seriesMu.Lock()
+	p.pruneSeries(now)
+	keys := make([]string, 0, len(p.series))
+	for key := range p.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	metrics := make([]metric.Metric, 0, len(keys))
+	for _, key := range keys {
+		metrics = append(metrics, p.series[key].metric)
+	}
+	p.seriesMu.Unlock()
+
+	families := []prometheusFamily{}
+	for _, m := range metrics {
+		metricType := prometheusType(m)
+		if n := len(families); n > 0 && families[n-1].name == m.Name {
+			if families[n-1].metricType != metricType {
+				p.log.Debug("Skipping ", m.Name, " series of type ", metricType,
+					" in a family of type ", families[n-1].metricType)
+				continue
+			}
+			families[n-1].series = append(families[n-1].series, m)
+			continue
+		}
+		families = append(families, prometheusFamily{name: m.Name, metricType: metricType, series: []metric.Metric{m}})
+	}
+	return families
+}
+
+func (p *Prometheus) handleScrape(writer http.ResponseWriter, req *http.Request) {
+	if !p.authorized(req) {
+		writer.Header().Set("WWW-Authenticate", `Basic realm="fullerite"`)
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	openMetrics := strings.Contains(req.Header.Get("Accept"), openMetricsTextAcceptHeader)
+	var body bytes.Buffer
+	writePrometheusFamilies(&body, p.families(time.Now()), openMetrics)
+
+	if openMetrics {
+		writer.Header().Set("Content-Type", openMetricsTextContentType)
+	} else {
+		writer.Header().Set("Content-Type", prometheusTextContentType)
+	}
+	writer.Write(body.Bytes())
+}
+
+func (p *Prometheus) authorized(req *http.Request) bool {
+	if p.basicAuthUsername == "" && p.basicAuthPassword == "" {
+		return true
+	}
+	username, password, ok := req.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(p.basicAuthUsername)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(p.basicAuthPassword)) == 1
+}
+
+// prometheusType maps the fullerite metric types to the Prometheus ones.
+// Counters are reset every interval so they are gauges for Prometheus.
+func prometheusType(m metric.Metric) string {
+	switch {
+	case m.Distribution != nil && len(m.Distribution.Buckets) == 0 && len(m.Distribution.Quantiles) > 0:
+		return "summary"
+	case m.Distribution != nil:
+		return "histogram"
+	case m.MetricType == metric.CumulativeCounter:
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
+
+// writePrometheusFamilies writes the families in the Prometheus text format
+// or in the OpenMetrics one
+func writePrometheusFamilies(buf *bytes.Buffer, families []prometheusFamily, openMetrics bool) {
+	for _, family := range families {
+		name := family.name
+		sampleSuffix := ""
+		if openMetrics && family.metricType == "counter" {
+			// OpenMetrics counter samples end with _total, the family doesn't
+			name = strings.TrimSuffix(name, "_total")
+			sampleSuffix = "_total"
+		}
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, family.metricType)
+
+		for _, m := range family.series {
+			d := m.Distribution
+			if d == nil {
+				writePrometheusSample(buf, name+sampleSuffix, m.Dimensions, "", "", m.Value)
+				continue
+			}
+			if family.metricType == "histogram" {
+				for _, bucket := range d.Buckets {
+					writePrometheusSample(buf, name+"_bucket", m.Dimensions, "le", metric.FormatBound(bucket.UpperBound), bucket.Count)
+				}
+				writePrometheusSample(buf, name+"_bucket", m.Dimensions, "le", "+Inf", d.Count)
+			} else {
+				for _, quantile := range d.Quantiles {
+					writePrometheusSample(buf, name, m.Dimensions, "quantile", metric.FormatBound(quantile.Quantile), quantile.Value)
+				}
+			}
+			writePrometheusSample(buf, name+"_sum", m.Dimensions, "", "", d.Sum)
+			writePrometheusSample(buf, name+"_count", m.Dimensions, "", "", d.Count)
+		}
+	}
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+}
+
+// writePrometheusSample writes one line of a family, the dimensions are
+// already sanitized label names and extraLabel is the le or quantile
+// label of histograms and summaries
+func writePrometheusSample(buf *bytes.Buffer, name string, dimensions map[string]string, extraLabel, extraValue string, value float64) {
+	labels := make([]string, 0, len(dimensions)+1)
+	for _, k := range sortedDimensionNames(dimensions) {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", k, prometheusLabelValue(dimensions[k])))
+	}
+	if extraLabel != "" {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", extraLabel, extraValue))
+	}
+
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	buf.WriteString(" " + prometheusValue(value) + "\n")
+}
+
+func prometheusValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// prometheusSeriesKey identifies a series by its sanitized name and labels
+// so that it sorts by name first
+func prometheusSeriesKey(m metric.Metric) string {
+	var key bytes.Buffer
+	key.WriteString(m.Name)
+	for _, k := range sortedDimensionNames(m.Dimensions) {
+		key.WriteString("\x00" + k + "=" + m.Dimensions[k])
+	}
+	return key.String()
+}
+
+func sortedDimensionNames(dimensions map[string]string) []string {
+	names := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prometheusName replaces the characters not allowed in metric names by _
+func prometheusName(name string) string {
+	return sanitizePrometheus(name, true)
+}
+
+// prometheusLabelName replaces the characters not allowed in label names
+// by _, the names starting with __ are reserved to Prometheus
+func prometheusLabelName(name string) string {
+	name = sanitizePrometheus(name, false)
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	return name
+}
+
+func sanitizePrometheus(name string, allowColon bool) string {
+	sanitized := []rune(name)
+	for i, r := range sanitized {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9' && i > 0) || (r == ':' && allowColon)
+		if !valid {
+			sanitized[i] = '_'
+		}
+	}
+	if len(sanitized) == 0 {
+		return "_"
+	}
+	return string(sanitized)
+}
+
+// prometheusLabelValue escapes the backslashes, double quotes and new lines
+func prometheusLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
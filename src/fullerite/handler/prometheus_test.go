@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"fullerite/metric"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestPrometheusHandler(interval, buffsize, timeoutsec int) *Prometheus {
+	testChannel := make(chan metric.Metric)
+	testLog := l.WithField("testing", "prometheus_handler")
+	timeout := time.Duration(timeoutsec) * time.Second
+
+	return newPrometheus(testChannel, interval, buffsize, timeout, testLog).(*Prometheus)
+}
+
+func scrapePrometheusHandler(p *Prometheus, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rsp := httptest.NewRecorder()
+	p.handleScrape(rsp, req)
+	return rsp
+}
+
+func TestPrometheusConfigureEmptyConfig(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{})
+
+	assert.Equal(t, 12, p.Interval())
+	assert.Equal(t, 13, p.MaxBufferSize())
+	assert.Equal(t, defaultPrometheusPort, p.Port())
+	assert.Equal(t, "/metrics", p.Path())
+	assert.Equal(t, 300*time.Second, p.Staleness())
+}
+
+func TestPrometheusConfigure(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{
+		"port":      "9100",
+		"path":      "/fullerite",
+		"staleness": 60,
+	})
+
+	assert.Equal(t, 9100, p.Port())
+	assert.Equal(t, "/fullerite", p.Path())
+	assert.Equal(t, 60*time.Second, p.Staleness())
+	assert.Equal(t, []string{":9100/fullerite"}, p.Targets())
+}
+
+func TestPrometheusTextFormat(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{
+		"defaultDimensions": map[string]interface{}{"host": "web1"},
+	})
+
+	requests := metric.WithValue("http.requests-total", 10)
+	requests.MetricType = metric.CumulativeCounter
+	requests.AddDimension("path", "/a\"b")
+	errors := metric.WithValue("errors", 2)
+	errors.MetricType = metric.Counter
+	latency := metric.WithDistribution("latency", metric.Distribution{
+		Count:   3,
+		Sum:     1.5,
+		Buckets: []metric.DistributionBucket{{UpperBound: 0.5, Count: 1}},
+	})
+	p.emitMetrics([]metric.Metric{requests, errors, latency})
+
+	// the last value of a series wins
+	requests.Value = 12
+	p.emitMetrics([]metric.Metric{requests})
+
+	rsp := scrapePrometheusHandler(p, "")
+	assert.Equal(t, prometheusTextContentType, rsp.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE errors gauge
+errors{host="web1"} 2
+# TYPE http_requests_total counter
+http_requests_total{host="web1",path="/a\"b"} 12
+# TYPE latency histogram
+latency_bucket{host="web1",le="0.5"} 1
+latency_bucket{host="web1",le="+Inf"} 3
+latency_sum{host="web1"} 1.5
+latency_count{host="web1"} 3
+`, rsp.Body.String())
+}
+
+func TestPrometheusOpenMetricsFormat(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+
+	requests := metric.WithValue("requests", 10)
+	requests.MetricType = metric.CumulativeCounter
+	summary := metric.WithDistribution("size", metric.Distribution{
+		Count:     4,
+		Sum:       8,
+		Quantiles: []metric.DistributionQuantile{{Quantile: 0.5, Value: 2}},
+	})
+	p.emitMetrics([]metric.Metric{requests, summary})
+
+	rsp := scrapePrometheusHandler(p, "application/openmetrics-text; version=1.0.0")
+	assert.Equal(t, openMetricsTextContentType, rsp.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE requests counter
+requests_total 10
+# TYPE size summary
+size{quantile="0.5"} 2
+size_sum 8
+size_count 4
+# EOF
+`, rsp.Body.String())
+}
+
+func TestPrometheusStaleness(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{"staleness": 10})
+
+	p.emitMetrics([]metric.Metric{metric.WithValue("old", 1), metric.WithValue("new", 2)})
+	p.series[prometheusSeriesKey(metric.WithValue("old", 1))] = prometheusSeries{
+		metric:   metric.WithValue("old", 1),
+		lastSeen: time.Now().Add(-11 * time.Second),
+	}
+
+	assert.Equal(t, "# TYPE new gauge\nnew 2\n", scrapePrometheusHandler(p, "").Body.String())
+	assert.Len(t, p.series, 1)
+}
+
+func TestPrometheusStalenessOnEmit(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{"staleness": 10})
+
+	p.emitMetrics([]metric.Metric{metric.WithValue("old", 1)})
+	p.series[prometheusSeriesKey(metric.WithValue("old", 1))] = prometheusSeries{
+		metric:   metric.WithValue("old", 1),
+		lastSeen: time.Now().Add(-11 * time.Second),
+	}
+
+	// the stale series are dropped even when nothing scrapes the handler
+	p.emitMetrics([]metric.Metric{metric.WithValue("new", 2)})
+	assert.Len(t, p.series, 1)
+}
+
+func TestPrometheusLabelCollisions(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+
+	m := metric.WithValue("requests", 1)
+	m.AddDimension("service-name", "b")
+	m.AddDimension("service.name", "c")
+	m.AddDimension("service_name", "a")
+	p.emitMetrics([]metric.Metric{m})
+
+	assert.Equal(t, "# TYPE requests gauge\nrequests{service_name=\"b\"} 1\n", scrapePrometheusHandler(p, "").Body.String())
+}
+
+func TestPrometheusBasicAuth(t *testing.T) {
+	p := getTestPrometheusHandler(12, 13, 14)
+	p.Configure(map[string]interface{}{
+		"basicAuthUsername": "prometheus",
+		"basicAuthPassword": "secret",
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, scrapePrometheusHandler(p, "").Code)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.SetBasicAuth("prometheus", "wrong")
+	rsp := httptest.NewRecorder()
+	p.handleScrape(rsp, req)
+	assert.Equal(t, http.StatusUnauthorized, rsp.Code)
+
+	req.SetBasicAuth("prometheus", "secret")
+	rsp = httptest.NewRecorder()
+	p.handleScrape(rsp, req)
+	assert.Equal(t, http.StatusOK, rsp.Code)
+}
+
+func TestPrometheusSanitization(t *testing.T) {
+	assert.Equal(t, "fullerite_cpu:usage", prometheusName("fullerite.cpu:usage"))
+	assert.Equal(t, "_xx_requests", prometheusName("5xx-requests"))
+	assert.Equal(t, "service_name", prometheusLabelName("service.name"))
+	assert.Equal(t, "_reserved", prometheusLabelName("__reserved"))
+	assert.Equal(t, `a\\b\nc`, prometheusLabelValue("a\\b\nc"))
+}
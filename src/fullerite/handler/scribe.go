@@ -66,6 +66,8 @@ func newScribe(
 	inst.port = defaultScribePort
 	inst.streamName = defaultScribeStreamName
 
+	inst.tapFormatter = func(m metric.Metric) interface{} { return inst.createScribeMetric(m) }
+
 	return inst
 }
 
@@ -86,6 +88,11 @@ func (s *Scribe) Configure(configMap map[string]interface{}) {
 	s.configureCommonParams(configMap)
 }
 
+// Targets returns the Scribe server the metrics are sent to
+func (s *Scribe) Targets() []string {
+	return []string{fmt.Sprintf("%s:%d", s.endpoint, s.port)}
+}
+
 func (s *Scribe) connectToScribe() {
 	server := fmt.Sprintf("%s:%d", s.endpoint, s.port)
 	conn, err := net.Dial("tcp", server)
@@ -139,13 +146,16 @@ func (s *Scribe) emitMetrics(metrics []metric.Metric) bool {
 			s.connectToScribe()
 			return false
 		}
+		for _, entry := range encodedMetrics {
+			s.addBytesSent(len(entry.Message))
+		}
 	}
 
 	s.log.Info("Successfully written ", len(encodedMetrics), " datapoints to Scribe")
 	return true
 }
 
-func (s Scribe) createScribeMetric(m metric.Metric) scribeMetric {
+func (s *Scribe) createScribeMetric(m metric.Metric) scribeMetric {
 	return scribeMetric{
 		Name:       m.Name,
 		Value:      m.Value,
@@ -6,6 +6,7 @@ import (
 	"fullerite/util"
 
 	"bytes"
+	"math"
 	"time"
 
 	l "github.com/Sirupsen/logrus"
@@ -54,6 +55,14 @@ func newSignalFx(
 	inst.log = log
 	inst.channel = channel
 
+	inst.SendDistributionsNatively()
+	inst.tapFormatter = func(m metric.Metric) interface{} {
+		if m.Distribution != nil {
+			return inst.convertDistributionToProto(m)
+		}
+		return inst.convertToProto(m)
+	}
+
 	return inst
 }
 
@@ -91,10 +100,15 @@ func (s *SignalFx) Configure(configMap map[string]interface{}) {
 }
 
 // Endpoint returns SignalFx' API endpoint
-func (s SignalFx) Endpoint() string {
+func (s *SignalFx) Endpoint() string {
 	return s.endpoint
 }
 
+// Targets returns the SignalFx endpoint the metrics are sent to
+func (s *SignalFx) Targets() []string {
+	return []string{s.endpoint}
+}
+
 // Run runs the handler main loop
 func (s *SignalFx) Run() {
 	httpAliveClient := new(util.HTTPAlive)
@@ -114,7 +128,7 @@ func signalFxKeySanitize(key string) string {
 	return util.StrSanitize(key, false, allowedDimKeyPuncts)
 }
 
-func (s SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
+func (s *SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
 	// Create a new values for the Datapoint that requires pointers.
 	outname := s.Prefix() + signalFxValueSanitize(incomingMetric.Name)
 	value := incomingMetric.Value
@@ -155,7 +169,39 @@ func (s SignalFx) convertToProto(incomingMetric metric.Metric) *DataPoint {
 	return datapoint
 }
 
-func (s SignalFx) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
+// convertDistributionToProto converts a Histogram metric to the datapoints
+// SignalFx builds histograms from: one cumulative counter per bucket with an
+// upper_bound dimension, the _sum and the _count. Quantiles are sent as
+// gauges with a quantile dimension.
+func (s *SignalFx) convertDistributionToProto(incomingMetric metric.Metric) []*DataPoint {
+	d := incomingMetric.Distribution
+	datapoints := make([]*DataPoint, 0, len(d.Buckets)+len(d.Quantiles)+3)
+	add := func(suffix, metricType string, value float64, dimension, dimensionValue string) {
+		m := metric.New(incomingMetric.Name + suffix)
+		m.MetricType = metricType
+		m.Value = value
+		m.AddDimensions(incomingMetric.Dimensions)
+		if dimension != "" {
+			m.AddDimension(dimension, dimensionValue)
+		}
+		datapoints = append(datapoints, s.convertToProto(m))
+	}
+
+	if len(d.Buckets) > 0 {
+		for _, bucket := range d.Buckets {
+			add("_bucket", metric.CumulativeCounter, bucket.Count, "upper_bound", metric.FormatBound(bucket.UpperBound))
+		}
+		add("_bucket", metric.CumulativeCounter, d.Count, "upper_bound", metric.FormatBound(math.Inf(1)))
+	}
+	for _, quantile := range d.Quantiles {
+		add("_quantile", metric.Gauge, quantile.Value, "quantile", metric.FormatBound(quantile.Quantile))
+	}
+	add("_sum", metric.CumulativeCounter, d.Sum, "", "")
+	add("_count", metric.CumulativeCounter, d.Count, "", "")
+	return datapoints
+}
+
+func (s *SignalFx) getSanitizedDimensions(incomingMetric metric.Metric) map[string]string {
 	dimSanitized := make(map[string]string)
 	dimensions := incomingMetric.GetDimensions(s.DefaultDimensions())
 	for key, value := range dimensions {
@@ -196,7 +242,11 @@ func (s *SignalFx) emitBatch(batchName string, metrics []metric.Metric) bool {
 
 	datapoints := make([]*DataPoint, 0, len(metrics))
 	for _, m := range metrics {
-		datapoints = append(datapoints, s.convertToProto(m))
+		if m.Distribution != nil {
+			datapoints = append(datapoints, s.convertDistributionToProto(m)...)
+		} else {
+			datapoints = append(datapoints, s.convertToProto(m))
+		}
 	}
 
 	payload := new(DataPointUploadMessage)
@@ -233,6 +283,7 @@ func (s *SignalFx) emitBatch(batchName string, metrics []metric.Metric) bool {
 			" to endpoint ", s.endpoint)
 		return false
 	}
+	s.addBytesSent(len(serialized))
 
 	if rsp.StatusCode != 200 {
 		s.log.Error("Failed to post to signalfx @", s.endpoint,
@@ -259,6 +310,10 @@ func (s *SignalFx) emitAndTime(batchName string, metrics []metric.Metric) bool {
 			metricsSent: len(metrics),
 		}
 		s.reportEmissionMetrics(emissionResult, timing)
+		s.recordRunStatus(start, elapsed, emissionResult)
+		if !emissionResult {
+			s.countDroppedMetrics("", metrics)
+		}
 	}
 
 	return emissionResult
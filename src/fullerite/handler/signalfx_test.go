@@ -2,6 +2,7 @@ package handler
 
 import (
 	"fullerite/metric"
+	"fullerite/util"
 
 	"io/ioutil"
 	"net/http"
@@ -135,3 +136,29 @@ func TestSignalFxSanitation(t *testing.T) {
 		}
 	}
 }
+
+func TestSignalFxBatchRunStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	s := getTestSignalfxHandler(12, 12, 12)
+	s.Configure(map[string]interface{}{
+		"authToken":        "secret",
+		"endpoint":         ts.URL,
+		"batchByDimension": "service",
+	})
+	s.emissionTimingChannel = make(chan emissionTiming, 1)
+	s.httpClient = new(util.HTTPAlive)
+	s.httpClient.Configure(time.Second, time.Second, 1)
+	assert.True(t, s.RunStatus().LastRunStart.IsZero())
+
+	m := metric.New("Test")
+	m.AddDimension("service", "fullerite")
+	assert.True(t, s.emitAndTime("fullerite", []metric.Metric{m}))
+
+	status := s.RunStatus()
+	assert.False(t, status.LastRunStart.IsZero())
+	assert.False(t, status.LastSuccess.IsZero())
+}
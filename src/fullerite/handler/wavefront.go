@@ -65,10 +65,12 @@ func newWavefront(
 	inst.interval = initialInterval
 	inst.channel = channel
 
+	inst.tapFormatter = func(m metric.Metric) interface{} { return inst.convertToWavefront(m) }
+
 	return inst
 }
 
-func (w Wavefront) escapeQuotes(value string) string {
+func (w *Wavefront) escapeQuotes(value string) string {
 	var escapedValue = ""
 	for _, c := range value {
 		if c == '"' {
@@ -80,7 +82,7 @@ func (w Wavefront) escapeQuotes(value string) string {
 	return escapedValue
 }
 
-func (w Wavefront) wavefrontValueSanitize(value string) string {
+func (w *Wavefront) wavefrontValueSanitize(value string) string {
 	value = strings.Trim(value, "_")
 	value = strings.Trim(value, "\"")
 	if strings.Contains(value, "\"") {
@@ -89,11 +91,11 @@ func (w Wavefront) wavefrontValueSanitize(value string) string {
 	return value
 }
 
-func (w Wavefront) wavefrontKeySanitize(key string) string {
+func (w *Wavefront) wavefrontKeySanitize(key string) string {
 	return util.StrSanitize(key, false, allowedKeyPuncts)
 }
 
-func (w Wavefront) wavefrontPointTagSanitize(pointTag string) string {
+func (w *Wavefront) wavefrontPointTagSanitize(pointTag string) string {
 	if len(pointTag) > pointTagLength {
 		runes := []rune(pointTag)
 		w.log.Warn("Truncating point tag: \"" + pointTag + "\". The maximum allowed length for a combination of a point tag key and value is 255 characters including =")
@@ -110,7 +112,7 @@ func (w Wavefront) wavefrontPointTagSanitize(pointTag string) string {
 	return pointTag
 }
 
-func (w Wavefront) wavefrontSourceSanitize(source string) string {
+func (w *Wavefront) wavefrontSourceSanitize(source string) string {
 	sanitizedSource := util.StrSanitize(source, false, allowedKeyPuncts)
 	if len(sanitizedSource) > sourceLength {
 		runes := []rune(sanitizedSource)
@@ -185,10 +187,18 @@ func (w *Wavefront) configureForProxyIngestion(configMap map[string]interface{})
 }
 
 // Endpoint returns the Wavefront API endpoint
-func (w Wavefront) Endpoint() string {
+func (w *Wavefront) Endpoint() string {
 	return w.endpoint
 }
 
+// Targets returns the Wavefront endpoint or proxy the metrics are sent to
+func (w *Wavefront) Targets() []string {
+	if w.proxyFlag {
+		return []string{fmt.Sprintf("%s:%s", w.proxyServer, w.port)}
+	}
+	return []string{w.endpoint}
+}
+
 // Run runs the handler main loop
 func (w *Wavefront) Run() {
 	w.run(w.emitMetrics)
@@ -252,6 +262,10 @@ func (w *Wavefront) emitAndTime(metrics []metric.Metric) bool {
 			metricsSent: len(metrics),
 		}
 		w.reportEmissionMetrics(emissionResult, timing)
+		w.recordRunStatus(start, elapsed, emissionResult)
+		if !emissionResult {
+			w.countDroppedMetrics("", metrics)
+		}
 	}
 
 	return emissionResult
@@ -274,7 +288,7 @@ func (w *Wavefront) emitBatch(metrics []metric.Metric) bool {
 	return w.emitMetricsForDirectIngestion(metrics, pStr, len(series))
 }
 
-func (w Wavefront) emitMetricsToProxy(metrics []metric.Metric, pStr string, nDataPoints int) bool {
+func (w *Wavefront) emitMetricsToProxy(metrics []metric.Metric, pStr string, nDataPoints int) bool {
 	w.log.Debug("Starting emission via Proxy")
 	addr := fmt.Sprintf("%s:%s", w.proxyServer, w.port)
 	conn, err := w.dialTimeout("tcp", addr)
@@ -282,13 +296,14 @@ func (w Wavefront) emitMetricsToProxy(metrics []metric.Metric, pStr string, nDat
 		w.log.Error("Failed to connect ", addr)
 		return false
 	}
-	conn.Write([]byte(pStr))
+	n, _ := conn.Write([]byte(pStr))
+	w.addBytesSent(n)
 	w.log.Info("Successfully sent ", nDataPoints, " datapoints to Wavefront")
 	conn.Close()
 	return true
 }
 
-func (w Wavefront) emitMetricsForDirectIngestion(metrics []metric.Metric, pStr string, nDataPoints int) bool {
+func (w *Wavefront) emitMetricsForDirectIngestion(metrics []metric.Metric, pStr string, nDataPoints int) bool {
 	w.log.Debug("Starting to emit metrics for Direct Ingestion")
 	apiURL := fmt.Sprintf("%s", w.endpoint)
 	req, err := http.NewRequest("POST", apiURL, bytes.NewBufferString(pStr))
@@ -311,6 +326,7 @@ func (w Wavefront) emitMetricsForDirectIngestion(metrics []metric.Metric, pStr s
 		w.log.Error("Failed to complete POST ", err)
 		return false
 	}
+	w.addBytesSent(len(pStr))
 
 	defer rsp.Body.Close()
 	if (rsp.StatusCode == http.StatusOK) || (rsp.StatusCode == http.StatusAccepted) {
@@ -326,11 +342,11 @@ func (w Wavefront) emitMetricsForDirectIngestion(metrics []metric.Metric, pStr s
 	return false
 }
 
-func (w Wavefront) dialTimeout(network, addr string) (net.Conn, error) {
+func (w *Wavefront) dialTimeout(network, addr string) (net.Conn, error) {
 	return net.DialTimeout(network, addr, w.timeout)
 }
 
-func (w Wavefront) getSanitizedDimensions(dimensions map[string](string)) (sanitizedDmensions []string) {
+func (w *Wavefront) getSanitizedDimensions(dimensions map[string](string)) (sanitizedDmensions []string) {
 	for name, value := range dimensions {
 		if name == "host" || value == "none" {
 			continue
@@ -348,7 +364,7 @@ func (w Wavefront) getSanitizedDimensions(dimensions map[string](string)) (sanit
 	return sanitizedDmensions
 }
 
-func (w Wavefront) wavefrontPayloadToString(p wavefrontPayload) string {
+func (w *Wavefront) wavefrontPayloadToString(p wavefrontPayload) string {
 	var payloadBuffer bytes.Buffer
 	var pointTagsBuffer bytes.Buffer
 	for i, series := range p.Series {
@@ -26,6 +26,7 @@ func createHandler(name string, globalConfig config.Config, instanceConfig map[s
 
 	// now apply the handler level configs
 	handlerInst.Configure(instanceConfig)
+	componentStatus.addHandler(name, handlerInst, instanceConfig)
 
 	// now run a listener channel for each collector
 	handlerInst.InitListeners(globalConfig)
@@ -7,9 +7,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	l "github.com/Sirupsen/logrus"
@@ -17,19 +22,33 @@ import (
 
 const (
 	defaultPort = 19090
+
+	defaultCardinalityTop = 10
+
+	tapBufferSize = 1000
 )
 
 // InternalServer will collect from each handler the status and return it over HTTP
 type InternalServer struct {
-	log               *l.Entry
-	handlerStatFunc   InternalStatFunc
-	collectorStatFunc InternalStatFunc
-	port              int
+	log                 *l.Entry
+	handlerStatFunc     InternalStatFunc
+	collectorStatFunc   InternalStatFunc
+	cardinalityStatFunc CardinalityStatFunc
+	statusFunc          StatusFunc
+	tap                 *metric.Tap
+	port                int
 }
 
 // InternalStatFunc can be used to extract metrics
 type InternalStatFunc func() (stats map[string]metric.InternalMetrics)
 
+// CardinalityStatFunc returns the cardinality report of each collector
+// limited to the top n offenders
+type CardinalityStatFunc func(n int) map[string]metric.CardinalityReport
+
+// StatusFunc returns the runtime status of the collectors and handlers
+type StatusFunc func() metric.StatusReport
+
 // ResponseFormat is the structure of the response from an http request
 type ResponseFormat struct {
 	Memory     metric.InternalMetrics
@@ -38,11 +57,14 @@ type ResponseFormat struct {
 }
 
 // New createse a new internal server instance
-func New(cfg config.Config, h InternalStatFunc, c InternalStatFunc) *InternalServer {
+func New(cfg config.Config, h InternalStatFunc, c InternalStatFunc, card CardinalityStatFunc, status StatusFunc) *InternalServer {
 	srv := new(InternalServer)
 	srv.log = l.WithFields(l.Fields{"app": "fullerite", "pkg": "internalserver"})
 	srv.handlerStatFunc = h
 	srv.collectorStatFunc = c
+	srv.cardinalityStatFunc = card
+	srv.statusFunc = status
+	srv.tap = metric.DefaultTap
 	srv.configure(cfg.InternalServerConfig)
 	return srv
 }
@@ -52,6 +74,9 @@ func (srv *InternalServer) Run() {
 	srv.log.Info(fmt.Sprintf("Starting to run internal metrics server on port %d", srv.port))
 	http.HandleFunc("/metrics", srv.handleInternalMetricsRequest)
 	http.HandleFunc("/metrics/prometheus", srv.handlePrometheusMetricsRequest)
+	http.HandleFunc("/cardinality", srv.handleCardinalityRequest)
+	http.HandleFunc("/tap", srv.handleTapRequest)
+	http.HandleFunc("/status", srv.handleStatusRequest)
 
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", srv.port))
 	if err != nil {
@@ -111,41 +136,262 @@ func (srv InternalServer) handlePrometheusMetricsRequest(writer http.ResponseWri
 	prometheusInternalMetricsCollectorStats(writer, srv.collectorStatFunc())
 }
 
+// handleCardinalityRequest returns the series tracked by the collectors
+// cardinality limiters. The number of top offending metric names and
+// dimensions can be set with the "top" query parameter.
+func (srv InternalServer) handleCardinalityRequest(writer http.ResponseWriter, req *http.Request) {
+	top := defaultCardinalityTop
+	if val := req.URL.Query().Get("top"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			top = n
+		}
+	}
+
+	reports := map[string]metric.CardinalityReport{}
+	if srv.cardinalityStatFunc != nil {
+		reports = srv.cardinalityStatFunc(top)
+	}
+
+	asString, err := json.Marshal(reports)
+	if err != nil {
+		srv.log.Warn("Failed to marshal cardinality reports because of error ", err)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(asString)
+}
+
+// handleStatusRequest returns the configuration and the last run of every
+// collector and handler. The response is JSON, unless HTML is asked for
+// with ?format=html or the Accept header.
+func (srv InternalServer) handleStatusRequest(writer http.ResponseWriter, req *http.Request) {
+	report := metric.StatusReport{}
+	if srv.statusFunc != nil {
+		report = srv.statusFunc()
+	}
+
+	if req.URL.Query().Get("format") == "html" || strings.Contains(req.Header.Get("Accept"), "text/html") {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusTemplate.Execute(writer, report); err != nil {
+			srv.log.Warn("Failed to render status page because of error ", err)
+		}
+		return
+	}
+
+	asString, err := json.Marshal(report)
+	if err != nil {
+		srv.log.Warn("Failed to marshal status because of error ", err)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(asString)
+}
+
+// handleTapRequest streams the metrics flowing through the pipeline as
+// newline delimited JSON until the client disconnects. Metrics are shown
+// as read from the collector and as received by each handler, along with
+// the representation the handler sends. They can be filtered with these
+// query parameters:
+//
+//	stage=collected|handler
+//	collector=<canonical collector name>
+//	handler=<handler name>
+//	name=<regex on the metric name>
+//	dimension=<name>:<value> (can be repeated)
+//	limit=<stop after that many metrics>
+func (srv InternalServer) handleTapRequest(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter, err := parseTapFilter(query)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if val := query.Get("limit"); val != "" {
+		if limit, err = strconv.Atoi(val); err != nil {
+			http.Error(writer, "Invalid limit: "+val, http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	subscription := srv.tap.Subscribe(filter, tapBufferSize)
+	defer srv.tap.Unsubscribe(subscription)
+	srv.log.Info("Started tapping metrics for ", req.RemoteAddr, ": ", req.URL.RawQuery)
+
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for sent := 0; limit <= 0 || sent < limit; sent++ {
+		select {
+		case event := <-subscription.Events:
+			asString, err := marshalTapEvent(event)
+			if err != nil {
+				srv.log.Debug("Skipped tapped metric ", event.Metric, ": ", err)
+				continue
+			}
+			if _, err := writer.Write(append(asString, '\n')); err != nil {
+				srv.log.Warn("Stopped tapping metrics for ", req.RemoteAddr, ": ", err)
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			srv.log.Info("Stopped tapping metrics for ", req.RemoteAddr, ", dropped ",
+				subscription.Dropped(), " metrics")
+			return
+		}
+	}
+}
+
+// tapEventJSON is a TapEvent whose metric value and handler representation
+// can hold NaN and infinite values, which JSON does not support
+type tapEventJSON struct {
+	metric.TapEvent
+	Metric    tapMetricJSON `json:"metric"`
+	Formatted string        `json:"formatted,omitempty"`
+}
+
+type tapMetricJSON struct {
+	metric.Metric
+	Value interface{} `json:"value"`
+}
+
+// marshalTapEvent encodes the event, writing non finite values as strings
+func marshalTapEvent(event metric.TapEvent) ([]byte, error) {
+	asString, err := json.Marshal(event)
+	if _, unsupported := err.(*json.UnsupportedValueError); !unsupported {
+		return asString, err
+	}
+
+	sanitized := tapEventJSON{
+		TapEvent: event,
+		Metric:   tapMetricJSON{Metric: event.Metric, Value: event.Metric.Value},
+	}
+	if math.IsNaN(event.Metric.Value) || math.IsInf(event.Metric.Value, 0) {
+		sanitized.Metric.Value = strconv.FormatFloat(event.Metric.Value, 'g', -1, 64)
+	}
+	if event.Formatted != nil {
+		sanitized.Formatted = fmt.Sprintf("%v", event.Formatted)
+	}
+	return json.Marshal(sanitized)
+}
+
+func parseTapFilter(query url.Values) (filter metric.TapFilter, err error) {
+	filter.Stage = query.Get("stage")
+	switch filter.Stage {
+	case "", metric.TapCollected, metric.TapHandler:
+	default:
+		return filter, fmt.Errorf("Invalid stage: %s", filter.Stage)
+	}
+
+	filter.Collector = query.Get("collector")
+	filter.Handler = query.Get("handler")
+
+	if name := query.Get("name"); name != "" {
+		if filter.Name, err = regexp.Compile(name); err != nil {
+			return filter, fmt.Errorf("Invalid name regex: %s", err)
+		}
+	}
+
+	for _, dimension := range query["dimension"] {
+		parts := strings.SplitN(dimension, ":", 2)
+		if len(parts) != 2 {
+			return filter, fmt.Errorf("Invalid dimension, expected name:value: %s", dimension)
+		}
+		if filter.Dimensions == nil {
+			filter.Dimensions = make(map[string]string)
+		}
+		filter.Dimensions[parts[0]] = parts[1]
+	}
+	return filter, nil
+}
+
 func prometheusInternalMetricsMemoryStats(writer http.ResponseWriter) {
-	prometheusInternalMetricsEmit("memory", "", writer, getMemoryStats(), true)
+	prometheusInternalMetricsEmit("memory", "", writer, getMemoryStats(), map[string]bool{})
 }
 
 func prometheusInternalMetricsHandlerStats(writer http.ResponseWriter, stats map[string]metric.InternalMetrics) {
-	emitTypes := true
-	for hName, h := range stats {
-		prometheusInternalMetricsEmit("handler", fmt.Sprintf("handler=\"%s\"", hName), writer, &h, emitTypes)
-		emitTypes = false
-	}
+	prometheusInternalMetricsStats(writer, "handler", stats)
 }
 
 func prometheusInternalMetricsCollectorStats(writer http.ResponseWriter, stats map[string]metric.InternalMetrics) {
-	emitTypes := true
-	for hName, h := range stats {
-		prometheusInternalMetricsEmit("handler", fmt.Sprintf("collector=\"%s\"", hName), writer, &h, emitTypes)
-		emitTypes = false
+	prometheusInternalMetricsStats(writer, "collector", stats)
+}
+
+// prometheusInternalMetricsStats emits the stats of each handler or
+// collector labelled with its name, in a stable order
+func prometheusInternalMetricsStats(writer http.ResponseWriter, kind string, stats map[string]metric.InternalMetrics) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	typesEmitted := map[string]bool{}
+	for _, name := range names {
+		im := stats[name]
+		prometheusInternalMetricsEmit(kind, fmt.Sprintf("%s=%q", kind, name), writer, &im, typesEmitted)
 	}
 }
 
-func prometheusInternalMetricsEmit(namePrefix string, dimString string, writer http.ResponseWriter, im *metric.InternalMetrics, emitTypes bool) {
-	for k, v := range im.Counters {
-		metricName := strings.Replace(k, ".", "_", -1)
-		if emitTypes {
-			io.WriteString(writer, fmt.Sprintf("# TYPE fullerite_internal_%s_%s_count counter\n", namePrefix, metricName))
+// prometheusInternalMetricsEmit writes the internal metrics in the
+// Prometheus text format. The TYPE line of a metric is only written the
+// first time it is seen in typesEmitted.
+func prometheusInternalMetricsEmit(namePrefix string, dimString string, writer http.ResponseWriter, im *metric.InternalMetrics, typesEmitted map[string]bool) {
+	emitType := func(metricName, metricType string) {
+		if !typesEmitted[metricName] {
+			io.WriteString(writer, fmt.Sprintf("# TYPE %s %s\n", metricName, metricType))
+			typesEmitted[metricName] = true
 		}
-		io.WriteString(writer, fmt.Sprintf("fullerite_internal_%s_%s_count{%s} %f\n", namePrefix, metricName, dimString, v))
 	}
-	for k, v := range im.Gauges {
-		metricName := strings.Replace(k, ".", "_", -1)
-		if emitTypes {
-			io.WriteString(writer, fmt.Sprintf("# TYPE fullerite_internal_%s_%s gauge\n", namePrefix, metricName))
+
+	for _, k := range sortedKeys(im.Counters) {
+		metricName := fmt.Sprintf("fullerite_internal_%s_%s_count", namePrefix, strings.Replace(k, ".", "_", -1))
+		emitType(metricName, "counter")
+		io.WriteString(writer, fmt.Sprintf("%s{%s} %f\n", metricName, dimString, im.Counters[k]))
+	}
+	for _, k := range sortedKeys(im.Gauges) {
+		metricName := fmt.Sprintf("fullerite_internal_%s_%s", namePrefix, strings.Replace(k, ".", "_", -1))
+		emitType(metricName, "gauge")
+		io.WriteString(writer, fmt.Sprintf("%s{%s} %f\n", metricName, dimString, im.Gauges[k]))
+	}
+
+	histogramNames := make([]string, 0, len(im.Histograms))
+	for k := range im.Histograms {
+		histogramNames = append(histogramNames, k)
+	}
+	sort.Strings(histogramNames)
+
+	labelPrefix := ""
+	if dimString != "" {
+		labelPrefix = dimString + ","
+	}
+	for _, k := range histogramNames {
+		h := im.Histograms[k]
+		metricName := fmt.Sprintf("fullerite_internal_%s_%s_seconds", namePrefix, strings.Replace(k, ".", "_", -1))
+		emitType(metricName, "histogram")
+		for _, bucket := range h.Buckets {
+			io.WriteString(writer, fmt.Sprintf("%s_bucket{%sle=\"%s\"} %d\n",
+				metricName, labelPrefix, strconv.FormatFloat(bucket.UpperBound, 'g', -1, 64), bucket.Count))
 		}
-		io.WriteString(writer, fmt.Sprintf("fullerite_internal_%s_%s{%s} %f\n", namePrefix, metricName, dimString, v))
+		io.WriteString(writer, fmt.Sprintf("%s_bucket{%sle=\"+Inf\"} %d\n", metricName, labelPrefix, h.Count))
+		io.WriteString(writer, fmt.Sprintf("%s_sum{%s} %f\n", metricName, dimString, h.Sum))
+		io.WriteString(writer, fmt.Sprintf("%s_count{%s} %d\n", metricName, dimString, h.Count))
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
 
 // responsible for querying each handler and serializing the total response
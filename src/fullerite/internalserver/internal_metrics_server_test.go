@@ -10,7 +10,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -24,12 +27,12 @@ type testHandler struct {
 	name    string
 }
 
-func (h testHandler) Run()                             {} // noop
-func (h testHandler) Configure(map[string]interface{}) {} // noop
-func (h testHandler) InternalMetrics() metric.InternalMetrics {
+func (h *testHandler) Run()                             {} // noop
+func (h *testHandler) Configure(map[string]interface{}) {} // noop
+func (h *testHandler) InternalMetrics() metric.InternalMetrics {
 	return h.metrics
 }
-func (h testHandler) Name() string {
+func (h *testHandler) Name() string {
 	return h.name
 }
 
@@ -139,7 +142,7 @@ func TestRespondToHttp(t *testing.T) {
 		map[string]float64{"secondgauge": 890.2},
 	)
 	testHandlers := []handler.Handler{h1, h2}
-	srv := New(cfg, handlerStatFunc(testHandlers), collectorStatFunc)
+	srv := New(cfg, handlerStatFunc(testHandlers), collectorStatFunc, nil, nil)
 	go srv.Run()
 
 	time.Sleep(100 * time.Millisecond) // wait for server to bind on port
@@ -177,3 +180,213 @@ func TestRespondToHttp(t *testing.T) {
 	assert.Equal(t, 456.2, handlerMetrics.Counters["secondcounter"])
 	assert.Equal(t, 890.2, handlerMetrics.Gauges["secondgauge"])
 }
+
+func TestPrometheusInternalMetricsStats(t *testing.T) {
+	stats := map[string]metric.InternalMetrics{
+		"second": {
+			Counters: map[string]float64{"metricsSent": 2},
+			Gauges:   map[string]float64{},
+		},
+		"first": {
+			Counters: map[string]float64{"metricsSent": 1},
+			Gauges:   map[string]float64{"intervalLength": 10},
+			Histograms: map[string]metric.HistogramSnapshot{
+				"emissionLatency": {
+					Buckets: []metric.HistogramBucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}},
+					Count:   3,
+					Sum:     4.5,
+				},
+			},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	prometheusInternalMetricsHandlerStats(rec, stats)
+	assert.Equal(t, `# TYPE fullerite_internal_handler_metricsSent_count counter
+fullerite_internal_handler_metricsSent_count{handler="first"} 1.000000
+# TYPE fullerite_internal_handler_intervalLength gauge
+fullerite_internal_handler_intervalLength{handler="first"} 10.000000
+# TYPE fullerite_internal_handler_emissionLatency_seconds histogram
+fullerite_internal_handler_emissionLatency_seconds_bucket{handler="first",le="0.5"} 1
+fullerite_internal_handler_emissionLatency_seconds_bucket{handler="first",le="1"} 2
+fullerite_internal_handler_emissionLatency_seconds_bucket{handler="first",le="+Inf"} 3
+fullerite_internal_handler_emissionLatency_seconds_sum{handler="first"} 4.500000
+fullerite_internal_handler_emissionLatency_seconds_count{handler="first"} 3
+fullerite_internal_handler_metricsSent_count{handler="second"} 2.000000
+`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	prometheusInternalMetricsCollectorStats(rec, map[string]metric.InternalMetrics{
+		"Test": {Counters: map[string]float64{"fullerite.collector_datapoints": 3}},
+	})
+	assert.Equal(t, `# TYPE fullerite_internal_collector_fullerite_collector_datapoints_count counter
+fullerite_internal_collector_fullerite_collector_datapoints_count{collector="Test"} 3.000000
+`, rec.Body.String())
+}
+
+func TestHandleCardinalityRequest(t *testing.T) {
+	testLog := l.WithField("testing", "internal_server")
+
+	var requestedTop int
+	srv := InternalServer{
+		log: testLog,
+		cardinalityStatFunc: func(n int) map[string]metric.CardinalityReport {
+			requestedTop = n
+			return map[string]metric.CardinalityReport{
+				"Test": metric.CardinalityReport{
+					Series:     2,
+					Limited:    1,
+					TopMetrics: []metric.SeriesCount{{Metric: "foo", Count: 2}},
+				},
+			}
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleCardinalityRequest(rec, httptest.NewRequest("GET", "/cardinality", nil))
+	assert.Equal(t, defaultCardinalityTop, requestedTop)
+
+	var reports map[string]metric.CardinalityReport
+	err := json.Unmarshal(rec.Body.Bytes(), &reports)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, reports["Test"].Series)
+	assert.Equal(t, uint64(1), reports["Test"].Limited)
+	assert.Equal(t, "foo", reports["Test"].TopMetrics[0].Metric)
+
+	rec = httptest.NewRecorder()
+	srv.handleCardinalityRequest(rec, httptest.NewRequest("GET", "/cardinality?top=3", nil))
+	assert.Equal(t, 3, requestedTop)
+}
+
+func TestHandleStatusRequest(t *testing.T) {
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		statusFunc: func() metric.StatusReport {
+			return metric.StatusReport{
+				Collectors: []metric.ComponentStatus{
+					{Name: "Test", Interval: 10, LastError: "something <broke>"},
+				},
+				Handlers: []metric.ComponentStatus{
+					{Name: "Graphite", Targets: []string{"localhost:2003"}},
+				},
+			}
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleStatusRequest(rec, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report metric.StatusReport
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "Test", report.Collectors[0].Name)
+	assert.Equal(t, []string{"localhost:2003"}, report.Handlers[0].Targets)
+
+	rec = httptest.NewRecorder()
+	srv.handleStatusRequest(rec, httptest.NewRequest("GET", "/status?format=html", nil))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "localhost:2003")
+	assert.Contains(t, rec.Body.String(), "something &lt;broke&gt;")
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec = httptest.NewRecorder()
+	srv.handleStatusRequest(rec, req)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+}
+
+func TestParseTapFilter(t *testing.T) {
+	query, _ := url.ParseQuery("stage=handler&collector=Test&handler=Graphite&name=^foo&dimension=host:a&dimension=env:b:c")
+	filter, err := parseTapFilter(query)
+	assert.Nil(t, err)
+	assert.Equal(t, metric.TapHandler, filter.Stage)
+	assert.Equal(t, "Test", filter.Collector)
+	assert.Equal(t, "Graphite", filter.Handler)
+	assert.Equal(t, "^foo", filter.Name.String())
+	assert.Equal(t, map[string]string{"host": "a", "env": "b:c"}, filter.Dimensions)
+
+	for _, invalid := range []string{"stage=nope", "name=(", "dimension=host"} {
+		query, _ = url.ParseQuery(invalid)
+		_, err = parseTapFilter(query)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestHandleTapRequest(t *testing.T) {
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		tap: metric.NewTap(),
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleTapRequest))
+	defer ts.Close()
+
+	go func() {
+		for !srv.tap.Active() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		srv.tap.Publish(metric.TapEvent{Stage: metric.TapCollected, Collector: "Test", Metric: metric.New("bar")})
+		srv.tap.Publish(metric.TapEvent{Stage: metric.TapCollected, Collector: "Test", Metric: metric.New("foo")})
+		srv.tap.Publish(metric.TapEvent{
+			Stage:     metric.TapHandler,
+			Collector: "Test",
+			Handler:   "Graphite",
+			Metric:    metric.New("foo"),
+			Formatted: "foo 0.000000 1\n",
+		})
+	}()
+
+	rsp, err := http.Get(ts.URL + "?name=^foo$&limit=2")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, rsp.StatusCode)
+
+	decoder := json.NewDecoder(rsp.Body)
+	defer rsp.Body.Close()
+
+	var event metric.TapEvent
+	assert.Nil(t, decoder.Decode(&event))
+	assert.Equal(t, metric.TapCollected, event.Stage)
+	assert.Equal(t, "foo", event.Metric.Name)
+
+	assert.Nil(t, decoder.Decode(&event))
+	assert.Equal(t, metric.TapHandler, event.Stage)
+	assert.Equal(t, "Graphite", event.Handler)
+	assert.Equal(t, "foo 0.000000 1\n", event.Formatted)
+
+	// the stream ends after limit metrics
+	assert.NotNil(t, decoder.Decode(&event))
+	assert.False(t, srv.tap.Active())
+}
+
+func TestMarshalTapEvent(t *testing.T) {
+	m := metric.WithValue("foo", 1)
+	asString, err := marshalTapEvent(metric.TapEvent{Stage: metric.TapCollected, Metric: m})
+	assert.Nil(t, err)
+	assert.Contains(t, string(asString), `"value":1`)
+
+	// NaN and infinite values are not valid JSON and are sent as strings
+	m = metric.WithValue("foo", math.NaN())
+	asString, err = marshalTapEvent(metric.TapEvent{
+		Stage:     metric.TapHandler,
+		Handler:   "Test",
+		Metric:    m,
+		Formatted: struct{ Value float64 }{math.Inf(1)},
+	})
+	assert.Nil(t, err)
+	var event map[string]interface{}
+	assert.Nil(t, json.Unmarshal(asString, &event))
+	assert.Equal(t, "Test", event["handler"])
+	assert.Equal(t, "foo", event["metric"].(map[string]interface{})["name"])
+	assert.Equal(t, "NaN", event["metric"].(map[string]interface{})["value"])
+	assert.Equal(t, "{+Inf}", event["formatted"])
+}
+
+func TestHandleTapRequestInvalidFilter(t *testing.T) {
+	srv := InternalServer{
+		log: l.WithField("testing", "internal_server"),
+		tap: metric.NewTap(),
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleTapRequest(rec, httptest.NewRequest("GET", "/tap?name=(", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
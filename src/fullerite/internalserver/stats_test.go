@@ -0,0 +1,73 @@
+package internalserver
+
+import (
+	"fullerite/handler"
+	"fullerite/metric"
+
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// The internal stats are read by the internal server while the collectors
+// and handlers update them, this is meant to be run with -race
+func TestConcurrentStatsUpdates(t *testing.T) {
+	log := l.WithField("testing", "internal_server_stats")
+	stats := metric.NewStatsRegistry()
+
+	channel := make(chan metric.Metric)
+	h := handler.NewTest(channel, 1, 1, time.Second, log)
+	h.SetCollectorEndpoints(map[string]handler.CollectorEnd{})
+	go h.Run()
+
+	srv := InternalServer{
+		log: log,
+		handlerStatFunc: func() map[string]metric.InternalMetrics {
+			return map[string]metric.InternalMetrics{h.Name(): h.InternalMetrics()}
+		},
+		collectorStatFunc: func() map[string]metric.InternalMetrics {
+			collectorStats := stats.CollectorMetrics()
+			for name, count := range h.DroppedPerCollector() {
+				if im, exists := collectorStats[name]; exists {
+					im.Counters["fullerite.collector_dropped"] = float64(count)
+				}
+			}
+			return collectorStats
+		},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			m := metric.New("test")
+			m.AddDimension("collector", "Test")
+			channel <- m
+			stats.Collector("Test").AddDatapoints(1)
+			stats.Collector("Test").ObserveCollection(time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.handleInternalMetricsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+			srv.handlePrometheusMetricsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics/prometheus", nil))
+		}
+	}()
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	srv.handleInternalMetricsRequest(rec, httptest.NewRequest("GET", "/metrics", nil))
+	var rsp ResponseFormat
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &rsp))
+	assert.Equal(t, 100.0, rsp.Collectors["Test"].Counters["fullerite.collector_datapoints"])
+	assert.Equal(t, uint64(100), rsp.Collectors["Test"].Histograms["fullerite.collection_duration"].Count)
+	assert.Contains(t, rsp.Handlers, "Test")
+	channel <- metric.Metric{}
+}
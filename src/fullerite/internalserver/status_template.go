@@ -0,0 +1,59 @@
+package internalserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+)
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format(time.RFC3339)
+	},
+	"json": func(v interface{}) string {
+		asString, _ := json.MarshalIndent(v, "", "  ")
+		return string(asString)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>fullerite status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+pre { margin: 0; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+{{define "components"}}
+<table>
+<tr>
+<th>Name</th><th>Interval</th><th>Last run</th><th>Duration (s)</th>
+<th>Last success</th><th>Last error</th><th>Targets</th><th>Config</th>
+</tr>
+{{range .}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{.Interval}}</td>
+<td>{{time .LastRunStart}}</td>
+<td>{{printf "%.3f" .LastRunDuration}}</td>
+<td>{{time .LastSuccess}}</td>
+<td class="error">{{if .LastError}}{{time .LastErrorTime}}: {{.LastError}}{{end}}</td>
+<td>{{range .Targets}}{{.}}<br>{{end}}</td>
+<td><pre>{{json .Config}}</pre></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+<h1>Collectors</h1>
+{{template "components" .Collectors}}
+<h1>Handlers</h1>
+{{template "components" .Handlers}}
+</body>
+</html>
+`))
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fullerite/collector"
 	"fullerite/config"
 	"fullerite/handler"
 	"fullerite/internalserver"
@@ -117,18 +118,19 @@ func start(ctx *cli.Context) {
 	hook := NewLogErrorHook(handlers)
 	log.Logger.Hooks.Add(hook)
 
+	stats := metric.NewStatsRegistry()
 	startHandlers(handlers)
-	collectors := startCollectors(c)
-
-	collectorStatChan := make(chan metric.CollectorEmission)
+	collectors := startCollectors(c, stats)
 
 	internalServer := internalserver.New(c,
 		handlerStatFunc(handlers),
-		readCollectorStat(collectorStatChan))
+		collectorStatFunc(stats, handlers),
+		cardinalityStatFunc(collectors),
+		componentStatus.report)
 
 	go internalServer.Run()
 
-	readFromCollectors(collectors, handlers, collectorStatChan)
+	readFromCollectors(collectors, handlers, stats)
 
 	<-quit
 }
@@ -143,26 +145,37 @@ func handlerStatFunc(handlers []handler.Handler) internalserver.InternalStatFunc
 	}
 }
 
-func readCollectorStat(collectorStatChan <-chan metric.CollectorEmission) internalserver.InternalStatFunc {
-	collectorMetrics := map[string]uint64{}
-	go func() {
-		for collectorMetric := range collectorStatChan {
-			collectorMetrics[collectorMetric.Name] = collectorMetric.EmissionCount
-		}
-	}()
+// collectorStatFunc returns the stats of each canonical collector name,
+// along with the number of its metrics the handlers failed to send
+func collectorStatFunc(stats *metric.StatsRegistry, handlers []handler.Handler) internalserver.InternalStatFunc {
 	return func() map[string]metric.InternalMetrics {
-		metricStats := map[string]metric.InternalMetrics{}
-		for k, v := range collectorMetrics {
-			counters := map[string]float64{"fullerite.collector_datapoints": float64(v)}
-			gauges := map[string]float64{}
-
-			m := metric.InternalMetrics{
-				Counters: counters,
-				Gauges:   gauges,
+		collectorStats := stats.CollectorMetrics()
+		for _, im := range collectorStats {
+			im.Counters["fullerite.collector_dropped"] = 0
+		}
+		for _, inst := range handlers {
+			for name, count := range inst.DroppedPerCollector() {
+				im, exists := collectorStats[name]
+				if !exists {
+					im = *metric.NewInternalMetrics()
+					collectorStats[name] = im
+				}
+				im.Counters["fullerite.collector_dropped"] += float64(count)
+			}
+		}
+		return collectorStats
+	}
+}
+
+func cardinalityStatFunc(collectors []collector.Collector) internalserver.CardinalityStatFunc {
+	return func(n int) map[string]metric.CardinalityReport {
+		reports := map[string]metric.CardinalityReport{}
+		for _, inst := range collectors {
+			for name, report := range inst.CardinalityReport(n) {
+				reports[name] = report
 			}
-			metricStats[k] = m
 		}
-		return metricStats
+		return reports
 	}
 }
 
@@ -187,14 +200,15 @@ func visualize(ctx *cli.Context) {
 	configMap["collectorFile"] = collectorFile
 
 	// Start collector and handlers
-	collector := startCollector("AdHoc", c, configMap)
+	stats := metric.NewStatsRegistry()
+	collector := startCollector("AdHoc", c, configMap, stats)
 	c.Collectors = []string{"AdHoc"}
 	c.DiamondCollectors = []string{}
 	handlers := createHandlers(c)
 	startHandlers(handlers)
 
 	// Read the metrics from the AdHoc collector
-	go readFromCollector(collector, handlers)
+	go readFromCollector(collector, handlers, stats)
 
 	// Stop collecting after `die-after` duration expires
 	quitChannel := make(chan bool, 1)
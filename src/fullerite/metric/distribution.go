@@ -0,0 +1,110 @@
+package metric
+
+import (
+	"math"
+	"strconv"
+)
+
+// Distribution is the value of a Histogram metric. It keeps the buckets of
+// a histogram, or the quantiles of a summary, together so that handlers
+// able to send distributions can do it natively. Counts are cumulative like
+// in the Prometheus exposition format.
+type Distribution struct {
+	Count float64 `json:"count"`
+	Sum   float64 `json:"sum"`
+	// Buckets are sorted by upper bound, the +Inf bucket is Count
+	Buckets   []DistributionBucket   `json:"buckets,omitempty"`
+	Quantiles []DistributionQuantile `json:"quantiles,omitempty"`
+	// Rollups are the series flattened instead of the buckets, quantiles,
+	// sum and count, for distributions read from the rollups of Dropwizard
+	// timers and histograms
+	Rollups []DistributionRollup `json:"-"`
+}
+
+// DistributionBucket is the number of observations less than or equal to
+// UpperBound
+type DistributionBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      float64 `json:"count"`
+}
+
+// DistributionQuantile is the value below which the Quantile fraction of
+// the observations fall
+type DistributionQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// DistributionRollup is one series of a flattened distribution, named
+// after the metric followed by Suffix and with Dimensions added to its own
+type DistributionRollup struct {
+	Suffix     string
+	MetricType string
+	Value      float64
+	Dimensions map[string]string
+}
+
+// WithDistribution returns a metric of type Histogram holding the
+// distribution. Its value is the number of observations.
+func WithDistribution(name string, distribution Distribution) Metric {
+	m := New(name)
+	m.MetricType = Histogram
+	m.Value = distribution.Count
+	m.Distribution = &distribution
+	return m
+}
+
+// Flatten returns the metrics handlers without native support for
+// distributions send instead of a Histogram metric: one cumulative counter
+// per bucket with an "le" dimension ending with the +Inf bucket, one gauge
+// per quantile with a "quantile" dimension, then the sum and the count.
+// The sum keeps the name of the metric. Distributions with rollups are
+// flattened into them instead. Other metrics are returned as is.
+func (m Metric) Flatten() []Metric {
+	d := m.Distribution
+	if d == nil {
+		return []Metric{m}
+	}
+	if len(d.Rollups) > 0 {
+		flattened := make([]Metric, 0, len(d.Rollups))
+		for _, rollup := range d.Rollups {
+			flat := New(m.Name + rollup.Suffix)
+			flat.MetricType = rollup.MetricType
+			flat.Value = rollup.Value
+			flat.AddDimensions(m.Dimensions)
+			flat.AddDimensions(rollup.Dimensions)
+			flattened = append(flattened, flat)
+		}
+		return flattened
+	}
+
+	flattened := make([]Metric, 0, len(d.Buckets)+len(d.Quantiles)+3)
+	add := func(name, metricType string, value float64, dimension, dimensionValue string) {
+		flat := New(name)
+		flat.MetricType = metricType
+		flat.Value = value
+		flat.AddDimensions(m.Dimensions)
+		if dimension != "" {
+			flat.AddDimension(dimension, dimensionValue)
+		}
+		flattened = append(flattened, flat)
+	}
+
+	if len(d.Buckets) > 0 {
+		for _, bucket := range d.Buckets {
+			add(m.Name+"_bucket", CumulativeCounter, bucket.Count, "le", FormatBound(bucket.UpperBound))
+		}
+		add(m.Name+"_bucket", CumulativeCounter, d.Count, "le", FormatBound(math.Inf(1)))
+	}
+	for _, quantile := range d.Quantiles {
+		add(m.Name+"_quantile", Gauge, quantile.Value, "quantile", FormatBound(quantile.Quantile))
+	}
+	add(m.Name, CumulativeCounter, d.Sum, "", "")
+	add(m.Name+"_count", CumulativeCounter, d.Count, "", "")
+	return flattened
+}
+
+// FormatBound formats a bucket bound or a quantile like Prometheus does
+func FormatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
@@ -1,9 +1,12 @@
 package metric
 
-// InternalMetrics holds the key:value pairs for counters/gauges
+import "time"
+
+// InternalMetrics holds the key:value pairs for counters/gauges/histograms
 type InternalMetrics struct {
-	Counters map[string]float64
-	Gauges   map[string]float64
+	Counters   map[string]float64
+	Gauges     map[string]float64
+	Histograms map[string]HistogramSnapshot `json:",omitempty"`
 }
 
 // NewInternalMetrics initializes the internal components of InternalMetrics
@@ -14,8 +17,44 @@ func NewInternalMetrics() *InternalMetrics {
 	return inst
 }
 
-// CollectorEmission counts collector emissions
-type CollectorEmission struct {
+// CardinalityReport describes the series tracked for a collector by the
+// cardinality limiter along with its top offenders
+type CardinalityReport struct {
+	Series        int
+	Limited       uint64
+	TopMetrics    []SeriesCount
+	TopDimensions []SeriesCount
+}
+
+// SeriesCount is the number of unique series seen for a metric name, or
+// the number of unique values seen for one dimension of a metric name
+type SeriesCount struct {
+	Metric    string
+	Dimension string `json:",omitempty"`
+	Count     int
+}
+
+// RunStatus is the outcome of the last run of a collector or the last
+// emission of a handler
+type RunStatus struct {
+	LastRunStart    time.Time
+	LastRunDuration float64
+	LastSuccess     time.Time
+}
+
+// ComponentStatus is the runtime status of a configured collector or handler
+type ComponentStatus struct {
+	RunStatus
 	Name          string
-	EmissionCount uint64
+	Interval      int
+	Config        map[string]interface{}
+	Targets       []string
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// StatusReport lists the status of every configured collector and handler
+type StatusReport struct {
+	Collectors []ComponentStatus
+	Handlers   []ComponentStatus
 }
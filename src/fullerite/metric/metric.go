@@ -5,6 +5,7 @@ const (
 	Gauge             = "gauge"
 	Counter           = "counter"
 	CumulativeCounter = "cumcounter"
+	Histogram         = "histogram"
 )
 
 // Metric type holds all the information for a single metric data
@@ -14,6 +15,8 @@ type Metric struct {
 	MetricType string            `json:"type"`
 	Value      float64           `json:"value"`
 	Dimensions map[string]string `json:"dimensions"`
+	// Distribution is only set on Histogram metrics
+	Distribution *Distribution `json:"distribution,omitempty"`
 }
 
 // New returns a new metric with name. Default metric type is "gauge"
@@ -83,7 +86,8 @@ func (m *Metric) ZeroValue() bool {
 	return (len(m.Name) == 0) &&
 		(len(m.MetricType) == 0) &&
 		(m.Value == 0.0) &&
-		(len(m.Dimensions) == 0)
+		(len(m.Dimensions) == 0) &&
+		(m.Distribution == nil)
 }
 
 // Sentinel is a metric value which forces handler to flush
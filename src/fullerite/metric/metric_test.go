@@ -101,3 +101,83 @@ func TestAddDimensions(t *testing.T) {
 
 	assert.Equal(t, m1, m2)
 }
+
+func TestFlattenDistribution(t *testing.T) {
+	m := metric.WithDistribution("latency", metric.Distribution{
+		Count: 3,
+		Sum:   1.5,
+		Buckets: []metric.DistributionBucket{
+			{UpperBound: 0.5, Count: 1},
+			{UpperBound: 1, Count: 2},
+		},
+	})
+	m.AddDimension("host", "foo")
+
+	flattened := m.Flatten()
+	assert.Len(t, flattened, 5)
+	expected := []struct {
+		name  string
+		le    string
+		value float64
+	}{
+		{"latency_bucket", "0.5", 1},
+		{"latency_bucket", "1", 2},
+		{"latency_bucket", "+Inf", 3},
+		{"latency", "", 1.5},
+		{"latency_count", "", 3},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.name, flattened[i].Name)
+		assert.Equal(t, metric.CumulativeCounter, flattened[i].MetricType)
+		assert.Equal(t, e.value, flattened[i].Value)
+		assert.Equal(t, "foo", flattened[i].Dimensions["host"])
+		le, ok := flattened[i].GetDimensionValue("le")
+		assert.Equal(t, e.le != "", ok)
+		assert.Equal(t, e.le, le)
+		assert.Nil(t, flattened[i].Distribution)
+	}
+	// the dimensions of the distribution are left untouched
+	assert.Equal(t, map[string]string{"host": "foo"}, m.Dimensions)
+
+	gauge := metric.WithValue("gauge", 1)
+	assert.Equal(t, []metric.Metric{gauge}, gauge.Flatten())
+}
+
+func TestFlattenSummary(t *testing.T) {
+	m := metric.WithDistribution("latency", metric.Distribution{
+		Count:     10,
+		Sum:       20,
+		Quantiles: []metric.DistributionQuantile{{Quantile: 0.99, Value: 4}},
+	})
+
+	flattened := m.Flatten()
+	assert.Len(t, flattened, 3)
+	assert.Equal(t, "latency_quantile", flattened[0].Name)
+	assert.Equal(t, metric.Gauge, flattened[0].MetricType)
+	assert.Equal(t, map[string]string{"quantile": "0.99"}, flattened[0].Dimensions)
+	assert.Equal(t, 4.0, flattened[0].Value)
+	assert.Equal(t, "latency", flattened[1].Name)
+	assert.Equal(t, "latency_count", flattened[2].Name)
+}
+
+func TestFlattenRollups(t *testing.T) {
+	m := metric.WithDistribution("requests", metric.Distribution{
+		Count:     10,
+		Quantiles: []metric.DistributionQuantile{{Quantile: 0.99, Value: 4}},
+		Rollups: []metric.DistributionRollup{
+			{Suffix: ".count", MetricType: metric.CumulativeCounter, Value: 10, Dimensions: map[string]string{"rollup": "count"}},
+			{Suffix: ".p99", MetricType: metric.Gauge, Value: 4, Dimensions: map[string]string{"rollup": "p99"}},
+		},
+	})
+	m.AddDimension("host", "foo")
+
+	flattened := m.Flatten()
+	assert.Len(t, flattened, 2)
+	assert.Equal(t, "requests.count", flattened[0].Name)
+	assert.Equal(t, metric.CumulativeCounter, flattened[0].MetricType)
+	assert.Equal(t, 10.0, flattened[0].Value)
+	assert.Equal(t, map[string]string{"host": "foo", "rollup": "count"}, flattened[0].Dimensions)
+	assert.Equal(t, "requests.p99", flattened[1].Name)
+	assert.Equal(t, map[string]string{"host": "foo", "rollup": "p99"}, flattened[1].Dimensions)
+	assert.Nil(t, flattened[1].Distribution)
+}
@@ -0,0 +1,152 @@
+package metric
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds in seconds of the LatencyHistogram buckets
+var LatencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// LatencyHistogram counts durations in the LatencyBuckets. It is safe for
+// concurrent use and its zero value is ready to use.
+type LatencyHistogram struct {
+	// the last bucket counts the durations above the last bound
+	buckets  [len(LatencyBuckets) + 1]uint64
+	count    uint64
+	sumNanos uint64
+}
+
+// HistogramBucket is the number of observations less than or equal to UpperBound
+type HistogramBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// HistogramSnapshot is a point in time copy of a histogram. Buckets are
+// cumulative, observations above the last bound are only part of Count.
+type HistogramSnapshot struct {
+	Buckets []HistogramBucket
+	Count   uint64
+	Sum     float64
+}
+
+// Observe adds a duration to the histogram
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(LatencyBuckets[:], seconds)
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	if d > 0 {
+		atomic.AddUint64(&h.sumNanos, uint64(d))
+	}
+}
+
+// Snapshot returns the current bucket counts
+func (h *LatencyHistogram) Snapshot() HistogramSnapshot {
+	snapshot := HistogramSnapshot{
+		Buckets: make([]HistogramBucket, len(LatencyBuckets)),
+		Sum:     time.Duration(atomic.LoadUint64(&h.sumNanos)).Seconds(),
+	}
+	var cumulative uint64
+	for i, bound := range LatencyBuckets {
+		cumulative += atomic.LoadUint64(&h.buckets[i])
+		snapshot.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	snapshot.Count = cumulative + atomic.LoadUint64(&h.buckets[len(LatencyBuckets)])
+	return snapshot
+}
+
+// CollectorStats are the internal stats of a canonical collector. All the
+// counters are updated atomically. The metrics dropped by the handlers are
+// tracked by each handler.
+type CollectorStats struct {
+	datapoints  uint64
+	blacklisted uint64
+	limited     uint64
+
+	collectionDuration LatencyHistogram
+}
+
+// AddDatapoints counts metrics read from the collector and sent to the handlers
+func (s *CollectorStats) AddDatapoints(n uint64) {
+	atomic.AddUint64(&s.datapoints, n)
+}
+
+// AddBlacklisted counts metrics dropped by the collector metrics blacklist
+func (s *CollectorStats) AddBlacklisted(n uint64) {
+	atomic.AddUint64(&s.blacklisted, n)
+}
+
+// AddLimited counts metrics dropped by the cardinality limiter
+func (s *CollectorStats) AddLimited(n uint64) {
+	atomic.AddUint64(&s.limited, n)
+}
+
+// ObserveCollection records how long a collection took
+func (s *CollectorStats) ObserveCollection(d time.Duration) {
+	s.collectionDuration.Observe(d)
+}
+
+// InternalMetrics returns the current values of the stats
+func (s *CollectorStats) InternalMetrics() InternalMetrics {
+	im := InternalMetrics{
+		Counters: map[string]float64{
+			"fullerite.collector_datapoints":  float64(atomic.LoadUint64(&s.datapoints)),
+			"fullerite.collector_blacklisted": float64(atomic.LoadUint64(&s.blacklisted)),
+			"fullerite.collector_limited":     float64(atomic.LoadUint64(&s.limited)),
+		},
+		Gauges: map[string]float64{},
+	}
+	if duration := s.collectionDuration.Snapshot(); duration.Count > 0 {
+		im.Histograms = map[string]HistogramSnapshot{
+			"fullerite.collection_duration": duration,
+		}
+	}
+	return im
+}
+
+// StatsRegistry holds the stats of every canonical collector name seen by
+// a fullerite instance
+type StatsRegistry struct {
+	mu         sync.RWMutex
+	collectors map[string]*CollectorStats
+}
+
+// NewStatsRegistry creates an empty registry
+func NewStatsRegistry() *StatsRegistry {
+	return &StatsRegistry{collectors: make(map[string]*CollectorStats)}
+}
+
+// Collector returns the stats of a canonical collector name, creating them
+// the first time the name is seen
+func (r *StatsRegistry) Collector(name string) *CollectorStats {
+	r.mu.RLock()
+	stats, exists := r.collectors[name]
+	r.mu.RUnlock()
+	if exists {
+		return stats
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stats, exists = r.collectors[name]; !exists {
+		stats = new(CollectorStats)
+		r.collectors[name] = stats
+	}
+	return stats
+}
+
+// CollectorMetrics returns the internal metrics of every collector
+func (r *StatsRegistry) CollectorMetrics() map[string]InternalMetrics {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metrics := make(map[string]InternalMetrics, len(r.collectors))
+	for name, stats := range r.collectors {
+		metrics[name] = stats.InternalMetrics()
+	}
+	return metrics
+}
@@ -0,0 +1,78 @@
+package metric
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h LatencyHistogram
+	assert.Equal(t, uint64(0), h.Snapshot().Count)
+
+	h.Observe(time.Millisecond)
+	h.Observe(10 * time.Millisecond)
+	h.Observe(200 * time.Millisecond)
+	h.Observe(time.Minute)
+
+	snapshot := h.Snapshot()
+	assert.Equal(t, uint64(4), snapshot.Count)
+	assert.InDelta(t, 60.211, snapshot.Sum, 1e-9)
+	assert.Equal(t, len(LatencyBuckets), len(snapshot.Buckets))
+	assert.Equal(t, HistogramBucket{UpperBound: 0.005, Count: 1}, snapshot.Buckets[0])
+	// bounds are inclusive
+	assert.Equal(t, HistogramBucket{UpperBound: 0.01, Count: 2}, snapshot.Buckets[1])
+	assert.Equal(t, HistogramBucket{UpperBound: 0.25, Count: 3}, snapshot.Buckets[5])
+	assert.Equal(t, HistogramBucket{UpperBound: 30, Count: 3}, snapshot.Buckets[len(LatencyBuckets)-1])
+}
+
+func TestStatsRegistryCollector(t *testing.T) {
+	r := NewStatsRegistry()
+	assert.True(t, r.Collector("Test") == r.Collector("Test"))
+
+	r.Collector("Test").AddDatapoints(3)
+	r.Collector("Test").AddBlacklisted(2)
+	r.Collector("Other").AddLimited(4)
+
+	metrics := r.CollectorMetrics()
+	assert.Equal(t, 2, len(metrics))
+	assert.Equal(t, InternalMetrics{
+		Counters: map[string]float64{
+			"fullerite.collector_datapoints":  3,
+			"fullerite.collector_blacklisted": 2,
+			"fullerite.collector_limited":     0,
+		},
+		Gauges: map[string]float64{},
+	}, metrics["Test"])
+	assert.Equal(t, 4.0, metrics["Other"].Counters["fullerite.collector_limited"])
+	assert.Nil(t, metrics["Other"].Histograms)
+
+	r.Collector("Test").ObserveCollection(2 * time.Second)
+	histogram := r.CollectorMetrics()["Test"].Histograms["fullerite.collection_duration"]
+	assert.Equal(t, uint64(1), histogram.Count)
+	assert.Equal(t, 2.0, histogram.Sum)
+}
+
+func TestStatsRegistryConcurrentUpdates(t *testing.T) {
+	r := NewStatsRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Collector("Test").AddDatapoints(1)
+				r.Collector("Test").ObserveCollection(time.Millisecond)
+				r.CollectorMetrics()
+			}
+		}()
+	}
+	wg.Wait()
+
+	metrics := r.CollectorMetrics()["Test"]
+	assert.Equal(t, 1000.0, metrics.Counters["fullerite.collector_datapoints"])
+	assert.Equal(t, uint64(1000), metrics.Histograms["fullerite.collection_duration"].Count)
+}
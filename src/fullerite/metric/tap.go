@@ -0,0 +1,164 @@
+package metric
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// The points of the pipeline at which metrics can be tapped
+const (
+	// TapCollected is a metric as read from a collector, after the
+	// collector prefix and blacklists have been applied
+	TapCollected = "collected"
+	// TapHandler is a metric as received by a handler, along with the
+	// handler specific representation it is going to be sent as
+	TapHandler = "handler"
+)
+
+// DefaultTap is the tap the collectors and handlers publish metrics to
+var DefaultTap = NewTap()
+
+// TapEvent is a metric seen at some point of the pipeline
+type TapEvent struct {
+	Stage     string      `json:"stage"`
+	Collector string      `json:"collector"`
+	Handler   string      `json:"handler,omitempty"`
+	Metric    Metric      `json:"metric"`
+	Formatted interface{} `json:"formatted,omitempty"`
+}
+
+// TapFilter selects the events a subscriber receives. Empty fields match
+// every event.
+type TapFilter struct {
+	Stage      string
+	Collector  string
+	Handler    string
+	Name       *regexp.Regexp
+	Dimensions map[string]string
+}
+
+// Matches returns true if the event passes the filter
+func (f TapFilter) Matches(e TapEvent) bool {
+	if !f.matchesSource(e.Stage, e.Collector, e.Handler) {
+		return false
+	}
+	if f.Name != nil && !f.Name.MatchString(e.Metric.Name) {
+		return false
+	}
+	for k, v := range f.Dimensions {
+		if value, ok := e.Metric.Dimensions[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f TapFilter) matchesSource(stage, collector, handler string) bool {
+	if f.Stage != "" && f.Stage != stage {
+		return false
+	}
+	if f.Collector != "" && f.Collector != collector {
+		return false
+	}
+	// handler filters only apply to metrics which reached a handler
+	if f.Handler != "" && f.Handler != handler {
+		return false
+	}
+	return true
+}
+
+// TapSubscription receives the events matching its filter on Events.
+// Events are dropped rather than slowing down the pipeline if the
+// subscriber does not keep up.
+type TapSubscription struct {
+	Events  chan TapEvent
+	filter  TapFilter
+	dropped uint64
+}
+
+// Dropped returns the number of events dropped because Events was full
+func (s *TapSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Tap lets subscribers watch the metrics flowing through the pipeline
+type Tap struct {
+	mu          sync.RWMutex
+	subscribers map[*TapSubscription]bool
+	active      int32
+}
+
+// NewTap creates a tap without subscribers
+func NewTap() *Tap {
+	return &Tap{subscribers: make(map[*TapSubscription]bool)}
+}
+
+// Subscribe returns a subscription receiving events matching the filter,
+// buffering up to bufferSize of them
+func (t *Tap) Subscribe(filter TapFilter, bufferSize int) *TapSubscription {
+	s := &TapSubscription{
+		Events: make(chan TapEvent, bufferSize),
+		filter: filter,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers[s] = true
+	atomic.StoreInt32(&t.active, int32(len(t.subscribers)))
+	return s
+}
+
+// Unsubscribe stops sending events to the subscription
+func (t *Tap) Unsubscribe(s *TapSubscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subscribers, s)
+	atomic.StoreInt32(&t.active, int32(len(t.subscribers)))
+}
+
+// Active is a cheap check whether anyone subscribed to the tap
+func (t *Tap) Active() bool {
+	return atomic.LoadInt32(&t.active) > 0
+}
+
+// Wants returns true if any subscriber is interested in metrics from the
+// given stage, collector and handler. It allows to skip formatting
+// metrics nobody is going to look at.
+func (t *Tap) Wants(stage, collector, handler string) bool {
+	if !t.Active() {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for s := range t.subscribers {
+		if s.filter.matchesSource(stage, collector, handler) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends the event to every matching subscriber without blocking
+func (t *Tap) Publish(e TapEvent) {
+	if !t.Active() {
+		return
+	}
+
+	// the dimensions may still be modified by the sender once published
+	e.Metric.Dimensions = e.Metric.GetDimensions(nil)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for s := range t.subscribers {
+		if !s.filter.Matches(e) {
+			continue
+		}
+		select {
+		case s.Events <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
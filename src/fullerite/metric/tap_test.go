@@ -0,0 +1,90 @@
+package metric
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTapInactiveWithoutSubscribers(t *testing.T) {
+	tap := NewTap()
+	assert.False(t, tap.Active())
+	assert.False(t, tap.Wants(TapCollected, "Test", ""))
+
+	// publishing without subscribers is a noop
+	tap.Publish(TapEvent{Stage: TapCollected, Collector: "Test", Metric: New("foo")})
+}
+
+func TestTapSubscribe(t *testing.T) {
+	tap := NewTap()
+	s := tap.Subscribe(TapFilter{}, 10)
+	assert.True(t, tap.Active())
+
+	m := New("foo")
+	m.AddDimension("host", "a")
+	tap.Publish(TapEvent{Stage: TapCollected, Collector: "Test", Metric: m})
+
+	e := <-s.Events
+	assert.Equal(t, TapCollected, e.Stage)
+	assert.Equal(t, "Test", e.Collector)
+	assert.Equal(t, m, e.Metric)
+
+	// the published metric does not share dimensions with the sender
+	m.AddDimension("host", "b")
+	assert.Equal(t, "a", e.Metric.Dimensions["host"])
+
+	tap.Unsubscribe(s)
+	assert.False(t, tap.Active())
+}
+
+func TestTapFilter(t *testing.T) {
+	m := New("foo.bar")
+	m.AddDimension("host", "a")
+	collected := TapEvent{Stage: TapCollected, Collector: "Test", Metric: m}
+	handled := TapEvent{Stage: TapHandler, Collector: "Test", Handler: "Graphite", Metric: m}
+
+	tests := []struct {
+		filter    TapFilter
+		collected bool
+		handled   bool
+	}{
+		{TapFilter{}, true, true},
+		{TapFilter{Stage: TapCollected}, true, false},
+		{TapFilter{Stage: TapHandler}, false, true},
+		{TapFilter{Collector: "Test"}, true, true},
+		{TapFilter{Collector: "Other"}, false, false},
+		{TapFilter{Handler: "Graphite"}, false, true},
+		{TapFilter{Handler: "SignalFx"}, false, false},
+		{TapFilter{Name: regexp.MustCompile(`^foo\.`)}, true, true},
+		{TapFilter{Name: regexp.MustCompile(`^bar`)}, false, false},
+		{TapFilter{Dimensions: map[string]string{"host": "a"}}, true, true},
+		{TapFilter{Dimensions: map[string]string{"host": "b"}}, false, false},
+		{TapFilter{Dimensions: map[string]string{"region": "a"}}, false, false},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.collected, test.filter.Matches(collected), "filter %d", i)
+		assert.Equal(t, test.handled, test.filter.Matches(handled), "filter %d", i)
+	}
+}
+
+func TestTapWants(t *testing.T) {
+	tap := NewTap()
+	tap.Subscribe(TapFilter{Handler: "Graphite"}, 10)
+
+	assert.True(t, tap.Wants(TapHandler, "Test", "Graphite"))
+	assert.False(t, tap.Wants(TapHandler, "Test", "SignalFx"))
+	assert.False(t, tap.Wants(TapCollected, "Test", ""))
+}
+
+func TestTapDropsWhenFull(t *testing.T) {
+	tap := NewTap()
+	s := tap.Subscribe(TapFilter{}, 1)
+
+	tap.Publish(TapEvent{Stage: TapCollected, Metric: New("foo")})
+	tap.Publish(TapEvent{Stage: TapCollected, Metric: New("bar")})
+
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.Equal(t, "foo", (<-s.Events).Metric.Name)
+}
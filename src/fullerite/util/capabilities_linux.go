@@ -0,0 +1,76 @@
+// +build linux
+
+package util
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// The capabilities used by the collectors, see linux/capability.h
+const (
+	CapDacReadSearch = 2
+	CapSysPtrace     = 19
+)
+
+// _LINUX_CAPABILITY_VERSION_3, two 32 bits words per set
+const linuxCapabilityVersion3 = 0x20080522
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// WithCapabilities runs fn with the capabilities raised in the effective set
+// of its thread. They must be in the permitted set, given to the binary with
+// setcap for instance, and are dropped once fn returns.
+func WithCapabilities(fn func(), capabilities ...int) error {
+	runtime.LockOSThread()
+
+	header := capHeader{version: linuxCapabilityVersion3}
+	var saved [2]capData
+	if err := capCall(syscall.SYS_CAPGET, &header, &saved); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+
+	raised := saved
+	for _, capability := range capabilities {
+		word, bit := capability/32, uint32(1)<<uint(capability%32)
+		if word >= len(raised) || raised[word].permitted&bit == 0 {
+			runtime.UnlockOSThread()
+			return fmt.Errorf("capability %d is not permitted", capability)
+		}
+		raised[word].effective |= bit
+	}
+	if err := capCall(syscall.SYS_CAPSET, &header, &raised); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+
+	fn()
+
+	// a thread which kept the capabilities must not run other goroutines,
+	// it exits with the goroutine when left locked
+	if err := capCall(syscall.SYS_CAPSET, &header, &saved); err != nil {
+		return err
+	}
+	runtime.UnlockOSThread()
+	return nil
+}
+
+func capCall(trap uintptr, header *capHeader, data *[2]capData) error {
+	_, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(header)), uintptr(unsafe.Pointer(data)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
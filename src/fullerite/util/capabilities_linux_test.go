@@ -0,0 +1,24 @@
+// +build linux
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCapabilities(t *testing.T) {
+	called := false
+	assert.Nil(t, WithCapabilities(func() { called = true }))
+	assert.True(t, called)
+
+	called = false
+	err := WithCapabilities(func() { called = true }, CapSysPtrace)
+	// only permitted when running as root or with setcap
+	assert.Equal(t, err == nil, called)
+
+	called = false
+	assert.NotNil(t, WithCapabilities(func() { called = true }, 100))
+	assert.False(t, called)
+}
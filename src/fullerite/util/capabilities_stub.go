@@ -0,0 +1,16 @@
+// +build !linux
+
+package util
+
+import "fmt"
+
+// The capabilities used by the collectors
+const (
+	CapDacReadSearch = 2
+	CapSysPtrace     = 19
+)
+
+// WithCapabilities is only available on linux
+func WithCapabilities(fn func(), capabilities ...int) error {
+	return fmt.Errorf("capabilities are only available on linux")
+}
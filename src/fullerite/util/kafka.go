@@ -0,0 +1,430 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The Kafka APIs used, at versions without tagged fields
+const (
+	kafkaListOffsetsKey     int16 = 2
+	kafkaMetadataKey        int16 = 3
+	kafkaOffsetFetchKey     int16 = 9
+	kafkaFindCoordinatorKey int16 = 10
+	kafkaListGroupsKey      int16 = 16
+
+	kafkaListOffsetsVersion     int16 = 1
+	kafkaMetadataVersion        int16 = 1
+	kafkaOffsetFetchVersion     int16 = 2
+	kafkaFindCoordinatorVersion int16 = 0
+	kafkaListGroupsVersion      int16 = 0
+
+	// the timestamp of ListOffsets asking for the high watermark
+	kafkaLatestOffset int64 = -1
+)
+
+var errKafkaShortResponse = errors.New("kafka: response too short")
+
+// KafkaError is an error code returned by a broker
+type KafkaError int16
+
+var kafkaErrorNames = map[KafkaError]string{
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	14: "COORDINATOR_LOAD_IN_PROGRESS",
+	15: "COORDINATOR_NOT_AVAILABLE",
+	16: "NOT_COORDINATOR",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	30: "GROUP_AUTHORIZATION_FAILED",
+	31: "CLUSTER_AUTHORIZATION_FAILED",
+}
+
+func (e KafkaError) Error() string {
+	if name, exists := kafkaErrorNames[e]; exists {
+		return "kafka: " + name
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// KafkaBroker is a broker of the cluster
+type KafkaBroker struct {
+	ID   int32
+	Host string
+	Port int32
+}
+
+// Addr returns the host:port of the broker
+func (b KafkaBroker) Addr() string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(int(b.Port)))
+}
+
+// KafkaPartition is a partition of a topic with its leader and replicas,
+// Leader is -1 when the partition is offline
+type KafkaPartition struct {
+	ID       int32
+	Leader   int32
+	Replicas []int32
+	ISR      []int32
+	Err      KafkaError
+}
+
+// KafkaTopic is a topic of the cluster metadata
+type KafkaTopic struct {
+	Name       string
+	Internal   bool
+	Partitions []KafkaPartition
+	Err        KafkaError
+}
+
+// KafkaMetadata is the brokers and topics of the cluster
+type KafkaMetadata struct {
+	Brokers      []KafkaBroker
+	ControllerID int32
+	Topics       []KafkaTopic
+}
+
+// KafkaOffsets are offsets by topic and partition
+type KafkaOffsets map[string]map[int32]int64
+
+func (o KafkaOffsets) set(topic string, partition int32, offset int64) {
+	if _, exists := o[topic]; !exists {
+		o[topic] = map[int32]int64{}
+	}
+	o[topic][partition] = offset
+}
+
+// KafkaConn is a minimal client of the Kafka protocol, enough to read the
+// metadata, the high watermarks and the committed offsets of the consumer
+// groups
+type KafkaConn struct {
+	conn          net.Conn
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+// DialKafka connects to the broker at addr, every request of the
+// connection has to complete within timeout
+func DialKafka(addr string, clientID string, timeout time.Duration) (*KafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaConn{conn: conn, clientID: clientID, timeout: timeout}, nil
+}
+
+// Close closes the connection
+func (c *KafkaConn) Close() error {
+	return c.conn.Close()
+}
+
+// Metadata returns the brokers and the topics named, all the topics when
+// topics is nil
+func (c *KafkaConn) Metadata(topics []string) (KafkaMetadata, error) {
+	e := &kafkaEncoder{}
+	if topics == nil {
+		e.putArrayLen(-1)
+	} else {
+		e.putArrayLen(len(topics))
+		for _, topic := range topics {
+			e.putString(topic)
+		}
+	}
+
+	metadata := KafkaMetadata{}
+	d, err := c.roundTrip(kafkaMetadataKey, kafkaMetadataVersion, e.Bytes())
+	if err != nil {
+		return metadata, err
+	}
+
+	for i := d.arrayLen(); i > 0; i-- {
+		broker := KafkaBroker{ID: d.int32(), Host: d.string(), Port: d.int32()}
+		d.string() // rack
+		metadata.Brokers = append(metadata.Brokers, broker)
+	}
+	metadata.ControllerID = d.int32()
+	for i := d.arrayLen(); i > 0; i-- {
+		topic := KafkaTopic{Err: KafkaError(d.int16()), Name: d.string(), Internal: d.int8() != 0}
+		for j := d.arrayLen(); j > 0; j-- {
+			topic.Partitions = append(topic.Partitions, KafkaPartition{
+				Err:      KafkaError(d.int16()),
+				ID:       d.int32(),
+				Leader:   d.int32(),
+				Replicas: d.int32Array(),
+				ISR:      d.int32Array(),
+			})
+		}
+		metadata.Topics = append(metadata.Topics, topic)
+	}
+	return metadata, d.err
+}
+
+// ListOffsets returns the high watermarks of the partitions, which have to
+// be led by the broker. The partitions in error are left out.
+func (c *KafkaConn) ListOffsets(partitions map[string][]int32) (KafkaOffsets, error) {
+	e := &kafkaEncoder{}
+	e.putInt32(-1) // replica id of the consumers
+	e.putArrayLen(len(partitions))
+	for topic, ids := range partitions {
+		e.putString(topic)
+		e.putArrayLen(len(ids))
+		for _, id := range ids {
+			e.putInt32(id)
+			e.putInt64(kafkaLatestOffset)
+		}
+	}
+
+	d, err := c.roundTrip(kafkaListOffsetsKey, kafkaListOffsetsVersion, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := KafkaOffsets{}
+	for i := d.arrayLen(); i > 0; i-- {
+		topic := d.string()
+		for j := d.arrayLen(); j > 0; j-- {
+			id, code := d.int32(), d.int16()
+			d.int64() // timestamp
+			offset := d.int64()
+			if code == 0 {
+				offsets.set(topic, id, offset)
+			}
+		}
+	}
+	return offsets, d.err
+}
+
+// ListGroups returns the consumer groups coordinated by the broker
+func (c *KafkaConn) ListGroups() ([]string, error) {
+	d, err := c.roundTrip(kafkaListGroupsKey, kafkaListGroupsVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code := d.int16(); code != 0 && d.err == nil {
+		return nil, KafkaError(code)
+	}
+	groups := []string{}
+	for i := d.arrayLen(); i > 0; i-- {
+		groups = append(groups, d.string())
+		d.string() // protocol type
+	}
+	return groups, d.err
+}
+
+// FindCoordinator returns the broker coordinating the consumer group
+func (c *KafkaConn) FindCoordinator(group string) (KafkaBroker, error) {
+	e := &kafkaEncoder{}
+	e.putString(group)
+
+	d, err := c.roundTrip(kafkaFindCoordinatorKey, kafkaFindCoordinatorVersion, e.Bytes())
+	if err != nil {
+		return KafkaBroker{}, err
+	}
+
+	code := d.int16()
+	broker := KafkaBroker{ID: d.int32(), Host: d.string(), Port: d.int32()}
+	if d.err == nil && code != 0 {
+		return KafkaBroker{}, KafkaError(code)
+	}
+	return broker, d.err
+}
+
+// OffsetFetch returns the offsets committed by the consumer group, it has
+// to be sent to the coordinator of the group. The partitions without a
+// committed offset are left out.
+func (c *KafkaConn) OffsetFetch(group string) (KafkaOffsets, error) {
+	e := &kafkaEncoder{}
+	e.putString(group)
+	e.putArrayLen(-1) // all the topics
+
+	d, err := c.roundTrip(kafkaOffsetFetchKey, kafkaOffsetFetchVersion, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := KafkaOffsets{}
+	for i := d.arrayLen(); i > 0; i-- {
+		topic := d.string()
+		for j := d.arrayLen(); j > 0; j-- {
+			id, offset := d.int32(), d.int64()
+			d.string() // metadata
+			if code := d.int16(); code == 0 && offset >= 0 {
+				offsets.set(topic, id, offset)
+			}
+		}
+	}
+	if code := d.int16(); code != 0 && d.err == nil {
+		return nil, KafkaError(code)
+	}
+	return offsets, d.err
+}
+
+// roundTrip sends a request and returns the decoder of the response body
+func (c *KafkaConn) roundTrip(apiKey int16, version int16, body []byte) (*kafkaDecoder, error) {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	c.correlationID++
+
+	header := &kafkaEncoder{}
+	header.putInt16(apiKey)
+	header.putInt16(version)
+	header.putInt32(c.correlationID)
+	header.putString(c.clientID)
+
+	request := &kafkaEncoder{}
+	request.putInt32(int32(header.Len() + len(body)))
+	request.Write(header.Bytes())
+	request.Write(body)
+	if _, err := c.conn.Write(request.Bytes()); err != nil {
+		return nil, err
+	}
+
+	response, err := readKafkaFrame(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	d := &kafkaDecoder{buf: response}
+	if correlationID := d.int32(); d.err == nil && correlationID != c.correlationID {
+		return nil, fmt.Errorf("kafka: response %d to request %d", correlationID, c.correlationID)
+	}
+	return d, d.err
+}
+
+// readKafkaFrame reads a size prefixed request or response
+func readKafkaFrame(reader io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("kafka: invalid frame size %d", size)
+	}
+	frame := make([]byte, size)
+	_, err := io.ReadFull(reader, frame)
+	return frame, err
+}
+
+// kafkaEncoder writes the primitive types of the protocol
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) putInt8(v int8) {
+	e.WriteByte(byte(v))
+}
+
+func (e *kafkaEncoder) putInt16(v int16) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) putInt32(v int32) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) putInt64(v int64) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) putString(v string) {
+	e.putInt16(int16(len(v)))
+	e.WriteString(v)
+}
+
+func (e *kafkaEncoder) putArrayLen(n int) {
+	e.putInt32(int32(n))
+}
+
+func (e *kafkaEncoder) putInt32Array(values []int32) {
+	e.putArrayLen(len(values))
+	for _, v := range values {
+		e.putInt32(v)
+	}
+}
+
+// kafkaDecoder reads the primitive types of the protocol, it keeps the
+// first error and returns zero values after it
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = errKafkaShortResponse
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.read(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.read(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.read(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.read(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a string, a null one being empty
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.read(int(n)))
+}
+
+// arrayLen reads the length of an array, a null one being empty. The
+// length is bounded by the bytes left so that a corrupt response cannot
+// make the callers loop for long.
+func (d *kafkaDecoder) arrayLen() int {
+	n := int(d.int32())
+	if n > len(d.buf) {
+		d.err = errKafkaShortResponse
+	}
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (d *kafkaDecoder) int32Array() []int32 {
+	values := []int32{}
+	for i := d.arrayLen(); i > 0; i-- {
+		values = append(values, d.int32())
+	}
+	return values
+}
@@ -0,0 +1,274 @@
+package util
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKafka is a broker answering the requests with the handler of their
+// api key, which is given the request body and returns the response body
+type fakeKafka struct {
+	listener net.Listener
+	handlers map[int16]func(d *kafkaDecoder, e *kafkaEncoder)
+	clientID string
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeKafka{listener: listener, handlers: map[int16]func(d *kafkaDecoder, e *kafkaEncoder){}}
+	go s.serve()
+	return s
+}
+
+func (s *fakeKafka) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeKafka) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		request, err := readKafkaFrame(conn)
+		if err != nil {
+			return
+		}
+		d := &kafkaDecoder{buf: request}
+		apiKey := d.int16()
+		d.int16() // version
+		correlationID := d.int32()
+		s.clientID = d.string()
+
+		body := &kafkaEncoder{}
+		body.putInt32(correlationID)
+		if handler, exists := s.handlers[apiKey]; exists {
+			handler(d, body)
+		}
+		response := &kafkaEncoder{}
+		response.putInt32(int32(body.Len()))
+		response.Write(body.Bytes())
+		conn.Write(response.Bytes())
+	}
+}
+
+func (s *fakeKafka) dial(t *testing.T) *KafkaConn {
+	conn, err := DialKafka(s.listener.Addr().String(), "fullerite", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestKafkaMetadata(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	var requested []string
+	s.handlers[kafkaMetadataKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		requested = nil
+		for i := d.arrayLen(); i > 0; i-- {
+			requested = append(requested, d.string())
+		}
+
+		e.putArrayLen(2)
+		for i, host := range []string{"kafka-1", "kafka-2"} {
+			e.putInt32(int32(i + 1))
+			e.putString(host)
+			e.putInt32(9092)
+			e.putInt16(-1) // null rack
+		}
+		e.putInt32(2)
+		e.putArrayLen(1)
+		e.putInt16(0)
+		e.putString("events")
+		e.putInt8(0)
+		e.putArrayLen(2)
+		e.putInt16(0)
+		e.putInt32(0)
+		e.putInt32(1)
+		e.putInt32Array([]int32{1, 2})
+		e.putInt32Array([]int32{1})
+		e.putInt16(5)
+		e.putInt32(1)
+		e.putInt32(-1)
+		e.putInt32Array([]int32{2})
+		e.putInt32Array([]int32{})
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	metadata, err := conn.Metadata(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, requested)
+	assert.Equal(t, "fullerite", s.clientID)
+	assert.Equal(t, KafkaMetadata{
+		Brokers:      []KafkaBroker{{ID: 1, Host: "kafka-1", Port: 9092}, {ID: 2, Host: "kafka-2", Port: 9092}},
+		ControllerID: 2,
+		Topics: []KafkaTopic{{
+			Name: "events",
+			Partitions: []KafkaPartition{
+				{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+				{ID: 1, Leader: -1, Replicas: []int32{2}, ISR: []int32{}, Err: KafkaError(5)},
+			},
+		}},
+	}, metadata)
+	assert.Equal(t, "kafka-1:9092", metadata.Brokers[0].Addr())
+
+	_, err = conn.Metadata([]string{"events", "logs"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"events", "logs"}, requested)
+}
+
+func TestKafkaListOffsets(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	s.handlers[kafkaListOffsetsKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		assert.Equal(t, int32(-1), d.int32())
+		assert.Equal(t, 1, d.arrayLen())
+		topic := d.string()
+		count := d.arrayLen()
+
+		e.putArrayLen(1)
+		e.putString(topic)
+		e.putArrayLen(count)
+		for i := 0; i < count; i++ {
+			partition := d.int32()
+			assert.Equal(t, kafkaLatestOffset, d.int64())
+			e.putInt32(partition)
+			e.putInt16(int16(partition) * 6) // NOT_LEADER_OR_FOLLOWER for partition 1
+			e.putInt64(-1)
+			e.putInt64(int64(100 + partition))
+		}
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	offsets, err := conn.ListOffsets(map[string][]int32{"events": {0, 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, KafkaOffsets{"events": {0: 100}}, offsets)
+}
+
+func TestKafkaListGroups(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	code := int16(0)
+	s.handlers[kafkaListGroupsKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		e.putInt16(code)
+		e.putArrayLen(2)
+		e.putString("indexer")
+		e.putString("consumer")
+		e.putString("connect-sink")
+		e.putString("connect")
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	groups, err := conn.ListGroups()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"indexer", "connect-sink"}, groups)
+
+	code = 15
+	_, err = conn.ListGroups()
+	assert.Equal(t, KafkaError(15), err)
+	assert.Equal(t, "kafka: COORDINATOR_NOT_AVAILABLE", err.Error())
+}
+
+func TestKafkaFindCoordinator(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	s.handlers[kafkaFindCoordinatorKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		if d.string() == "indexer" {
+			e.putInt16(0)
+			e.putInt32(2)
+			e.putString("kafka-2")
+			e.putInt32(9093)
+		} else {
+			e.putInt16(15)
+			e.putInt32(-1)
+			e.putString("")
+			e.putInt32(-1)
+		}
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	coordinator, err := conn.FindCoordinator("indexer")
+	assert.Nil(t, err)
+	assert.Equal(t, KafkaBroker{ID: 2, Host: "kafka-2", Port: 9093}, coordinator)
+
+	_, err = conn.FindCoordinator("unknown")
+	assert.Equal(t, KafkaError(15), err)
+}
+
+func TestKafkaOffsetFetch(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	s.handlers[kafkaOffsetFetchKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		group := d.string()
+		assert.Equal(t, 0, d.arrayLen())
+		if group != "indexer" {
+			e.putArrayLen(0)
+			e.putInt16(16)
+			return
+		}
+
+		e.putArrayLen(1)
+		e.putString("events")
+		e.putArrayLen(3)
+		for partition, offset := range []int64{90, -1, 40} {
+			e.putInt32(int32(partition))
+			e.putInt64(offset)
+			e.putString("")
+			e.putInt16(int16(partition/2) * 3)
+		}
+		e.putInt16(0)
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	offsets, err := conn.OffsetFetch("indexer")
+	assert.Nil(t, err)
+	assert.Equal(t, KafkaOffsets{"events": {0: 90}}, offsets)
+
+	_, err = conn.OffsetFetch("elsewhere")
+	assert.Equal(t, KafkaError(16), err)
+}
+
+func TestKafkaInvalidResponses(t *testing.T) {
+	s := newFakeKafka(t)
+	defer s.listener.Close()
+	s.handlers[kafkaMetadataKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		e.putArrayLen(1000)
+	}
+	s.handlers[kafkaListGroupsKey] = func(d *kafkaDecoder, e *kafkaEncoder) {
+		e.putInt16(0)
+		e.putArrayLen(1)
+		e.putString("indexer")
+	}
+
+	conn := s.dial(t)
+	defer conn.Close()
+	_, err := conn.Metadata(nil)
+	assert.Equal(t, errKafkaShortResponse, err)
+	_, err = conn.ListGroups()
+	assert.Equal(t, errKafkaShortResponse, err)
+}
+
+func TestDialKafkaRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = DialKafka(addr, "fullerite", time.Second)
+	assert.NotNil(t, err)
+}
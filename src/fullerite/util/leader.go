@@ -0,0 +1,239 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+)
+
+// zkSequenceLength is the length of the sequence number ZooKeeper appends
+// to the names of sequential nodes
+const zkSequenceLength = 10
+
+var (
+	lookupHost = net.LookupHost
+	dialZk     = dialZooKeeper
+)
+
+// WithDefaultPort appends the port to a host given without one
+func WithDefaultPort(host string, port int) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// LeaderOf returns the leader advertised by the leader endpoint of a
+// Marathon (v2/leader) or Chronos (leader) host
+func LeaderOf(host string, endpoint string, client http.Client) (string, error) {
+	contents, err := GetWrapper(getLeaderURL(host, endpoint), client)
+	if err != nil {
+		return "", err
+	}
+
+	var leadermap map[string]string
+	if decodeErr := json.Unmarshal(contents, &leadermap); decodeErr != nil {
+		return "", decodeErr
+	}
+
+	leader, exists := leadermap["leader"]
+	if !exists {
+		return "", leaderError{"Could not find \"leader\" in leader JSON"}
+	}
+	return leader, nil
+}
+
+// MesosLeader returns the host:port of the elected Mesos master, asking
+// each master in turn where /redirect points to, or reading its
+// /master/state for older masters.
+func MesosLeader(masters []string, client http.Client) (string, error) {
+	noRedirect := client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	err := leaderError{"No mesos master to ask for the leader"}
+	for _, master := range masters {
+		leader, redirectErr := mesosRedirectLeader(master, noRedirect)
+		if redirectErr == nil {
+			return leader, nil
+		}
+		leader, stateErr := mesosStateLeader(master, client)
+		if stateErr == nil {
+			return leader, nil
+		}
+		err = leaderError{fmt.Sprintf("Could not find the leader from %s: %s, %s", master, redirectErr, stateErr)}
+	}
+	return "", err
+}
+
+func mesosRedirectLeader(master string, client http.Client) (string, error) {
+	r, err := client.Get(getLeaderURL(master, "redirect"))
+	if err != nil {
+		return "", err
+	}
+	r.Body.Close()
+
+	location := r.Header.Get("Location")
+	if location == "" {
+		return "", httpError{r.StatusCode}
+	}
+	// the location has no scheme, like //10.0.0.1:5050
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", leaderError{"No host in the /redirect location " + location}
+	}
+	return u.Host, nil
+}
+
+func mesosStateLeader(master string, client http.Client) (string, error) {
+	contents, err := GetWrapper(getLeaderURL(master, "master/state"), client)
+	if err != nil {
+		return "", err
+	}
+
+	var state struct {
+		Leader     string          `json:"leader"`
+		LeaderInfo mesosMasterInfo `json:"leader_info"`
+	}
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return "", err
+	}
+	if leader := state.LeaderInfo.hostPort(); leader != "" {
+		return leader, nil
+	}
+	// the leader is a libprocess PID like master@10.0.0.1:5050
+	if i := strings.Index(state.Leader, "@"); i >= 0 {
+		return state.Leader[i+1:], nil
+	}
+	return "", leaderError{"No elected mesos master"}
+}
+
+// mesosMasterInfo is the JSON serialization of MasterInfo found in
+// /master/state and in the ZooKeeper election nodes
+type mesosMasterInfo struct {
+	Hostname string `json:"hostname"`
+	Port     int    `json:"port"`
+	Address  struct {
+		Hostname string `json:"hostname"`
+		IP       string `json:"ip"`
+		Port     int    `json:"port"`
+	} `json:"address"`
+}
+
+func (info mesosMasterInfo) hostPort() string {
+	switch {
+	case info.Address.Hostname != "" && info.Address.Port != 0:
+		return net.JoinHostPort(info.Address.Hostname, strconv.Itoa(info.Address.Port))
+	case info.Address.IP != "" && info.Address.Port != 0:
+		return net.JoinHostPort(info.Address.IP, strconv.Itoa(info.Address.Port))
+	case info.Hostname != "" && info.Port != 0:
+		return net.JoinHostPort(info.Hostname, strconv.Itoa(info.Port))
+	}
+	return ""
+}
+
+// ZooKeeperLeader returns the host:port of the leader elected under path.
+// The leader is the sequential child with the lowest sequence number, its
+// content is either a Mesos MasterInfo in JSON or the host:port itself as
+// written by the Curator leader latch of Marathon and Chronos.
+func ZooKeeperLeader(servers []string, path string, timeout time.Duration) (string, error) {
+	zk, err := dialZk(servers, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer zk.close()
+
+	children, err := zk.children(path)
+	if err != nil {
+		return "", err
+	}
+	candidate := electedChild(children)
+	if candidate == "" {
+		return "", leaderError{"No leader elected under " + path}
+	}
+
+	data, err := zk.data(strings.TrimSuffix(path, "/") + "/" + candidate)
+	if err != nil {
+		return "", err
+	}
+
+	var info mesosMasterInfo
+	if json.Unmarshal(data, &info) == nil {
+		if leader := info.hostPort(); leader != "" {
+			return leader, nil
+		}
+		return "", leaderError{"No address in the leader node " + candidate}
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// electedChild returns the sequential node with the lowest sequence
+// number, Mesos JSON nodes first since Mesos also writes protobuf ones
+func electedChild(children []string) string {
+	candidates := []string{}
+	for _, child := range children {
+		if strings.HasPrefix(child, "json.info_") {
+			candidates = append(candidates, child)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, child := range children {
+			if len(child) >= zkSequenceLength {
+				if _, err := strconv.ParseUint(child[len(child)-zkSequenceLength:], 10, 64); err == nil {
+					candidates = append(candidates, child)
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i][len(candidates[i])-zkSequenceLength:] < candidates[j][len(candidates[j])-zkSequenceLength:]
+	})
+	return candidates[0]
+}
+
+// IsLocalHost checks if host, with or without a port, is this host: its
+// hostname, or a name or an IP resolving to an address of a local interface
+func IsLocalHost(host string, log *l.Entry) (bool, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	h, err := hostname()
+	if err != nil {
+		return false, err
+	}
+	if host == h {
+		return true, nil
+	}
+
+	ips := []string{host}
+	if net.ParseIP(host) == nil {
+		if ips, err = lookupHost(host); err != nil {
+			log.Debug("Cannot resolve ", host, ": ", err)
+			return false, nil
+		}
+	}
+	for _, ip := range ips {
+		isOurIP, err := IPInHostInterfaces(ip, log)
+		if err != nil || isOurIP {
+			return isOurIP, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,259 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDefaultPort(t *testing.T) {
+	assert.Equal(t, "host:5050", WithDefaultPort("host", 5050))
+	assert.Equal(t, "host:5051", WithDefaultPort("host:5051", 5050))
+	assert.Equal(t, "10.0.0.1:5050", WithDefaultPort("10.0.0.1", 5050))
+}
+
+func TestMesosLeaderRedirect(t *testing.T) {
+	oldGetLeaderURL := getLeaderURL
+	defer func() { getLeaderURL = oldGetLeaderURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/redirect", r.URL.Path)
+		w.Header().Set("Location", "//10.0.0.2:5050")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+	getLeaderURL = func(host string, endpoint string) string { return ts.URL + "/" + endpoint }
+
+	leader, err := MesosLeader([]string{"master1"}, http.Client{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.2:5050", leader)
+}
+
+func TestMesosLeaderState(t *testing.T) {
+	oldGetLeaderURL := getLeaderURL
+	defer func() { getLeaderURL = oldGetLeaderURL }()
+
+	tests := []struct {
+		state    string
+		expected string
+		msg      string
+	}{
+		{`{"leader_info": {"hostname": "master2", "port": 5050}}`, "master2:5050", "Should read the hostname of leader_info"},
+		{`{"leader_info": {"address": {"ip": "10.0.0.2", "port": 5050}}}`, "10.0.0.2:5050", "Should read the address of leader_info"},
+		{`{"leader": "master@10.0.0.3:5050"}`, "10.0.0.3:5050", "Should read the PID of the leader"},
+	}
+
+	for _, test := range tests {
+		state := test.state
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/master/state" {
+				fmt.Fprint(w, state)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		getLeaderURL = func(host string, endpoint string) string { return ts.URL + "/" + endpoint }
+
+		leader, err := MesosLeader([]string{"master1"}, http.Client{})
+		ts.Close()
+
+		assert.Nil(t, err, test.msg)
+		assert.Equal(t, test.expected, leader, test.msg)
+	}
+}
+
+func TestMesosLeaderTriesEachMaster(t *testing.T) {
+	oldGetLeaderURL := getLeaderURL
+	defer func() { getLeaderURL = oldGetLeaderURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "//master2:5050")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+	getLeaderURL = func(host string, endpoint string) string {
+		if host == "down" {
+			return "http://127.0.0.1:1/" + endpoint
+		}
+		return ts.URL + "/" + endpoint
+	}
+
+	leader, err := MesosLeader([]string{"down", "master2"}, http.Client{})
+	assert.Nil(t, err)
+	assert.Equal(t, "master2:5050", leader)
+
+	_, err = MesosLeader([]string{"down"}, http.Client{})
+	assert.NotNil(t, err)
+}
+
+func TestElectedChild(t *testing.T) {
+	assert.Equal(t, "json.info_0000000002", electedChild([]string{
+		"info_0000000001", "json.info_0000000003", "json.info_0000000002", "log_replicas",
+	}))
+	assert.Equal(t, "_c_af1-latch-0000000004", electedChild([]string{
+		"_c_bd2-latch-0000000007", "_c_af1-latch-0000000004",
+	}))
+	assert.Equal(t, "", electedChild([]string{"log_replicas"}))
+}
+
+func TestIsLocalHost(t *testing.T) {
+	oldHostname := hostname
+	oldLookupHost := lookupHost
+	defer func() {
+		hostname = oldHostname
+		lookupHost = oldLookupHost
+	}()
+	log := l.WithFields(l.Fields{})
+
+	hostname = func() (string, error) { return "thequeen", nil }
+	lookupHost = func(host string) ([]string, error) {
+		if host == "localalias" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+		msg      string
+	}{
+		{"thequeen:5050", true, "Should match the hostname"},
+		{"127.0.0.1:5050", true, "Should match a local IP"},
+		{"localalias", true, "Should match a name resolving to a local IP"},
+		{"192.0.2.1:5050", false, "Should not match a remote IP"},
+		{"unknown:5050", false, "Should not match a name not resolving"},
+	}
+
+	for _, test := range tests {
+		actual, err := IsLocalHost(test.host, log)
+		assert.Nil(t, err, test.msg)
+		assert.Equal(t, test.expected, actual, test.msg)
+	}
+}
+
+// fakeZooKeeper answers the handshake, getChildren and getData requests
+// with the given nodes
+func fakeZooKeeper(t *testing.T, nodes map[string][]byte) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	readPacket := func(conn net.Conn) ([]byte, error) {
+		var length int32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		packet := make([]byte, length)
+		_, err := io.ReadFull(conn, packet)
+		return packet, err
+	}
+	writePacket := func(conn net.Conn, packet []byte) {
+		var framed bytes.Buffer
+		writeZkBuffer(&framed, packet)
+		conn.Write(framed.Bytes())
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// the session, nothing in it is read
+		if _, err := readPacket(conn); err != nil {
+			return
+		}
+		writePacket(conn, make([]byte, 36))
+
+		for {
+			req, err := readPacket(conn)
+			if err != nil {
+				return
+			}
+			reader := bytes.NewReader(req)
+			var xid, opcode int32
+			binary.Read(reader, binary.BigEndian, &xid)
+			binary.Read(reader, binary.BigEndian, &opcode)
+			if opcode == zkOpClose {
+				return
+			}
+			path, _ := readZkBuffer(reader)
+
+			var rsp bytes.Buffer
+			binary.Write(&rsp, binary.BigEndian, xid)
+			binary.Write(&rsp, binary.BigEndian, int64(1))
+			switch opcode {
+			case zkOpGetChildren:
+				children := []string{}
+				for node := range nodes {
+					if strings.HasPrefix(node, string(path)+"/") {
+						children = append(children, strings.TrimPrefix(node, string(path)+"/"))
+					}
+				}
+				binary.Write(&rsp, binary.BigEndian, int32(0))
+				binary.Write(&rsp, binary.BigEndian, int32(len(children)))
+				for _, child := range children {
+					writeZkBuffer(&rsp, []byte(child))
+				}
+			case zkOpGetData:
+				data, exists := nodes[string(path)]
+				if !exists {
+					binary.Write(&rsp, binary.BigEndian, int32(zkErrNoNode))
+					break
+				}
+				binary.Write(&rsp, binary.BigEndian, int32(0))
+				writeZkBuffer(&rsp, data)
+			}
+			writePacket(conn, rsp.Bytes())
+		}
+	}()
+	return listener
+}
+
+func TestZooKeeperLeaderMesos(t *testing.T) {
+	zk := fakeZooKeeper(t, map[string][]byte{
+		"/mesos/json.info_0000000002": []byte(`{"hostname": "master2", "port": 5050}`),
+		"/mesos/json.info_0000000003": []byte(`{"hostname": "master3", "port": 5050}`),
+		"/mesos/info_0000000002":      []byte("protobuf"),
+	})
+	defer zk.Close()
+
+	leader, err := ZooKeeperLeader([]string{zk.Addr().String()}, "/mesos", time.Second)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "master2:5050", leader)
+}
+
+func TestZooKeeperLeaderLatch(t *testing.T) {
+	zk := fakeZooKeeper(t, map[string][]byte{
+		"/marathon/leader/_c_bd2-latch-0000000007": []byte("marathon2:8080"),
+		"/marathon/leader/_c_af1-latch-0000000004": []byte("marathon1:8080"),
+	})
+	defer zk.Close()
+
+	leader, err := ZooKeeperLeader([]string{"127.0.0.1:1", zk.Addr().String()}, "/marathon/leader", time.Second)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "marathon1:8080", leader)
+}
+
+func TestZooKeeperLeaderNoLeader(t *testing.T) {
+	zk := fakeZooKeeper(t, map[string][]byte{})
+	defer zk.Close()
+
+	_, err := ZooKeeperLeader([]string{zk.Addr().String()}, "/mesos", time.Second)
+
+	assert.NotNil(t, err)
+}
@@ -1,14 +1,12 @@
 package util
 
 import (
-	"encoding/json"
 	"fmt"
 	l "github.com/Sirupsen/logrus"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
-	"strings"
 )
 
 var (
@@ -33,37 +31,14 @@ func (e leaderError) Error() string {
 	return e.Reason
 }
 
-// IsLeader checks if a given host is the marathon leader
+// IsLeader checks if this host is the marathon or chronos leader
+// advertised by the leader endpoint of the given host
 func IsLeader(host string, endpoint string, client http.Client, log *l.Entry) (bool, error) {
-	url := getLeaderURL(host, endpoint)
-
-	contents, err := GetWrapper(url, client)
-	if err != nil {
-		return false, err
-	}
-
-	var leadermap map[string]string
-
-	if decodeErr := json.Unmarshal(contents, &leadermap); decodeErr != nil {
-		return false, decodeErr
-	}
-
-	leader, exists := leadermap["leader"]
-	if !exists {
-		return false, leaderError{"Could not find \"leader\" in leader JSON"}
-	}
-
-	s := strings.Split(leader, ":")
-
-	h, err := hostname()
-	if err != nil {
-		return false, err
-	}
-	isOurIP, err := IPInHostInterfaces(s[0], log)
+	leader, err := LeaderOf(host, endpoint, client)
 	if err != nil {
 		return false, err
 	}
-	return s[0] == h || isOurIP, nil
+	return IsLocalHost(leader, log)
 }
 
 // IPInHostInterfaces checks if given IP is assigned to a local interface
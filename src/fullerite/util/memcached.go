@@ -0,0 +1,64 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// MemcachedConn is a minimal memcached client of the text protocol, enough
+// to read the stats of a server
+type MemcachedConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// DialMemcached connects to the server on the network, tcp or unix, every
+// command of the connection has to complete within timeout
+func DialMemcached(network string, addr string, timeout time.Duration) (*MemcachedConn, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &MemcachedConn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// Stats sends a stats command, with the group of stats as argument like
+// "slabs" or "items", and returns the "STAT <name> <value>" lines by name
+func (c *MemcachedConn) Stats(args ...string) (map[string]string, error) {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	command := strings.Join(append([]string{"stats"}, args...), " ")
+	if _, err := fmt.Fprintf(c.conn, "%s\r\n", command); err != nil {
+		return nil, err
+	}
+
+	stats := map[string]string{}
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "END":
+			return stats, nil
+		case strings.HasPrefix(line, "STAT "):
+			fields := strings.SplitN(line[len("STAT "):], " ", 2)
+			if len(fields) == 2 {
+				stats[fields[0]] = fields[1]
+			}
+		case line == "ERROR", strings.HasPrefix(line, "CLIENT_ERROR"), strings.HasPrefix(line, "SERVER_ERROR"):
+			return nil, fmt.Errorf("memcached: %s returned %s", command, line)
+		}
+	}
+}
+
+// Close closes the connection
+func (c *MemcachedConn) Close() error {
+	return c.conn.Close()
+}
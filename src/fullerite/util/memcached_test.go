@@ -0,0 +1,75 @@
+package util
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveFakeMemcached answers the stats commands with canned replies on the
+// listener, other commands get an ERROR
+func serveFakeMemcached(listener net.Listener, replies map[string]string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				reply, exists := replies[strings.TrimSpace(line)]
+				if !exists {
+					reply = "ERROR\r\n"
+				}
+				conn.Write([]byte(reply))
+			}
+		}(conn)
+	}
+}
+
+func TestMemcachedConnStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memcached")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "memcached.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(t, err)
+	defer listener.Close()
+	go serveFakeMemcached(listener, map[string]string{
+		"stats":       "STAT pid 42\r\nSTAT version 1.6.9\r\nSTAT curr_items 300\r\nEND\r\n",
+		"stats slabs": "STAT 1:chunk_size 96\r\nSTAT active_slabs 1\r\nEND\r\n",
+	})
+
+	conn, err := DialMemcached("unix", socketPath, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	stats, err := conn.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"pid": "42", "version": "1.6.9", "curr_items": "300"}, stats)
+
+	stats, err = conn.Stats("slabs")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"1:chunk_size": "96", "active_slabs": "1"}, stats)
+
+	_, err = conn.Stats("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestDialMemcachedFailure(t *testing.T) {
+	_, err := DialMemcached("unix", "/does/not/exist.sock", time.Second)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,552 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// MySQL capability flags, commands and packet headers, see
+// https://dev.mysql.com/doc/dev/mysql-server/latest/PAGE_PROTOCOL.html
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientConnectWithDB    = 0x00000008
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientTransactions     = 0x00002000
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+
+	mysqlComQuit  = 0x01
+	mysqlComQuery = 0x03
+
+	mysqlOK             = 0x00
+	mysqlAuthMoreData   = 0x01
+	mysqlNull           = 0xfb
+	mysqlEOF            = 0xfe
+	mysqlAuthSwitch     = 0xfe
+	mysqlErr            = 0xff
+	mysqlMaxPacketSize  = 1<<24 - 1
+	mysqlCharsetUTF8    = 33
+	mysqlDefaultAddress = "127.0.0.1:3306"
+
+	mysqlNativePassword  = "mysql_native_password"
+	mysqlCachingSHA2     = "caching_sha2_password"
+	mysqlFastAuthSuccess = 3
+	mysqlFullAuth        = 4
+	mysqlRequestKey      = 2
+)
+
+// MySQLDSN is where and as whom to connect to a MySQL server
+type MySQLDSN struct {
+	User     string
+	Password string
+	// Net is tcp or unix
+	Net    string
+	Addr   string
+	DBName string
+	// AllowPublicKeyRetrieval lets caching_sha2_password ask the server
+	// for its public key over TCP, which a man in the middle could replace
+	AllowPublicKeyRetrieval bool
+}
+
+// MySQLError is an error returned by the server
+type MySQLError struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+func (e MySQLError) Error() string {
+	return fmt.Sprintf("mysql: error %d (%s): %s", e.Code, e.State, e.Message)
+}
+
+// MySQLResult is the result set of a query, NULL values are not Valid
+type MySQLResult struct {
+	Columns []string
+	Rows    [][]sql.NullString
+}
+
+// Records returns the rows of the result keyed by column name
+func (r *MySQLResult) Records() []map[string]sql.NullString {
+	records := make([]map[string]sql.NullString, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		record := make(map[string]sql.NullString, len(r.Columns))
+		for i, column := range r.Columns {
+			record[column] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// MySQLConn is a minimal MySQL client running text queries, enough to read
+// the status of a server. It authenticates with mysql_native_password or
+// caching_sha2_password and does not support TLS, so the public key of the
+// server is only asked for when allowed by the DSN.
+type MySQLConn struct {
+	conn     net.Conn
+	timeout  time.Duration
+	sequence byte
+}
+
+// ParseMySQLDSN reads a data source name formatted like the ones of the Go
+// MySQL driver: [user[:password]@][net[(addr)]]/dbname, for instance
+// "fullerite:secret@tcp(127.0.0.1:3306)/" or "root@unix(/run/mysqld.sock)/".
+// Of the parameters after a "?", only allowPublicKeyRetrieval=true is read.
+func ParseMySQLDSN(dsn string) (MySQLDSN, error) {
+	parsed := MySQLDSN{Net: "tcp", Addr: mysqlDefaultAddress}
+	if i := strings.Index(dsn, "?"); i >= 0 {
+		for _, param := range strings.Split(dsn[i+1:], "&") {
+			if param == "allowPublicKeyRetrieval=true" {
+				parsed.AllowPublicKeyRetrieval = true
+			}
+		}
+		dsn = dsn[:i]
+	}
+
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return parsed, fmt.Errorf("mysql: invalid DSN %q, missing the /", dsn)
+	}
+	parsed.DBName = dsn[slash+1:]
+	dsn = dsn[:slash]
+
+	if at := strings.LastIndex(dsn, "@"); at >= 0 {
+		credentials := strings.SplitN(dsn[:at], ":", 2)
+		parsed.User = credentials[0]
+		if len(credentials) == 2 {
+			parsed.Password = credentials[1]
+		}
+		dsn = dsn[at+1:]
+	}
+
+	if dsn == "" {
+		return parsed, nil
+	}
+	open := strings.Index(dsn, "(")
+	if open < 0 {
+		parsed.Net = dsn
+	} else {
+		if !strings.HasSuffix(dsn, ")") {
+			return parsed, fmt.Errorf("mysql: invalid DSN address %q", dsn)
+		}
+		parsed.Net = dsn[:open]
+		parsed.Addr = dsn[open+1 : len(dsn)-1]
+	}
+	if parsed.Net != "tcp" && parsed.Net != "unix" {
+		return parsed, fmt.Errorf("mysql: unknown network %q", parsed.Net)
+	}
+	if parsed.Net == "tcp" && !strings.Contains(parsed.Addr, ":") {
+		parsed.Addr = WithDefaultPort(parsed.Addr, 3306)
+	}
+	return parsed, nil
+}
+
+// DialMySQL connects and authenticates to the server of the DSN, every
+// read and write of the connection has to complete within timeout
+func DialMySQL(dsn MySQLDSN, timeout time.Duration) (*MySQLConn, error) {
+	conn, err := net.DialTimeout(dsn.Net, dsn.Addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &MySQLConn{conn: conn, timeout: timeout}
+	if err := c.handshake(dsn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Query runs a statement and returns its result set, which is empty for
+// statements without one
+func (c *MySQLConn) Query(query string) (*MySQLResult, error) {
+	c.sequence = 0
+	if err := c.writePacket(append([]byte{mysqlComQuery}, query...)); err != nil {
+		return nil, err
+	}
+
+	packet, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch packet[0] {
+	case mysqlOK:
+		return &MySQLResult{}, nil
+	case mysqlErr:
+		return nil, parseMySQLError(packet)
+	}
+
+	count, _, err := readLengthEncodedInt(packet)
+	if err != nil {
+		return nil, err
+	}
+	result := &MySQLResult{Columns: make([]string, 0, count)}
+	for i := uint64(0); i < count; i++ {
+		if packet, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		name, err := columnName(packet)
+		if err != nil {
+			return nil, err
+		}
+		result.Columns = append(result.Columns, name)
+	}
+	if packet, err = c.readPacket(); err != nil {
+		return nil, err
+	}
+	if !isMySQLEOF(packet) {
+		return nil, fmt.Errorf("mysql: expected an EOF after the column definitions")
+	}
+
+	for {
+		if packet, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if isMySQLEOF(packet) {
+			return result, nil
+		}
+		if packet[0] == mysqlErr {
+			return nil, parseMySQLError(packet)
+		}
+		row, err := parseMySQLRow(packet, len(result.Columns))
+		if err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, row)
+	}
+}
+
+// Close tells the server the session is over and closes the connection
+func (c *MySQLConn) Close() error {
+	c.sequence = 0
+	c.writePacket([]byte{mysqlComQuit})
+	return c.conn.Close()
+}
+
+// handshake answers the initial handshake of the server and goes through
+// the authentication, switching plugins if the server asks to
+func (c *MySQLConn) handshake(dsn MySQLDSN) error {
+	packet, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if packet[0] == mysqlErr {
+		return parseMySQLError(packet)
+	}
+	scramble, plugin, err := parseMySQLHandshake(packet)
+	if err != nil {
+		return err
+	}
+	if plugin != mysqlCachingSHA2 {
+		plugin = mysqlNativePassword
+	}
+
+	capabilities := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientTransactions |
+		mysqlClientSecureConnection | mysqlClientPluginAuth)
+	if dsn.DBName != "" {
+		capabilities |= mysqlClientConnectWithDB
+	}
+	authResponse := mysqlScramble(plugin, scramble, dsn.Password)
+
+	var rsp bytes.Buffer
+	binary.Write(&rsp, binary.LittleEndian, capabilities)
+	binary.Write(&rsp, binary.LittleEndian, uint32(mysqlMaxPacketSize))
+	rsp.WriteByte(mysqlCharsetUTF8)
+	rsp.Write(make([]byte, 23))
+	rsp.WriteString(dsn.User)
+	rsp.WriteByte(0)
+	rsp.WriteByte(byte(len(authResponse)))
+	rsp.Write(authResponse)
+	if dsn.DBName != "" {
+		rsp.WriteString(dsn.DBName)
+		rsp.WriteByte(0)
+	}
+	rsp.WriteString(plugin)
+	rsp.WriteByte(0)
+	if err := c.writePacket(rsp.Bytes()); err != nil {
+		return err
+	}
+
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		switch packet[0] {
+		case mysqlOK:
+			return nil
+		case mysqlErr:
+			return parseMySQLError(packet)
+		case mysqlAuthSwitch:
+			end := bytes.IndexByte(packet[1:], 0)
+			if end < 0 {
+				return fmt.Errorf("mysql: invalid auth switch request")
+			}
+			plugin = string(packet[1 : end+1])
+			scramble = bytes.TrimRight(packet[end+2:], "\x00")
+			if plugin != mysqlNativePassword && plugin != mysqlCachingSHA2 {
+				return fmt.Errorf("mysql: unsupported authentication plugin %s", plugin)
+			}
+			err = c.writePacket(mysqlScramble(plugin, scramble, dsn.Password))
+		case mysqlAuthMoreData:
+			err = c.fullAuth(packet[1:], plugin, scramble, dsn)
+		default:
+			return fmt.Errorf("mysql: unexpected packet 0x%02x during authentication", packet[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fullAuth handles the caching_sha2_password exchanges after the scramble:
+// nothing to do after a fast authentication, otherwise the password is
+// sent in clear over UNIX sockets and encrypted with the key of the server
+// over TCP, which is only asked for with AllowPublicKeyRetrieval
+func (c *MySQLConn) fullAuth(data []byte, plugin string, scramble []byte, dsn MySQLDSN) error {
+	if plugin != mysqlCachingSHA2 || len(data) == 0 {
+		return fmt.Errorf("mysql: unexpected auth data for %s", plugin)
+	}
+	switch data[0] {
+	case mysqlFastAuthSuccess:
+		return nil
+	case mysqlFullAuth:
+		if dsn.Net == "unix" {
+			return c.writePacket(append([]byte(dsn.Password), 0))
+		}
+		if !dsn.AllowPublicKeyRetrieval {
+			return fmt.Errorf("mysql: the server asks for the full caching_sha2_password authentication, " +
+				"add allowPublicKeyRetrieval=true to the DSN to get its public key over TCP")
+		}
+		return c.writePacket([]byte{mysqlRequestKey})
+	}
+
+	// anything else is the public key of the server
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("mysql: invalid public key of the server")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("mysql: the public key of the server is not an RSA key")
+	}
+	password := append([]byte(dsn.Password), 0)
+	for i := range password {
+		password[i] ^= scramble[i%len(scramble)]
+	}
+	encrypted, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, password, nil)
+	if err != nil {
+		return err
+	}
+	return c.writePacket(encrypted)
+}
+
+func (c *MySQLConn) writePacket(payload []byte) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	header := make([]byte, 4)
+	header[0] = byte(len(payload))
+	header[1] = byte(len(payload) >> 8)
+	header[2] = byte(len(payload) >> 16)
+	header[3] = c.sequence
+	c.sequence++
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// readPacket returns the next payload, joining the packets of payloads
+// longer than the maximum packet size
+func (c *MySQLConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return nil, err
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.sequence = header[3] + 1
+
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(c.conn, packet); err != nil {
+			return nil, err
+		}
+		payload = append(payload, packet...)
+		if length < mysqlMaxPacketSize {
+			break
+		}
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("mysql: empty packet")
+	}
+	return payload, nil
+}
+
+// parseMySQLHandshake returns the scramble and the authentication plugin of
+// a protocol 10 initial handshake
+func parseMySQLHandshake(packet []byte) ([]byte, string, error) {
+	if packet[0] != 10 {
+		return nil, "", fmt.Errorf("mysql: unsupported protocol version %d", packet[0])
+	}
+	// the server version ends with a NUL, the connection id follows
+	end := bytes.IndexByte(packet[1:], 0)
+	if end < 0 || len(packet) < end+2+4+8+1+2 {
+		return nil, "", fmt.Errorf("mysql: invalid handshake")
+	}
+	pos := end + 2 + 4
+	scramble := append([]byte{}, packet[pos:pos+8]...)
+	pos += 8 + 1 + 2 // scramble, filler and lower capabilities
+	// character set, status, upper capabilities, scramble length, reserved
+	pos += 1 + 2 + 2 + 1 + 10
+	if pos >= len(packet) {
+		return scramble, mysqlNativePassword, nil
+	}
+
+	rest := packet[pos:]
+	end = bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return append(scramble, rest...), mysqlNativePassword, nil
+	}
+	scramble = append(scramble, rest[:end]...)
+	plugin := ""
+	if pluginEnd := bytes.IndexByte(rest[end+1:], 0); pluginEnd >= 0 {
+		plugin = string(rest[end+1 : end+1+pluginEnd])
+	} else {
+		plugin = string(rest[end+1:])
+	}
+	return scramble, plugin, nil
+}
+
+// mysqlScramble returns the authentication response of a password:
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))) for
+// mysql_native_password and SHA256(password) XOR
+// SHA256(SHA256(SHA256(password)) + scramble) for caching_sha2_password
+func mysqlScramble(plugin string, scramble []byte, password string) []byte {
+	if password == "" {
+		return []byte{}
+	}
+	if plugin == mysqlCachingSHA2 {
+		hash := sha256.Sum256([]byte(password))
+		hashHash := sha256.Sum256(hash[:])
+		salted := sha256.Sum256(append(hashHash[:], scramble...))
+		for i := range hash {
+			hash[i] ^= salted[i]
+		}
+		return hash[:]
+	}
+	hash := sha1.Sum([]byte(password))
+	hashHash := sha1.Sum(hash[:])
+	salted := sha1.Sum(append(append([]byte{}, scramble...), hashHash[:]...))
+	for i := range hash {
+		hash[i] ^= salted[i]
+	}
+	return hash[:]
+}
+
+func parseMySQLError(packet []byte) error {
+	if len(packet) < 3 {
+		return MySQLError{Message: "invalid error packet"}
+	}
+	e := MySQLError{Code: binary.LittleEndian.Uint16(packet[1:3])}
+	message := packet[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		e.State = string(message[1:6])
+		message = message[6:]
+	}
+	e.Message = string(message)
+	return e
+}
+
+// isMySQLEOF tells an EOF packet from a row starting with a long
+// length encoded string
+func isMySQLEOF(packet []byte) bool {
+	return packet[0] == mysqlEOF && len(packet) < 9
+}
+
+// columnName reads the name out of a column definition, which starts with
+// the catalog, the schema, the table and the original table
+func columnName(packet []byte) (string, error) {
+	pos := 0
+	for i := 0; i < 4; i++ {
+		_, n, err := readLengthEncodedString(packet[pos:])
+		if err != nil {
+			return "", err
+		}
+		pos += n
+	}
+	name, _, err := readLengthEncodedString(packet[pos:])
+	return string(name), err
+}
+
+func parseMySQLRow(packet []byte, columns int) ([]sql.NullString, error) {
+	row := make([]sql.NullString, columns)
+	pos := 0
+	for i := range row {
+		if pos >= len(packet) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if packet[pos] == mysqlNull {
+			pos++
+			continue
+		}
+		value, n, err := readLengthEncodedString(packet[pos:])
+		if err != nil {
+			return nil, err
+		}
+		row[i] = sql.NullString{String: string(value), Valid: true}
+		pos += n
+	}
+	return row, nil
+}
+
+// readLengthEncodedInt returns the integer and the number of bytes read
+func readLengthEncodedInt(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	size := 0
+	switch b[0] {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	default:
+		return uint64(b[0]), 1, nil
+	}
+	if len(b) < size+1 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	var n uint64
+	for i := size; i > 0; i-- {
+		n = n<<8 | uint64(b[i])
+	}
+	return n, size + 1, nil
+}
+
+// readLengthEncodedString returns the string and the number of bytes read
+func readLengthEncodedString(b []byte) ([]byte, int, error) {
+	length, n, err := readLengthEncodedInt(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint64(len(b)-n) < length {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return b[n : n+int(length)], n + int(length), nil
+}
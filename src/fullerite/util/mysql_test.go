@@ -0,0 +1,377 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMySQL is a MySQL server accepting one user and answering canned
+// result sets, a nil row value is a NULL
+type fakeMySQL struct {
+	listener net.Listener
+	user     string
+	password string
+	// plugin is the one of the handshake, switchTo makes the server ask
+	// for another one and fullAuth skips the caching_sha2 fast path
+	plugin   string
+	switchTo string
+	fullAuth bool
+	key      *rsa.PrivateKey
+	results  map[string]fakeMySQLResult
+}
+
+type fakeMySQLResult struct {
+	columns []string
+	rows    [][]*string
+}
+
+func newFakeMySQL(t *testing.T) *fakeMySQL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMySQL{
+		listener: listener,
+		user:     "fullerite",
+		password: "secret",
+		plugin:   mysqlNativePassword,
+		results:  map[string]fakeMySQLResult{},
+	}
+}
+
+func (s *fakeMySQL) dsn() MySQLDSN {
+	return MySQLDSN{User: s.user, Password: s.password, Net: "tcp", Addr: s.listener.Addr().String()}
+}
+
+func (s *fakeMySQL) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeMySQL) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &MySQLConn{conn: conn, timeout: time.Second}
+
+	scramble := []byte("abcdefghijklmnopqrst")
+	var handshake bytes.Buffer
+	handshake.WriteByte(10)
+	handshake.WriteString("8.0.0-fake\x00")
+	handshake.Write([]byte{1, 0, 0, 0})
+	handshake.Write(scramble[:8])
+	handshake.Write([]byte{0, 0xff, 0xff, mysqlCharsetUTF8, 2, 0, 0xff, 0xff, 21})
+	handshake.Write(make([]byte, 10))
+	handshake.Write(scramble[8:])
+	handshake.WriteByte(0)
+	handshake.WriteString(s.plugin + "\x00")
+	c.writePacket(handshake.Bytes())
+
+	packet, err := c.readPacket()
+	if err != nil {
+		return
+	}
+	// capabilities, max packet size, charset and filler
+	rest := packet[4+4+1+23:]
+	end := bytes.IndexByte(rest, 0)
+	user := string(rest[:end])
+	authResponse := rest[end+2 : end+2+int(rest[end+1])]
+
+	plugin := s.plugin
+	if s.switchTo != "" {
+		plugin = s.switchTo
+		scramble = []byte("ABCDEFGHIJKLMNOPQRST")
+		c.writePacket([]byte("\xfe" + plugin + "\x00" + string(scramble) + "\x00"))
+		if authResponse, err = c.readPacket(); err != nil {
+			return
+		}
+	}
+
+	authenticated := false
+	switch {
+	case user != s.user:
+	case plugin == mysqlNativePassword:
+		authenticated = checkNativePassword(authResponse, scramble, s.password)
+	case !s.fullAuth:
+		authenticated = checkCachingSHA2Password(authResponse, scramble, s.password)
+		if authenticated {
+			c.writePacket([]byte{mysqlAuthMoreData, mysqlFastAuthSuccess})
+		}
+	default:
+		c.writePacket([]byte{mysqlAuthMoreData, mysqlFullAuth})
+		if packet, err = c.readPacket(); err != nil || packet[0] != mysqlRequestKey {
+			return
+		}
+		der, _ := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+		c.writePacket(append([]byte{mysqlAuthMoreData}, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...))
+		if packet, err = c.readPacket(); err != nil {
+			return
+		}
+		password, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, s.key, packet, nil)
+		if err != nil {
+			return
+		}
+		for i := range password {
+			password[i] ^= scramble[i%len(scramble)]
+		}
+		authenticated = string(password) == s.password+"\x00"
+	}
+	if !authenticated {
+		c.writePacket([]byte("\xff\x15\x04#28000Access denied for user"))
+		return
+	}
+	c.writePacket([]byte{mysqlOK, 0, 0, 2, 0, 0, 0})
+
+	for {
+		packet, err := c.readPacket()
+		if err != nil || packet[0] == mysqlComQuit {
+			return
+		}
+		result, exists := s.results[string(packet[1:])]
+		if !exists {
+			c.writePacket([]byte("\xff\x28\x04#42000You have an error in your SQL syntax"))
+			continue
+		}
+		s.writeResult(c, result)
+	}
+}
+
+func (s *fakeMySQL) writeResult(c *MySQLConn, result fakeMySQLResult) {
+	eof := []byte{mysqlEOF, 0, 0, 2, 0}
+	c.writePacket([]byte{byte(len(result.columns))})
+	for _, column := range result.columns {
+		var definition bytes.Buffer
+		for _, s := range []string{"def", "", "", "", column, column} {
+			writeLengthEncodedString(&definition, s)
+		}
+		definition.Write([]byte{0x0c, mysqlCharsetUTF8, 0, 0, 1, 0, 0, 0xfd, 0, 0, 0, 0, 0})
+		c.writePacket(definition.Bytes())
+	}
+	c.writePacket(eof)
+	for _, row := range result.rows {
+		var values bytes.Buffer
+		for _, value := range row {
+			if value == nil {
+				values.WriteByte(mysqlNull)
+			} else {
+				writeLengthEncodedString(&values, *value)
+			}
+		}
+		c.writePacket(values.Bytes())
+	}
+	c.writePacket(eof)
+}
+
+func writeLengthEncodedString(w *bytes.Buffer, s string) {
+	if len(s) < 0xfb {
+		w.WriteByte(byte(len(s)))
+	} else {
+		w.Write([]byte{0xfc, byte(len(s)), byte(len(s) >> 8)})
+	}
+	w.WriteString(s)
+}
+
+// checkNativePassword verifies the response the way the server does, from
+// the SHA1(SHA1(password)) it stores
+func checkNativePassword(response []byte, scramble []byte, password string) bool {
+	hash := sha1.Sum([]byte(password))
+	stored := sha1.Sum(hash[:])
+	salted := sha1.Sum(append(append([]byte{}, scramble...), stored[:]...))
+	if len(response) != len(salted) {
+		return false
+	}
+	candidate := make([]byte, len(response))
+	for i := range response {
+		candidate[i] = response[i] ^ salted[i]
+	}
+	check := sha1.Sum(candidate)
+	return bytes.Equal(check[:], stored[:])
+}
+
+// checkCachingSHA2Password verifies the response from the
+// SHA256(SHA256(password)) the server caches
+func checkCachingSHA2Password(response []byte, scramble []byte, password string) bool {
+	hash := sha256.Sum256([]byte(password))
+	stored := sha256.Sum256(hash[:])
+	salted := sha256.Sum256(append(stored[:], scramble...))
+	if len(response) != len(salted) {
+		return false
+	}
+	candidate := make([]byte, len(response))
+	for i := range response {
+		candidate[i] = response[i] ^ salted[i]
+	}
+	check := sha256.Sum256(candidate)
+	return bytes.Equal(check[:], stored[:])
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestParseMySQLDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected MySQLDSN
+	}{
+		{"/", MySQLDSN{Net: "tcp", Addr: "127.0.0.1:3306"}},
+		{"user:p@ss:word@tcp(db1:3307)/app?timeout=1s", MySQLDSN{User: "user", Password: "p@ss:word", Net: "tcp", Addr: "db1:3307", DBName: "app"}},
+		{"root@unix(/var/run/mysqld/mysqld.sock)/", MySQLDSN{User: "root", Net: "unix", Addr: "/var/run/mysqld/mysqld.sock"}},
+		{"monitor@tcp(10.0.0.1)/", MySQLDSN{User: "monitor", Net: "tcp", Addr: "10.0.0.1:3306"}},
+		{"monitor@tcp(10.0.0.1)/?timeout=1s&allowPublicKeyRetrieval=true", MySQLDSN{User: "monitor", Net: "tcp", Addr: "10.0.0.1:3306", AllowPublicKeyRetrieval: true}},
+	}
+	for _, test := range tests {
+		dsn, err := ParseMySQLDSN(test.dsn)
+		assert.Nil(t, err, test.dsn)
+		assert.Equal(t, test.expected, dsn, test.dsn)
+	}
+
+	for _, invalid := range []string{"tcp(localhost:3306)", "udp(localhost)/", "tcp(localhost/"} {
+		_, err := ParseMySQLDSN(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestMySQLQuery(t *testing.T) {
+	server := newFakeMySQL(t)
+	defer server.listener.Close()
+	server.results["SHOW GLOBAL STATUS"] = fakeMySQLResult{
+		columns: []string{"Variable_name", "Value"},
+		rows: [][]*string{
+			{stringPtr("Threads_connected"), stringPtr("5")},
+			{stringPtr("Ssl_cipher"), nil},
+		},
+	}
+	go server.serve()
+
+	conn, err := DialMySQL(server.dsn(), time.Second)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	result, err := conn.Query("SHOW GLOBAL STATUS")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Variable_name", "Value"}, result.Columns)
+	assert.Equal(t, []map[string]sql.NullString{
+		{"Variable_name": {String: "Threads_connected", Valid: true}, "Value": {String: "5", Valid: true}},
+		{"Variable_name": {String: "Ssl_cipher", Valid: true}, "Value": {}},
+	}, result.Records())
+
+	_, err = conn.Query("SHOW NONSENSE")
+	assert.Equal(t, MySQLError{Code: 1064, State: "42000", Message: "You have an error in your SQL syntax"}, err)
+
+	// the connection is still usable after an error
+	result, err = conn.Query("SHOW GLOBAL STATUS")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result.Rows))
+}
+
+func TestMySQLAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		plugin   string
+		switchTo string
+		fullAuth bool
+		password string
+		ok       bool
+		msg      string
+	}{
+		{mysqlNativePassword, "", false, "secret", true, "Should log in with mysql_native_password"},
+		{mysqlNativePassword, "", false, "wrong", false, "Should not log in with a wrong password"},
+		{mysqlCachingSHA2, "", false, "secret", true, "Should log in with caching_sha2_password"},
+		{mysqlCachingSHA2, "", true, "secret", true, "Should send the password encrypted with the key of the server"},
+		{mysqlCachingSHA2, "", true, "wrong", false, "Should not log in with a wrong encrypted password"},
+		{mysqlCachingSHA2, mysqlNativePassword, false, "secret", true, "Should switch to mysql_native_password"},
+		{"sha256_password", mysqlCachingSHA2, false, "secret", true, "Should switch to caching_sha2_password"},
+	}
+
+	for _, test := range tests {
+		server := newFakeMySQL(t)
+		server.plugin = test.plugin
+		server.switchTo = test.switchTo
+		server.fullAuth = test.fullAuth
+		server.key = key
+		go server.serve()
+
+		dsn := server.dsn()
+		dsn.Password = test.password
+		dsn.AllowPublicKeyRetrieval = true
+		conn, err := DialMySQL(dsn, time.Second)
+		if test.ok {
+			if assert.Nil(t, err, test.msg) {
+				conn.Close()
+			}
+		} else {
+			assert.Equal(t, MySQLError{Code: 1045, State: "28000", Message: "Access denied for user"}, err, test.msg)
+		}
+		server.listener.Close()
+	}
+}
+
+func TestMySQLPublicKeyRetrievalNotAllowed(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeMySQL(t)
+	defer server.listener.Close()
+	server.plugin = mysqlCachingSHA2
+	server.fullAuth = true
+	server.key = key
+	go server.serve()
+
+	_, err = DialMySQL(server.dsn(), time.Second)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "allowPublicKeyRetrieval=true")
+	}
+}
+
+func TestMySQLDialClosedServer(t *testing.T) {
+	server := newFakeMySQL(t)
+	server.listener.Close()
+
+	_, err := DialMySQL(server.dsn(), time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestReadLengthEncodedInt(t *testing.T) {
+	tests := []struct {
+		b        []byte
+		expected uint64
+		n        int
+	}{
+		{[]byte{0xfa}, 250, 1},
+		{[]byte{0xfc, 0x01, 0x02}, 0x0201, 3},
+		{[]byte{0xfd, 0x01, 0x02, 0x03}, 0x030201, 4},
+		{[]byte{0xfe, 1, 0, 0, 0, 0, 0, 0, 1}, 1<<56 + 1, 9},
+	}
+	for _, test := range tests {
+		value, n, err := readLengthEncodedInt(test.b)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, value)
+		assert.Equal(t, test.n, n)
+	}
+
+	_, _, err := readLengthEncodedInt([]byte{0xfc, 0x01})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -3,18 +3,40 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // For dependency injection
 var (
 	ipGetter   = getIps
 	httpRegexp = regexp.MustCompile(`http`)
+
+	statNerveConfig = os.Stat
+	readNerveConfig = ioutil.ReadFile
 )
 
+// nerveConfigCache keeps the services parsed out of each config file until
+// the file is modified
+var nerveConfigCache = struct {
+	sync.Mutex
+	files map[string]nerveConfigFile
+}{files: make(map[string]nerveConfigFile)}
+
+type nerveConfigFile struct {
+	modTime  time.Time
+	size     int64
+	services []NerveService
+}
+
 // example configuration::
 //
 // {
@@ -30,6 +52,18 @@ var (
 //
 // Most imporantly is the port, host and service name. The service name is assumed to be formatted like this::
 //
+// Synapse configurations have services too, the ones with a default server
+// on this host are read like nerve services::
+//
+// {
+//     "services": {
+//         "<SERVICE_NAME>.<NAMESPACE>": {
+//             "default_servers": [{"host": "<IPADDR>", "port": ###}],
+//             "discovery": {...},
+//             "haproxy": {...}
+//         }
+//     }
+// }
 type nerveConfigData struct {
 	Services map[string]map[string]interface{}
 }
@@ -50,37 +84,163 @@ type EndPoint struct {
 
 // ParseNerveConfig is responsible for taking the JSON string coming in into a list of NerveServices
 func ParseNerveConfig(raw *[]byte, namespaceIncluded bool) ([]NerveService, error) {
-	services := make(map[string]NerveService)
-	results := []NerveService{}
-	parsed := new(nerveConfigData)
+	services, err := parseNerveServices(*raw)
+	if err != nil {
+		return []NerveService{}, err
+	}
+	return dedupeNerveServices(services, namespaceIncluded), nil
+}
+
+// ReadNerveConfigs returns the services of all the nerve and synapse config
+// files matching the globs, deduplicated like ParseNerveConfig does. Files
+// are only parsed again when their modification time or size change. The
+// services of the readable files are returned along with the error of the
+// others.
+func ReadNerveConfigs(globs []string, namespaceIncluded bool) ([]NerveService, error) {
+	paths := []string{}
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return []NerveService{}, err
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return []NerveService{}, fmt.Errorf("No nerve config file matching %s", strings.Join(globs, ", "))
+	}
+	sort.Strings(paths)
+
+	services := []NerveService{}
+	errs := []string{}
+	for _, path := range paths {
+		fileServices, err := readNerveConfigFile(path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		services = append(services, fileServices...)
+	}
+
+	var err error
+	if len(errs) > 0 {
+		err = fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return dedupeNerveServices(services, namespaceIncluded), err
+}
+
+func readNerveConfigFile(path string) ([]NerveService, error) {
+	info, err := statNerveConfig(path)
+	if err != nil {
+		return nil, err
+	}
 
-	err := json.Unmarshal(*raw, parsed)
+	nerveConfigCache.Lock()
+	cached, exists := nerveConfigCache.files[path]
+	nerveConfigCache.Unlock()
+	if exists && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.services, nil
+	}
+
+	raw, err := readNerveConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	services, err := parseNerveServices(raw)
 	if err != nil {
-		return results, err
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err)
 	}
 
+	nerveConfigCache.Lock()
+	nerveConfigCache.files[path] = nerveConfigFile{
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		services: services,
+	}
+	nerveConfigCache.Unlock()
+	return services, nil
+}
+
+// parseNerveServices returns the nerve services and the synapse services
+// with a default server on this host, with duplicates
+func parseNerveServices(raw []byte) ([]NerveService, error) {
+	parsed := new(nerveConfigData)
+	if err := json.Unmarshal(raw, parsed); err != nil {
+		return nil, err
+	}
+
+	results := []NerveService{}
 	for rawServiceName, serviceConfig := range parsed.Services {
-		host := strings.TrimSpace(serviceConfig["host"].(string))
+		nameParts := strings.Split(rawServiceName, ".")
+		if len(nameParts) < 2 {
+			continue
+		}
+
+		if servers, ok := serviceConfig["default_servers"].([]interface{}); ok {
+			results = append(results, synapseServices(nameParts[0], nameParts[1], servers)...)
+			continue
+		}
+
+		host, _ := serviceConfig["host"].(string)
 		service := new(NerveService)
-		service.Name = strings.Split(rawServiceName, ".")[0]
-		service.Namespace = strings.Split(rawServiceName, ".")[1]
-		service.Host = host
-		port := extractPort(serviceConfig)
-
-		if port != -1 {
-			service.Port = port
-			if namespaceIncluded {
-				services[service.Name+service.Namespace+":"+strconv.Itoa(port)] = *service
-			} else {
-				services[service.Name+":"+strconv.Itoa(port)] = *service
-			}
+		service.Name = nameParts[0]
+		service.Namespace = nameParts[1]
+		service.Host = strings.TrimSpace(host)
+		service.Port = extractPort(serviceConfig)
+
+		if service.Port != -1 {
+			results = append(results, *service)
 		}
 	}
+	return results, nil
+}
 
+// synapseServices returns a service for each default server on this host
+func synapseServices(name string, namespace string, servers []interface{}) []NerveService {
+	ips, err := ipGetter()
+	if err != nil {
+		return nil
+	}
+	local := make(map[string]bool)
+	for _, ip := range ips {
+		local[ip] = true
+	}
+
+	results := []NerveService{}
+	for _, s := range servers {
+		server, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host, _ := server["host"].(string)
+		port, ok := server["port"].(float64)
+		if !ok || !local[strings.TrimSpace(host)] {
+			continue
+		}
+		results = append(results, NerveService{
+			Name:      name,
+			Namespace: namespace,
+			Host:      strings.TrimSpace(host),
+			Port:      int(port),
+		})
+	}
+	return results
+}
+
+func dedupeNerveServices(all []NerveService, namespaceIncluded bool) []NerveService {
+	services := make(map[string]NerveService)
+	for _, service := range all {
+		if namespaceIncluded {
+			services[service.Name+service.Namespace+":"+strconv.Itoa(service.Port)] = service
+		} else {
+			services[service.Name+":"+strconv.Itoa(service.Port)] = service
+		}
+	}
+
+	results := []NerveService{}
 	for _, value := range services {
 		results = append(results, value)
 	}
-	return results, nil
+	return results
 }
 
 func extractPort(serviceConfig map[string]interface{}) int {
@@ -1,7 +1,11 @@
 package util
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -212,3 +216,116 @@ func TestBadURI(t *testing.T) {
 	results, _ := ParseNerveConfig(&cfgString, true)
 	assert.Equal(t, 0, len(results))
 }
+
+func synapseConfig() []byte {
+	return []byte(`
+	{
+	    "services": {
+	        "other_service.main": {
+	            "default_servers": [
+	                {"host": "10.56.5.21", "port": 31000},
+	                {"host": "10.56.5.99", "port": 31001}
+	            ],
+	            "discovery": {"method": "zookeeper", "path": "/nerve/other_service.main"},
+	            "haproxy": {"port": 20001}
+	        },
+	        "example_service.main": {
+	            "default_servers": [{"host": "10.56.5.21", "port": 13752}],
+	            "haproxy": {"port": 20002}
+	        }
+	    }
+	}`)
+}
+
+func TestSynapseConfigParsing(t *testing.T) {
+	cfgString := synapseConfig()
+	ipGetter = func() ([]string, error) { return []string{"10.56.5.21"}, nil }
+	results, err := ParseNerveConfig(&cfgString, true)
+	assert.Nil(t, err)
+	m := make(map[NerveService]bool)
+	for _, r := range results {
+		m[r] = true
+	}
+	assert.Equal(t, map[NerveService]bool{
+		NerveService{Name: "other_service", Namespace: "main", Port: 31000, Host: "10.56.5.21"}:   true,
+		NerveService{Name: "example_service", Namespace: "main", Port: 13752, Host: "10.56.5.21"}: true,
+	}, m)
+}
+
+func TestReadNerveConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nerve")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "nerve1.conf.json"), getTestNerveConfig(), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "nerve2.conf.json"), badURINerveConfig(), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "synapse.conf.json"), synapseConfig(), 0644))
+	ipGetter = func() ([]string, error) { return []string{"10.56.5.21"}, nil }
+
+	globs := []string{filepath.Join(dir, "nerve*.conf.json"), filepath.Join(dir, "synapse.conf.json")}
+	results, err := ReadNerveConfigs(globs, true)
+	assert.Nil(t, err)
+	m := make(map[NerveService]bool)
+	for _, r := range results {
+		m[r] = true
+	}
+	assert.Equal(t, map[NerveService]bool{
+		NerveService{Name: "example_service", Namespace: "mesosstage_main", Port: 22224, Host: "10.56.5.21"}: true,
+		NerveService{Name: "example_service", Namespace: "main", Port: 13752, Host: "10.56.5.21"}:            true,
+		NerveService{Name: "example_service", Namespace: "another", Port: 13752, Host: "10.56.5.21"}:         true,
+		NerveService{Name: "other_service", Namespace: "main", Port: 31000, Host: "10.56.5.21"}:              true,
+	}, m)
+
+	results, err = ReadNerveConfigs(globs, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+}
+
+func TestReadNerveConfigsCache(t *testing.T) {
+	oldRead := readNerveConfig
+	defer func() { readNerveConfig = oldRead }()
+
+	tmpFile, err := ioutil.TempFile("", "nerve")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Write(getTestNerveConfig())
+	tmpFile.Close()
+	ipGetter = func() ([]string, error) { return []string{"10.56.5.21"}, nil }
+
+	reads := 0
+	readNerveConfig = func(path string) ([]byte, error) {
+		reads++
+		return oldRead(path)
+	}
+
+	for i := 0; i < 2; i++ {
+		results, err := ReadNerveConfigs([]string{tmpFile.Name()}, true)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(results))
+	}
+	assert.Equal(t, 1, reads, "An unchanged file should be parsed once")
+
+	modified := time.Now().Add(time.Minute)
+	assert.Nil(t, ioutil.WriteFile(tmpFile.Name(), []byte(`{"services": {}}`), 0644))
+	assert.Nil(t, os.Chtimes(tmpFile.Name(), modified, modified))
+	results, err := ReadNerveConfigs([]string{tmpFile.Name()}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(results))
+	assert.Equal(t, 2, reads, "A modified file should be parsed again")
+}
+
+func TestReadNerveConfigsErrors(t *testing.T) {
+	results, err := ReadNerveConfigs([]string{"/nonexistent/nerve*.json"}, true)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(results))
+
+	tmpFile, err := ioutil.TempFile("", "nerve")
+	assert.Nil(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Write([]byte("notjson"))
+	tmpFile.Close()
+
+	results, err = ReadNerveConfigs([]string{tmpFile.Name()}, true)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(results))
+}
@@ -1,8 +1,12 @@
 package util
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
 
 	l "github.com/Sirupsen/logrus"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -21,7 +25,8 @@ func trimSuffix(name string, suffix string) (string, bool) {
 }
 
 // ExtractPrometheusMetrics returns an array of metrics extracted from the
-// given Prometheus endpoint.
+// given Prometheus endpoint. The series of each histogram and summary are
+// grouped in a single metric.Histogram metric.
 func ExtractPrometheusMetrics(
 	body []byte,
 	contentType string,
@@ -34,6 +39,8 @@ func ExtractPrometheusMetrics(
 	metrics = []metric.Metric{}
 
 	var metricType textparse.MetricType
+	// index in metrics of the distribution of each histogram and summary
+	distributions := make(map[string]int)
 
 	parser := textparse.New(body, contentType)
 	for {
@@ -85,53 +92,106 @@ func ExtractPrometheusMetrics(
 			}
 		}
 
+		var fulleriteMetricName string
+		if prefix != "" {
+			fulleriteMetricName = fmt.Sprintf("%s%s", prefix, metricName)
+		} else {
+			fulleriteMetricName = metricName
+		}
+
+		_, _, value := parser.Series()
+
 		var fulleriteMetricType string
 		switch metricType {
 		case textparse.MetricTypeGauge:
 			fulleriteMetricType = metric.Gauge
 		case textparse.MetricTypeCounter:
 			fulleriteMetricType = metric.CumulativeCounter
-		case textparse.MetricTypeSummary:
-			if isCount {
-				fulleriteMetricType = metric.CumulativeCounter
-				metricName = fmt.Sprintf("%s_count", metricName)
-			} else if isSum {
-				fulleriteMetricType = metric.CumulativeCounter
-			} else {
-				fulleriteMetricType = metric.Gauge
-				metricName = fmt.Sprintf("%s_quantile", metricName)
+		case textparse.MetricTypeSummary, textparse.MetricTypeHistogram:
+			bound := entryLabels["le"]
+			if metricType == textparse.MetricTypeSummary {
+				bound = entryLabels["quantile"]
 			}
-		case textparse.MetricTypeHistogram:
-			fulleriteMetricType = metric.CumulativeCounter
-			if isCount {
-				metricName = fmt.Sprintf("%s_count", metricName)
-			} else if isBucket {
-				metricName = fmt.Sprintf("%s_bucket", metricName)
+			if !isSum && !isCount {
+				delete(entryLabels, "le")
+				delete(entryLabels, "quantile")
 			}
+
+			key := seriesKey(fulleriteMetricName, entryLabels)
+			i, exists := distributions[key]
+			if !exists {
+				m := metric.WithDistribution(fulleriteMetricName, metric.Distribution{})
+				addDimensions(&m, entryLabels, generatedDimensions)
+				i = len(metrics)
+				distributions[key] = i
+				metrics = append(metrics, m)
+			}
+			addToDistribution(&metrics[i], isSum, isCount, isBucket, bound, value)
+			continue
 		default:
 			continue
 		}
 
-		var fulleriteMetricName string
-		if prefix != "" {
-			fulleriteMetricName = fmt.Sprintf("%s%s", prefix, metricName)
-		} else {
-			fulleriteMetricName = metricName
-		}
-
-		_, _, value := parser.Series()
-
 		metric := metric.New(fulleriteMetricName)
 		metric.MetricType = fulleriteMetricType
 		metric.Value = value
-		for labelName, labelValue := range entryLabels {
-			metric.AddDimension(labelName, labelValue)
-		}
-		for dimensionName, dimensionValue := range generatedDimensions {
-			metric.AddDimension(dimensionName, dimensionValue)
-		}
+		addDimensions(&metric, entryLabels, generatedDimensions)
 		metrics = append(metrics, metric)
 	}
 
 	return metrics, err
 }
+
+func addDimensions(m *metric.Metric, entryLabels map[string]string, generatedDimensions map[string]string) {
+	for labelName, labelValue := range entryLabels {
+		m.AddDimension(labelName, labelValue)
+	}
+	for dimensionName, dimensionValue := range generatedDimensions {
+		m.AddDimension(dimensionName, dimensionValue)
+	}
+}
+
+// addToDistribution adds the value of one of the series of a histogram or
+// a summary to its distribution
+func addToDistribution(m *metric.Metric, isSum, isCount, isBucket bool, bound string, value float64) {
+	d := m.Distribution
+	switch {
+	case isSum:
+		d.Sum = value
+	case isCount:
+		d.Count = value
+		m.Value = value
+	case isBucket:
+		upperBound, err := strconv.ParseFloat(bound, 64)
+		if err != nil || math.IsInf(upperBound, 1) {
+			// the +Inf bucket is the count
+			return
+		}
+		d.Buckets = append(d.Buckets, metric.DistributionBucket{UpperBound: upperBound, Count: value})
+		sort.Slice(d.Buckets, func(i, j int) bool {
+			return d.Buckets[i].UpperBound < d.Buckets[j].UpperBound
+		})
+	default:
+		quantile, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return
+		}
+		d.Quantiles = append(d.Quantiles, metric.DistributionQuantile{Quantile: quantile, Value: value})
+	}
+}
+
+// seriesKey identifies a series by its name and its labels
+func seriesKey(name string, entryLabels map[string]string) string {
+	keys := make([]string, 0, len(entryLabels))
+	for k := range entryLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var key bytes.Buffer
+	key.WriteString(name)
+	for _, k := range keys {
+		fmt.Fprintf(&key, "\x00%s=%s", k, entryLabels[k])
+	}
+	return key.String()
+}
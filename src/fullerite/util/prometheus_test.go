@@ -299,7 +299,58 @@ func TestExtractPrometheusMetrics(t *testing.T) {
 	actualMetrics, err := ExtractPrometheusMetrics(body, contentType, nil, nil, "", map[string]string{}, nil)
 
 	assert.Nil(t, err)
-	assert.Equal(t, expectedMetrics, actualMetrics)
+	flattened := []metric.Metric{}
+	for _, m := range actualMetrics {
+		flattened = append(flattened, m.Flatten()...)
+	}
+	assert.Equal(t, expectedMetrics, flattened)
+}
+
+func TestExtractPrometheusMetricsDistributions(t *testing.T) {
+	actualMetrics, err := ExtractPrometheusMetrics(
+		body,
+		contentType,
+		map[string]bool{
+			"kubelet_docker_operations_latency_microseconds": true,
+			"kubelet_cgroup_manager_duration_seconds":        true,
+		},
+		nil,
+		"",
+		map[string]string{"foo": "bar"},
+		nil,
+	)
+
+	assert.Nil(t, err)
+	assert.Len(t, actualMetrics, 4)
+
+	summary := actualMetrics[0]
+	assert.Equal(t, "kubelet_docker_operations_latency_microseconds", summary.Name)
+	assert.Equal(t, metric.Histogram, summary.MetricType)
+	assert.Equal(t, 202.0, summary.Value)
+	assert.Equal(t, map[string]string{"operation_type": "stop_container", "foo": "bar"}, summary.Dimensions)
+	assert.Equal(t, &metric.Distribution{
+		Count: 202,
+		Sum:   1.165381e+06,
+		Quantiles: []metric.DistributionQuantile{
+			{Quantile: 0.5, Value: 123},
+			{Quantile: 0.9, Value: 456},
+			{Quantile: 0.99, Value: 789},
+		},
+	}, summary.Distribution)
+
+	histogram := actualMetrics[2]
+	assert.Equal(t, "kubelet_cgroup_manager_duration_seconds", histogram.Name)
+	assert.Equal(t, metric.Histogram, histogram.MetricType)
+	assert.Equal(t, map[string]string{"operation_type": "create", "foo": "bar"}, histogram.Dimensions)
+	assert.Equal(t, &metric.Distribution{
+		Count: 3,
+		Sum:   0.01842405,
+		Buckets: []metric.DistributionBucket{
+			{UpperBound: 0.005, Count: 2},
+			{UpperBound: 0.01, Count: 3},
+			{UpperBound: 10, Count: 3},
+		},
+	}, histogram.Distribution)
 }
 
 func TestExtractPrometheusMetricsWithPrefixAndWhitelist(t *testing.T) {
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisOptions is where and as whom to connect to a Redis server
+type RedisOptions struct {
+	// Net is tcp or unix
+	Net  string
+	Addr string
+	// User is the ACL user of Redis 6, the password alone authenticates
+	// against requirepass
+	User     string
+	Password string
+	// TLS is the configuration of a TLS connection, nil for plain text
+	TLS *tls.Config
+}
+
+// RedisError is an error reply of the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisConn is a minimal Redis client speaking RESP2. The replies are
+// strings for simple and bulk strings, int64 for integers, []interface{}
+// for arrays and nil for the null bulk string and array.
+type RedisConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// DialRedis connects and authenticates to the server, every command of the
+// connection has to complete within timeout
+func DialRedis(options RedisOptions, timeout time.Duration) (*RedisConn, error) {
+	network := options.Net
+	if network == "" {
+		network = "tcp"
+	}
+
+	var conn net.Conn
+	var err error
+	if options.TLS != nil && network == "tcp" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, options.Addr, options.TLS)
+	} else {
+		conn, err = net.DialTimeout(network, options.Addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RedisConn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	if options.Password != "" {
+		args := []string{"AUTH", options.Password}
+		if options.User != "" {
+			args = []string{"AUTH", options.User, options.Password}
+		}
+		if _, err := c.Do(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Do sends a command and returns its reply, an error reply is a RedisError
+func (c *RedisConn) Do(args ...string) (interface{}, error) {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	command := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		command = append(command, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+	if _, err := c.conn.Write(command); err != nil {
+		return nil, err
+	}
+
+	reply, err := readRedisReply(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(RedisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// Close closes the connection
+func (c *RedisConn) Close() error {
+	return c.conn.Close()
+}
+
+// readRedisReply reads a reply, the error replies nested in an array are
+// returned as RedisError values
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(line, "\r\n") || len(line) < 3 {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk string length %q", line)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			value, err := readRedisReply(reader)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line)
+}
+
+// ParseRedisInfo returns the fields of the INFO reply by section, the
+// sections are lower case like "server" or "keyspace"
+func ParseRedisInfo(info string) map[string]map[string]string {
+	sections := map[string]map[string]string{}
+	section := map[string]string{}
+	sections[""] = section
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			name := strings.ToLower(strings.TrimSpace(line[1:]))
+			if section = sections[name]; section == nil {
+				section = map[string]string{}
+				sections[name] = section
+			}
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			section[line[:i]] = line[i+1:]
+		}
+	}
+	return sections
+}
+
+// ParseRedisInfoFields splits the values of the INFO lines made of
+// comma separated fields, like "keys=1,expires=0" in the keyspace section
+func ParseRedisInfoFields(value string) map[string]string {
+	fields := map[string]string{}
+	for _, field := range strings.Split(value, ",") {
+		if i := strings.Index(field, "="); i > 0 {
+			fields[field[:i]] = field[i+1:]
+		}
+	}
+	return fields
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis is a Redis server answering canned replies to the commands,
+// requiring AUTH when password is set
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	replies  map[string]string
+}
+
+// newFakeRedis starts the server, its password and replies are set before
+// serving as the connections read them
+func newFakeRedis(t *testing.T, password string, replies map[string]string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{listener: listener, password: password, replies: replies}
+	go s.serve()
+	return s
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		command, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+		args := []string{}
+		for _, arg := range command.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		reply, exists := s.replies[strings.Join(args, " ")]
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == s.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case !exists:
+			reply = "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisConnDo(t *testing.T) {
+	s := newFakeRedis(t, "", map[string]string{
+		"PING":          "+PONG\r\n",
+		"INFO all":      "$28\r\n# Server\r\nuptime_in_days:1\r\n\r\n",
+		"SLOWLOG LEN":   ":3\r\n",
+		"GET missing":   "$-1\r\n",
+		"SLOWLOG GET 1": "*1\r\n*3\r\n:14\r\n:1600000000\r\n*2\r\n$3\r\nGET\r\n$-1\r\n",
+	})
+	defer s.listener.Close()
+
+	conn, err := DialRedis(RedisOptions{Addr: s.listener.Addr().String()}, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	reply, err := conn.Do("PING")
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	reply, err = conn.Do("INFO", "all")
+	assert.Nil(t, err)
+	assert.Equal(t, "# Server\r\nuptime_in_days:1\r\n", reply)
+
+	reply, err = conn.Do("SLOWLOG", "LEN")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), reply)
+
+	reply, err = conn.Do("GET", "missing")
+	assert.Nil(t, err)
+	assert.Nil(t, reply)
+
+	reply, err = conn.Do("SLOWLOG", "GET", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{int64(14), int64(1600000000), []interface{}{"GET", nil}},
+	}, reply)
+
+	_, err = conn.Do("CONFIG", "GET", "maxmemory")
+	assert.Equal(t, RedisError("ERR unknown command 'CONFIG'"), err)
+}
+
+func TestDialRedisAuth(t *testing.T) {
+	s := newFakeRedis(t, "secret", map[string]string{"PING": "+PONG\r\n"})
+	defer s.listener.Close()
+
+	conn, err := DialRedis(RedisOptions{Addr: s.listener.Addr().String(), User: "fullerite", Password: "secret"}, time.Second)
+	assert.Nil(t, err)
+	reply, err := conn.Do("PING")
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", reply)
+	conn.Close()
+
+	_, err = DialRedis(RedisOptions{Addr: s.listener.Addr().String(), Password: "wrong"}, time.Second)
+	assert.Equal(t, RedisError("WRONGPASS invalid username-password pair"), err)
+
+	conn, err = DialRedis(RedisOptions{Addr: s.listener.Addr().String()}, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Do("PING")
+	assert.Equal(t, RedisError("NOAUTH Authentication required."), err)
+}
+
+func TestDialRedisTLS(t *testing.T) {
+	// borrow the self-signed certificate of httptest, valid for 127.0.0.1
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	defer ts.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	assert.Nil(t, err)
+	s := &fakeRedis{listener: listener, replies: map[string]string{"PING": "+PONG\r\n"}}
+	go s.serve()
+	defer listener.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	conn, err := DialRedis(RedisOptions{Addr: listener.Addr().String(), TLS: &tls.Config{RootCAs: roots}}, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+	reply, err := conn.Do("PING")
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	_, err = DialRedis(RedisOptions{Addr: listener.Addr().String(), TLS: &tls.Config{}}, time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestReadRedisReplyInvalid(t *testing.T) {
+	for _, reply := range []string{"PONG\r\n", "+PONG\n", "$abc\r\n", "?1\r\n", "$10\r\nshort\r\n"} {
+		_, err := readRedisReply(bufio.NewReader(strings.NewReader(reply)))
+		assert.NotNil(t, err, reply)
+	}
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	info := ParseRedisInfo("# Server\r\nredis_version:6.2.6\r\nuptime_in_seconds:10\r\n\r\n" +
+		"# Keyspace\r\ndb0:keys=1,expires=0,avg_ttl=0\r\n")
+	assert.Equal(t, map[string]map[string]string{
+		"":         {},
+		"server":   {"redis_version": "6.2.6", "uptime_in_seconds": "10"},
+		"keyspace": {"db0": "keys=1,expires=0,avg_ttl=0"},
+	}, info)
+}
+
+func TestParseRedisInfoFields(t *testing.T) {
+	assert.Equal(t,
+		map[string]string{"ip": "10.0.0.2", "port": "6379", "state": "online", "offset": "1000", "lag": "0"},
+		ParseRedisInfoFields("ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0"),
+	)
+	assert.Equal(t, map[string]string{}, ParseRedisInfoFields("invalid"))
+}
@@ -0,0 +1,167 @@
+package util
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The states of the kernel, indexed by their number, UDP sockets are
+// established when connected and close otherwise
+var socketStates = []string{
+	"unknown",
+	"established",
+	"syn_sent",
+	"syn_recv",
+	"fin_wait1",
+	"fin_wait2",
+	"time_wait",
+	"close",
+	"close_wait",
+	"last_ack",
+	"listen",
+	"closing",
+	"new_syn_recv",
+}
+
+// Socket is a TCP or UDP socket as listed by the kernel. For listening
+// sockets RecvQ is the accept queue and SendQ its maximum length, the
+// maximum is not in /proc. The buffer sizes and the drops are only read
+// over netlink, but for the drops of UDP sockets.
+type Socket struct {
+	// Protocol is tcp or udp
+	Protocol string
+	// Family is ipv4 or ipv6
+	Family     string
+	State      string
+	LocalIP    net.IP
+	LocalPort  int
+	RemoteIP   net.IP
+	RemotePort int
+	RecvQ      uint32
+	SendQ      uint32
+	RcvBuf     uint32
+	SndBuf     uint32
+	Drops      uint32
+	Inode      uint32
+}
+
+// ListSockets returns the sockets of a protocol over netlink sock_diag,
+// falling back to the /proc/net files under procPath when netlink fails
+func ListSockets(procPath string, protocol string) ([]Socket, error) {
+	sockets, err := SockDiagSockets(protocol)
+	if err == nil {
+		return sockets, nil
+	}
+	sockets, procErr := ProcNetSockets(procPath, protocol)
+	if procErr != nil {
+		return nil, fmt.Errorf("sock_diag: %s, /proc: %s", err, procErr)
+	}
+	return sockets, nil
+}
+
+// ProcNetSockets returns the IPv4 and IPv6 sockets of a protocol listed in
+// /proc/net/{tcp,tcp6,udp,udp6} under procPath. A missing IPv6 file means
+// IPv6 is disabled.
+func ProcNetSockets(procPath string, protocol string) ([]Socket, error) {
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, fmt.Errorf("unknown protocol %s", protocol)
+	}
+
+	sockets := []Socket{}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		path := filepath.Join(procPath, "net", protocol)
+		if family == "ipv6" {
+			path += "6"
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			if family == "ipv6" {
+				continue
+			}
+			return nil, err
+		}
+		parsed, err := ParseProcNetSockets(string(contents), protocol, family)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		sockets = append(sockets, parsed...)
+	}
+	return sockets, nil
+}
+
+// ParseProcNetSockets parses the content of a /proc/net/{tcp,udp}{,6} file,
+// the lines look like:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+//	 0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 14821 1 0000000000000000 100 0 0 10 0
+func ParseProcNetSockets(contents string, protocol string, family string) ([]Socket, error) {
+	sockets := []Socket{}
+	lines := strings.Split(strings.TrimSpace(contents), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		socket := Socket{Protocol: protocol, Family: family}
+
+		var err error
+		if socket.LocalIP, socket.LocalPort, err = parseProcNetAddress(fields[1]); err != nil {
+			return nil, err
+		}
+		if socket.RemoteIP, socket.RemotePort, err = parseProcNetAddress(fields[2]); err != nil {
+			return nil, err
+		}
+		state, _ := strconv.ParseUint(fields[3], 16, 8)
+		socket.State = socketStateName(int(state))
+
+		queues := strings.Split(fields[4], ":")
+		if len(queues) == 2 {
+			sendQ, _ := strconv.ParseUint(queues[0], 16, 32)
+			recvQ, _ := strconv.ParseUint(queues[1], 16, 32)
+			socket.SendQ, socket.RecvQ = uint32(sendQ), uint32(recvQ)
+		}
+		inode, _ := strconv.ParseUint(fields[9], 10, 32)
+		socket.Inode = uint32(inode)
+		if protocol == "udp" && len(fields) > 12 {
+			drops, _ := strconv.ParseUint(fields[12], 10, 32)
+			socket.Drops = uint32(drops)
+		}
+		sockets = append(sockets, socket)
+	}
+	return sockets, nil
+}
+
+// parseProcNetAddress decodes an address like 0100007F:0050, the address
+// is made of 32 bits words in host order and the port is big endian
+func parseProcNetAddress(address string) (net.IP, int, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port %s", address)
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		ip[word], ip[word+1], ip[word+2], ip[word+3] = raw[word+3], raw[word+2], raw[word+1], raw[word]
+	}
+	return ip, int(port), nil
+}
+
+func socketStateName(state int) string {
+	if state <= 0 || state >= len(socketStates) {
+		return socketStates[0]
+	}
+	return socketStates[state]
+}
@@ -0,0 +1,181 @@
+// +build linux
+
+package util
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// sock_diag constants, see linux/sock_diag.h and linux/inet_diag.h
+const (
+	netlinkSockDiag   = 4
+	sockDiagByFamily  = 20
+	inetDiagMemInfo   = 1
+	inetDiagSkMemInfo = 7
+
+	// the indexes of the INET_DIAG_SKMEMINFO values
+	skMemInfoRcvBuf = 1
+	skMemInfoSndBuf = 3
+	skMemInfoDrops  = 8
+
+	inetDiagReqLen = 56
+	inetDiagMsgLen = 72
+)
+
+// netlink headers are in host byte order, the ports and the addresses of
+// inet_diag_sockid in network byte order
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	one := uint16(1)
+	if *(*byte)(unsafe.Pointer(&one)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// SockDiagSockets returns the IPv4 and IPv6 sockets of a protocol, tcp or
+// udp, dumped over netlink sock_diag
+func SockDiagSockets(protocol string) ([]Socket, error) {
+	var proto uint8
+	switch protocol {
+	case "tcp":
+		proto = syscall.IPPROTO_TCP
+	case "udp":
+		proto = syscall.IPPROTO_UDP
+	default:
+		return nil, fmt.Errorf("unknown protocol %s", protocol)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkSockDiag)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	sockets := []Socket{}
+	for seq, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		if err := sendSockDiagRequest(fd, uint32(seq+1), family, proto); err != nil {
+			return nil, err
+		}
+		dumped, err := receiveSockDiagDump(fd, protocol)
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, dumped...)
+	}
+	return sockets, nil
+}
+
+// sendSockDiagRequest asks for a dump of the sockets of a family in any
+// state, with their queues and memory
+func sendSockDiagRequest(fd int, seq uint32, family uint8, protocol uint8) error {
+	request := make([]byte, syscall.NLMSG_HDRLEN+inetDiagReqLen)
+	nativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	nativeEndian.PutUint16(request[4:6], sockDiagByFamily)
+	nativeEndian.PutUint16(request[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	nativeEndian.PutUint32(request[8:12], seq)
+
+	// inet_diag_req_v2, the socket id is left empty to match them all
+	req := request[syscall.NLMSG_HDRLEN:]
+	req[0] = family
+	req[1] = protocol
+	req[2] = 1<<(inetDiagMemInfo-1) | 1<<(inetDiagSkMemInfo-1)
+	nativeEndian.PutUint32(req[4:8], 0xffffffff)
+
+	return syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// receiveSockDiagDump reads the messages of a dump until its end
+func receiveSockDiagDump(fd int, protocol string) ([]Socket, error) {
+	sockets := []Socket{}
+	buffer := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			switch message.Header.Type {
+			case syscall.NLMSG_DONE:
+				return sockets, nil
+			case syscall.NLMSG_ERROR:
+				if len(message.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(message.Data[0:4])); errno != 0 {
+						return nil, os.NewSyscallError("sock_diag", syscall.Errno(-errno))
+					}
+				}
+				return nil, fmt.Errorf("sock_diag: invalid error message")
+			case sockDiagByFamily:
+				socket, err := parseInetDiagMsg(message.Data, protocol)
+				if err != nil {
+					return nil, err
+				}
+				sockets = append(sockets, socket)
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg reads an inet_diag_msg and its attributes
+func parseInetDiagMsg(data []byte, protocol string) (Socket, error) {
+	if len(data) < inetDiagMsgLen {
+		return Socket{}, fmt.Errorf("sock_diag: message too short")
+	}
+	socket := Socket{
+		Protocol:   protocol,
+		Family:     "ipv4",
+		State:      socketStateName(int(data[1])),
+		LocalPort:  int(binary.BigEndian.Uint16(data[4:6])),
+		RemotePort: int(binary.BigEndian.Uint16(data[6:8])),
+		RecvQ:      nativeEndian.Uint32(data[56:60]),
+		SendQ:      nativeEndian.Uint32(data[60:64]),
+		Inode:      nativeEndian.Uint32(data[68:72]),
+	}
+	if data[0] == syscall.AF_INET6 {
+		socket.Family = "ipv6"
+		socket.LocalIP = net.IP(append([]byte{}, data[8:24]...))
+		socket.RemoteIP = net.IP(append([]byte{}, data[24:40]...))
+	} else {
+		socket.LocalIP = net.IP(append([]byte{}, data[8:12]...))
+		socket.RemoteIP = net.IP(append([]byte{}, data[24:28]...))
+	}
+
+	attributes := data[inetDiagMsgLen:]
+	for len(attributes) >= syscall.SizeofRtAttr {
+		length := int(nativeEndian.Uint16(attributes[0:2]))
+		kind := nativeEndian.Uint16(attributes[2:4])
+		if length < syscall.SizeofRtAttr || length > len(attributes) {
+			break
+		}
+		value := attributes[syscall.SizeofRtAttr:length]
+		if kind == inetDiagSkMemInfo {
+			memInfo := func(index int) uint32 {
+				if len(value) < (index+1)*4 {
+					return 0
+				}
+				return nativeEndian.Uint32(value[index*4 : index*4+4])
+			}
+			socket.RcvBuf = memInfo(skMemInfoRcvBuf)
+			socket.SndBuf = memInfo(skMemInfoSndBuf)
+			socket.Drops = memInfo(skMemInfoDrops)
+		}
+		aligned := (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if aligned > len(attributes) {
+			break
+		}
+		attributes = attributes[aligned:]
+	}
+	return socket, nil
+}
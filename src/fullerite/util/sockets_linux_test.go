@@ -0,0 +1,46 @@
+// +build linux
+
+package util
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSockDiagSockets(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen: ", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	sockets, err := SockDiagSockets("tcp")
+	if err != nil {
+		t.Skip("sock_diag is not available: ", err)
+	}
+
+	var found *Socket
+	for i, socket := range sockets {
+		if socket.LocalPort == port && socket.State == "listen" {
+			found = &sockets[i]
+		}
+	}
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "tcp", found.Protocol)
+		assert.Equal(t, "ipv4", found.Family)
+		assert.Equal(t, "127.0.0.1", found.LocalIP.String())
+		assert.True(t, found.SendQ > 0)
+		assert.True(t, found.RcvBuf > 0)
+	}
+
+	_, err = SockDiagSockets("sctp")
+	assert.NotNil(t, err)
+}
+
+func TestParseInetDiagMsgTooShort(t *testing.T) {
+	_, err := parseInetDiagMsg(make([]byte, 10), "tcp")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package util
+
+import "fmt"
+
+// SockDiagSockets is only available on linux, ListSockets falls back to
+// /proc elsewhere
+func SockDiagSockets(protocol string) ([]Socket, error) {
+	return nil, fmt.Errorf("sock_diag is only available on linux")
+}
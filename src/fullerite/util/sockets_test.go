@@ -0,0 +1,93 @@
+package util
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000003 00:00000000 00000000   106        0 21234 1 0000000000000000 100 0 0 10 0
+   1: 0500000A:1F90 0600000A:A028 01 00000010:00000000 01:00000014 00000000  1000        0 31415 1 0000000000000000 20 4 30 10 -1
+`
+	testProcNetTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 18000 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:1F90 00000000000000000000000001000000:C350 01 00000000:00000000 00:00000000 00000000     0        0 18001 1 0000000000000000 20 4 30 10 -1
+`
+	testProcNetUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+ 3152: 00000000:1FBD 00000000:0000 07 00000000:00000200 00:00000000 00000000 65534        0 38412 2 0000000000000000 42
+`
+)
+
+func TestParseProcNetSockets(t *testing.T) {
+	sockets, err := ParseProcNetSockets(testProcNetTCP, "tcp", "ipv4")
+	assert.Nil(t, err)
+	assert.Equal(t, []Socket{
+		{
+			Protocol: "tcp", Family: "ipv4", State: "listen",
+			LocalIP: net.IP{127, 0, 0, 1}, LocalPort: 3306, RemoteIP: net.IP{0, 0, 0, 0},
+			RecvQ: 3, Inode: 21234,
+		},
+		{
+			Protocol: "tcp", Family: "ipv4", State: "established",
+			LocalIP: net.IP{10, 0, 0, 5}, LocalPort: 8080, RemoteIP: net.IP{10, 0, 0, 6}, RemotePort: 41000,
+			SendQ: 16, Inode: 31415,
+		},
+	}, sockets)
+}
+
+func TestParseProcNetSocketsIPv6(t *testing.T) {
+	sockets, err := ParseProcNetSockets(testProcNetTCP6, "tcp", "ipv6")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sockets))
+	assert.Equal(t, "::", sockets[0].LocalIP.String())
+	assert.Equal(t, 22, sockets[0].LocalPort)
+	assert.Equal(t, "listen", sockets[0].State)
+	assert.Equal(t, "::1", sockets[1].LocalIP.String())
+	assert.Equal(t, "::1", sockets[1].RemoteIP.String())
+	assert.Equal(t, 50000, sockets[1].RemotePort)
+	assert.Equal(t, "ipv6", sockets[1].Family)
+}
+
+func TestParseProcNetSocketsUDP(t *testing.T) {
+	sockets, err := ParseProcNetSockets(testProcNetUDP, "udp", "ipv4")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sockets))
+	assert.Equal(t, "close", sockets[0].State)
+	assert.Equal(t, 8125, sockets[0].LocalPort)
+	assert.Equal(t, uint32(512), sockets[0].RecvQ)
+	assert.Equal(t, uint32(42), sockets[0].Drops)
+}
+
+func TestParseProcNetSocketsInvalid(t *testing.T) {
+	_, err := ParseProcNetSockets("header\n 0: 0100007F 00000000:0000 0A 0:0 0 0 0 0 0", "tcp", "ipv4")
+	assert.NotNil(t, err)
+}
+
+func TestProcNetSockets(t *testing.T) {
+	procPath, err := ioutil.TempDir("", "proc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(procPath)
+	os.Mkdir(filepath.Join(procPath, "net"), 0755)
+	ioutil.WriteFile(filepath.Join(procPath, "net", "tcp"), []byte(testProcNetTCP), 0644)
+
+	// without tcp6, IPv6 is disabled
+	sockets, err := ProcNetSockets(procPath, "tcp")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sockets))
+
+	ioutil.WriteFile(filepath.Join(procPath, "net", "tcp6"), []byte(testProcNetTCP6), 0644)
+	sockets, err = ProcNetSockets(procPath, "tcp")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(sockets))
+
+	_, err = ProcNetSockets(procPath, "udp")
+	assert.NotNil(t, err)
+	_, err = ProcNetSockets(procPath, "sctp")
+	assert.NotNil(t, err)
+}
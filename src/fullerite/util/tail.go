@@ -0,0 +1,229 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const tailReadSize = 64 * 1024
+
+// TailOffset is how far a file was read, the offset is the end of its last
+// complete line
+type TailOffset struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// Tailer follows files by path like tail -F does. It reads the end of a
+// file renamed by its rotation before following the new one from its start,
+// and starts over a truncated file. A file is followed from its end, or
+// from its start with FromBeginning, unless it was read up to an offset
+// given to NewTailer.
+type Tailer struct {
+	FromBeginning bool
+
+	files   map[string]*tailedFile
+	offsets map[string]TailOffset
+}
+
+type tailedFile struct {
+	file    *os.File
+	info    os.FileInfo
+	inode   uint64
+	offset  int64
+	pending []byte
+}
+
+// NewTailer creates a tailer resuming from the offsets, saved by
+// SaveTailOffsets for instance
+func NewTailer(offsets map[string]TailOffset) *Tailer {
+	if offsets == nil {
+		offsets = map[string]TailOffset{}
+	}
+	return &Tailer{
+		files:   map[string]*tailedFile{},
+		offsets: offsets,
+	}
+}
+
+// Poll calls fn with each line appended to a file since the last call,
+// without its line ending. A line is only read once complete.
+func (t *Tailer) Poll(path string, fn func(line string)) error {
+	info, err := os.Stat(path)
+	tailed := t.files[path]
+	if err != nil {
+		// the file was renamed and not created yet
+		if tailed != nil {
+			return t.read(path, tailed, fn)
+		}
+		return err
+	}
+
+	rotated := false
+	if tailed != nil && !os.SameFile(tailed.info, info) {
+		if err := t.read(path, tailed, fn); err != nil {
+			return err
+		}
+		tailed.file.Close()
+		delete(t.files, path)
+		tailed = nil
+		rotated = true
+	}
+
+	if tailed == nil {
+		if tailed, err = t.open(path, rotated); err != nil {
+			return err
+		}
+		t.files[path] = tailed
+	} else if info.Size() < tailed.offset+int64(len(tailed.pending)) {
+		// truncated
+		if _, err := tailed.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		tailed.offset = 0
+		tailed.pending = nil
+		t.offsets[path] = TailOffset{Inode: tailed.inode}
+	}
+
+	return t.read(path, tailed, fn)
+}
+
+func (t *Tailer) open(path string, rotated bool) (*tailedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	tailed := &tailedFile{file: file, info: info, inode: FileInode(info)}
+	if saved, exists := t.offsets[path]; exists && saved.Inode == tailed.inode && saved.Offset <= info.Size() {
+		tailed.offset = saved.Offset
+	} else if !rotated && !t.FromBeginning {
+		tailed.offset = info.Size()
+	}
+
+	if _, err := file.Seek(tailed.offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	t.offsets[path] = TailOffset{Inode: tailed.inode, Offset: tailed.offset}
+	return tailed, nil
+}
+
+func (t *Tailer) read(path string, tailed *tailedFile, fn func(line string)) error {
+	buffer := make([]byte, tailReadSize)
+	for {
+		n, err := tailed.file.Read(buffer)
+		if n > 0 {
+			data := append(tailed.pending, buffer[:n]...)
+			for {
+				end := bytes.IndexByte(data, '\n')
+				if end < 0 {
+					break
+				}
+				fn(strings.TrimSuffix(string(data[:end]), "\r"))
+				tailed.offset += int64(end + 1)
+				data = data[end+1:]
+			}
+			tailed.pending = append([]byte{}, data...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.offsets[path] = TailOffset{Inode: tailed.inode, Offset: tailed.offset}
+	return nil
+}
+
+// Offsets returns how far each file was read
+func (t *Tailer) Offsets() map[string]TailOffset {
+	offsets := make(map[string]TailOffset, len(t.offsets))
+	for path, offset := range t.offsets {
+		offsets[path] = offset
+	}
+	return offsets
+}
+
+// Paths returns the paths followed or with an offset, sorted
+func (t *Tailer) Paths() []string {
+	paths := make([]string, 0, len(t.offsets))
+	for path := range t.offsets {
+		paths = append(paths, path)
+	}
+	for path := range t.files {
+		if _, exists := t.offsets[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Forget closes the file of a path and forgets its offset
+func (t *Tailer) Forget(path string) {
+	if tailed, exists := t.files[path]; exists {
+		tailed.file.Close()
+		delete(t.files, path)
+	}
+	delete(t.offsets, path)
+}
+
+// Close closes the files followed
+func (t *Tailer) Close() {
+	for path, tailed := range t.files {
+		tailed.file.Close()
+		delete(t.files, path)
+	}
+}
+
+// LoadTailOffsets reads the offsets saved in a file, none when it does not
+// exist
+func LoadTailOffsets(path string) (map[string]TailOffset, error) {
+	offsets := map[string]TailOffset{}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &offsets); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// SaveTailOffsets writes the offsets to a file, replacing it atomically
+func SaveTailOffsets(path string, offsets map[string]TailOffset) error {
+	contents, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,16 @@
+// +build linux
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileInode returns the inode of a file
+func FileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tailTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tail")
+	assert.Nil(t, err)
+	return dir
+}
+
+func appendLines(t *testing.T, path string, contents string) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString(contents)
+	assert.Nil(t, err)
+	file.Close()
+}
+
+func pollLines(t *testing.T, tailer *Tailer, path string) []string {
+	lines := []string{}
+	err := tailer.Poll(path, func(line string) { lines = append(lines, line) })
+	assert.Nil(t, err)
+	return lines
+}
+
+func TestTailerFollowsFromTheEnd(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "old line\n")
+
+	tailer := NewTailer(nil)
+	defer tailer.Close()
+	assert.Equal(t, []string{}, pollLines(t, tailer, path))
+
+	appendLines(t, path, "first\r\nsecond\nthi")
+	assert.Equal(t, []string{"first", "second"}, pollLines(t, tailer, path))
+	assert.Equal(t, int64(len("old line\nfirst\r\nsecond\n")), tailer.Offsets()[path].Offset)
+
+	appendLines(t, path, "rd\n")
+	assert.Equal(t, []string{"third"}, pollLines(t, tailer, path))
+}
+
+func TestTailerFromBeginning(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "old line\n")
+
+	tailer := NewTailer(nil)
+	tailer.FromBeginning = true
+	defer tailer.Close()
+	assert.Equal(t, []string{"old line"}, pollLines(t, tailer, path))
+}
+
+func TestTailerRotation(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "")
+
+	tailer := NewTailer(nil)
+	defer tailer.Close()
+	pollLines(t, tailer, path)
+
+	appendLines(t, path, "before\n")
+	assert.Nil(t, os.Rename(path, path+".1"))
+	appendLines(t, path+".1", "late\n")
+
+	// the file is not created yet
+	assert.Equal(t, []string{"before", "late"}, pollLines(t, tailer, path))
+
+	appendLines(t, path+".1", "later\n")
+	appendLines(t, path, "after\n")
+	assert.Equal(t, []string{"later", "after"}, pollLines(t, tailer, path))
+	assert.Equal(t, int64(len("after\n")), tailer.Offsets()[path].Offset)
+}
+
+func TestTailerTruncation(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "")
+
+	tailer := NewTailer(nil)
+	defer tailer.Close()
+	pollLines(t, tailer, path)
+	appendLines(t, path, "a long line before the truncation\n")
+	assert.Equal(t, []string{"a long line before the truncation"}, pollLines(t, tailer, path))
+
+	assert.Nil(t, os.Truncate(path, 0))
+	appendLines(t, path, "after\n")
+	assert.Equal(t, []string{"after"}, pollLines(t, tailer, path))
+}
+
+func TestTailerForget(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "")
+
+	tailer := NewTailer(map[string]TailOffset{"/var/log/old.log": {Inode: 1}})
+	defer tailer.Close()
+	pollLines(t, tailer, path)
+	assert.Equal(t, []string{path, "/var/log/old.log"}, tailer.Paths())
+
+	tailer.Forget("/var/log/old.log")
+	tailer.Forget(path)
+	assert.Equal(t, []string{}, tailer.Paths())
+	assert.Equal(t, map[string]TailOffset{}, tailer.Offsets())
+
+	// a path forgotten is followed again from its end
+	appendLines(t, path, "before\n")
+	assert.Equal(t, []string{}, pollLines(t, tailer, path))
+	appendLines(t, path, "after\n")
+	assert.Equal(t, []string{"after"}, pollLines(t, tailer, path))
+}
+
+func TestTailerMissingFile(t *testing.T) {
+	tailer := NewTailer(nil)
+	err := tailer.Poll("/does/not/exist.log", func(string) {})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTailerResumesFromOffsets(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	stateFile := filepath.Join(dir, "offsets")
+	appendLines(t, path, "")
+
+	tailer := NewTailer(nil)
+	pollLines(t, tailer, path)
+	appendLines(t, path, "read\n")
+	pollLines(t, tailer, path)
+	assert.Nil(t, SaveTailOffsets(stateFile, tailer.Offsets()))
+	tailer.Close()
+
+	appendLines(t, path, "while stopped\n")
+
+	offsets, err := LoadTailOffsets(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, tailer.Offsets(), offsets)
+	tailer = NewTailer(offsets)
+	defer tailer.Close()
+	assert.Equal(t, []string{"while stopped"}, pollLines(t, tailer, path))
+}
+
+func TestTailerIgnoresOffsetsOfAnotherFile(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "new file\n")
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	// an offset past the end of the file is never restored
+	tailer := NewTailer(map[string]TailOffset{path: {Inode: FileInode(info), Offset: 1000}})
+	tailer.FromBeginning = true
+	defer tailer.Close()
+	assert.Equal(t, []string{"new file"}, pollLines(t, tailer, path))
+}
+
+func TestLoadTailOffsetsMissingFile(t *testing.T) {
+	offsets, err := LoadTailOffsets("/does/not/exist")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]TailOffset{}, offsets)
+}
+
+func TestLoadTailOffsetsInvalidFile(t *testing.T) {
+	dir := tailTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "offsets")
+	ioutil.WriteFile(path, []byte("{"), 0644)
+
+	_, err := LoadTailOffsets(path)
+	assert.NotNil(t, err)
+}
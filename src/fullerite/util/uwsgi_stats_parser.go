@@ -5,6 +5,7 @@ import (
 
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -50,3 +51,114 @@ func ParseUWSGIWorkersStats(raw []byte) ([]metric.Metric, error) {
 	}
 	return results, err
 }
+
+// uWSGIStats is the document written by the uWSGI stats server, see
+// http://uwsgi-docs.readthedocs.io/en/latest/StatsServer.html
+type uWSGIStats struct {
+	ListenQueue       float64 `json:"listen_queue"`
+	ListenQueueErrors float64 `json:"listen_queue_errors"`
+	Load              float64 `json:"load"`
+	Sockets           []struct {
+		Name     string  `json:"name"`
+		Queue    float64 `json:"queue"`
+		MaxQueue float64 `json:"max_queue"`
+	} `json:"sockets"`
+	Workers []struct {
+		ID            int     `json:"id"`
+		Status        string  `json:"status"`
+		Requests      float64 `json:"requests"`
+		Exceptions    float64 `json:"exceptions"`
+		HarakiriCount float64 `json:"harakiri_count"`
+		RespawnCount  float64 `json:"respawn_count"`
+		RSS           float64 `json:"rss"`
+		VSZ           float64 `json:"vsz"`
+		AvgRt         float64 `json:"avg_rt"`
+		RunningTime   float64 `json:"running_time"`
+		Tx            float64 `json:"tx"`
+		Cores         []struct {
+			InRequest float64 `json:"in_request"`
+		} `json:"cores"`
+	} `json:"workers"`
+}
+
+// ParseUWSGIStats returns the metrics of each worker, with a "worker"
+// dimension, and of the whole server read from the JSON written by a uWSGI
+// stats server. Average response times are in microseconds, memory sizes
+// in bytes.
+func ParseUWSGIStats(raw []byte) ([]metric.Metric, error) {
+	var stats uWSGIStats
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, err
+	}
+
+	results := []metric.Metric{}
+	add := func(name string, metricType string, value float64, dimensions map[string]string) {
+		m := metric.WithValue("uwsgi."+name, value)
+		m.MetricType = metricType
+		m.AddDimensions(dimensions)
+		results = append(results, m)
+	}
+
+	add("listen_queue", metric.Gauge, stats.ListenQueue, nil)
+	add("listen_queue_errors", metric.CumulativeCounter, stats.ListenQueueErrors, nil)
+	add("load", metric.Gauge, stats.Load, nil)
+	for _, socket := range stats.Sockets {
+		dimensions := map[string]string{"socket": socket.Name}
+		add("socket.queue", metric.Gauge, socket.Queue, dimensions)
+		add("socket.max_queue", metric.Gauge, socket.MaxQueue, dimensions)
+	}
+
+	var requests, exceptions, harakiris, rss, busyCores, cores, avgRt, serving float64
+	statuses := map[string]float64{"idle": 0, "busy": 0}
+	for _, worker := range stats.Workers {
+		var workerBusyCores float64
+		for _, core := range worker.Cores {
+			workerBusyCores += core.InRequest
+		}
+
+		dimensions := map[string]string{"worker": strconv.Itoa(worker.ID)}
+		add("worker.requests", metric.CumulativeCounter, worker.Requests, dimensions)
+		add("worker.exceptions", metric.CumulativeCounter, worker.Exceptions, dimensions)
+		add("worker.harakiri_count", metric.CumulativeCounter, worker.HarakiriCount, dimensions)
+		add("worker.respawn_count", metric.CumulativeCounter, worker.RespawnCount, dimensions)
+		add("worker.running_time", metric.CumulativeCounter, worker.RunningTime, dimensions)
+		add("worker.tx", metric.CumulativeCounter, worker.Tx, dimensions)
+		add("worker.rss", metric.Gauge, worker.RSS, dimensions)
+		add("worker.vsz", metric.Gauge, worker.VSZ, dimensions)
+		add("worker.avg_rt", metric.Gauge, worker.AvgRt, dimensions)
+		add("worker.busy_cores", metric.Gauge, workerBusyCores, dimensions)
+
+		// Consider all status starting by sig as just "sig"
+		status := worker.Status
+		if strings.HasPrefix(status, "sig") {
+			status = "sig"
+		}
+		statuses[status]++
+
+		requests += worker.Requests
+		exceptions += worker.Exceptions
+		harakiris += worker.HarakiriCount
+		rss += worker.RSS
+		busyCores += workerBusyCores
+		cores += float64(len(worker.Cores))
+		if worker.Requests > 0 {
+			avgRt += worker.AvgRt
+			serving++
+		}
+	}
+
+	add("requests", metric.CumulativeCounter, requests, nil)
+	add("exceptions", metric.CumulativeCounter, exceptions, nil)
+	add("harakiri_count", metric.CumulativeCounter, harakiris, nil)
+	add("rss", metric.Gauge, rss, nil)
+	add("busy_cores", metric.Gauge, busyCores, nil)
+	add("cores", metric.Gauge, cores, nil)
+	if serving > 0 {
+		// the average of the workers which served requests
+		add("avg_rt", metric.Gauge, avgRt/serving, nil)
+	}
+	for status, count := range statuses {
+		add("workers", metric.Gauge, count, map[string]string{"status": status})
+	}
+	return results, nil
+}
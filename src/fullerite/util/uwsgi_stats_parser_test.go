@@ -72,3 +72,80 @@ func TestParseUWSGIWorkersStatsWeirdJSON(t *testing.T) {
 	}`))
 	assert.Equal(t, 0, len(outMetrics))
 }
+
+func getUWSGIStatsServerResponse() []byte {
+	return []byte(`{
+		"version": "2.0.18",
+		"listen_queue": 3,
+		"listen_queue_errors": 1,
+		"load": 2,
+		"sockets": [{"name": "127.0.0.1:8080", "proto": "uwsgi", "queue": 3, "max_queue": 100}],
+		"workers": [
+			{"id": 1, "status": "busy", "requests": 10, "exceptions": 1, "harakiri_count": 0,
+			 "respawn_count": 1, "rss": 1000, "vsz": 5000, "avg_rt": 200, "running_time": 3000, "tx": 4096,
+			 "cores": [{"id": 0, "in_request": 1}, {"id": 1, "in_request": 0}]},
+			{"id": 2, "status": "idle", "requests": 20, "exceptions": 0, "harakiri_count": 2,
+			 "respawn_count": 3, "rss": 2000, "vsz": 6000, "avg_rt": 400, "running_time": 5000, "tx": 1024,
+			 "cores": [{"id": 0, "in_request": 0}, {"id": 1, "in_request": 0}]},
+			{"id": 3, "status": "cheap", "requests": 0, "avg_rt": 0, "cores": []}
+		]
+	}`)
+}
+
+func TestParseUWSGIStats(t *testing.T) {
+	results, err := ParseUWSGIStats(getUWSGIStatsServerResponse())
+	assert.Nil(t, err)
+
+	actual := make(map[string]metric.Metric)
+	for _, m := range results {
+		key := m.Name
+		for _, dimension := range []string{"worker", "status", "socket"} {
+			if value, ok := m.Dimensions[dimension]; ok {
+				key += "," + value
+			}
+		}
+		actual[key] = m
+	}
+
+	expected := map[string]float64{
+		"uwsgi.listen_queue":                    3,
+		"uwsgi.listen_queue_errors":             1,
+		"uwsgi.load":                            2,
+		"uwsgi.socket.queue,127.0.0.1:8080":     3,
+		"uwsgi.socket.max_queue,127.0.0.1:8080": 100,
+		"uwsgi.worker.requests,1":               10,
+		"uwsgi.worker.exceptions,1":             1,
+		"uwsgi.worker.harakiri_count,2":         2,
+		"uwsgi.worker.respawn_count,2":          3,
+		"uwsgi.worker.rss,2":                    2000,
+		"uwsgi.worker.vsz,1":                    5000,
+		"uwsgi.worker.avg_rt,2":                 400,
+		"uwsgi.worker.running_time,1":           3000,
+		"uwsgi.worker.tx,1":                     4096,
+		"uwsgi.worker.busy_cores,1":             1,
+		"uwsgi.requests":                        30,
+		"uwsgi.exceptions":                      1,
+		"uwsgi.harakiri_count":                  2,
+		"uwsgi.rss":                             3000,
+		"uwsgi.busy_cores":                      1,
+		"uwsgi.cores":                           4,
+		"uwsgi.avg_rt":                          300,
+		"uwsgi.workers,busy":                    1,
+		"uwsgi.workers,idle":                    1,
+		"uwsgi.workers,cheap":                   1,
+	}
+	for key, value := range expected {
+		m, ok := actual[key]
+		if assert.True(t, ok, key) {
+			assert.Equal(t, value, m.Value, key)
+		}
+	}
+	assert.Equal(t, metric.CumulativeCounter, actual["uwsgi.worker.requests,1"].MetricType)
+	assert.Equal(t, metric.Gauge, actual["uwsgi.worker.rss,1"].MetricType)
+	assert.Equal(t, metric.CumulativeCounter, actual["uwsgi.requests"].MetricType)
+}
+
+func TestParseUWSGIStatsInvalidJSON(t *testing.T) {
+	_, err := ParseUWSGIStats([]byte("{\"workers\": "))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,198 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// ZooKeeper opcodes and error codes, see the jute definitions in the
+// ZooKeeper sources
+const (
+	zkOpGetData     = 4
+	zkOpGetChildren = 8
+	zkOpClose       = -11
+
+	zkErrNoNode = -101
+)
+
+// zkConn is a minimal read only ZooKeeper client, enough to find out who
+// won an election
+type zkConn struct {
+	conn    net.Conn
+	timeout time.Duration
+	xid     int32
+}
+
+// zkError is returned when ZooKeeper answers a request with an error code
+type zkError struct {
+	Path string
+	Code int32
+}
+
+func (e zkError) Error() string {
+	if e.Code == zkErrNoNode {
+		return fmt.Sprintf("zookeeper: no node %s", e.Path)
+	}
+	return fmt.Sprintf("zookeeper: error %d reading %s", e.Code, e.Path)
+}
+
+// dialZooKeeper opens a session on the first of the servers accepting it
+func dialZooKeeper(servers []string, timeout time.Duration) (*zkConn, error) {
+	err := fmt.Errorf("zookeeper: no server to connect to")
+	for _, server := range servers {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", server, timeout); err != nil {
+			continue
+		}
+		zk := &zkConn{conn: conn, timeout: timeout}
+		if err = zk.handshake(); err != nil {
+			conn.Close()
+			continue
+		}
+		return zk, nil
+	}
+	return nil, err
+}
+
+func (zk *zkConn) handshake() error {
+	var req bytes.Buffer
+	binary.Write(&req, binary.BigEndian, int32(0))                           // protocol version
+	binary.Write(&req, binary.BigEndian, int64(0))                           // last zxid seen
+	binary.Write(&req, binary.BigEndian, int32(zk.timeout/time.Millisecond)) // session timeout
+	binary.Write(&req, binary.BigEndian, int64(0))                           // session id
+	writeZkBuffer(&req, make([]byte, 16))                                    // password
+
+	if err := zk.send(req.Bytes()); err != nil {
+		return err
+	}
+	// the response is the negotiated session, nothing in it is needed
+	_, err := zk.receive()
+	return err
+}
+
+// call sends a request and returns the body of the response
+func (zk *zkConn) call(opcode int32, path string, body []byte) ([]byte, error) {
+	zk.xid++
+	var req bytes.Buffer
+	binary.Write(&req, binary.BigEndian, zk.xid)
+	binary.Write(&req, binary.BigEndian, opcode)
+	req.Write(body)
+	if err := zk.send(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	rsp, err := zk.receive()
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Xid  int32
+		Zxid int64
+		Err  int32
+	}
+	reader := bytes.NewReader(rsp)
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Err != 0 {
+		return nil, zkError{Path: path, Code: header.Err}
+	}
+	return rsp[len(rsp)-reader.Len():], nil
+}
+
+// children returns the names of the children of path
+func (zk *zkConn) children(path string) ([]string, error) {
+	rsp, err := zk.call(zkOpGetChildren, path, zkPathRequest(path))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bytes.NewReader(rsp)
+	var count int32
+	if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	children := make([]string, 0, count)
+	for i := int32(0); i < count; i++ {
+		child, err := readZkBuffer(reader)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, string(child))
+	}
+	return children, nil
+}
+
+// data returns the content of the node at path
+func (zk *zkConn) data(path string) ([]byte, error) {
+	rsp, err := zk.call(zkOpGetData, path, zkPathRequest(path))
+	if err != nil {
+		return nil, err
+	}
+	return readZkBuffer(bytes.NewReader(rsp))
+}
+
+// close ends the session so that ZooKeeper does not wait for it to expire
+func (zk *zkConn) close() {
+	zk.xid++
+	var req bytes.Buffer
+	binary.Write(&req, binary.BigEndian, zk.xid)
+	binary.Write(&req, binary.BigEndian, int32(zkOpClose))
+	zk.send(req.Bytes())
+	zk.conn.Close()
+}
+
+func (zk *zkConn) send(packet []byte) error {
+	zk.conn.SetDeadline(time.Now().Add(zk.timeout))
+	var framed bytes.Buffer
+	writeZkBuffer(&framed, packet)
+	_, err := zk.conn.Write(framed.Bytes())
+	return err
+}
+
+func (zk *zkConn) receive() ([]byte, error) {
+	zk.conn.SetDeadline(time.Now().Add(zk.timeout))
+	var length int32
+	if err := binary.Read(zk.conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length < 0 || length > 1<<20 {
+		return nil, fmt.Errorf("zookeeper: invalid packet length %d", length)
+	}
+	packet := make([]byte, length)
+	_, err := io.ReadFull(zk.conn, packet)
+	return packet, err
+}
+
+// zkPathRequest is the body of the requests made of a path and a watch flag
+func zkPathRequest(path string) []byte {
+	var body bytes.Buffer
+	writeZkBuffer(&body, []byte(path))
+	body.WriteByte(0) // no watch
+	return body.Bytes()
+}
+
+func writeZkBuffer(w *bytes.Buffer, b []byte) {
+	binary.Write(w, binary.BigEndian, int32(len(b)))
+	w.Write(b)
+}
+
+func readZkBuffer(r *bytes.Reader) ([]byte, error) {
+	var length int32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, nil
+	}
+	if int(length) > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, length)
+	r.Read(b)
+	return b, nil
+}